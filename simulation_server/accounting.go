@@ -0,0 +1,111 @@
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"text/tabwriter"
+	"time"
+
+	"github.com/fvdveen/generative_agents/simulation_server/llm/accounting"
+	"github.com/fvdveen/generative_agents/simulation_server/server"
+)
+
+// errOverBudget is returned by a run that stopped because it exceeded its budget.
+var errOverBudget = errors.New("run exceeded its budget")
+
+// stepUsage is a line of usage.jsonl, written after every step.
+type stepUsage struct {
+	Step    int       `json:"step"`
+	SimTime time.Time `json:"sim_time"`
+	// What the step itself used, including earlier attempts at the step that failed
+	StepTotals accounting.Totals `json:"step_totals"`
+	// What the run used up to and including the step
+	Run accounting.Report `json:"run"`
+}
+
+// usageRecorder writes the model usage of every step to a file and stops the simulation once it exceeds the budget.
+type usageRecorder struct {
+	tracker *accounting.Tracker
+	log     *slog.Logger
+	enc     *json.Encoder
+	// Totals after the previous step
+	last accounting.Totals
+	// Called when the budget is exceeded, the simulation should stop after the current step
+	overBudget func()
+}
+
+func (r *usageRecorder) StepDone(step int, movements server.Movements) {
+	report := r.tracker.Report()
+	line := stepUsage{
+		Step:       step,
+		SimTime:    movements.CurrentTime,
+		StepTotals: report.Total.Sub(r.last),
+		Run:        report,
+	}
+	r.last = report.Total
+
+	if err := r.enc.Encode(line); err != nil {
+		r.log.Error("usage_save_fail", slog.String("type", "usage"), slog.Int("step", step), slog.Any("err", err))
+	}
+	r.log.Info("step_usage",
+		slog.String("type", "usage"),
+		slog.Int("step", step),
+		slog.Int("calls", line.StepTotals.Calls),
+		slog.Int("input_tokens", line.StepTotals.InputTokens),
+		slog.Int("output_tokens", line.StepTotals.OutputTokens),
+		slog.Float64("cost_usd", line.StepTotals.Cost),
+		slog.Float64("run_cost_usd", report.Total.Cost),
+	)
+
+	if r.tracker.OverBudget() {
+		r.log.Warn("budget_exceeded",
+			slog.String("type", "usage"),
+			slog.Int("step", step),
+			slog.Float64("run_cost_usd", report.Total.Cost),
+		)
+		r.overBudget()
+	}
+}
+
+// openUsageFile opens the file the usage of every step of the run is appended to.
+func openUsageFile(runDir string) (*os.File, error) {
+	f, err := os.OpenFile(filepath.Join(runDir, "usage.jsonl"), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("could not open usage file: %w", err)
+	}
+
+	return f, nil
+}
+
+// printUsage writes a summary of report to w, every breakdown is sorted by cost.
+func printUsage(w io.Writer, report accounting.Report) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', tabwriter.AlignRight)
+	row := func(name string, t accounting.Totals) {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t%d\t$%.4f\t%s\t%d\t$%.4f\t\n",
+			name, t.Calls, t.Retries, t.Failures, t.InputTokens, t.OutputTokens, t.Cost, t.Latency.Round(time.Millisecond), t.CacheHits, t.SavedCost)
+	}
+	section := func(title string, totals map[string]accounting.Totals) {
+		fmt.Fprintf(tw, "%s\tcalls\tretries\tfailures\tinput\toutput\tcost\tlatency\tcached\tsaved\t\n", title)
+		names := slices.SortedFunc(maps.Keys(totals), func(a, b string) int {
+			return cmp.Or(cmp.Compare(totals[b].Cost, totals[a].Cost), cmp.Compare(a, b))
+		})
+		for _, name := range names {
+			row(name, totals[name])
+		}
+		fmt.Fprintln(tw, "\t\t\t\t\t\t\t\t\t\t")
+	}
+
+	section("persona", report.Personas)
+	section("phase", report.Phases)
+	section("prompt", report.Prompts)
+	row("total", report.Total)
+	_ = tw.Flush()
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fvdveen/generative_agents/simulation_server/llm/accounting"
+)
+
+func TestRunStopsOverBudget(t *testing.T) {
+	simulations := t.TempDir()
+	if err := os.CopyFS(filepath.Join(simulations, "test"), os.DirFS("../environment/frontend_server/storage/base_the_ville_isabella_maria_klaus")); err != nil {
+		t.Fatalf("could not copy simulation: %v", err)
+	}
+
+	conf := Config{
+		SimulationDir:  simulations,
+		MazeDir:        "../environment/frontend_server/static_dirs/assets",
+		LogDir:         t.TempDir(),
+		BackupDir:      t.TempDir(),
+		SimulationName: "test",
+		SimulationMaze: "the_ville",
+		Backend:        "fake",
+		BackupInterval: 1000,
+		Workers:        1,
+		BudgetUSD:      0.01,
+	}
+	conf.setDefaults()
+	s, err := openSession(conf, true, true)
+	if err != nil {
+		t.Fatalf("could not open session: %v", err)
+	}
+	defer s.Close()
+
+	// The fake backend is free, so the budget is spent before the run starts
+	s.usage.Record(context.Background(), accounting.Call{Model: "gpt-5-nano", Attempts: 1, InputTokens: 1_000_000})
+
+	err = runSimulation(context.Background(), s, 10, time.Time{})
+	if !errors.Is(err, errOverBudget) {
+		t.Fatalf("Expected the run to stop over budget, got: %v", err)
+	}
+
+	// The step that exceeded the budget is saved
+	content, err := os.ReadFile(filepath.Join(simulations, "test", "reverie", "meta.json"))
+	if err != nil {
+		t.Fatalf("could not read meta: %v", err)
+	}
+	var meta struct {
+		Step int `json:"step"`
+	}
+	if err := json.Unmarshal(content, &meta); err != nil {
+		t.Fatalf("could not parse meta: %v", err)
+	}
+	if meta.Step != 1 {
+		t.Fatalf("Expected the run to stop after the first step, got: %d", meta.Step)
+	}
+	if _, err := os.Stat(filepath.Join(s.logs.RunDir, "usage.jsonl")); err != nil {
+		t.Fatalf("Expected the usage to be written: %v", err)
+	}
+}
@@ -0,0 +1,130 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/fvdveen/generative_agents/simulation_server/memory"
+)
+
+// How many memorable events a persona writes down about a day
+const diaryEvents = 3
+
+// How many diary entries a persona keeps in its state, older entries are only kept by the storage
+const diaryKept = 7
+
+// DiaryEntry is what a persona wrote down about a day once it went to bed.
+type DiaryEntry struct {
+	// The start of the day the entry is about
+	Day time.Time
+	// The most memorable events of the day
+	Events []string
+	// How the persona feels about the day
+	Feelings string
+	// What the persona wants the next day to be like
+	Wants string
+}
+
+// wentToBed returns whether the persona sleeps for the rest of the day and has not written about today in its diary yet.
+func (p *Persona) wentToBed() bool {
+	schedule := p.state.DailySchedule
+	idx := p.state.GetDailyPlanIndex()
+	// Sleeping at the start of the schedule is the night before the persona woke up
+	if idx == 0 || idx >= len(schedule) {
+		return false
+	}
+	for _, plan := range schedule[idx:] {
+		if !strings.Contains(plan.Activity, "sleeping") {
+			return false
+		}
+	}
+
+	if n := len(p.state.Diary); n != 0 && !isDifferentDate(p.state.Diary[n-1].Day, p.StartOfDay()) {
+		return false
+	}
+
+	return true
+}
+
+// writeDiary summarizes the day of the persona and interprets it, both are remembered as thoughts and added to the diary.
+func (p *Persona) writeDiary(ctx context.Context) error {
+	events, err := p.cognition.GenerateDaySummary(ctx, p, diaryEvents)
+	if err != nil {
+		return fmt.Errorf("could not generate day summary: %w", err)
+	}
+	feelings, wants, err := p.cognition.GenerateDayInterpretation(ctx, p, events)
+	if err != nil {
+		return fmt.Errorf("could not generate day interpretation: %w", err)
+	}
+
+	day := p.StartOfDay()
+	date := day.Format("Monday January 02")
+
+	// The summary builds on everything the persona thought and talked about today, which makes it deeper than any of those thoughts
+	var evidence []memory.NodeId
+	for _, n := range p.latestNodes([]memory.NodeType{memory.NodeTypeThought, memory.NodeTypeChat}) {
+		if !p.associativeMemory.GetNode(n).Created.Before(day) {
+			evidence = append(evidence, n)
+		}
+	}
+
+	summary, err := p.rememberThought(ctx,
+		memory.SPO{Subject: p.name, Predicate: "summarize", Object: date},
+		[]string{"diary"},
+		fmt.Sprintf("%s's diary for %s: %s", p.name, date, strings.Join(events, " ")),
+		evidence)
+	if err != nil {
+		return err
+	}
+	interpretation, err := p.rememberThought(ctx,
+		memory.SPO{Subject: p.name, Predicate: "interpret", Object: date},
+		[]string{"diary"},
+		fmt.Sprintf("%s %s", feelings, wants),
+		[]memory.NodeId{summary.Id})
+	if err != nil {
+		return err
+	}
+
+	p.state.Diary = append(p.state.Diary, DiaryEntry{
+		Day:      day,
+		Events:   events,
+		Feelings: feelings,
+		Wants:    wants,
+	})
+	p.state.Diary = p.state.Diary[max(0, len(p.state.Diary)-diaryKept):]
+
+	p.ctx.Log.Info("diary_written",
+		slog.String("type", "diary"),
+		slog.String("day", date),
+		slog.Int("events", len(events)),
+		slog.Int("depth", interpretation.Depth),
+	)
+
+	return nil
+}
+
+// yesterdaysDiary returns the statements of the diary entry of the day before, if the persona wrote one.
+func (p *Persona) yesterdaysDiary() []string {
+	statements := []string{}
+
+	n := len(p.state.Diary)
+	if n == 0 {
+		return statements
+	}
+	entry := p.state.Diary[n-1]
+	if isDifferentDate(entry.Day, p.StartOfDay().AddDate(0, 0, -1)) {
+		return statements
+	}
+
+	date := entry.Day.Format("Monday January 02")
+	for _, event := range entry.Events {
+		statements = append(statements, fmt.Sprintf("%s: %s", date, event))
+	}
+
+	return append(statements,
+		fmt.Sprintf("%s: %s", date, entry.Feelings),
+		fmt.Sprintf("%s: %s", date, entry.Wants))
+}
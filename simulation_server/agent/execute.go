@@ -2,7 +2,9 @@ package agent
 
 import (
 	"fmt"
+	"log/slog"
 	"math/rand"
+	"slices"
 
 	"github.com/fvdveen/generative_agents/simulation_server/maze"
 	"github.com/fvdveen/generative_agents/simulation_server/memory"
@@ -22,7 +24,7 @@ func sample[T any](arr []T, sampleSize int) []T {
 	return arr[:sampleSize]
 }
 
-func (p *Persona) execute(m *maze.Maze, personas map[string]*Persona, plan memory.Path) (maze.TilePos, string, maze.Event) {
+func (p *Persona) execute(m *maze.Maze, personas map[string]*Persona, plan memory.Path) (maze.TilePos, string, maze.Event, error) {
 	if plan.HasState(memory.PathStateRandom) && len(p.state.PlannedPath) == 0 {
 		p.state.ActivityPathSet = false
 	}
@@ -32,39 +34,35 @@ func (p *Persona) execute(m *maze.Maze, personas map[string]*Persona, plan memor
 
 		if plan.HasState(memory.PathStatePersona) {
 			targetPersonaPos := personas[plan.GetArg()].state.Position
-			potentialPath := m.Pathfind(p.state.Position, targetPersonaPos)
-			if len(potentialPath) <= 2 {
+			// The original code compares the paths to both middle tiles of the path between the personas,
+			// but as the path is a shortest path the first middle tile is always the closest.
+			if potentialPath, ok := m.Pathfind(p.state.Position, targetPersonaPos); !ok {
+				// The personas cannot reach each other, so we stay in place
+			} else if len(potentialPath) <= 2 {
 				targetTiles = []maze.TilePos{potentialPath[0]}
 			} else {
-				p1 := m.Pathfind(p.state.Position, potentialPath[len(potentialPath)/2])
-				p2 := m.Pathfind(p.state.Position, potentialPath[len(potentialPath)/2+1])
-				if len(p1) <= len(p2) {
-					targetTiles = []maze.TilePos{potentialPath[len(potentialPath)/2]}
-				} else {
-					targetTiles = []maze.TilePos{potentialPath[len(potentialPath)/2+1]}
-				}
+				targetTiles = []maze.TilePos{potentialPath[len(potentialPath)/2]}
 			}
 		} else if plan.HasState(memory.PathStateWaiting) {
 			var x, y int
 			n, err := fmt.Sscanf(plan.GetArg(), memory.WaitingArgFormat, &x, &y)
 			if n != 2 {
-				panic(fmt.Errorf("Parsed unexpected amount of wait argument, got: %d, expected 2", n))
+				return maze.TilePos{}, "", maze.Event{}, fmt.Errorf("parsed unexpected amount of wait argument, got: %d, expected 2", n)
 			} else if err != nil {
-				panic(fmt.Errorf("Could not parse waiting arguments: %w", err))
+				return maze.TilePos{}, "", maze.Event{}, fmt.Errorf("could not parse waiting arguments: %w", err)
 			}
 			targetTiles = []maze.TilePos{{X: x, Y: y}}
 		} else if plan.HasState(memory.PathStateRandom) {
 			t, ok := m.PathToTiles(plan.AtLevel(memory.PathLevelArena))
 			if !ok {
-				panic(fmt.Errorf("could not find path in maze: %s", plan.ToString()))
+				return maze.TilePos{}, "", maze.Event{}, fmt.Errorf("could not find path in maze: %s", plan.ToString())
 			}
-			targetTiles = sample(t, 1)
+			targetTiles = sample(slices.Clone(t), 1)
 		} else {
 			if t, ok := m.PathToTiles(plan); ok {
-				targetTiles = t
+				targetTiles = slices.Clone(t)
 			} else {
-				// NOTE(Friso): This should probably not be a panic as its in the core simulation loop but idk what else to do now
-				panic(fmt.Errorf("Path not present in maze: %s", plan.ToString()))
+				return maze.TilePos{}, "", maze.Event{}, fmt.Errorf("path not present in maze: %s", plan.ToString())
 			}
 		}
 
@@ -90,21 +88,28 @@ func (p *Persona) execute(m *maze.Maze, personas map[string]*Persona, plan memor
 
 		currTile := p.state.Position
 		closestTile := maze.TilePos{X: -1, Y: -1}
-		path := []maze.TilePos{}
+		closestDistance := -1
 
 		for _, target := range targetTiles {
-			currPath := m.Pathfind(currTile, target)
-			if (closestTile == maze.TilePos{X: -1, Y: -1}) {
-				closestTile = target
-				path = currPath
-			} else if len(currPath) < len(path) {
+			// Object tiles are targeted over and over again, so their distance fields are worth caching
+			d, ok := m.DistanceField(target).Distance(currTile)
+			if ok && (closestDistance == -1 || d < closestDistance) {
 				closestTile = target
-				path = currPath
+				closestDistance = d
 			}
 		}
 
-		// The path returned by maze.Pathfind still includes the start tile, so skip that
-		p.state.PlannedPath = path[1:]
+		p.state.PlannedPath = []maze.TilePos{}
+		if closestDistance == -1 {
+			p.ctx.Log.Warn("path_unreachable",
+				slog.String("event", "path_unreachable"),
+				slog.Any("from", currTile),
+				slog.String("activity_address", plan.ToString()),
+			)
+		} else if path, ok := m.Pathfind(currTile, closestTile); ok {
+			// The path returned by maze.Pathfind still includes the start tile, so skip that
+			p.state.PlannedPath = path[1:]
+		}
 		p.state.ActivityPathSet = true
 	}
 
@@ -116,5 +121,40 @@ func (p *Persona) execute(m *maze.Maze, personas map[string]*Persona, plan memor
 
 	description := fmt.Sprintf("%s @ %s", p.state.ActivityDescription, p.state.ActivityAddress.ToString())
 
-	return tile, p.state.ActivityPronunciato, maze.Event{SPO: p.state.ActivitySPO, Description: description}
+	return tile, p.state.ActivityPronunciato, maze.Event{SPO: p.state.ActivitySPO, Description: description}, nil
+}
+
+// How many steps longer than the planned path a way around a blocked tile may be, before waiting for the tile to clear is preferred
+const maxDetour = 10
+
+// Replan is used when next, the tile the persona was about to move to, turns out to be blocked by another persona.
+// It plans a new path to the end of the planned path around the blocked tiles, and returns where the persona moves to
+// instead and whether the path changed. If there is no reasonable way around, the persona waits in place for the tile to clear.
+func (p *Persona) Replan(m *maze.Maze, next maze.TilePos, blocked func(maze.TilePos) bool) (maze.TilePos, bool) {
+	remaining := append([]maze.TilePos{next}, p.state.PlannedPath...)
+	end := remaining[len(remaining)-1]
+
+	path, ok := m.PathfindAvoiding(p.state.Position, end, blocked)
+	if ok && len(path) == 1 {
+		// The path only passed by, the persona is already where it needs to be
+		p.state.PlannedPath = []maze.TilePos{}
+		return p.state.Position, true
+	}
+	if !ok || len(path)-1 > len(remaining)+maxDetour {
+		p.state.PlannedPath = remaining
+		p.ctx.Log.Debug("path_blocked",
+			slog.String("event", "path_blocked"),
+			slog.Any("tile", next),
+		)
+		return p.state.Position, false
+	}
+
+	p.ctx.Log.Debug("path_replanned",
+		slog.String("event", "path_replanned"),
+		slog.Any("tile", next),
+		slog.Int("old_length", len(remaining)),
+		slog.Int("new_length", len(path)-1),
+	)
+	p.state.PlannedPath = path[2:]
+	return path[1], true
 }
@@ -0,0 +1,22 @@
+package agent
+
+import (
+	"log/slog"
+
+	"github.com/fvdveen/generative_agents/simulation_server/memory"
+)
+
+// forget applies the forgetting policy of the persona to its associative memory.
+func (p *Persona) forget() {
+	result := p.associativeMemory.Forget(p.state.Forgetting, p.state.CurrentTime)
+	if result == (memory.ForgetResult{}) {
+		return
+	}
+
+	p.ctx.Log.Info("memory_forget",
+		slog.String("type", "memory_forget"),
+		slog.Int("expired", result.Expired),
+		slog.Int("decayed", result.Decayed),
+		slog.Int("merged", result.Merged),
+	)
+}
@@ -0,0 +1,63 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/fvdveen/generative_agents/simulation_server/maze"
+	"github.com/fvdveen/generative_agents/simulation_server/memory"
+)
+
+// RememberThought has the persona remember thought exactly as given, as if they thought of it themselves.
+// Unlike Whisper the thought is not rephrased, so scripted experiments plant the same thought every run.
+func (p *Persona) RememberThought(ctx context.Context, thought string) error {
+	if _, err := p.rememberGeneratedThought(ctx, thought, []memory.NodeId{}); err != nil {
+		return fmt.Errorf("could not remember thought: %w", err)
+	}
+
+	return nil
+}
+
+// RememberEvent has the persona remember event as if they perceived it.
+func (p *Persona) RememberEvent(ctx context.Context, event maze.Event) error {
+	description := fmt.Sprintf("%s is %s", event.SPO.Subject, event.Description)
+	keywords := []string{memory.ParsePath(event.SPO.Subject).Base(), memory.ParsePath(event.SPO.Object).Base()}
+
+	importance, err := p.cognition.GenerateImportanceScore(ctx, p, memory.NodeTypeEvent, description)
+	if err != nil {
+		return fmt.Errorf("could not generate event importance: %w", err)
+	}
+	valence, err := p.cognition.GenerateValenceScore(ctx, p, memory.NodeTypeEvent, description)
+	if err != nil {
+		return fmt.Errorf("could not generate event valence: %w", err)
+	}
+
+	expanded, err := p.expandMemoryDescription(ctx, valence, nil, description)
+	if err != nil {
+		return err
+	}
+	embedding, err := p.GetEmbedding(ctx, expanded)
+	if err != nil {
+		return err
+	}
+
+	p.addEventToMemory(event.SPO, expanded, description, keywords, importance, valence, []memory.NodeId{}, expanded, embedding)
+	return nil
+}
+
+// SetCurrentPlans replaces what the persona is currently planning, it is picked up the next time they plan their day.
+func (p *Persona) SetCurrentPlans(plans string) {
+	p.state.CurrentPlans = plans
+}
+
+// SetDailyPlanRequirements replaces the requirements for the daily plan of the persona.
+func (p *Persona) SetDailyPlanRequirements(requirements string) {
+	p.state.DailyPlanRequirements = requirements
+}
+
+// Teleport moves the persona to pos, the path to their current activity is planned again from there.
+func (p *Persona) Teleport(pos maze.TilePos) {
+	p.state.Position = pos
+	p.state.PlannedPath = nil
+	p.state.ActivityPathSet = false
+}
@@ -0,0 +1,57 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/fvdveen/generative_agents/simulation_server/memory"
+)
+
+// Interview has the persona answer the last question the interviewer asked in conversation.
+// The answer is based off of what the persona remembers about the question, the persona itself is not changed by the interview.
+func (p *Persona) Interview(ctx context.Context, interviewer string, conversation []memory.Utterance) (string, error) {
+	if len(conversation) == 0 {
+		return "", errors.New("the interviewer has not asked anything")
+	}
+	question := conversation[len(conversation)-1].Sentence
+
+	retrieved, err := p.retrieveForFocalPoints(ctx, []string{question}, withRetrievalCount(50), withReadOnlyRetrieval())
+	if err != nil {
+		return "", fmt.Errorf("could not retrieve memories: %w", err)
+	}
+
+	summary, err := p.cognition.GenerateInterviewSummary(ctx, p, question, retrieved[question])
+	if err != nil {
+		return "", fmt.Errorf("could not generate interview summary: %w", err)
+	}
+
+	answer, err := p.cognition.GenerateInterviewAnswer(ctx, p, interviewer, conversation, summary)
+	if err != nil {
+		return "", fmt.Errorf("could not generate interview answer: %w", err)
+	}
+
+	p.ctx.Log.Info("interview",
+		slog.String("event", "interview"),
+		slog.String("interviewer", interviewer),
+		slog.String("question", question),
+		slog.String("answer", answer),
+	)
+
+	return answer, nil
+}
+
+// Whisper plants a thought in the persona's mind, it is remembered as if the persona thought of it themselves.
+func (p *Persona) Whisper(ctx context.Context, whisper string) error {
+	thought, err := p.cognition.GenerateWhisperedThought(ctx, p, whisper)
+	if err != nil {
+		return fmt.Errorf("could not generate whispered thought: %w", err)
+	}
+
+	if _, err := p.rememberGeneratedThought(ctx, thought, []memory.NodeId{}); err != nil {
+		return fmt.Errorf("could not remember whispered thought: %w", err)
+	}
+
+	return nil
+}
@@ -2,6 +2,7 @@ package agent
 
 import (
 	"cmp"
+	"context"
 	"fmt"
 	"slices"
 
@@ -9,7 +10,7 @@ import (
 	"github.com/fvdveen/generative_agents/simulation_server/memory"
 )
 
-func (p *Persona) percieve(m *maze.Maze) []memory.NodeId {
+func (p *Persona) percieve(ctx context.Context, m *maze.Maze) ([]memory.NodeId, error) {
 	nearbyTiles := m.GetNearbyTiles(p.state.Position, p.state.VisionRadius)
 
 	for _, pos := range nearbyTiles {
@@ -68,7 +69,19 @@ func (p *Persona) percieve(m *maze.Maze) []memory.NodeId {
 		percievedEvents = append(percievedEvents, ev.event)
 	}
 
-	memories := make([]memory.NodeId, 0, len(percievedEvents))
+	// The events are scored first, so the embeddings of all new memories can be requested at once
+	type perception struct {
+		event               maze.Event
+		description         string
+		originalDescription string
+		keywords            []string
+		importance          int
+		valence             int
+		chat                *perceivedChat
+	}
+	perceptions := make([]perception, 0, len(percievedEvents))
+	descriptions := make([]string, 0, len(percievedEvents))
+	latestSPOs := p.associativeMemory.GetLatestEventSPOs(p.state.Retention)
 	for _, percievedEvent := range percievedEvents {
 		if percievedEvent.SPO.Predicate == "" {
 			percievedEvent.SPO.Predicate = "is"
@@ -79,9 +92,10 @@ func (p *Persona) percieve(m *maze.Maze) []memory.NodeId {
 		percievedEvent.Description = fmt.Sprintf("%s is %s", percievedEvent.SPO.Subject, percievedEvent.Description)
 
 		// Skip events we have recently percieved already
-		if _, ok := p.associativeMemory.GetLatestEventSPOs(p.state.Retention)[percievedEvent.SPO]; ok {
+		if _, ok := latestSPOs[percievedEvent.SPO]; ok {
 			continue
 		}
+		latestSPOs[percievedEvent.SPO] = struct{}{}
 
 		keywords := make([]string, 0, 2)
 
@@ -90,31 +104,91 @@ func (p *Persona) percieve(m *maze.Maze) []memory.NodeId {
 		keywords = append(keywords, subject)
 		keywords = append(keywords, object)
 
-		importance := p.cognition.GenerateImportanceScore(p, memory.NodeTypeEvent, percievedEvent.Description)
-		valence := p.cognition.GenerateValenceScore(p, memory.NodeTypeEvent, percievedEvent.Description)
-
-		originalDescription := percievedEvent.Description
-		description := p.expandMemoryDescription(valence, nil, percievedEvent.Description)
+		importance, err := p.cognition.GenerateImportanceScore(ctx, p, memory.NodeTypeEvent, percievedEvent.Description)
+		if err != nil {
+			return nil, fmt.Errorf("could not generate event importance: %w", err)
+		}
+		valence, err := p.cognition.GenerateValenceScore(ctx, p, memory.NodeTypeEvent, percievedEvent.Description)
+		if err != nil {
+			return nil, fmt.Errorf("could not generate event valence: %w", err)
+		}
 
-		var embedding []float64 = p.GetEmbedding(description)
+		description, err := p.expandMemoryDescription(ctx, valence, nil, percievedEvent.Description)
+		if err != nil {
+			return nil, err
+		}
+		descriptions = append(descriptions, description)
 
-		chatNodes := make([]memory.NodeId, 0, 1)
+		var chat *perceivedChat
 		if subject == p.name && percievedEvent.SPO.Predicate == "chat with" {
-			currentEvent := p.state.ActivitySPO
+			chat, err = p.percieveChat(ctx)
+			if err != nil {
+				return nil, err
+			}
+			descriptions = append(descriptions, chat.description)
+		}
+
+		perceptions = append(perceptions, perception{
+			event:               percievedEvent,
+			description:         description,
+			originalDescription: percievedEvent.Description,
+			keywords:            keywords,
+			importance:          importance,
+			valence:             valence,
+			chat:                chat,
+		})
+	}
 
-			chatImportance := p.cognition.GenerateImportanceScoreChat(p, p.state.Chat, p.state.ActivityDescription)
-			chatValence := p.cognition.GenerateValenceScoreChat(p, p.state.Chat, p.state.ActivityDescription)
+	if _, err := p.GetEmbeddings(ctx, descriptions); err != nil {
+		return nil, err
+	}
 
-			chatDescription := p.expandMemoryDescription(chatValence, p.state.Chat, p.state.ActivityDescription)
-			var chatEmbedding []float64 = p.GetEmbedding(chatDescription)
+	memories := make([]memory.NodeId, 0, len(perceptions))
+	for _, perception := range perceptions {
+		embedding, err := p.GetEmbedding(ctx, perception.description)
+		if err != nil {
+			return nil, err
+		}
 
-			chatNode := p.addChatToMemory(currentEvent, chatDescription, p.state.ActivityDescription, keywords, chatImportance, chatValence, p.state.Chat, p.state.CurrentTime, nil, chatDescription, chatEmbedding)
+		chatNodes := make([]memory.NodeId, 0, 1)
+		if chat := perception.chat; chat != nil {
+			chatEmbedding, err := p.GetEmbedding(ctx, chat.description)
+			if err != nil {
+				return nil, err
+			}
+
+			chatNode := p.addChatToMemory(chat.event, chat.description, p.state.ActivityDescription, perception.keywords, chat.importance, chat.valence, p.state.Chat, p.state.CurrentTime, nil, chat.description, chatEmbedding)
 			chatNodes = append(chatNodes, chatNode.Id)
 		}
 
-		memories = append(memories, p.addEventToMemory(percievedEvent.SPO, description, originalDescription, keywords, importance, valence, chatNodes, description, embedding).Id)
+		memories = append(memories, p.addEventToMemory(perception.event.SPO, perception.description, perception.originalDescription, perception.keywords, perception.importance, perception.valence, chatNodes, perception.description, embedding).Id)
+	}
+
+	return memories, nil
+}
+
+// perceivedChat is the chat of the persona, scored to be added to its memory.
+type perceivedChat struct {
+	event       memory.SPO
+	description string
+	importance  int
+	valence     int
+}
+
+func (p *Persona) percieveChat(ctx context.Context) (*perceivedChat, error) {
+	importance, err := p.cognition.GenerateImportanceScoreChat(ctx, p, p.state.Chat, p.state.ActivityDescription)
+	if err != nil {
+		return nil, fmt.Errorf("could not generate chat importance: %w", err)
+	}
+	valence, err := p.cognition.GenerateValenceScoreChat(ctx, p, p.state.Chat, p.state.ActivityDescription)
+	if err != nil {
+		return nil, fmt.Errorf("could not generate chat valence: %w", err)
+	}
 
+	description, err := p.expandMemoryDescription(ctx, valence, p.state.Chat, p.state.ActivityDescription)
+	if err != nil {
+		return nil, err
 	}
 
-	return memories
+	return &perceivedChat{event: p.state.ActivitySPO, description: description, importance: importance, valence: valence}, nil
 }
@@ -1,14 +1,21 @@
 package agent
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/fvdveen/generative_agents/simulation_server/llm"
+	"github.com/fvdveen/generative_agents/simulation_server/llm/accounting"
 	"github.com/fvdveen/generative_agents/simulation_server/maze"
 	"github.com/fvdveen/generative_agents/simulation_server/memory"
+	"github.com/fvdveen/generative_agents/simulation_server/metrics"
+	"github.com/fvdveen/generative_agents/simulation_server/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type SPODescription struct {
@@ -109,6 +116,15 @@ type State struct {
 	AsymetricEncoding bool
 
 	NegativityBias float64
+
+	// Which memories the persona forgets, the policy is applied at the start of every new day
+	Forgetting memory.ForgettingPolicy
+	// What the persona reflects on and when
+	Reflection ReflectionPolicy
+	// When the persona last reflected because of the daily trigger of its reflection policy
+	LastDailyReflection time.Time
+	// What the persona wrote about the latest days it went to bed, oldest first
+	Diary []DiaryEntry
 }
 
 func (s *State) SetActivity(plog *slog.Logger, activityAddress memory.Path, duration time.Duration, activityDescription string, activityPronunciato string, activitySPO memory.SPO, activityObjectDescription string, activityObjectPronunciato string, activityObjectSPO memory.SPO) {
@@ -132,6 +148,7 @@ func (s *State) SetActivity(plog *slog.Logger, activityAddress memory.Path, dura
 	plog.Info("set_activity",
 		slog.String("type", "activity_set"),
 		slog.String("node_type", "activity"),
+		slog.String("description", activityDescription),
 		slog.String("address", activityAddress.ToString()),
 		slog.String("start_time", s.CurrentTime.Format(time.RFC3339)),
 		slog.Int("duration", int(duration.Minutes())),
@@ -162,6 +179,7 @@ func (s *State) SetChatActivity(plog *slog.Logger, activityAddress memory.Path,
 	plog.Info("set_activity",
 		slog.String("type", "activity_set"),
 		slog.String("node_type", "chat"),
+		slog.String("description", activityDescription),
 		slog.String("chatting_with", chattingWith),
 		slog.String("address", activityAddress.ToString()),
 		slog.String("start_time", s.CurrentTime.Format(time.RFC3339)),
@@ -238,11 +256,19 @@ type Persona struct {
 
 	// Context for the current move
 	ctx MoveCtx
+	// State carried between the phases of the current move
+	pending pendingMove
 }
 
 func (p *Persona) SetCtx(ctx MoveCtx) {
 	p.ctx = ctx
 	p.ctx.Log = p.ctx.Log.With(slog.String("persona", p.name))
+	if p.ctx.Metrics == nil {
+		p.ctx.Metrics = metrics.Nop{}
+	}
+	if p.ctx.Tracer == nil {
+		p.ctx.Tracer = tracing.Nop()
+	}
 }
 
 func (p *Persona) State() State {
@@ -268,12 +294,17 @@ func New(name string, assocMem *memory.Associative, spatialMem *memory.Spatial,
 	}
 }
 
-func (p *Persona) expandMemoryDescription(valence int, chat []memory.Utterance, description string) string {
+func (p *Persona) expandMemoryDescription(ctx context.Context, valence int, chat []memory.Utterance, description string) (string, error) {
 	if valence >= -3 || !p.state.AsymetricEncoding {
-		return description
+		return description, nil
 	}
 
-	return p.cognition.GenerateExpandedMemoryDescription(p, chat, description)
+	expanded, err := p.cognition.GenerateExpandedMemoryDescription(ctx, p, chat, description)
+	if err != nil {
+		return "", fmt.Errorf("could not expand memory description: %w", err)
+	}
+
+	return expanded, nil
 }
 
 func (p *Persona) addChatToMemory(spo memory.SPO, description, original string, keywords []string, importance, valence int, chat []memory.Utterance, created time.Time, expiration *time.Time, embeddingKey string, embedding []float64) memory.ConceptNode {
@@ -289,6 +320,11 @@ func (p *Persona) addChatToMemory(spo memory.SPO, description, original string,
 		slog.Any("expiration", expiration),
 	)
 
+	if slices.Contains(p.state.Reflection.evidence(), memory.NodeTypeChat) {
+		p.state.CurrentReflectionTrigger -= importance
+		p.state.ReflectionElements += 1
+	}
+
 	return node
 }
 
@@ -331,19 +367,71 @@ func (p *Persona) addEventToMemory(spo memory.SPO, description, original string,
 
 type MoveCtx struct {
 	Log *slog.Logger
+	// Records the durations of the phases and the reflections of the persona, discarded when nil
+	Metrics metrics.Recorder
+	// Traces the phases of the persona, discarded when nil
+	Tracer trace.Tracer
+}
+
+// Move advances the persona by a single step, returning the tile they move to and the event they are engaged in.
+// When an error is returned the persona may have been partially updated, and should be reloaded before it is moved again.
+//
+// Move runs all phases of a step at once, to move multiple personas concurrently use the individual phases:
+// Prepare, DecideReaction, React and Finish.
+func (p *Persona) Move(ctx context.Context, maze *maze.Maze, personas map[string]*Persona, pos maze.TilePos, currTime time.Time) (next_tile maze.TilePos, pronunciato string, event maze.Event, err error) {
+	if err := p.Prepare(ctx, maze, currTime); err != nil {
+		return next_tile, "", event, err
+	}
+
+	reaction, err := p.DecideReaction(ctx, personas)
+	if err != nil {
+		return next_tile, "", event, err
+	}
+
+	if err := p.React(ctx, maze, reaction, personas); err != nil {
+		return next_tile, "", event, err
+	}
+
+	return p.Finish(ctx, maze, personas)
+}
+
+// pendingMove holds the results of Prepare that are needed by the later phases of a step.
+type pendingMove struct {
+	start     time.Time
+	retrieved map[string]relevantNodes
 }
 
-func (p *Persona) Move(maze *maze.Maze, personas map[string]*Persona, pos maze.TilePos, currTime time.Time) (next_tile maze.TilePos, pronunciato string, event maze.Event) {
+// phase runs fn in a span and logs how long it took, the calls fn makes to models with the ctx passed to it are accounted to
+// the phase.
+func (p *Persona) phase(ctx context.Context, name string, fn func(ctx context.Context) error) (err error) {
+	ctx, span := p.ctx.Tracer.Start(ctx, name, trace.WithAttributes(
+		attribute.String("persona", p.name),
+		attribute.String("phase", name),
+	))
+	defer func() { tracing.End(span, err) }()
+
 	start := time.Now()
+	if err := fn(accounting.WithPhase(accounting.WithPersona(ctx, p.name), name)); err != nil {
+		return err
+	}
+	duration := time.Since(start)
+	p.ctx.Log.Debug("persona_phase_done",
+		slog.String("event", "persona_phase_done"),
+		slog.String("phase", name),
+		slog.Duration("duration", duration),
+	)
+	p.ctx.Metrics.PhaseDone(p.name, name, duration)
+
+	return nil
+}
+
+// Prepare starts a step, the persona percieves its surroundings, retrieves related memories and makes sure it has an activity.
+// It only reads from the maze and does not look at other personas, so it is safe to prepare multiple personas concurrently.
+func (p *Persona) Prepare(ctx context.Context, maze *maze.Maze, currTime time.Time) error {
+	p.pending = pendingMove{start: time.Now()}
 	p.ctx.Log.Info("persona_step_start",
 		slog.String("event", "persona_step_start"),
 	)
-	defer func() {
-		p.ctx.Log.Info("persona_step_done",
-			slog.String("event", "persona_step_done"),
-			slog.Duration("duration", time.Since(start)),
-		)
-	}()
 
 	newDay := NewDayTypeNoNewDay
 	if p.state.CurrentTime.IsZero() {
@@ -353,48 +441,100 @@ func (p *Persona) Move(maze *maze.Maze, personas map[string]*Persona, pos maze.T
 	}
 	p.state.CurrentTime = currTime
 
-	phaseStart := time.Now()
-	percieved := p.percieve(maze)
-	p.ctx.Log.Debug("persona_phase_done",
-		slog.String("event", "persona_phase_done"),
-		slog.String("phase", "perceive"),
-		slog.Duration("duration", time.Since(phaseStart)),
-	)
+	if newDay == NewDayTypeNewDay {
+		p.forget()
+	}
 
-	phaseStart = time.Now()
-	retrieved := p.retrieveForPerceptions(percieved)
-	p.ctx.Log.Debug("persona_phase_done",
-		slog.String("event", "persona_phase_done"),
-		slog.String("phase", "retrieve"),
-		slog.Duration("duration", time.Since(phaseStart)),
-	)
+	var percieved []memory.NodeId
+	err := p.phase(ctx, "perceive", func(ctx context.Context) (err error) {
+		percieved, err = p.percieve(ctx, maze)
+		return err
+	})
+	if err != nil {
+		return p.failStep(fmt.Errorf("could not perceive: %w", err))
+	}
 
-	phaseStart = time.Now()
-	plan := p.plan(maze, personas, retrieved, newDay)
-	p.ctx.Log.Debug("persona_phase_done",
-		slog.String("event", "persona_phase_done"),
-		slog.String("phase", "plan"),
-		slog.Duration("duration", time.Since(phaseStart)),
-	)
+	_ = p.phase(ctx, "retrieve", func(context.Context) error {
+		p.pending.retrieved = p.retrieveForPerceptions(percieved)
+		return nil
+	})
 
-	phaseStart = time.Now()
-	p.reflect()
-	p.ctx.Log.Debug("persona_phase_done",
-		slog.String("event", "persona_phase_done"),
-		slog.String("phase", "reflect"),
-		slog.Duration("duration", time.Since(phaseStart)),
-	)
+	if err := p.phase(ctx, "plan_activity", func(ctx context.Context) error { return p.planActivity(ctx, maze, newDay) }); err != nil {
+		return p.failStep(fmt.Errorf("could not plan: %w", err))
+	}
 
+	return nil
+}
+
+// DecideReaction decides how the persona reacts to what it percieved in Prepare.
+// Other personas are only read, so it is safe to decide the reactions of multiple personas concurrently.
+func (p *Persona) DecideReaction(ctx context.Context, personas map[string]*Persona) (reaction Reaction, err error) {
+	err = p.phase(ctx, "decide_reaction", func(ctx context.Context) (err error) {
+		reaction, err = p.decideReaction(ctx, p.pending.retrieved, personas)
+		return err
+	})
+	if err != nil {
+		return reaction, p.failStep(fmt.Errorf("could not plan: %w", err))
+	}
+
+	return reaction, nil
+}
+
+// React performs a reaction returned by DecideReaction. Reacting with a chat changes the state of the target as well,
+// thus reactions may only run concurrently when they involve different personas.
+func (p *Persona) React(ctx context.Context, maze *maze.Maze, reaction Reaction, personas map[string]*Persona) error {
+	if err := p.phase(ctx, "react", func(ctx context.Context) error { return p.react(ctx, maze, reaction, personas) }); err != nil {
+		return p.failStep(fmt.Errorf("could not plan: %w", err))
+	}
+
+	return nil
+}
+
+// Finish ends the step, the persona reflects and determines where to move to.
+// Other personas are only read, so it is safe to finish multiple personas concurrently once all of them have reacted.
+func (p *Persona) Finish(ctx context.Context, maze *maze.Maze, personas map[string]*Persona) (next_tile maze.TilePos, pronunciato string, event maze.Event, err error) {
 	defer func() {
-		p.ctx.Log.Debug("persona_phase_done",
-			slog.String("event", "persona_phase_done"),
-			slog.String("phase", "execute"),
-			slog.Duration("duration", time.Since(phaseStart)),
+		p.ctx.Log.Info("persona_step_done",
+			slog.String("event", "persona_step_done"),
+			slog.Duration("duration", time.Since(p.pending.start)),
+			slog.Bool("ok", err == nil),
 		)
+		p.pending = pendingMove{}
 	}()
 
-	phaseStart = time.Now()
-	return p.execute(maze, personas, plan)
+	plan := p.finishPlan()
+
+	if err := p.phase(ctx, "reflect", func(ctx context.Context) error { return p.reflect(ctx) }); err != nil {
+		return next_tile, "", event, fmt.Errorf("could not reflect: %w", err)
+	}
+
+	if p.wentToBed() {
+		if err := p.phase(ctx, "write_diary", p.writeDiary); err != nil {
+			return next_tile, "", event, fmt.Errorf("could not write diary: %w", err)
+		}
+	}
+
+	err = p.phase(ctx, "execute", func(context.Context) (err error) {
+		next_tile, pronunciato, event, err = p.execute(maze, personas, plan)
+		return err
+	})
+	if err != nil {
+		return next_tile, "", event, fmt.Errorf("could not execute: %w", err)
+	}
+
+	return next_tile, pronunciato, event, nil
+}
+
+// failStep logs the end of a step that failed before it could finish.
+func (p *Persona) failStep(err error) error {
+	p.ctx.Log.Info("persona_step_done",
+		slog.String("event", "persona_step_done"),
+		slog.Duration("duration", time.Since(p.pending.start)),
+		slog.Bool("ok", false),
+	)
+	p.pending = pendingMove{}
+
+	return err
 }
 
 func (p *Persona) GetCurrentEvent() maze.Event {
@@ -572,12 +712,57 @@ func (p *Persona) ActivityEndTime(idx int) time.Time {
 	return t
 }
 
-func (p *Persona) GetEmbedding(str string) []float64 {
-	embedding, ok := p.associativeMemory.GetEmbedding(str)
-	if !ok {
-		embedding = p.embedder.GenerateEmbedding(str)
-		p.associativeMemory.SaveEmbedding(str, embedding)
+func (p *Persona) GetEmbedding(ctx context.Context, str string) ([]float64, error) {
+	embedding, err := p.lookupEmbedding(ctx, str)
+	if err != nil {
+		return nil, err
+	}
+	p.associativeMemory.SaveEmbedding(str, embedding)
+
+	return embedding, nil
+}
+
+// GetEmbeddings is like GetEmbedding for several strings, the embeddings missing from memory are generated with a
+// single request when the embedder of the persona is a llm.BatchEmbedder.
+func (p *Persona) GetEmbeddings(ctx context.Context, strs []string) ([][]float64, error) {
+	missing := []string{}
+	for _, str := range strs {
+		if _, ok := p.associativeMemory.GetEmbedding(str); !ok && !slices.Contains(missing, str) {
+			missing = append(missing, str)
+		}
+	}
+	if batch, ok := p.embedder.(llm.BatchEmbedder); ok && len(missing) > 1 {
+		embeddings, err := batch.GenerateEmbeddings(ctx, missing)
+		if err != nil {
+			return nil, fmt.Errorf("could not generate embeddings: %w", err)
+		}
+		for i, str := range missing {
+			p.associativeMemory.SaveEmbedding(str, embeddings[i])
+		}
+	}
+
+	embeddings := make([][]float64, 0, len(strs))
+	for _, str := range strs {
+		embedding, err := p.GetEmbedding(ctx, str)
+		if err != nil {
+			return nil, err
+		}
+		embeddings = append(embeddings, embedding)
+	}
+
+	return embeddings, nil
+}
+
+// lookupEmbedding is like GetEmbedding but does not store newly generated embeddings in memory.
+func (p *Persona) lookupEmbedding(ctx context.Context, str string) ([]float64, error) {
+	if embedding, ok := p.associativeMemory.GetEmbedding(str); ok {
+		return embedding, nil
+	}
+
+	embedding, err := p.embedder.GenerateEmbedding(ctx, str)
+	if err != nil {
+		return nil, fmt.Errorf("could not generate embedding: %w", err)
 	}
 
-	return embedding
+	return embedding, nil
 }
@@ -1,6 +1,8 @@
 package agent
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"maps"
 	"math/rand"
@@ -13,6 +15,10 @@ import (
 	"github.com/fvdveen/generative_agents/simulation_server/memory"
 )
 
+// errScheduleTooLong is returned when the activities of a daily schedule take longer than a day. The schedule comes
+// from the model, so the step fails and is retried with a new schedule rather than cutting the activities short.
+var errScheduleTooLong = errors.New("daily schedule is longer than a day")
+
 type NewDayType int
 
 const (
@@ -21,7 +27,7 @@ const (
 	NewDayTypeNewDay
 )
 
-func (p *Persona) reviseIdentity() {
+func (p *Persona) reviseIdentity(ctx context.Context) error {
 	/* NOTE(Friso): In the original code the currently field of the persona's state is only updated once per day, whilst they are sleeping probably.
 	   This cannot be correct in any way, also they only update the daily_plan_req which gets fed into the prompts via the personas identity stable set,
 	   however this can then conflict with the daily requirements as specified in the field in its state.
@@ -30,9 +36,13 @@ func (p *Persona) reviseIdentity() {
 		fmt.Sprintf("%s's plan for %s.", p.Name(), p.CurrentTime().Format("Monday January 02")),
 		fmt.Sprintf("Important recent events for %s's life.", p.Name()),
 	}
-	retrieved := p.retrieveForFocalPoints(focalPoints)
+	retrieved, err := p.retrieveForFocalPoints(ctx, focalPoints)
+	if err != nil {
+		return err
+	}
 
-	statements := []string{}
+	// What the persona wrote in its diary last night is on its mind while planning the new day
+	statements := p.yesterdaysDiary()
 	for _, nodes := range retrieved {
 		for _, node := range nodes {
 			mem := p.GetMemory(node)
@@ -41,41 +51,60 @@ func (p *Persona) reviseIdentity() {
 		}
 	}
 
-	note := p.cognition.GeneratePlanningNote(p, statements)
-	feelings := p.cognition.GeneratePlanningFeelings(p, statements)
+	note, err := p.cognition.GeneratePlanningNote(ctx, p, statements)
+	if err != nil {
+		return fmt.Errorf("could not generate planning note: %w", err)
+	}
+	feelings, err := p.cognition.GeneratePlanningFeelings(ctx, p, statements)
+	if err != nil {
+		return fmt.Errorf("could not generate planning feelings: %w", err)
+	}
 
-	newStatus := p.cognition.GenerateCurrentPlans(p, note, feelings)
+	newStatus, err := p.cognition.GenerateCurrentPlans(ctx, p, note, feelings)
+	if err != nil {
+		return fmt.Errorf("could not generate current plans: %w", err)
+	}
 
 	p.state.CurrentPlans = newStatus
 
-	dailyReq := p.cognition.GenerateNewDailyRequirements(p)
+	dailyReq, err := p.cognition.GenerateNewDailyRequirements(ctx, p)
+	if err != nil {
+		return fmt.Errorf("could not generate daily requirements: %w", err)
+	}
 	p.state.DailyPlanRequirements = dailyReq
-}
 
-func (p *Persona) longTermPlanning(newDay NewDayType) {
-	var wakeUpHour time.Time
+	return nil
+}
 
+func (p *Persona) longTermPlanning(ctx context.Context, newDay NewDayType) error {
 	switch newDay {
 	case NewTypeDayFirstDay:
-		wakeUpHour = p.cognition.GenerateWakeUpHour(p)
-		p.state.DailyPlan = p.cognition.GenerateDailyPlan(p, wakeUpHour)
 	case NewDayTypeNewDay:
 		/* NOTE(Friso): In the current version of the code of the original paper
 		   that is github the agents do not create a new daily plan every morning.
 		   To me it seems like that is a mistake, they even say that it should happen.
 		   Thus that happens here.
 		*/
-		p.reviseIdentity()
-
-		wakeUpHour = p.cognition.GenerateWakeUpHour(p)
-		p.state.DailyPlan = p.cognition.GenerateDailyPlan(p, wakeUpHour)
+		if err := p.reviseIdentity(ctx); err != nil {
+			return fmt.Errorf("could not revise identity: %w", err)
+		}
 
 		// NOTE(Friso): In the original code they state that a new daily plan _should_ be created here, but it isn't
 	default:
-		panic("this should be unreachable")
+		return fmt.Errorf("unexpected new day type: %d", newDay)
 	}
 
-	p.state.DailySchedule = p.cognition.GenerateHourlySchedule(p, wakeUpHour)
+	wakeUpHour, err := p.cognition.GenerateWakeUpHour(ctx, p)
+	if err != nil {
+		return fmt.Errorf("could not generate wake up hour: %w", err)
+	}
+	if p.state.DailyPlan, err = p.cognition.GenerateDailyPlan(ctx, p, wakeUpHour); err != nil {
+		return fmt.Errorf("could not generate daily plan: %w", err)
+	}
+
+	if p.state.DailySchedule, err = p.cognition.GenerateHourlySchedule(ctx, p, wakeUpHour); err != nil {
+		return fmt.Errorf("could not generate hourly schedule: %w", err)
+	}
 	p.state.OriginalDailySchedule = slices.Clone(p.state.DailySchedule)
 
 	originalThought := fmt.Sprintf(
@@ -83,8 +112,6 @@ func (p *Persona) longTermPlanning(newDay NewDayType) {
 		p.name,
 		p.state.CurrentTime.Format("Monday January 02"),
 		strings.Join(p.state.DailyPlan, ", "))
-	createdAt := p.state.CurrentTime
-	expiratesAt := createdAt.Add(30 * 24 * time.Hour)
 	spo := memory.SPO{
 		Subject:   p.name,
 		Predicate: "plan",
@@ -92,14 +119,11 @@ func (p *Persona) longTermPlanning(newDay NewDayType) {
 	}
 	keywords := []string{"plan"}
 
-	importance := p.cognition.GenerateImportanceScore(p, memory.NodeTypeThought, originalThought)
-	valence := p.cognition.GenerateValenceScore(p, memory.NodeTypeThought, originalThought)
-	thought := p.expandMemoryDescription(valence, nil, originalThought)
-	embedding := p.GetEmbedding(thought)
-	p.addThoughtToMemory(spo, thought, originalThought, keywords, importance, valence, make([]memory.NodeId, 0), createdAt, &expiratesAt, thought, embedding)
+	_, err = p.rememberThought(ctx, spo, keywords, originalThought, make([]memory.NodeId, 0))
+	return err
 }
 
-func (p *Persona) determineActivity(maze *maze.Maze) {
+func (p *Persona) determineActivity(ctx context.Context, maze *maze.Maze) error {
 	shouldDecomposeActivity := func(desc string, dur int) bool {
 		if !strings.Contains(desc, "sleep") && !strings.Contains(desc, "bed") {
 			return true
@@ -114,36 +138,41 @@ func (p *Persona) determineActivity(maze *maze.Maze) {
 		return true
 	}
 
+	// decompose replaces the plan at idx in the daily schedule with its decomposition, if it should be decomposed
+	decompose := func(idx int) error {
+		plan := p.state.DailySchedule[idx]
+		if plan.Duration < 60 || !shouldDecomposeActivity(plan.Activity, plan.Duration) {
+			return nil
+		}
+
+		decomposedPlan, err := p.cognition.GeneratePlanDecomposition(ctx, p, plan)
+		if err != nil {
+			return fmt.Errorf("could not decompose plan %q: %w", plan.Activity, err)
+		}
+
+		before, after := slices.Clone(p.state.DailySchedule[:idx]), p.state.DailySchedule[idx+1:]
+		p.state.DailySchedule = append(
+			before,
+			decomposedPlan...,
+		)
+		p.state.DailySchedule = append(
+			p.state.DailySchedule,
+			after...,
+		)
+
+		return nil
+	}
+
 	currIndex := p.state.GetDailyPlanIndex()
 	currIndexInHour := p.state.GetDailyPlanIndexInMinutes(60)
 
 	if currIndex == 0 {
-		plan := p.state.DailySchedule[currIndex]
-		if plan.Duration >= 60 && shouldDecomposeActivity(plan.Activity, plan.Duration) {
-			decomposedPlan := p.cognition.GeneratePlanDecomposition(p, plan)
-			before, after := slices.Clone(p.state.DailySchedule[:currIndex]), p.state.DailySchedule[currIndex+1:]
-			p.state.DailySchedule = append(
-				before,
-				decomposedPlan...,
-			)
-			p.state.DailySchedule = append(
-				p.state.DailySchedule,
-				after...,
-			)
+		if err := decompose(currIndex); err != nil {
+			return err
 		}
 		if currIndexInHour+1 < len(p.state.DailySchedule) {
-			plan := p.state.DailySchedule[currIndexInHour+1]
-			if plan.Duration >= 60 && shouldDecomposeActivity(plan.Activity, plan.Duration) {
-				decomposedPlan := p.cognition.GeneratePlanDecomposition(p, plan)
-				before, after := slices.Clone(p.state.DailySchedule[:currIndexInHour+1]), p.state.DailySchedule[currIndexInHour+2:]
-				p.state.DailySchedule = append(
-					before,
-					decomposedPlan...,
-				)
-				p.state.DailySchedule = append(
-					p.state.DailySchedule,
-					after...,
-				)
+			if err := decompose(currIndexInHour + 1); err != nil {
+				return err
 			}
 		}
 	}
@@ -152,18 +181,8 @@ func (p *Persona) determineActivity(maze *maze.Maze) {
 		// NOTE(Friso): In the original code they don't decompose activitys after 11 pm.
 		// I'm not sure exactly why they do this.
 		if p.state.CurrentTime.Hour() < 23 {
-			plan := p.state.DailySchedule[currIndexInHour]
-			if plan.Duration >= 60 && shouldDecomposeActivity(plan.Activity, plan.Duration) {
-				decomposedPlan := p.cognition.GeneratePlanDecomposition(p, plan)
-				before, after := slices.Clone(p.state.DailySchedule[:currIndexInHour]), p.state.DailySchedule[currIndexInHour+1:]
-				p.state.DailySchedule = append(
-					before,
-					decomposedPlan...,
-				)
-				p.state.DailySchedule = append(
-					p.state.DailySchedule,
-					after...,
-				)
+			if err := decompose(currIndexInHour); err != nil {
+				return err
 			}
 		}
 	}
@@ -181,29 +200,53 @@ func (p *Persona) determineActivity(maze *maze.Maze) {
 				Duration: int(dayDuration.Minutes()) - int(scheduledDuration.Minutes()),
 			})
 	} else if scheduledDuration > dayDuration {
-		panic("TODO: handle daily plan longer than day")
+		return errScheduleTooLong
 	}
 
 	currPlan := p.state.DailySchedule[currIndex]
 
 	world := maze.GetTile(p.state.Position).Path.Get(memory.PathLevelWorld)
-	sector := p.cognition.GenerateActivitySector(p, maze, currPlan.Activity, world)
-	arena := p.cognition.GenerateActivityArena(p, maze, currPlan.Activity, world, sector)
+	sector, err := p.cognition.GenerateActivitySector(ctx, p, maze, currPlan.Activity, world)
+	if err != nil {
+		return fmt.Errorf("could not generate activity sector: %w", err)
+	}
+	arena, err := p.cognition.GenerateActivityArena(ctx, p, maze, currPlan.Activity, world, sector)
+	if err != nil {
+		return fmt.Errorf("could not generate activity arena: %w", err)
+	}
 	activityAddress := memory.NewPath(
 		memory.PathWithWorld(world),
 		memory.PathWithSector(sector),
 		memory.PathWithArena(arena),
 	)
-	activityObject := p.cognition.GenerateActivityObject(p, maze, currPlan.Activity, activityAddress)
+	activityObject, err := p.cognition.GenerateActivityObject(ctx, p, maze, currPlan.Activity, activityAddress)
+	if err != nil {
+		return fmt.Errorf("could not generate activity object: %w", err)
+	}
 	activityAddress = activityAddress.Copy(memory.PathWithObject(activityObject))
 
-	activityPronunciato := p.cognition.GenerateActivityPronunciato(p, currPlan.Activity)
-	activitySPO := p.cognition.GenerateActivitySPO(p, currPlan.Activity)
+	activityPronunciato, err := p.cognition.GenerateActivityPronunciato(ctx, p, currPlan.Activity)
+	if err != nil {
+		return fmt.Errorf("could not generate activity pronunciato: %w", err)
+	}
+	activitySPO, err := p.cognition.GenerateActivitySPO(ctx, p, currPlan.Activity)
+	if err != nil {
+		return fmt.Errorf("could not generate activity SPO: %w", err)
+	}
 
 	// Since the persona's activitys also influence object states we need to set those up
-	activityObjectDescription := p.cognition.GenerateActivityObjectDescription(p, activityObject, currPlan.Activity)
-	activityObjectPronunciato := p.cognition.GenerateActivityObjectPronunciato(p, activityObjectDescription)
-	activityObjectSPO := p.cognition.GenerateActivityObjectSPO(p, activityObject, activityObjectDescription)
+	activityObjectDescription, err := p.cognition.GenerateActivityObjectDescription(ctx, p, activityObject, currPlan.Activity)
+	if err != nil {
+		return fmt.Errorf("could not generate activity object description: %w", err)
+	}
+	activityObjectPronunciato, err := p.cognition.GenerateActivityObjectPronunciato(ctx, p, activityObjectDescription)
+	if err != nil {
+		return fmt.Errorf("could not generate activity object pronunciato: %w", err)
+	}
+	activityObjectSPO, err := p.cognition.GenerateActivityObjectSPO(ctx, p, activityObject, activityObjectDescription)
+	if err != nil {
+		return fmt.Errorf("could not generate activity object SPO: %w", err)
+	}
 
 	// NOTE(Friso): In the original code they state that adding a new activity means adding it to some kind of activity queue,
 	// this is not what happens, they just set the current activity, so that is the behaviour I'll copy
@@ -217,6 +260,8 @@ func (p *Persona) determineActivity(maze *maze.Maze) {
 		activityObjectDescription,
 		activityObjectPronunciato,
 		activityObjectSPO)
+
+	return nil
 }
 
 func (p *Persona) chooseRetrieved(retrieved map[string]relevantNodes) (relevantNodes, bool) {
@@ -250,34 +295,34 @@ func (p *Persona) chooseRetrieved(retrieved map[string]relevantNodes) (relevantN
 	return relevantNodes{}, false
 }
 
-func letsTalk(init, target *Persona, focussed relevantNodes) bool {
+func letsTalk(ctx context.Context, init, target *Persona, focussed relevantNodes) (bool, error) {
 	if init.state.ActivityAddress.IsEmpty() ||
 		init.state.ActivityDescription == "" ||
 		target.state.ActivityAddress.IsEmpty() ||
 		target.state.ActivityDescription == "" {
-		return false
+		return false, nil
 	}
 
 	if strings.Contains(init.state.ActivityDescription, "sleeping") ||
 		strings.Contains(target.state.ActivityDescription, "sleeping") {
-		return false
+		return false, nil
 	}
 
 	// NOTE(Friso): I'm not sure why this case is here but they have it in the original code
 	if init.state.CurrentTime.Hour() == 23 {
-		return false
+		return false, nil
 	}
 
 	if target.state.ActivityAddress.HasState(memory.PathStateWaiting) {
-		return false
+		return false, nil
 	}
 
 	if init.state.ChattingWith != "" || target.state.ChattingWith != "" {
-		return false
+		return false, nil
 	}
 
 	if p, ok := init.state.ChattingWithBuffer[target.name]; ok && p > 0 {
-		return false
+		return false, nil
 	}
 
 	events := make([]memory.NodeId, 0, len(focussed.events))
@@ -289,34 +334,39 @@ func letsTalk(init, target *Persona, focussed relevantNodes) bool {
 		thoughts = append(thoughts, node)
 	}
 
-	return init.cognition.GenerateDecideToTalk(init, target, events, thoughts)
+	talk, err := init.cognition.GenerateDecideToTalk(ctx, init, target, events, thoughts)
+	if err != nil {
+		return false, fmt.Errorf("could not decide to talk: %w", err)
+	}
+
+	return talk, nil
 }
 
 // The name is copied from the orignal code but its deceptive, this function actually decides whether init should wait on target to finish their activity.
-func letsReact(init, target *Persona, focussed relevantNodes) (mode string, ok bool) {
+func letsReact(ctx context.Context, init, target *Persona, focussed relevantNodes) (mode string, ok bool, err error) {
 	if init.state.ActivityAddress.IsEmpty() ||
 		init.state.ActivityDescription == "" ||
 		target.state.ActivityAddress.IsEmpty() ||
 		target.state.ActivityDescription == "" {
-		return "", false
+		return "", false, nil
 	}
 
 	if strings.Contains(init.state.ActivityDescription, "sleeping") ||
 		strings.Contains(target.state.ActivityDescription, "sleeping") {
-		return "", false
+		return "", false, nil
 	}
 
 	// NOTE(Friso): I'm not sure why this case is here but they have it in the original code
 	if init.state.CurrentTime.Hour() == 23 {
-		return "", false
+		return "", false, nil
 	}
 
 	if strings.Contains(target.state.ActivityDescription, "waiting") {
-		return "", false
+		return "", false, nil
 	}
 
 	if len(init.state.PlannedPath) == 0 {
-		return "", false
+		return "", false, nil
 	}
 	// NOTE(Friso): I don't fully understand why skip reacting if targets activities have different addresses,
 	// to me it seems like this would prevent personas from reacting to each other even if they can see each other,
@@ -325,7 +375,7 @@ func letsReact(init, target *Persona, focussed relevantNodes) (mode string, ok b
 	// If the address of the init and target personas are different it means that they are going to (?) different game zones,
 	// so they should not interact.
 	if init.state.ActivityAddress != target.state.ActivityAddress {
-		return "", false
+		return "", false, nil
 	}
 
 	events := make([]memory.NodeId, 0, len(focussed.events))
@@ -337,23 +387,26 @@ func letsReact(init, target *Persona, focussed relevantNodes) (mode string, ok b
 		thoughts = append(thoughts, node)
 	}
 
-	shouldWait := init.cognition.GenerateDecideToWait(init, target, events, thoughts)
+	shouldWait, err := init.cognition.GenerateDecideToWait(ctx, init, target, events, thoughts)
+	if err != nil {
+		return "", false, fmt.Errorf("could not decide to wait: %w", err)
+	}
 	if shouldWait {
 		return fmt.Sprintf("wait: %s",
 				target.state.ActivityStartTime.
 					Add(target.state.ActivityDuration).
 					Format("January 02, 2006, 15:04:05")),
-			true
+			true, nil
 	}
 
-	return "", false
+	return "", false, nil
 }
 
-func (p *Persona) shouldReact(focussedEvent relevantNodes, personas map[string]*Persona) (mode string, ok bool) {
+func (p *Persona) shouldReact(ctx context.Context, focussedEvent relevantNodes, personas map[string]*Persona) (mode string, ok bool, err error) {
 	if p.state.ChattingWith != "" {
-		return "", false
+		return "", false, nil
 	} else if p.state.ActivityAddress.HasState(memory.PathStateWaiting) {
-		return "", false
+		return "", false, nil
 	}
 
 	currEvent := p.associativeMemory.GetNode(focussedEvent.currEvent)
@@ -362,17 +415,21 @@ func (p *Persona) shouldReact(focussedEvent relevantNodes, personas map[string]*
 		target, ok := personas[currEvent.Subject]
 		if !ok || p.name == target.name {
 			// Target does not exist or we are reaction to ourselves
-			return "", false
+			return "", false, nil
 		}
 
-		if letsTalk(p, target, focussedEvent) {
-			return fmt.Sprintf("chat with %s", currEvent.Subject), true
+		talk, err := letsTalk(ctx, p, target, focussedEvent)
+		if err != nil {
+			return "", false, err
+		}
+		if talk {
+			return fmt.Sprintf("chat with %s", currEvent.Subject), true, nil
 		}
 
-		return letsReact(p, personas[currEvent.Subject], focussedEvent)
+		return letsReact(ctx, p, personas[currEvent.Subject], focussedEvent)
 	}
 
-	return "", false
+	return "", false, nil
 }
 
 func (p *Persona) SumPlanDir(plans []llm.Plan) (out int) {
@@ -382,7 +439,7 @@ func (p *Persona) SumPlanDir(plans []llm.Plan) (out int) {
 	return out
 }
 
-func (p *Persona) createReact(summary string, duration int, address memory.Path, spo memory.SPO, actStartTime time.Time, pronunciato string, chattingWith string, chat []memory.Utterance, chattingWithBuffer map[string]int, chatEndTime time.Time) {
+func (p *Persona) createReact(ctx context.Context, summary string, duration int, address memory.Path, spo memory.SPO, actStartTime time.Time, pronunciato string, chattingWith string, chat []memory.Utterance, chattingWithBuffer map[string]int, chatEndTime time.Time) error {
 	minSum := 0
 	for i := 0; i < p.state.GetOriginalDailyPlanIndex(); i += 1 {
 		minSum += p.state.OriginalDailySchedule[i].Duration
@@ -421,7 +478,10 @@ func (p *Persona) createReact(summary string, duration int, address memory.Path,
 		endIndex = len(p.state.DailySchedule)
 	}
 
-	newPlans := p.cognition.GenerateReactionScheduleUpdate(p, llm.Plan{Duration: duration, Activity: summary}, startTime, endTime)
+	newPlans, err := p.cognition.GenerateReactionScheduleUpdate(ctx, p, llm.Plan{Duration: duration, Activity: summary}, startTime, endTime)
+	if err != nil {
+		return fmt.Errorf("could not generate reaction schedule: %w", err)
+	}
 
 	before, after := slices.Clone(p.state.DailySchedule[:startIndex]), p.state.DailySchedule[endIndex:]
 	p.state.DailySchedule = append(
@@ -449,7 +509,7 @@ func (p *Persona) createReact(summary string, duration int, address memory.Path,
 				Duration: int(dayDuration.Minutes()) - int(scheduledDuration.Minutes()),
 			})
 	} else if scheduledDuration > dayDuration {
-		panic("TODO: handle daily plan longer than day")
+		return errScheduleTooLong
 	}
 
 	dur := time.Duration(duration) * time.Minute
@@ -458,6 +518,8 @@ func (p *Persona) createReact(summary string, duration int, address memory.Path,
 	} else {
 		p.state.SetActivity(p.ctx.Log, address, dur, summary, pronunciato, spo, "", "", memory.SPO{})
 	}
+
+	return nil
 }
 
 func getLastN[T any](elems []T, n int) []T {
@@ -468,14 +530,20 @@ func getLastN[T any](elems []T, n int) []T {
 	return elems[len(elems)-n:]
 }
 
-func (p *Persona) iterativeGenerateConversation(target *Persona, maze *maze.Maze) (chat []memory.Utterance, duration int) {
-	generateUtterance := func(init, target *Persona, chat []memory.Utterance) (memory.Utterance, bool) {
-		relationshipMemories := p.retrieveForFocalPoints([]string{target.name}, withRetrievalCount(50))
+func (p *Persona) iterativeGenerateConversation(ctx context.Context, target *Persona, maze *maze.Maze) (chat []memory.Utterance, duration int, err error) {
+	generateUtterance := func(init, target *Persona, chat []memory.Utterance) (memory.Utterance, bool, error) {
+		relationshipMemories, err := p.retrieveForFocalPoints(ctx, []string{target.name}, withRetrievalCount(50))
+		if err != nil {
+			return memory.Utterance{}, false, err
+		}
 		nodes := []memory.NodeId{}
 		for _, ns := range relationshipMemories {
 			nodes = append(nodes, ns...)
 		}
-		relationship := p.cognition.GenerateRelationshipSummary(p, target, nodes)
+		relationship, err := p.cognition.GenerateRelationshipSummary(ctx, p, target, nodes)
+		if err != nil {
+			return memory.Utterance{}, false, fmt.Errorf("could not generate relationship summary: %w", err)
+		}
 
 		focalPoints := []string{relationship, fmt.Sprintf("%s is %s", target.name, target.ActivityDescription())}
 		lastUtt := getLastN(chat, 4)
@@ -483,24 +551,38 @@ func (p *Persona) iterativeGenerateConversation(target *Persona, maze *maze.Maze
 			focalPoints = append(focalPoints, fmt.Sprintf("%s: %s\n", utt.Speaker, utt.Sentence))
 		}
 
-		retrieved := init.retrieveForFocalPoints(focalPoints, withRetrievalCount(15))
+		retrieved, err := init.retrieveForFocalPoints(ctx, focalPoints, withRetrievalCount(15))
+		if err != nil {
+			return memory.Utterance{}, false, err
+		}
 		nodes = []memory.NodeId{}
 		for _, ns := range retrieved {
 			nodes = append(nodes, ns...)
 		}
 
-		return init.cognition.GenerateOneUtterance(init, target, maze, chat, nodes, relationship)
+		utt, done, err := init.cognition.GenerateOneUtterance(ctx, init, target, maze, chat, nodes, relationship)
+		if err != nil {
+			return memory.Utterance{}, false, fmt.Errorf("could not generate utterance: %w", err)
+		}
+
+		return utt, done, nil
 	}
 
 	length := 0
 	for i := 0; i < 8; i += 1 {
-		utt, done := generateUtterance(p, target, chat)
+		utt, done, err := generateUtterance(p, target, chat)
+		if err != nil {
+			return nil, 0, err
+		}
 		chat = append(chat, utt)
 		if done {
 			break
 		}
 
-		utt, done = generateUtterance(target, p, chat)
+		utt, done, err = generateUtterance(target, p, chat)
+		if err != nil {
+			return nil, 0, err
+		}
 		chat = append(chat, utt)
 		if done {
 			break
@@ -509,14 +591,20 @@ func (p *Persona) iterativeGenerateConversation(target *Persona, maze *maze.Maze
 		length += len(utt.Speaker) + len(utt.Sentence) + 3
 	}
 
-	return chat, int(float64(length)/8) / 30
+	return chat, int(float64(length)/8) / 30, nil
 }
 
-func (p *Persona) chatReact(maze *maze.Maze, reactionMode string, personas map[string]*Persona) {
+func (p *Persona) chatReact(ctx context.Context, maze *maze.Maze, reactionMode string, personas map[string]*Persona) error {
 	target := personas[strings.TrimPrefix(reactionMode, "chat with ")]
 
-	conversation, duration := p.iterativeGenerateConversation(target, maze)
-	summary := p.cognition.GenerateConversationSummary(p, conversation)
+	conversation, duration, err := p.iterativeGenerateConversation(ctx, target, maze)
+	if err != nil {
+		return fmt.Errorf("could not generate conversation with %s: %w", target.name, err)
+	}
+	summary, err := p.cognition.GenerateConversationSummary(ctx, p, conversation)
+	if err != nil {
+		return fmt.Errorf("could not generate conversation summary: %w", err)
+	}
 
 	endOfMinute := p.state.CurrentTime
 	if endOfMinute.Second() != 0 {
@@ -524,7 +612,7 @@ func (p *Persona) chatReact(maze *maze.Maze, reactionMode string, personas map[s
 	}
 	chatEndTime := endOfMinute.Add(time.Duration(duration) * time.Minute)
 
-	react := func(p, other *Persona) {
+	react := func(p, other *Persona) error {
 		address := memory.SpecialPath(memory.PathStatePersona, other.name)
 		spo := memory.SPO{
 			Subject:   p.name,
@@ -535,14 +623,16 @@ func (p *Persona) chatReact(maze *maze.Maze, reactionMode string, personas map[s
 		chattingWith := map[string]int{other.name: p.state.ChattingCooldown}
 		pronunciato := "💬"
 
-		p.createReact(summary, duration, address, spo, p.state.ActivityStartTime, pronunciato, other.name, conversation, chattingWith, chatEndTime)
+		return p.createReact(ctx, summary, duration, address, spo, p.state.ActivityStartTime, pronunciato, other.name, conversation, chattingWith, chatEndTime)
 	}
 
-	react(p, target)
-	react(target, p)
+	if err := react(p, target); err != nil {
+		return err
+	}
+	return react(target, p)
 }
 
-func (p *Persona) waitReact(reactionMode string) {
+func (p *Persona) waitReact(ctx context.Context, reactionMode string) error {
 	// NOTE(Friso): Because of this it is important that descriptions do not contain parentheses by themselves, only we should insert them
 	// its kind of a dumb design descition but oh well.
 	descStart := strings.Index(p.state.ActivityDescription, "(")
@@ -556,7 +646,7 @@ func (p *Persona) waitReact(reactionMode string) {
 	insertedActivity := fmt.Sprintf("waiting to start %s", desc)
 	endTime, err := time.Parse("January 02, 2006, 15:04:05", strings.TrimPrefix(reactionMode, "wait: "))
 	if err != nil {
-		panic(fmt.Errorf("unable to parse formatted time: %w", err))
+		return fmt.Errorf("unable to parse formatted time: %w", err)
 	}
 	activityDuration := int(endTime.Sub(p.state.CurrentTime).Minutes()) + 1
 
@@ -569,35 +659,87 @@ func (p *Persona) waitReact(reactionMode string) {
 
 	pronunciatio := "⌛"
 
-	p.createReact(insertedActivity, activityDuration, address, spo, time.Time{}, pronunciatio, "", []memory.Utterance{}, map[string]int{}, time.Time{})
+	return p.createReact(ctx, insertedActivity, activityDuration, address, spo, time.Time{}, pronunciatio, "", []memory.Utterance{}, map[string]int{}, time.Time{})
 }
 
-func (p *Persona) plan(maze *maze.Maze, personas map[string]*Persona, retrieved map[string]relevantNodes, newDay NewDayType) memory.Path {
+// planActivity makes sure the persona has a schedule for the day and an activity to perform.
+func (p *Persona) planActivity(ctx context.Context, maze *maze.Maze, newDay NewDayType) error {
 	// On the start of a new day the personas schedule is empty, thus we need to fill it
 	if newDay != NewDayTypeNoNewDay {
-		p.longTermPlanning(newDay)
+		if err := p.longTermPlanning(ctx, newDay); err != nil {
+			return fmt.Errorf("could not perform long term planning: %w", err)
+		}
 	}
 
 	if p.state.IsActivityFinished() {
-		p.determineActivity(maze)
+		if err := p.determineActivity(ctx, maze); err != nil {
+			return fmt.Errorf("could not determine activity: %w", err)
+		}
 	}
 
-	var focussedEvent relevantNodes
-	var ok bool = false
-	if len(retrieved) > 0 {
-		focussedEvent, ok = p.chooseRetrieved(retrieved)
+	return nil
+}
+
+// Reaction is how a persona wants to react to what it percieved during a step.
+type Reaction struct {
+	// The persona that reacts
+	Persona string
+	// The persona that is reacted to, empty if there is no reaction
+	Target string
+
+	// Either "chat with <target>" or "wait: <end time>"
+	mode string
+}
+
+// IsChat reports whether the persona wants to start a conversation with Target.
+func (r Reaction) IsChat() bool {
+	return strings.HasPrefix(r.mode, "chat with")
+}
+
+// IsWait reports whether the persona wants to wait until Target finished their activity.
+func (r Reaction) IsWait() bool {
+	return strings.HasPrefix(r.mode, "wait")
+}
+
+// decideReaction chooses one of the retrieved events to focus on and decides how to react to it.
+// It only reads the state of the other personas.
+func (p *Persona) decideReaction(ctx context.Context, retrieved map[string]relevantNodes, personas map[string]*Persona) (Reaction, error) {
+	reaction := Reaction{Persona: p.name}
+
+	if len(retrieved) == 0 {
+		return reaction, nil
+	}
+
+	focussedEvent, ok := p.chooseRetrieved(retrieved)
+	if !ok {
+		return reaction, nil
 	}
 
+	mode, ok, err := p.shouldReact(ctx, focussedEvent, personas)
+	if err != nil {
+		return reaction, fmt.Errorf("could not decide reaction: %w", err)
+	}
 	if ok {
-		if reactionMode, ok := p.shouldReact(focussedEvent, personas); ok {
-			if strings.HasPrefix(reactionMode, "chat with") {
-				p.chatReact(maze, reactionMode, personas)
-			} else if strings.HasPrefix(reactionMode, "wait") {
-				p.waitReact(reactionMode)
-			}
-		}
+		reaction.Target = p.associativeMemory.GetNode(focussedEvent.currEvent).Subject
+		reaction.mode = mode
 	}
 
+	return reaction, nil
+}
+
+// react performs the reaction, a chat changes the state of the target as well.
+func (p *Persona) react(ctx context.Context, maze *maze.Maze, reaction Reaction, personas map[string]*Persona) error {
+	if reaction.IsChat() {
+		return p.chatReact(ctx, maze, reaction.mode, personas)
+	} else if reaction.IsWait() {
+		return p.waitReact(ctx, reaction.mode)
+	}
+
+	return nil
+}
+
+// finishPlan cleans up the state left behind by planning and returns the address of the current activity.
+func (p *Persona) finishPlan() memory.Path {
 	// Clean up chat related persona state if we're not actively in a chat
 	if p.state.ActivitySPO.Predicate != "chat with" {
 		p.state.ChattingWith = ""
@@ -1,7 +1,11 @@
 package agent
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
+	"maps"
+	"math"
 	"slices"
 	"strings"
 	"time"
@@ -9,19 +13,79 @@ import (
 	"github.com/fvdveen/generative_agents/simulation_server/memory"
 )
 
-func (p *Persona) generateFocalPoints() []string {
-	nFocalPoints := 3
+// ReflectionPolicy configures what a persona reflects on and when, the zero value reflects like the original code:
+// on events and thoughts, once the importance of the events perceived since the last reflection exceeds the reflection
+// trigger of the persona.
+type ReflectionPolicy struct {
+	// The types of memories reflected on, events and thoughts when empty. Chats that are reflected on count towards the
+	// reflection trigger, like events.
+	Evidence []memory.NodeType
+	// How many focal points a reflection generates, 3 when 0
+	FocalPoints int
+	// How many insights are generated for every focal point, 5 when 0
+	Insights int
+	// How many levels of reflection are done, 1 when 0. Every level after the first reflects on the thoughts of the
+	// level before it, which results in thoughts of a greater depth.
+	Levels int
 
-	nodes := make([]memory.NodeId, 0, len(p.associativeMemory.GetLatestEventIds())+len(p.associativeMemory.GetLatestThoughtIds()))
-	nodes = append(nodes, p.associativeMemory.GetLatestEventIds()...)
-	nodes = append(nodes, p.associativeMemory.GetLatestThoughtIds()...)
+	// Whether the importance of new memories no longer triggers a reflection
+	IgnoreImportance bool
+	// Whether the persona reflects every day once it is DailyAt
+	Daily bool
+	// The time of day, since midnight, at which the persona reflects when Daily is set
+	DailyAt time.Duration
+	// The persona reflects once it has not made a new memory for IdleAfter, 0 disables reflecting when idle
+	IdleAfter time.Duration
+}
+
+func (r ReflectionPolicy) evidence() []memory.NodeType {
+	if len(r.Evidence) == 0 {
+		return []memory.NodeType{memory.NodeTypeEvent, memory.NodeTypeThought}
+	}
+	return r.Evidence
+}
+
+func (r ReflectionPolicy) focalPoints() int {
+	if r.FocalPoints <= 0 {
+		return 3
+	}
+	return r.FocalPoints
+}
+
+func (r ReflectionPolicy) insights() int {
+	if r.Insights <= 0 {
+		return 5
+	}
+	return r.Insights
+}
+
+func (r ReflectionPolicy) levels() int {
+	return max(r.Levels, 1)
+}
 
-	nodes = slices.DeleteFunc(nodes, func(n memory.NodeId) bool {
+// latestNodes returns the memories of the persona of types, leaving out idle events. Within a type newer memories come first.
+func (p *Persona) latestNodes(types []memory.NodeType) []memory.NodeId {
+	var nodes []memory.NodeId
+	for _, t := range types {
+		switch t {
+		case memory.NodeTypeEvent:
+			nodes = append(nodes, p.associativeMemory.GetLatestEventIds()...)
+		case memory.NodeTypeThought:
+			nodes = append(nodes, p.associativeMemory.GetLatestThoughtIds()...)
+		case memory.NodeTypeChat:
+			nodes = append(nodes, p.associativeMemory.GetLatestChatIds()...)
+		}
+	}
+
+	return slices.DeleteFunc(nodes, func(n memory.NodeId) bool {
 		return strings.Contains(
 			p.associativeMemory.GetNode(n).EmbeddingKey,
 			"idle")
 	})
+}
 
+func (p *Persona) generateFocalPoints(ctx context.Context, nodes []memory.NodeId) ([]string, error) {
+	nodes = slices.Clone(nodes)
 	slices.SortFunc(nodes, func(a, b memory.NodeId) int {
 		memA := p.associativeMemory.GetNode(a)
 		memB := p.associativeMemory.GetNode(b)
@@ -37,33 +101,154 @@ func (p *Persona) generateFocalPoints() []string {
 		n = len(nodes)
 	}
 
-	return p.cognition.GenerateFocalPoints(p, nodes[len(nodes)-n:], nFocalPoints)
+	focalPoints, err := p.cognition.GenerateFocalPoints(ctx, p, nodes[len(nodes)-n:], p.state.Reflection.focalPoints())
+	if err != nil {
+		return nil, fmt.Errorf("could not generate focal points: %w", err)
+	}
+
+	return focalPoints, nil
 }
 
-func (p *Persona) runReflect() {
-	focalPoints := p.generateFocalPoints()
-	retrieved := p.retrieveForFocalPoints(focalPoints)
+// rememberThought scores the thought and adds it to the persona's memory, thoughts expire after 30 days.
+func (p *Persona) rememberThought(ctx context.Context, spo memory.SPO, keywords []string, originalThought string, evidence []memory.NodeId) (memory.ConceptNode, error) {
+	created := p.state.CurrentTime
+	expiration := p.state.CurrentTime.Add(time.Hour * 24 * 30)
 
-	for _, nodes := range retrieved {
-		thoughts := p.cognition.GenerateInsightAndEvidence(p, nodes, 5)
-		for originalThought, evidence := range thoughts {
-			created := p.state.CurrentTime
-			expiration := p.state.CurrentTime.Add(time.Hour * 24 * 30)
-			spo := p.cognition.GenerateActivitySPO(p, originalThought)
-			keywords := []string{spo.Subject, spo.Predicate, spo.Object}
-			importance := p.cognition.GenerateImportanceScore(p, memory.NodeTypeThought, originalThought)
-			valence := p.cognition.GenerateValenceScore(p, memory.NodeTypeThought, originalThought)
-			thought := p.expandMemoryDescription(valence, nil, originalThought)
-			embedding := p.GetEmbedding(thought)
+	importance, err := p.cognition.GenerateImportanceScore(ctx, p, memory.NodeTypeThought, originalThought)
+	if err != nil {
+		return memory.ConceptNode{}, fmt.Errorf("could not generate thought importance: %w", err)
+	}
+	valence, err := p.cognition.GenerateValenceScore(ctx, p, memory.NodeTypeThought, originalThought)
+	if err != nil {
+		return memory.ConceptNode{}, fmt.Errorf("could not generate thought valence: %w", err)
+	}
+
+	thought, err := p.expandMemoryDescription(ctx, valence, nil, originalThought)
+	if err != nil {
+		return memory.ConceptNode{}, err
+	}
+	embedding, err := p.GetEmbedding(ctx, thought)
+	if err != nil {
+		return memory.ConceptNode{}, err
+	}
+
+	return p.addThoughtToMemory(spo, thought, originalThought, keywords, importance, valence, evidence, created, &expiration, thought, embedding), nil
+}
+
+// rememberGeneratedThought is like rememberThought but generates the SPO (and keywords) of the thought as well.
+func (p *Persona) rememberGeneratedThought(ctx context.Context, originalThought string, evidence []memory.NodeId) (memory.ConceptNode, error) {
+	spo, err := p.cognition.GenerateActivitySPO(ctx, p, originalThought)
+	if err != nil {
+		return memory.ConceptNode{}, fmt.Errorf("could not generate thought SPO: %w", err)
+	}
+	keywords := []string{spo.Subject, spo.Predicate, spo.Object}
 
-			p.addThoughtToMemory(spo, thought, originalThought, keywords, importance, valence, evidence, created, &expiration, thought, embedding)
+	return p.rememberThought(ctx, spo, keywords, originalThought, evidence)
+}
+
+// runReflect reflects on the memories of the persona, a level at a time. The first level reflects on the evidence of the
+// reflection policy, every following level on the thoughts of at least the depth of the thoughts the level before it had.
+func (p *Persona) runReflect(ctx context.Context) error {
+	policy := p.state.Reflection
+	evidence := p.latestNodes(policy.evidence())
+	depth := 0
+
+	for level := 1; level <= policy.levels() && len(evidence) != 0; level++ {
+		focalPoints, err := p.generateFocalPoints(ctx, evidence)
+		if err != nil {
+			return err
+		}
+
+		opts := []retrievalOpt{withNodeTypes(policy.evidence())}
+		if level > 1 {
+			opts = []retrievalOpt{withNodeTypes([]memory.NodeType{memory.NodeTypeThought}), withMinDepth(depth)}
+		}
+		retrieved, err := p.retrieveForFocalPoints(ctx, focalPoints, opts...)
+		if err != nil {
+			return err
 		}
+
+		var thoughts []memory.NodeId
+		for _, focalPoint := range focalPoints {
+			insights, err := p.cognition.GenerateInsightAndEvidence(ctx, p, retrieved[focalPoint], policy.insights())
+			if err != nil {
+				return fmt.Errorf("could not generate insights: %w", err)
+			}
+
+			for _, originalThought := range slices.Sorted(maps.Keys(insights)) {
+				node, err := p.rememberGeneratedThought(ctx, originalThought, insights[originalThought])
+				if err != nil {
+					return err
+				}
+				thoughts = append(thoughts, node.Id)
+			}
+		}
+
+		p.ctx.Log.Info("reflection_level_done",
+			slog.String("type", "reflection"),
+			slog.Int("level", level),
+			slog.Int("evidence", len(evidence)),
+			slog.Int("thoughts", len(thoughts)),
+		)
+
+		// The next level only reflects on thoughts that are at least as deep as the shallowest thought of this level
+		depth = math.MaxInt
+		for _, id := range thoughts {
+			depth = min(depth, p.associativeMemory.GetNode(id).Depth)
+		}
+		evidence = slices.DeleteFunc(p.latestNodes([]memory.NodeType{memory.NodeTypeThought}), func(n memory.NodeId) bool {
+			return p.associativeMemory.GetNode(n).Depth < depth
+		})
 	}
+
+	return nil
 }
 
-func (p *Persona) shouldReflect() bool {
-	return p.state.CurrentReflectionTrigger < 1 &&
-		len(p.associativeMemory.GetLatestEventIds())+len(p.associativeMemory.GetLatestThoughtIds()) != 0
+// reflectionTrigger returns what makes the persona reflect this step, or an empty string when it does not reflect.
+func (p *Persona) reflectionTrigger() string {
+	policy := p.state.Reflection
+	if len(p.latestNodes(policy.evidence())) == 0 {
+		return ""
+	}
+
+	if !policy.IgnoreImportance && p.state.CurrentReflectionTrigger < 1 {
+		return "importance"
+	}
+
+	// Only the importance trigger reflects on memories that were already reflected on
+	if p.state.ReflectionElements == 0 {
+		return ""
+	}
+
+	if policy.Daily {
+		next := p.StartOfDay().Add(policy.DailyAt)
+		if !p.state.LastDailyReflection.IsZero() {
+			// The simulation skips ahead while everyone sleeps, so a reflection at a time the persona is
+			// asleep is done once they wake up
+			last := p.state.LastDailyReflection
+			next = time.Date(last.Year(), last.Month(), last.Day(), 0, 0, 0, 0, last.Location()).Add(policy.DailyAt)
+			if !next.After(last) {
+				next = next.AddDate(0, 0, 1)
+			}
+		}
+		if !p.state.CurrentTime.Before(next) {
+			return "daily"
+		}
+	}
+
+	if policy.IdleAfter > 0 {
+		var latest time.Time
+		for _, n := range p.latestNodes(policy.evidence()) {
+			if created := p.associativeMemory.GetNode(n).Created; created.After(latest) {
+				latest = created
+			}
+		}
+		if p.state.CurrentTime.Sub(latest) >= policy.IdleAfter {
+			return "idle"
+		}
+	}
+
+	return ""
 }
 
 func (p *Persona) resetReflectionTrigger() {
@@ -71,10 +256,20 @@ func (p *Persona) resetReflectionTrigger() {
 	p.state.ReflectionElements = 0
 }
 
-func (p *Persona) reflect() {
-	if p.shouldReflect() {
-		p.runReflect()
+func (p *Persona) reflect(ctx context.Context) error {
+	if trigger := p.reflectionTrigger(); trigger != "" {
+		p.ctx.Log.Info("reflection_start",
+			slog.String("type", "reflection"),
+			slog.String("trigger", trigger),
+		)
+		if err := p.runReflect(ctx); err != nil {
+			return err
+		}
+		if trigger == "daily" {
+			p.state.LastDailyReflection = p.state.CurrentTime
+		}
 		p.resetReflectionTrigger()
+		p.ctx.Metrics.Reflection(p.name)
 	}
 
 	if !p.state.ChatEndTime.IsZero() &&
@@ -86,38 +281,26 @@ func (p *Persona) reflect() {
 			evidence = []memory.NodeId{id}
 		}
 
-		origPlanningThought := p.cognition.GeneratePlanningThoughtAfterConversation(p, p.state.Chat)
+		origPlanningThought, err := p.cognition.GeneratePlanningThoughtAfterConversation(ctx, p, p.state.Chat)
+		if err != nil {
+			return fmt.Errorf("could not generate planning thought: %w", err)
+		}
 		origPlanningThought = fmt.Sprintf("For %s's planning: %s", p.name, origPlanningThought)
 
-		created := p.state.CurrentTime
-		expired := p.state.CurrentTime.Add(time.Hour * 24 * 30)
-
-		spo := p.cognition.GenerateActivitySPO(p, origPlanningThought)
-		keywords := []string{spo.Subject, spo.Predicate, spo.Object}
-
-		importance := p.cognition.GenerateImportanceScore(p, memory.NodeTypeThought, origPlanningThought)
-		valence := p.cognition.GenerateValenceScore(p, memory.NodeTypeThought, origPlanningThought)
-
-		planningThought := p.expandMemoryDescription(valence, nil, origPlanningThought)
-		embedding := p.GetEmbedding(planningThought)
-
-		p.addThoughtToMemory(spo, planningThought, origPlanningThought, keywords, importance, valence, evidence, created, &expired, planningThought, embedding)
+		if _, err := p.rememberGeneratedThought(ctx, origPlanningThought, evidence); err != nil {
+			return err
+		}
 
-		origMemoThought := p.cognition.GenerateMemoAfterConversation(p, p.state.Chat)
+		origMemoThought, err := p.cognition.GenerateMemoAfterConversation(ctx, p, p.state.Chat)
+		if err != nil {
+			return fmt.Errorf("could not generate memo: %w", err)
+		}
 		origMemoThought = fmt.Sprintf("%s %s", p.name, origMemoThought)
 
-		created2 := p.state.CurrentTime
-		expired2 := p.state.CurrentTime.Add(time.Hour * 24 * 30)
-
-		spo2 := p.cognition.GenerateActivitySPO(p, origMemoThought)
-		keywords2 := []string{spo2.Subject, spo2.Predicate, spo2.Object}
-
-		importance2 := p.cognition.GenerateImportanceScore(p, memory.NodeTypeThought, origMemoThought)
-		valence2 := p.cognition.GenerateValenceScore(p, memory.NodeTypeThought, origMemoThought)
-
-		memoThought := p.expandMemoryDescription(valence2, nil, origMemoThought)
-		embedding2 := p.GetEmbedding(memoThought)
-
-		p.addThoughtToMemory(spo2, memoThought, origMemoThought, keywords2, importance2, valence2, evidence, created2, &expired2, memoThought, embedding2)
+		if _, err := p.rememberGeneratedThought(ctx, origMemoThought, evidence); err != nil {
+			return err
+		}
 	}
+
+	return nil
 }
@@ -8,7 +8,6 @@ import (
 	"math"
 	"slices"
 	"strconv"
-	"strings"
 
 	"github.com/fvdveen/generative_agents/simulation_server/memory"
 )
@@ -193,11 +192,9 @@ func extractValence(p *Persona, nodes []memory.NodeId) map[memory.NodeId]float64
 	return out
 }
 
-func extractRelevance(p *Persona, nodes []memory.NodeId, focalPoint string) map[memory.NodeId]float64 {
+func extractRelevance(p *Persona, nodes []memory.NodeId, focalEmbedding []float64) map[memory.NodeId]float64 {
 	out := map[memory.NodeId]float64{}
 
-	focalEmbedding := p.GetEmbedding(focalPoint)
-
 	for _, node := range nodes {
 		nodeEmbedding, _ := p.associativeMemory.GetEmbeddingByNodeId(node)
 		out[node] = float64(cosineSimilarity(nodeEmbedding, focalEmbedding))
@@ -206,8 +203,41 @@ func extractRelevance(p *Persona, nodes []memory.NodeId, focalPoint string) map[
 	return out
 }
 
+// Below this amount of memories all of them are scored, above it only the candidates found using the index are
+const indexThreshold = 1000
+
+// retrievalCandidates narrows nodes, sorted by recency, down to the nodes most related to the focal point according to
+// the index. The most recently accessed nodes always remain candidates, as they may be retrieved for their recency alone.
+func retrievalCandidates(p *Persona, nodes []memory.NodeId, focalEmbedding []float64, count int) []memory.NodeId {
+	retrievable := make(map[memory.NodeId]bool, len(nodes))
+	for _, node := range nodes {
+		retrievable[node] = true
+	}
+
+	// The index is approximate, so we ask for more candidates than are retrieved
+	nearest := p.associativeMemory.NearestNodes(focalEmbedding, 4*count, func(c memory.ConceptNode) bool {
+		return retrievable[c.Id]
+	})
+
+	candidates := make(map[memory.NodeId]bool, len(nearest)+count)
+	for _, node := range nearest {
+		candidates[node] = true
+	}
+	for _, node := range nodes[:min(count, len(nodes))] {
+		candidates[node] = true
+	}
+
+	return slices.DeleteFunc(slices.Clone(nodes), func(n memory.NodeId) bool { return !candidates[n] })
+}
+
 type retrievalConfig struct {
 	count int
+	// The types of memories that can be retrieved
+	types []memory.NodeType
+	// The minimum depth of the retrieved memories
+	minDepth int
+	// Whether retrieval leaves the memory untouched, not marking the retrieved memories as accessed
+	readOnly bool
 }
 
 type retrievalOpt func(*retrievalConfig)
@@ -218,35 +248,77 @@ func withRetrievalCount(n int) retrievalOpt {
 	}
 }
 
-func (p *Persona) retrieveForFocalPoints(focalPoints []string, retrievalOpts ...retrievalOpt) map[string][]memory.NodeId {
+func withNodeTypes(types []memory.NodeType) retrievalOpt {
+	return func(rc *retrievalConfig) {
+		rc.types = types
+	}
+}
+
+func withMinDepth(depth int) retrievalOpt {
+	return func(rc *retrievalConfig) {
+		rc.minDepth = depth
+	}
+}
+
+func withReadOnlyRetrieval() retrievalOpt {
+	return func(rc *retrievalConfig) {
+		rc.readOnly = true
+	}
+}
+
+func (p *Persona) retrieveForFocalPoints(ctx context.Context, focalPoints []string, retrievalOpts ...retrievalOpt) (map[string][]memory.NodeId, error) {
 	config := retrievalConfig{
 		count: 30,
+		types: []memory.NodeType{memory.NodeTypeEvent, memory.NodeTypeThought},
+	}
+	for _, opt := range retrievalOpts {
+		opt(&config)
+	}
+
+	getEmbedding := p.GetEmbedding
+	if config.readOnly {
+		getEmbedding = p.lookupEmbedding
 	}
 
 	retrieved := map[string][]memory.NodeId{}
 
 	for _, focalPoint := range focalPoints {
-		nodes := make([]memory.NodeId, 0, len(p.associativeMemory.GetLatestEventIds())+len(p.associativeMemory.GetLatestThoughtIds()))
-		nodes = append(nodes, p.associativeMemory.GetLatestEventIds()...)
-		nodes = append(nodes, p.associativeMemory.GetLatestThoughtIds()...)
-
-		nodes = slices.DeleteFunc(nodes, func(n memory.NodeId) bool {
-			return strings.Contains(
-				p.associativeMemory.GetNode(n).EmbeddingKey,
-				"idle")
+		nodes := slices.DeleteFunc(p.latestNodes(config.types), func(n memory.NodeId) bool {
+			return p.associativeMemory.GetNode(n).Depth < config.minDepth
 		})
 
+		// There is nothing to score, normalizing the scores would fail on the empty maps
+		if len(nodes) == 0 {
+			retrieved[focalPoint] = []memory.NodeId{}
+			continue
+		}
+
 		slices.SortFunc(nodes, func(a, b memory.NodeId) int {
 			memA := p.associativeMemory.GetNode(a)
 			memB := p.associativeMemory.GetNode(b)
 			return memB.LastAccessed.Compare(memA.LastAccessed)
 		})
 
+		focalEmbedding, err := getEmbedding(ctx, focalPoint)
+		if err != nil {
+			return nil, fmt.Errorf("could not compute relevance for focal point %q: %w", focalPoint, err)
+		}
+
+		// Recency depends on the position among all nodes, so it is computed before narrowing the nodes down
 		recencyScores := extractRecency(p, nodes)
+		if len(nodes) > indexThreshold {
+			nodes = retrievalCandidates(p, nodes, focalEmbedding, config.count)
+			candidateScores := make(map[memory.NodeId]float64, len(nodes))
+			for _, node := range nodes {
+				candidateScores[node] = recencyScores[node]
+			}
+			recencyScores = candidateScores
+		}
+
 		recencyScores = normalizeMap(recencyScores, 0, 1)
 		importanceScores := extractImportance(p, nodes)
 		importanceScores = normalizeMap(importanceScores, 0, 1)
-		relevanceScores := extractRelevance(p, nodes, focalPoint)
+		relevanceScores := extractRelevance(p, nodes, focalEmbedding)
 		relevanceScores = normalizeMap(relevanceScores, 0, 1)
 		valenceScores := extractValence(p, nodes)
 		valenceScores = absolute(valenceScores)
@@ -268,13 +340,15 @@ func (p *Persona) retrieveForFocalPoints(focalPoints []string, retrievalOpts ...
 		out = highestNValues(out, config.count)
 		outNodes := make([]memory.NodeId, 0, len(out))
 		for k := range out {
-			p.associativeMemory.UpdateNode(k, func(c *memory.ConceptNode) {
-				c.LastAccessed = p.state.CurrentTime
-			})
+			if !config.readOnly {
+				p.associativeMemory.UpdateNode(k, func(c *memory.ConceptNode) {
+					c.LastAccessed = p.state.CurrentTime
+				})
+			}
 			outNodes = append(outNodes, k)
 		}
 
-		if p.ctx.Log.Enabled(context.Background(), slog.LevelDebug) {
+		if p.ctx.Log.Enabled(ctx, slog.LevelDebug) {
 			logOut := make([]slog.Attr, 0, len(outNodes))
 
 			for _, node := range outNodes {
@@ -302,5 +376,5 @@ func (p *Persona) retrieveForFocalPoints(focalPoints []string, retrievalOpts ...
 		retrieved[focalPoint] = outNodes
 	}
 
-	return retrieved
+	return retrieved, nil
 }
@@ -0,0 +1,904 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"math"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fvdveen/generative_agents/simulation_server/llm"
+	"github.com/fvdveen/generative_agents/simulation_server/llm/accounting"
+	"github.com/fvdveen/generative_agents/simulation_server/llm/cassette"
+	"github.com/fvdveen/generative_agents/simulation_server/llm/embedding"
+	"github.com/fvdveen/generative_agents/simulation_server/llm/fake"
+	"github.com/fvdveen/generative_agents/simulation_server/llm/openai"
+	"github.com/fvdveen/generative_agents/simulation_server/llm/promptcache"
+	"github.com/fvdveen/generative_agents/simulation_server/llm/provider"
+	"github.com/fvdveen/generative_agents/simulation_server/loganalysis"
+	"github.com/fvdveen/generative_agents/simulation_server/logging"
+	"github.com/fvdveen/generative_agents/simulation_server/memory"
+	"github.com/fvdveen/generative_agents/simulation_server/metrics"
+	"github.com/fvdveen/generative_agents/simulation_server/server"
+	simulationloader "github.com/fvdveen/generative_agents/simulation_server/simulation_loader"
+	"github.com/fvdveen/generative_agents/simulation_server/tracing"
+)
+
+// command is a subcommand of the simulation server.
+type command struct {
+	// The arguments of the command
+	args string
+	help string
+	// Whether the command runs or talks to the personas, which needs the language model backend and writes run logs
+	usesModels bool
+	// Whether the command leaves the configured simulation alone, its database is then not opened
+	skipsStorage bool
+	// Whether the first argument names the simulation the command creates, it is used instead of simulation_name
+	namesSimulation bool
+	// setup registers the flags of the command and returns the function executing it
+	setup func(fs *flag.FlagSet) func(ctx context.Context, s *session, args []string) error
+}
+
+var commands = map[string]command{
+	"run": {
+		help:       "run the simulation, until it is stopped or the stop condition is reached",
+		usesModels: true,
+		setup:      runCommand,
+	},
+	"interview": {
+		args:       "<persona>",
+		help:       "interview a persona, questions are read from stdin one per line",
+		usesModels: true,
+		setup:      interviewCommand,
+	},
+	"whisper": {
+		args:       "<persona> <text>",
+		help:       "plant a thought in the mind of a persona",
+		usesModels: true,
+		setup:      whisperCommand,
+	},
+	"fork": {
+		args:  "<name> <step>",
+		help:  "create simulation name from the backup of the simulation at step",
+		setup: forkCommand,
+	},
+	"rewind": {
+		args:  "<step>",
+		help:  "restore the simulation to its backup at step, removing everything after it",
+		setup: rewindCommand,
+	},
+	"import": {
+		args:  "<folder>",
+		help:  "replace the simulation in the database with a reverie folder, needs --storage sqlite",
+		setup: importCommand,
+	},
+	"export": {
+		args:  "<folder>",
+		help:  "write the simulation in the database to a reverie folder, needs --storage sqlite",
+		setup: exportCommand,
+	},
+	"inspect": {
+		help:  "print the state of the simulation and its personas",
+		setup: inspectCommand,
+	},
+	"validate": {
+		help:  "check the configuration and whether the simulation can be loaded",
+		setup: validateCommand,
+	},
+	"logs": {
+		args:         "<report> [run]",
+		help:         "report on the latest or given run: steps, prompts, retries, activities or retrieval",
+		skipsStorage: true,
+		setup:        logsCommand,
+	},
+	"new": {
+		args:            "<name> <spec>",
+		help:            "create simulation name in --maze from a YAML or JSON file describing its personas",
+		usesModels:      true,
+		skipsStorage:    true,
+		namesSimulation: true,
+		setup:           newCommand,
+	},
+}
+
+// usage returns the usage of every command.
+func usage() string {
+	var b strings.Builder
+	b.WriteString("usage: simulation_server <command> [flags] [arguments]\n\ncommands:\n")
+	for _, name := range slices.Sorted(maps.Keys(commands)) {
+		fmt.Fprintf(&b, "  %-28s %s\n", strings.TrimSpace(name+" "+commands[name].args), commands[name].help)
+	}
+	b.WriteString("\nrun simulation_server <command> -h for the flags of a command, run is the default command")
+
+	return b.String()
+}
+
+// execute parses the command line args and executes the command they name.
+// The configuration is read from the environment, then from the config file and lastly from the flags.
+func execute(ctx context.Context, args []string) error {
+	name := "run"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+	cmd, ok := commands[name]
+	if !ok {
+		return fmt.Errorf("unknown command %q\n\n%s", name, usage())
+	}
+
+	conf, envErr := envConfig()
+
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: simulation_server %s [flags] %s\n\n%s\n\nflags:\n", name, cmd.args, cmd.help)
+		fs.PrintDefaults()
+	}
+	configFile := fs.String("config", os.Getenv("CONFIG_FILE"), "YAML or TOML file overriding the environment")
+	conf.bindFlags(fs)
+	exec := cmd.setup(fs)
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	if *configFile != "" {
+		// The flags have to override the config file, but the file is only known after parsing them.
+		// So the flags that were set are set again after loading it.
+		set := map[string]string{}
+		fs.Visit(func(f *flag.Flag) {
+			set[f.Name] = f.Value.String()
+		})
+		if err := conf.loadFile(*configFile); err != nil {
+			return err
+		}
+		for name, value := range set {
+			if err := fs.Set(name, value); err != nil {
+				return fmt.Errorf("could not set flag %s: %w", name, err)
+			}
+		}
+	}
+	// The run logs and backups of a new simulation belong to it, not to the configured simulation
+	if cmd.namesSimulation && fs.NArg() > 0 {
+		conf.SimulationName = fs.Arg(0)
+	}
+	conf.setDefaults()
+
+	// Commands that don't use the models should work without API keys, and commands that leave the simulations alone
+	// without the simulation folders, except for validate which checks everything. New leaves the configured
+	// simulation alone, but creates its simulation in the same folders.
+	folders := !cmd.skipsStorage || cmd.namesSimulation || name == "validate"
+	if err := errors.Join(envErr, conf.validate(cmd.usesModels || name == "validate", folders)); err != nil {
+		return fmt.Errorf("invalid configuration:\n%w", err)
+	}
+
+	s, err := openSession(conf, cmd.usesModels, !cmd.skipsStorage)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	if cmd.usesModels {
+		defer logging.RecoverAndLog(s.log, s.logs.Sync)
+	}
+
+	return exec(ctx, s, fs.Args())
+}
+
+// session holds everything a command needs to access the simulation.
+type session struct {
+	conf Config
+	log  *slog.Logger
+	// Only set when the command uses the models
+	logs *logging.RunLogs
+	// The database the simulation is stored in, nil when the simulation is stored in files
+	db *simulationloader.SQLiteStorage
+
+	cassette *cassette.Cassette
+	// Caches the embeddings generated by the embedder, nil when the embedder is not cached
+	embeddingCache *embedding.Cache
+	// Caches the responses to prompts, nil when no prompt is cached
+	promptCache *promptcache.Cache
+	embedder    llm.Embedder
+	cognition   llm.Cognition
+	// The usage of the language models, only set for commands that use them
+	usage *accounting.Tracker
+	// Only set when the command uses the models and metrics_addr is set
+	metrics *metrics.Prometheus
+	// Only set when the command uses the models and trace_exporter is set
+	tracing *tracing.Provider
+}
+
+// openSession opens the language model backend if models is true, and the storage of the simulation if storage is true.
+func openSession(conf Config, models bool, storage bool) (s *session, err error) {
+	s = &session{conf: conf}
+	defer func() {
+		if err != nil {
+			s.Close()
+		}
+	}()
+
+	if models {
+		if s.logs, err = logging.NewRunLogs(logging.Config{
+			BaseDir:        path.Join(conf.LogDir, conf.SimulationName),
+			AlsoToStderr:   true,
+			EnableDebugLog: true,
+		}); err != nil {
+			return nil, fmt.Errorf("could not create logger: %w", err)
+		}
+		s.log = s.logs.Log
+
+		if err := s.openModels(); err != nil {
+			return nil, err
+		}
+	} else {
+		s.log = slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn}))
+		// Loading personas needs a backend, but commands that don't use the models never call it
+		f := fake.New(fake.WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))))
+		s.embedder, s.cognition = f, f
+	}
+
+	if storage && conf.Storage == "sqlite" {
+		if s.db, err = simulationloader.OpenSQLiteStorage(conf.SQLiteFile, conf.BackupDir, conf.SimulationName); err != nil {
+			return nil, fmt.Errorf("could not open database: %w", err)
+		}
+	}
+
+	return s, nil
+}
+
+// openModels creates the language model backend.
+func (s *session) openModels() error {
+	conf := s.conf
+	s.usage = accounting.NewTracker(accounting.WithBudget(conf.BudgetUSD), accounting.WithLogger(s.log))
+	if conf.MetricsAddr != "" {
+		s.metrics = metrics.NewPrometheus()
+	}
+	if conf.TraceExporter != "" {
+		file := conf.TraceFile
+		if file == "" {
+			file = filepath.Join(s.logs.RunDir, "traces.jsonl")
+		}
+		var err error
+		if s.tracing, err = tracing.Open(conf.TraceExporter, file, conf.SimulationName, s.logs.RunID); err != nil {
+			return err
+		}
+	}
+
+	if conf.CassetteMode != "" {
+		mode, err := cassette.ParseMode(conf.CassetteMode)
+		if err != nil {
+			return fmt.Errorf("invalid cassette mode: %w", err)
+		}
+
+		if s.cassette, err = cassette.Open(conf.CassetteFile, mode); err != nil {
+			return fmt.Errorf("could not open cassette: %w", err)
+		}
+	}
+
+	switch conf.Backend {
+	case "", "openai":
+		clientOpts := []openai.ClientOpt{openai.WithAPIKey(conf.TextModelKey), openai.WithLogger(s.log), openai.WithUsageTracker(s.usage)}
+		if s.cassette != nil {
+			clientOpts = append(clientOpts, openai.WithPromptHook(s.cassette))
+		}
+		if conf.TextModelURL != "" {
+			clientOpts = append(clientOpts, openai.WithURL(conf.TextModelURL))
+		}
+		if conf.TextModel != "" {
+			clientOpts = append(clientOpts, openai.WithTextModel(conf.TextModel))
+		}
+		if s.metrics != nil {
+			clientOpts = append(clientOpts, openai.WithMetrics(s.metrics))
+		}
+		if s.tracing != nil {
+			clientOpts = append(clientOpts, openai.WithTracer(s.tracing.Tracer()))
+		}
+		if len(conf.PromptModels) > 0 {
+			clientOpts = append(clientOpts, openai.WithPromptModels(conf.PromptModels))
+		}
+		if len(conf.PromptCache) > 0 {
+			ttls, err := conf.promptCacheTTLs()
+			if err != nil {
+				return fmt.Errorf("invalid prompt cache: %w", err)
+			}
+			if s.promptCache, err = promptcache.Open(conf.PromptCacheFile, ttls); err != nil {
+				return fmt.Errorf("could not open prompt cache: %w", err)
+			}
+			clientOpts = append(clientOpts, openai.WithPromptCache(s.promptCache))
+		}
+		providerOpts := []provider.Opt{provider.WithAPIKey(conf.TextModelKey), provider.WithURL(conf.TextModelURL)}
+		switch conf.TextProvider {
+		case "", "responses":
+			clientOpts = append(clientOpts, openai.WithProvider(provider.NewResponses(providerOpts...)))
+		case "chat":
+			clientOpts = append(clientOpts, openai.WithProvider(provider.NewChat(providerOpts...)))
+		case "anthropic":
+			clientOpts = append(clientOpts, openai.WithProvider(provider.NewAnthropic(providerOpts...)))
+		default:
+			return fmt.Errorf("unknown text provider %q, expected \"responses\", \"chat\" or \"anthropic\"", conf.TextProvider)
+		}
+		s.cognition = openai.New(clientOpts...)
+
+		embedderOpts := []openai.ClientOpt{openai.WithAPIKey(conf.EmbeddingKey), openai.WithLogger(s.log), openai.WithUsageTracker(s.usage)}
+		if s.metrics != nil {
+			embedderOpts = append(embedderOpts, openai.WithMetrics(s.metrics))
+		}
+		if conf.EmbeddingURL != "" {
+			embedderOpts = append(embedderOpts, openai.WithURL(conf.EmbeddingURL))
+		}
+		if conf.EmbeddingModel != "" {
+			embedderOpts = append(embedderOpts, openai.WithEmbeddingsModel(conf.EmbeddingModel))
+		}
+		embedder := openai.New(embedderOpts...)
+		var err error
+		if s.embeddingCache, err = embedding.OpenCache(conf.EmbeddingCache, embedder.EmbeddingModel(), embedding.NewBatcher(embedder)); err != nil {
+			return fmt.Errorf("could not open embedding cache: %w", err)
+		}
+		s.embedder = s.embeddingCache
+	case "fake":
+		f := fake.New(fake.WithLogger(s.log))
+		s.cognition, s.embedder = f, f
+	default:
+		return fmt.Errorf("unknown LLM backend %q, expected \"openai\" or \"fake\"", conf.Backend)
+	}
+
+	if s.cassette != nil {
+		// When replaying we still run the cognition so its prompts get compared with the recorded ones,
+		// but the embedder has no prompts and would only cause network traffic.
+		if s.cassette.Mode() == cassette.ModeReplay {
+			s.embedder = nil
+		}
+		s.cognition, s.embedder = s.cassette.Wrap(s.cognition, s.embedder)
+	}
+
+	return nil
+}
+
+func (s *session) Close() {
+	if s.db != nil {
+		_ = s.db.Close()
+	}
+	if s.cassette != nil {
+		_ = s.cassette.Close()
+	}
+	if s.embeddingCache != nil {
+		_ = s.embeddingCache.Close()
+	}
+	if s.promptCache != nil {
+		_ = s.promptCache.Close()
+	}
+	if s.tracing != nil {
+		_ = s.tracing.Close(context.Background())
+	}
+	if s.logs != nil {
+		_ = s.logs.Close()
+	}
+}
+
+// loadSimulation loads the simulation from storage, the simulation is saved to the same place it is loaded from.
+func (s *session) loadSimulation() (*server.Server, error) {
+	var sim *server.Server
+	var err error
+	if s.db != nil {
+		sim, err = s.db.Load(s.conf.MazeDir, s.embedder, s.cognition, s.log)
+		if err != nil {
+			return nil, fmt.Errorf("could not load simulation: %w", err)
+		}
+		sim.Storage = s.db
+	} else {
+		sim, err = simulationloader.LoadSimulation(path.Join(s.conf.SimulationDir, s.conf.SimulationName), s.conf.MazeDir, s.embedder, s.cognition, s.log)
+		if err != nil {
+			return nil, fmt.Errorf("could not load simulation: %w", err)
+		}
+		sim.Storage = s.fileStorage()
+	}
+
+	sim.BackupInterval = s.conf.BackupInterval
+	sim.Workers = s.conf.Workers
+	sim.AvoidCollisions = s.conf.AvoidCollisions
+
+	return sim, nil
+}
+
+// fileStorage returns the storage of the simulation when it is stored in files.
+func (s *session) fileStorage() *simulationloader.FileStorage {
+	return &simulationloader.FileStorage{
+		SimulationsFolder: s.conf.SimulationDir,
+		Simulation:        s.conf.SimulationName,
+		Maze:              s.conf.SimulationMaze,
+		BackupFolder:      s.conf.BackupDir,
+	}
+}
+
+func runCommand(fs *flag.FlagSet) func(ctx context.Context, s *session, args []string) error {
+	steps := fs.Int("steps", 0, "stop after running this many steps")
+	until := fs.String("until", "", `stop once the simulation reaches this time, for example "February 14, 2023, 18:00"`)
+
+	return func(ctx context.Context, s *session, args []string) error {
+		if len(args) != 0 {
+			return fmt.Errorf("run takes no arguments, got: %q", args)
+		}
+		if *steps < 0 {
+			return fmt.Errorf("--steps must not be negative, got: %d", *steps)
+		}
+		if *steps != 0 && *until != "" {
+			return errors.New("only one of --steps and --until can be used")
+		}
+
+		var untilTime time.Time
+		if *until != "" {
+			t, err := server.ParseTime(*until)
+			if err != nil {
+				return err
+			}
+			untilTime = t
+		}
+
+		return runSimulation(ctx, s, *steps, untilTime)
+	}
+}
+
+// runSimulation runs the simulation, retrying failed steps, until steps steps are executed or the simulation reaches
+// time until. Without either it runs until the context is cancelled.
+func runSimulation(ctx context.Context, s *session, steps int, until time.Time) error {
+	usageFile, err := openUsageFile(s.logs.RunDir)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = usageFile.Close() }()
+	defer func() {
+		report := s.usage.Report()
+		s.log.Info("usage_summary", slog.String("type", "usage"), slog.Any("usage", report))
+		printUsage(os.Stdout, report)
+	}()
+
+	// Exceeding the budget pauses the simulation after the current step when it can be resumed through the API.
+	// Without the API the run stops instead, the step is saved and an error wrapping errOverBudget is returned.
+	ctx, stopRun := context.WithCancelCause(ctx)
+	defer stopRun(nil)
+	recorder := &usageRecorder{tracker: s.usage, log: s.log, enc: json.NewEncoder(usageFile), overBudget: func() {
+		stopRun(fmt.Errorf("%w of $%.2f", errOverBudget, s.conf.BudgetUSD))
+	}}
+
+	var api *server.API
+	if s.conf.APIAddr != "" {
+		control := server.NewControl()
+		recorder.overBudget = control.Pause
+		api = server.NewAPI(control, s.log)
+		httpServer := &http.Server{Addr: s.conf.APIAddr, Handler: api.Handler()}
+		go func() {
+			if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				s.log.Error("api_fail", slog.String("type", "api"), slog.Any("err", err))
+			}
+		}()
+		defer func() { _ = httpServer.Shutdown(context.Background()) }()
+	}
+
+	if s.metrics != nil {
+		mux := http.NewServeMux()
+		mux.Handle("GET /metrics", s.metrics.Handler())
+		metricsServer := &http.Server{Addr: s.conf.MetricsAddr, Handler: mux}
+		go func() {
+			if err := metricsServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				s.log.Error("metrics_fail", slog.String("type", "metrics"), slog.Any("err", err))
+			}
+		}()
+		defer func() { _ = metricsServer.Shutdown(context.Background()) }()
+	}
+
+	var scenario *server.Scenario
+	if s.conf.ScenarioFile != "" {
+		if scenario, err = server.LoadScenario(s.conf.ScenarioFile); err != nil {
+			return err
+		}
+	}
+
+	// The step to stop at, kept across retries so failed steps do not count
+	lastStep := -1
+	retries := 0
+	for {
+		sim, err := s.loadSimulation()
+		if err != nil {
+			return err
+		}
+		sim.Scenario = scenario
+		if s.metrics != nil {
+			sim.Metrics = s.metrics
+		}
+		if s.tracing != nil {
+			sim.Tracer = s.tracing.Tracer()
+		}
+		sim.Observer = recorder
+		if api != nil {
+			api.Attach(sim)
+			sim.Observer = server.StepObservers{api, recorder}
+		}
+
+		startStep := sim.Step
+		if lastStep < 0 {
+			lastStep = math.MaxInt
+			if steps > 0 {
+				lastStep = startStep + steps
+			}
+		}
+
+		if !until.IsZero() {
+			err = sim.RunUntil(ctx, until)
+		} else {
+			err = sim.Run(ctx, lastStep-startStep)
+		}
+		if err == nil {
+			s.log.Info("simulation_done", slog.String("type", "simulation"), slog.Int("step", sim.Step))
+			return nil
+		}
+		if ctx.Err() != nil {
+			s.log.Info("simulation_stopped",
+				slog.String("type", "simulation"),
+				slog.Int("step", sim.Step),
+				slog.Bool("over_budget", s.usage.OverBudget()),
+			)
+			return stopCause(ctx)
+		}
+
+		// Only consecutive failures of the same step count towards the retries
+		if sim.Step != startStep {
+			retries = 0
+		}
+		retries += 1
+		if retries > s.conf.StepRetries {
+			return fmt.Errorf("could not run simulation, step failed %d times: %w", retries, err)
+		}
+
+		s.log.Warn("step_retry",
+			slog.String("type", "step"),
+			slog.String("phase", "retry"),
+			slog.Int("step", sim.Step),
+			slog.Int("attempt", retries),
+			slog.Any("err", err),
+		)
+
+		// The failed step may have changed the simulation in memory, so it is reloaded from storage before retrying
+		select {
+		case <-ctx.Done():
+			return stopCause(ctx)
+		case <-time.After(time.Duration(retries) * time.Second):
+		}
+	}
+}
+
+// stopCause returns why the run stopped once ctx is done, stopping the run on purpose is not an error
+// unless it ran out of budget.
+func stopCause(ctx context.Context) error {
+	if cause := context.Cause(ctx); errors.Is(cause, errOverBudget) {
+		return cause
+	}
+	return nil
+}
+
+func interviewCommand(fs *flag.FlagSet) func(ctx context.Context, s *session, args []string) error {
+	return func(ctx context.Context, s *session, args []string) error {
+		if len(args) != 1 {
+			return errors.New("interview takes the name of a persona")
+		}
+
+		sim, err := s.loadSimulation()
+		if err != nil {
+			return err
+		}
+		return interview(ctx, sim, args[0])
+	}
+}
+
+func whisperCommand(fs *flag.FlagSet) func(ctx context.Context, s *session, args []string) error {
+	return func(ctx context.Context, s *session, args []string) error {
+		if len(args) < 2 {
+			return errors.New("whisper takes the name of a persona and the thought to plant")
+		}
+
+		sim, err := s.loadSimulation()
+		if err != nil {
+			return err
+		}
+		return sim.Whisper(ctx, args[0], strings.Join(args[1:], " "))
+	}
+}
+
+func forkCommand(fs *flag.FlagSet) func(ctx context.Context, s *session, args []string) error {
+	return func(ctx context.Context, s *session, args []string) error {
+		if len(args) != 2 {
+			return errors.New("fork takes the name of the new simulation and a step")
+		}
+		step, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("invalid step %q: %w", args[1], err)
+		}
+		if filepath.Base(args[0]) != args[0] {
+			return fmt.Errorf("invalid simulation name %q", args[0])
+		}
+
+		if s.db != nil {
+			return s.db.Fork(step, filepath.Join(filepath.Dir(s.conf.SQLiteFile), args[0]+".sqlite"))
+		}
+		return s.fileStorage().Fork(step, args[0])
+	}
+}
+
+func rewindCommand(fs *flag.FlagSet) func(ctx context.Context, s *session, args []string) error {
+	return func(ctx context.Context, s *session, args []string) error {
+		if len(args) != 1 {
+			return errors.New("rewind takes a step")
+		}
+		step, err := strconv.Atoi(args[0])
+		if err != nil {
+			return fmt.Errorf("invalid step %q: %w", args[0], err)
+		}
+
+		if s.db != nil {
+			return s.db.Rewind(step)
+		}
+		return s.fileStorage().Rewind(step)
+	}
+}
+
+func importCommand(fs *flag.FlagSet) func(ctx context.Context, s *session, args []string) error {
+	return func(ctx context.Context, s *session, args []string) error {
+		if len(args) != 1 {
+			return errors.New("import takes a simulation folder")
+		}
+		if s.db == nil {
+			return errors.New("import needs --storage sqlite")
+		}
+
+		return s.db.Import(args[0], s.conf.MazeDir, s.log)
+	}
+}
+
+func exportCommand(fs *flag.FlagSet) func(ctx context.Context, s *session, args []string) error {
+	return func(ctx context.Context, s *session, args []string) error {
+		if len(args) != 1 {
+			return errors.New("export takes a simulation folder")
+		}
+		if s.db == nil {
+			return errors.New("export needs --storage sqlite")
+		}
+
+		folder := filepath.Clean(args[0])
+		return s.db.Export(&simulationloader.FileStorage{
+			SimulationsFolder: filepath.Dir(folder),
+			Simulation:        filepath.Base(folder),
+		}, s.conf.MazeDir, s.log)
+	}
+}
+
+func inspectCommand(fs *flag.FlagSet) func(ctx context.Context, s *session, args []string) error {
+	return func(ctx context.Context, s *session, args []string) error {
+		if len(args) != 0 {
+			return fmt.Errorf("inspect takes no arguments, got: %q", args)
+		}
+
+		sim, err := s.loadSimulation()
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("simulation: %s\n", s.conf.SimulationName)
+		if sim.ForkedSim != "" {
+			fmt.Printf("forked from: %s\n", sim.ForkedSim)
+		}
+		fmt.Printf("maze: %s\n", sim.Maze.Name())
+		fmt.Printf("step: %d\n", sim.Step)
+		fmt.Printf("time: %s\n", sim.CurrentTime.Format(simulationloader.CurrentTimeFormat))
+
+		for _, name := range slices.Sorted(maps.Keys(sim.Personas)) {
+			state := sim.Personas[name].State()
+			associative, _ := sim.Personas[name].Memory()
+
+			counts := map[memory.NodeType]int{}
+			forgotten := 0
+			for _, node := range associative.Nodes() {
+				if node.Forgotten {
+					forgotten += 1
+					continue
+				}
+				counts[node.Type] += 1
+			}
+
+			fmt.Printf("\n%s\n", name)
+			fmt.Printf("  position: (%d, %d)\n", sim.PersonaPositions[name].X, sim.PersonaPositions[name].Y)
+			fmt.Printf("  activity: %s\n", state.ActivityDescription)
+			if state.ChattingWith != "" {
+				fmt.Printf("  chatting with: %s\n", state.ChattingWith)
+			}
+			fmt.Printf("  memories: %d events, %d thoughts, %d chats, %d forgotten\n",
+				counts[memory.NodeTypeEvent], counts[memory.NodeTypeThought], counts[memory.NodeTypeChat], forgotten)
+		}
+
+		return nil
+	}
+}
+
+func newCommand(fs *flag.FlagSet) func(ctx context.Context, s *session, args []string) error {
+	return func(ctx context.Context, s *session, args []string) error {
+		if len(args) != 2 {
+			return errors.New("new takes the name of the new simulation and a spec file")
+		}
+		if s.conf.SimulationMaze == "" {
+			return errors.New("new needs --maze")
+		}
+
+		spec, err := simulationloader.LoadSimulationSpec(args[1])
+		if err != nil {
+			return err
+		}
+
+		storage := &simulationloader.FileStorage{
+			SimulationsFolder: s.conf.SimulationDir,
+			BackupFolder:      s.conf.BackupDir,
+			Simulation:        args[0],
+			Maze:              s.conf.SimulationMaze,
+		}
+		if err := storage.Create(ctx, spec, s.conf.MazeDir, s.embedder, s.cognition, s.log); err != nil {
+			return err
+		}
+		if s.conf.Storage != "sqlite" {
+			return nil
+		}
+
+		// The simulation is created as a folder first, like every other simulation stored in a database
+		db, err := simulationloader.OpenSQLiteStorage(filepath.Join(filepath.Dir(s.conf.SQLiteFile), args[0]+".sqlite"), s.conf.BackupDir, args[0])
+		if err != nil {
+			return fmt.Errorf("could not open database: %w", err)
+		}
+		defer db.Close()
+
+		return db.Import(path.Join(s.conf.SimulationDir, args[0]), s.conf.MazeDir, s.log)
+	}
+}
+
+func validateCommand(fs *flag.FlagSet) func(ctx context.Context, s *session, args []string) error {
+	return func(ctx context.Context, s *session, args []string) error {
+		if len(args) != 0 {
+			return fmt.Errorf("validate takes no arguments, got: %q", args)
+		}
+
+		// The configuration is validated before any command runs, so only the simulation itself is left
+		sim, err := s.loadSimulation()
+		if err != nil {
+			return err
+		}
+
+		if s.conf.ScenarioFile != "" {
+			scenario, err := server.LoadScenario(s.conf.ScenarioFile)
+			if err != nil {
+				return err
+			}
+			if err := scenario.Check(sim); err != nil {
+				return fmt.Errorf("invalid scenario %s: %w", s.conf.ScenarioFile, err)
+			}
+		}
+
+		fmt.Printf("simulation %s is valid, at step %d with %d personas\n", s.conf.SimulationName, sim.Step, len(sim.Personas))
+		return nil
+	}
+}
+
+// interview interviews persona name, until stdin is closed.
+func interview(ctx context.Context, sim *server.Server, name string) error {
+	const interviewer = "Interviewer"
+
+	conversation := []memory.Utterance{}
+	scanner := bufio.NewScanner(os.Stdin)
+	for {
+		fmt.Printf("%s: ", interviewer)
+		if !scanner.Scan() {
+			fmt.Println()
+			return scanner.Err()
+		}
+
+		question := strings.TrimSpace(scanner.Text())
+		if question == "" {
+			continue
+		}
+		conversation = append(conversation, memory.Utterance{Speaker: interviewer, Sentence: question})
+
+		answer, err := sim.Interview(ctx, name, interviewer, conversation)
+		if err != nil {
+			return err
+		}
+		conversation = append(conversation, memory.Utterance{Speaker: name, Sentence: answer})
+
+		fmt.Printf("%s: %s\n", name, answer)
+	}
+}
+
+func logsCommand(fs *flag.FlagSet) func(ctx context.Context, s *session, args []string) error {
+	format := fs.String("format", "text", `output format, "text" or "csv"`)
+	limit := fs.Int("limit", 0, "maximum amount of rows to print, 0 prints all rows")
+	persona := fs.String("persona", "", "only show the activities of this persona")
+	focalPoint := fs.String("focal-point", "", "show the retrievals for focal points containing this text, needed by the retrieval report")
+
+	return func(ctx context.Context, s *session, args []string) error {
+		if len(args) != 1 && len(args) != 2 {
+			return errors.New("logs takes a report and optionally the id or folder of a run")
+		}
+
+		dir := ""
+		if len(args) == 2 {
+			dir = args[1]
+			// A run id is looked up in the logs of the simulation, anything else is taken as a folder
+			if _, err := os.Stat(dir); err != nil {
+				dir = path.Join(s.conf.LogDir, s.conf.SimulationName, args[1])
+			}
+		} else {
+			var err error
+			if dir, err = latestRun(path.Join(s.conf.LogDir, s.conf.SimulationName)); err != nil {
+				return err
+			}
+		}
+
+		records, err := loganalysis.Read(dir)
+		if err != nil {
+			return err
+		}
+
+		var table loganalysis.Table
+		switch args[0] {
+		case "steps":
+			table = loganalysis.Steps(records)
+		case "prompts":
+			table = loganalysis.Prompts(records)
+		case "retries":
+			table = loganalysis.Retries(records)
+		case "activities":
+			table = loganalysis.Activities(records, *persona)
+		case "retrieval":
+			if *focalPoint == "" {
+				return errors.New("the retrieval report needs --focal-point")
+			}
+			table = loganalysis.Retrievals(records, *focalPoint)
+		default:
+			return fmt.Errorf("unknown report %q, expected steps, prompts, retries, activities or retrieval", args[0])
+		}
+		table = table.Limit(*limit)
+
+		switch *format {
+		case "text":
+			return table.WriteText(os.Stdout)
+		case "csv":
+			return table.WriteCSV(os.Stdout)
+		default:
+			return fmt.Errorf("unknown format %q, expected \"text\" or \"csv\"", *format)
+		}
+	}
+}
+
+// latestRun returns the folder of the latest run in dir, run ids start with the time the run started so they sort by it.
+func latestRun(dir string) (string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", fmt.Errorf("could not read runs: %w", err)
+	}
+
+	latest := ""
+	for _, entry := range entries {
+		if entry.IsDir() && entry.Name() > latest {
+			latest = entry.Name()
+		}
+	}
+	if latest == "" {
+		return "", fmt.Errorf("no runs in %s", dir)
+	}
+
+	return path.Join(dir, latest), nil
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLogsWithoutSimulation(t *testing.T) {
+	logs := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(logs, "test", "run-1"), 0o755); err != nil {
+		t.Fatalf("could not create run: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(logs, "test", "run-1", "events.jsonl"), nil, 0o644); err != nil {
+		t.Fatalf("could not write logs: %v", err)
+	}
+
+	// Only the logs are on this machine
+	missing := filepath.Join(t.TempDir(), "missing")
+	t.Setenv("SIMULATION_DIR", missing)
+	t.Setenv("MAZE_DIR", missing)
+	t.Setenv("BACKUP_DIR", missing)
+	t.Setenv("LOG_DIR", logs)
+	t.Setenv("SIMULATION_NAME", "test")
+	t.Setenv("SIMULATION_MAZE", "the_ville")
+
+	if err := execute(context.Background(), []string{"logs", "steps"}); err != nil {
+		t.Fatalf("could not report on the logs: %v", err)
+	}
+
+	// Commands using the simulation still need its folders
+	if err := execute(context.Background(), []string{"inspect"}); err == nil {
+		t.Fatalf("Expected inspect to fail without the simulation folders")
+	}
+}
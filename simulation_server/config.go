@@ -0,0 +1,450 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/fvdveen/generative_agents/simulation_server/llm/cassette"
+	"github.com/fvdveen/generative_agents/simulation_server/llm/openai"
+	"gopkg.in/yaml.v3"
+)
+
+// Config holds the configuration of the simulation server. It is read from the environment, which includes .env,
+// then from the config file and finally from the command line flags, every layer overriding the ones before it.
+type Config struct {
+	SimulationDir string `yaml:"simulation_dir" toml:"simulation_dir"`
+	MazeDir       string `yaml:"maze_dir" toml:"maze_dir"`
+	LogDir        string `yaml:"log_dir" toml:"log_dir"`
+	BackupDir     string `yaml:"backup_dir" toml:"backup_dir"`
+
+	SimulationName string `yaml:"simulation_name" toml:"simulation_name"`
+	SimulationMaze string `yaml:"simulation_maze" toml:"simulation_maze"`
+
+	TextModelURL string `yaml:"text_model_url" toml:"text_model_url"`
+	TextModelKey string `yaml:"text_model_key" toml:"text_model_key"`
+	TextModel    string `yaml:"text_model" toml:"text_model"`
+	// The API the text model is served through, "responses" for the OpenAI Responses API, "chat" for the Chat Completions
+	// API, which is also served by local servers like vLLM and Ollama, or "anthropic" for the Anthropic Messages API
+	TextProvider string `yaml:"text_provider" toml:"text_provider"`
+	// Sends the prompts named by the keys to the model they map to instead of TextModel, using the same provider
+	PromptModels map[string]string `yaml:"prompt_models" toml:"prompt_models"`
+	// Caches the responses to the prompts named by the keys for the duration they map to, like "24h", or forever for "0"
+	PromptCache map[string]string `yaml:"prompt_cache" toml:"prompt_cache"`
+	// The database responses are cached in, shared by all simulations, defaults to <SimulationDir>/prompt_cache.sqlite
+	PromptCacheFile string `yaml:"prompt_cache_file" toml:"prompt_cache_file"`
+
+	EmbeddingURL   string `yaml:"embedding_url" toml:"embedding_url"`
+	EmbeddingKey   string `yaml:"embedding_key" toml:"embedding_key"`
+	EmbeddingModel string `yaml:"embedding_model" toml:"embedding_model"`
+	// The database generated embeddings are cached in, shared by all simulations, defaults to <SimulationDir>/embedding_cache.sqlite
+	EmbeddingCache string `yaml:"embedding_cache" toml:"embedding_cache"`
+
+	BackupInterval int `yaml:"backup_interval" toml:"backup_interval"`
+	// How many times a failed step is retried, after reloading the simulation from storage, before giving up
+	StepRetries int `yaml:"step_retries" toml:"step_retries"`
+	// How many personas are moved concurrently
+	Workers int `yaml:"workers" toml:"workers"`
+	// Whether personas avoid walking through each other
+	AvoidCollisions bool `yaml:"avoid_collisions" toml:"avoid_collisions"`
+
+	// Where the simulation is stored, either "file" for the reverie folder layout or "sqlite" for a single SQLite database
+	Storage string `yaml:"storage" toml:"storage"`
+	// The database used when Storage is "sqlite", defaults to <SimulationDir>/<SimulationName>.sqlite
+	SQLiteFile string `yaml:"sqlite_file" toml:"sqlite_file"`
+
+	// Address to serve the control API on, the API is disabled when empty
+	APIAddr string `yaml:"api_addr" toml:"api_addr"`
+	// Address to serve Prometheus metrics on at /metrics, no metrics are recorded when empty
+	MetricsAddr string `yaml:"metrics_addr" toml:"metrics_addr"`
+	// Where the OpenTelemetry spans of a run are exported to, "stdout", "file" to write them to TraceFile or empty to disable tracing
+	TraceExporter string `yaml:"trace_exporter" toml:"trace_exporter"`
+	// The file spans are written to when TraceExporter is "file", defaults to traces.jsonl in the log folder of the run
+	TraceFile string `yaml:"trace_file" toml:"trace_file"`
+
+	// Which implementation to use for cognition and embeddings, either "openai" or "fake"
+	Backend string `yaml:"backend" toml:"backend"`
+
+	// Record all LLM calls to, or replay them from CassetteFile, either "record", "replay" or empty to disable
+	CassetteMode string `yaml:"cassette_mode" toml:"cassette_mode"`
+	CassetteFile string `yaml:"cassette_file" toml:"cassette_file"`
+
+	// YAML or JSON file with the scenario applied whilst running the simulation, no scenario is used when empty
+	ScenarioFile string `yaml:"scenario_file" toml:"scenario_file"`
+
+	// How many US dollars a run may spend on language models before the simulation is paused, 0 for no limit.
+	// Without the API the simulation cannot be resumed, so the run stops with an error instead.
+	BudgetUSD float64 `yaml:"budget_usd" toml:"budget_usd"`
+}
+
+// envReader reads configuration from environment variables, collecting every invalid value instead of stopping at the first.
+type envReader struct {
+	errs []error
+}
+
+// int reads an integer from the environment variable key, returning def if it is not set.
+func (r *envReader) int(key string, def int) int {
+	str := os.Getenv(key)
+	if str == "" {
+		return def
+	}
+
+	i, err := strconv.Atoi(str)
+	if err != nil {
+		r.errs = append(r.errs, fmt.Errorf("could not convert %s=%q to int: %w", key, str, err))
+		return def
+	}
+
+	return i
+}
+
+// bool reads a boolean from the environment variable key, returning def if it is not set.
+func (r *envReader) bool(key string, def bool) bool {
+	str := os.Getenv(key)
+	if str == "" {
+		return def
+	}
+
+	b, err := strconv.ParseBool(str)
+	if err != nil {
+		r.errs = append(r.errs, fmt.Errorf("could not convert %s=%q to bool: %w", key, str, err))
+		return def
+	}
+
+	return b
+}
+
+// float reads a floating point number from the environment variable key, returning def if it is not set.
+func (r *envReader) float(key string, def float64) float64 {
+	str := os.Getenv(key)
+	if str == "" {
+		return def
+	}
+
+	f, err := strconv.ParseFloat(str, 64)
+	if err != nil {
+		r.errs = append(r.errs, fmt.Errorf("could not convert %s=%q to float: %w", key, str, err))
+		return def
+	}
+
+	return f
+}
+
+// mapping reads a comma separated list of key=value pairs from the environment variable key, returning nil if it is not set.
+func (r *envReader) mapping(key string) map[string]string {
+	str := os.Getenv(key)
+	if str == "" {
+		return nil
+	}
+
+	var m map[string]string
+	if err := (*promptMap)(&m).Set(str); err != nil {
+		r.errs = append(r.errs, fmt.Errorf("could not parse %s=%q: %w", key, str, err))
+		return nil
+	}
+
+	return m
+}
+
+// envConfig reads the configuration from the environment.
+func envConfig() (Config, error) {
+	var env envReader
+
+	conf := Config{
+		SimulationDir: os.Getenv("SIMULATION_DIR"),
+		MazeDir:       os.Getenv("MAZE_DIR"),
+		LogDir:        os.Getenv("LOG_DIR"),
+		BackupDir:     os.Getenv("BACKUP_DIR"),
+
+		SimulationName: os.Getenv("SIMULATION_NAME"),
+		SimulationMaze: os.Getenv("SIMULATION_MAZE"),
+
+		TextModelURL: os.Getenv("TEXT_MODEL_URL"),
+		TextModelKey: os.Getenv("TEXT_MODEL_KEY"),
+		TextModel:    os.Getenv("TEXT_MODEL_LLM"),
+		TextProvider: os.Getenv("TEXT_MODEL_PROVIDER"),
+		PromptModels: env.mapping("PROMPT_MODELS"),
+
+		PromptCache:     env.mapping("PROMPT_CACHE"),
+		PromptCacheFile: os.Getenv("PROMPT_CACHE_FILE"),
+
+		EmbeddingKey:   os.Getenv("EMBEDDING_KEY"),
+		EmbeddingURL:   os.Getenv("EMBEDDING_URL"),
+		EmbeddingModel: os.Getenv("EMBEDDING_MODEL"),
+		EmbeddingCache: os.Getenv("EMBEDDING_CACHE"),
+
+		BackupInterval: env.int("BACKUP_INTERVAL", 100),
+		StepRetries:    env.int("STEP_RETRIES", 5),
+		Workers:        env.int("WORKERS", 1),
+
+		AvoidCollisions: env.bool("AVOID_COLLISIONS", false),
+
+		Storage:    os.Getenv("STORAGE"),
+		SQLiteFile: os.Getenv("SQLITE_FILE"),
+
+		APIAddr:     os.Getenv("API_ADDR"),
+		MetricsAddr: os.Getenv("METRICS_ADDR"),
+
+		TraceExporter: os.Getenv("TRACE_EXPORTER"),
+		TraceFile:     os.Getenv("TRACE_FILE"),
+
+		Backend: os.Getenv("LLM_BACKEND"),
+
+		CassetteMode: os.Getenv("CASSETTE_MODE"),
+		CassetteFile: os.Getenv("CASSETTE_FILE"),
+
+		ScenarioFile: os.Getenv("SCENARIO_FILE"),
+
+		BudgetUSD: env.float("BUDGET_USD", 0),
+	}
+
+	return conf, errors.Join(env.errs...)
+}
+
+// loadFile overrides the configuration with the settings in file, settings missing from the file are left as they are.
+// The format is picked by the extension of the file, either YAML or TOML.
+func (conf *Config) loadFile(file string) error {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("could not read config file: %w", err)
+	}
+
+	switch filepath.Ext(file) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(content, conf)
+	case ".toml":
+		err = toml.Unmarshal(content, conf)
+	default:
+		return fmt.Errorf("unknown config file format %q, expected .yaml, .yml or .toml", filepath.Ext(file))
+	}
+	if err != nil {
+		return fmt.Errorf("could not parse config file %s: %w", file, err)
+	}
+
+	return nil
+}
+
+// promptMap is a flag mapping prompts to a value, like a model, it is set with a comma separated list of name=value
+// pairs and can be repeated, every time adding to the mapping.
+type promptMap map[string]string
+
+func (m *promptMap) String() string {
+	if m == nil {
+		return ""
+	}
+
+	pairs := []string{}
+	for _, name := range slices.Sorted(maps.Keys(*m)) {
+		pairs = append(pairs, name+"="+(*m)[name])
+	}
+
+	return strings.Join(pairs, ",")
+}
+
+func (m *promptMap) Set(value string) error {
+	if *m == nil {
+		*m = promptMap{}
+	}
+
+	for _, pair := range strings.Split(value, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || name == "" || value == "" {
+			return fmt.Errorf("expected <prompt>=<value>, got: %q", pair)
+		}
+		(*m)[name] = value
+	}
+
+	return nil
+}
+
+// bindFlags registers a flag for every setting that makes sense to change for a single run, the current values are the defaults.
+// API keys can only be set in the environment or the config file, so they do not end up in the shell history.
+func (conf *Config) bindFlags(fs *flag.FlagSet) {
+	fs.StringVar(&conf.SimulationDir, "simulation-dir", conf.SimulationDir, "folder containing the simulations")
+	fs.StringVar(&conf.MazeDir, "maze-dir", conf.MazeDir, "folder containing the mazes")
+	fs.StringVar(&conf.LogDir, "log-dir", conf.LogDir, "folder the logs of every run are written to")
+	fs.StringVar(&conf.BackupDir, "backup-dir", conf.BackupDir, "folder backups are written to")
+	fs.StringVar(&conf.SimulationName, "simulation", conf.SimulationName, "name of the simulation")
+	fs.StringVar(&conf.SimulationMaze, "maze", conf.SimulationMaze, "name of the maze the simulation takes place in")
+	fs.StringVar(&conf.TextModelURL, "text-model-url", conf.TextModelURL, "URL of the API serving the text model")
+	fs.StringVar(&conf.TextModel, "text-model", conf.TextModel, "text model used for cognition")
+	fs.StringVar(&conf.TextProvider, "text-provider", conf.TextProvider, `API the text model is served through, "responses", "chat" or "anthropic"`)
+	fs.Var((*promptMap)(&conf.PromptModels), "prompt-model", "send a prompt to another model than the text model, as <prompt>=<model>, can be repeated")
+	fs.Var((*promptMap)(&conf.PromptCache), "prompt-cache", "cache the responses to a prompt, as <prompt>=<ttl> with a ttl like 24h or 0 to keep them forever, can be repeated")
+	fs.StringVar(&conf.PromptCacheFile, "prompt-cache-file", conf.PromptCacheFile, "database responses to prompts are cached in")
+	fs.StringVar(&conf.EmbeddingURL, "embedding-url", conf.EmbeddingURL, "URL of the API serving the embedding model")
+	fs.StringVar(&conf.EmbeddingModel, "embedding-model", conf.EmbeddingModel, "model used for embeddings")
+	fs.StringVar(&conf.EmbeddingCache, "embedding-cache", conf.EmbeddingCache, "database generated embeddings are cached in")
+	fs.IntVar(&conf.BackupInterval, "backup-interval", conf.BackupInterval, "amount of steps between backups")
+	fs.IntVar(&conf.StepRetries, "step-retries", conf.StepRetries, "how often a failed step is retried")
+	fs.IntVar(&conf.Workers, "workers", conf.Workers, "amount of personas moved concurrently")
+	fs.BoolVar(&conf.AvoidCollisions, "avoid-collisions", conf.AvoidCollisions, "whether personas avoid walking through each other")
+	fs.StringVar(&conf.Storage, "storage", conf.Storage, `where the simulation is stored, "file" or "sqlite"`)
+	fs.StringVar(&conf.SQLiteFile, "sqlite-file", conf.SQLiteFile, "database the simulation is stored in when using sqlite storage")
+	fs.StringVar(&conf.APIAddr, "api-addr", conf.APIAddr, "address to serve the control API on")
+	fs.StringVar(&conf.MetricsAddr, "metrics-addr", conf.MetricsAddr, "address to serve Prometheus metrics on")
+	fs.StringVar(&conf.TraceExporter, "trace-exporter", conf.TraceExporter, `where spans are exported to, "stdout" or "file"`)
+	fs.StringVar(&conf.TraceFile, "trace-file", conf.TraceFile, "file spans are written to when using the file trace exporter")
+	fs.StringVar(&conf.Backend, "backend", conf.Backend, `language model backend, "openai" or "fake"`)
+	fs.StringVar(&conf.CassetteMode, "cassette-mode", conf.CassetteMode, `"record" or "replay" language model calls`)
+	fs.StringVar(&conf.CassetteFile, "cassette-file", conf.CassetteFile, "file language model calls are recorded to or replayed from")
+	fs.StringVar(&conf.ScenarioFile, "scenario", conf.ScenarioFile, "YAML or JSON scenario applied whilst running the simulation")
+	fs.Float64Var(&conf.BudgetUSD, "budget", conf.BudgetUSD, "US dollars a run may spend on language models before it is paused, or stopped without the API, 0 for no limit")
+}
+
+// setDefaults fills in the settings whose defaults depend on other settings.
+func (conf *Config) setDefaults() {
+	if conf.SQLiteFile == "" {
+		conf.SQLiteFile = path.Join(conf.SimulationDir, conf.SimulationName+".sqlite")
+	}
+	if conf.EmbeddingCache == "" {
+		conf.EmbeddingCache = path.Join(conf.SimulationDir, "embedding_cache.sqlite")
+	}
+	if conf.PromptCacheFile == "" {
+		conf.PromptCacheFile = path.Join(conf.SimulationDir, "prompt_cache.sqlite")
+	}
+}
+
+// promptCacheTTLs parses how long the responses to every prompt in PromptCache are cached.
+func (conf Config) promptCacheTTLs() (map[string]time.Duration, error) {
+	var errs []error
+	ttls := map[string]time.Duration{}
+	for _, name := range slices.Sorted(maps.Keys(conf.PromptCache)) {
+		if !slices.Contains(openai.PromptNames(), name) {
+			errs = append(errs, fmt.Errorf("prompt_cache contains unknown prompt %q", name))
+			continue
+		}
+
+		ttl, err := time.ParseDuration(conf.PromptCache[name])
+		if err != nil || ttl < 0 {
+			errs = append(errs, fmt.Errorf("prompt_cache contains invalid duration %q for prompt %q", conf.PromptCache[name], name))
+			continue
+		}
+		ttls[name] = ttl
+	}
+
+	return ttls, errors.Join(errs...)
+}
+
+// validate checks whether the configuration can be used to run a simulation, it returns every problem it finds.
+// The language model backend is only checked if models is true, and the simulation, maze and backup folders only if
+// folders is true.
+func (conf Config) validate(models bool, folders bool) error {
+	var errs []error
+	dir := func(name, dir string) {
+		if dir == "" {
+			errs = append(errs, fmt.Errorf("%s is not set", name))
+		} else if info, err := os.Stat(dir); err != nil {
+			errs = append(errs, fmt.Errorf("%s %s does not exist", name, dir))
+		} else if !info.IsDir() {
+			errs = append(errs, fmt.Errorf("%s %s is not a directory", name, dir))
+		}
+	}
+
+	if folders {
+		dir("simulation_dir", conf.SimulationDir)
+		dir("maze_dir", conf.MazeDir)
+		if conf.SimulationMaze != "" && conf.MazeDir != "" {
+			dir("simulation_maze", path.Join(conf.MazeDir, conf.SimulationMaze))
+		}
+		if conf.BackupDir == "" {
+			errs = append(errs, errors.New("backup_dir is not set"))
+		}
+	}
+	if conf.SimulationName == "" {
+		errs = append(errs, errors.New("simulation_name is not set"))
+	}
+	if conf.LogDir == "" {
+		errs = append(errs, errors.New("log_dir is not set"))
+	}
+
+	if conf.BackupInterval < 1 {
+		errs = append(errs, fmt.Errorf("backup_interval must be at least 1, got: %d", conf.BackupInterval))
+	}
+	if conf.StepRetries < 0 {
+		errs = append(errs, fmt.Errorf("step_retries must not be negative, got: %d", conf.StepRetries))
+	}
+
+	if conf.MetricsAddr != "" && conf.MetricsAddr == conf.APIAddr {
+		errs = append(errs, fmt.Errorf("metrics_addr and api_addr must differ, both are %s", conf.APIAddr))
+	}
+
+	switch conf.Storage {
+	case "", "file", "sqlite":
+	default:
+		errs = append(errs, fmt.Errorf("unknown storage %q, expected \"file\" or \"sqlite\"", conf.Storage))
+	}
+
+	if !models {
+		return errors.Join(errs...)
+	}
+
+	if conf.BudgetUSD < 0 {
+		errs = append(errs, fmt.Errorf("budget_usd must not be negative, got: %v", conf.BudgetUSD))
+	}
+
+	switch conf.TraceExporter {
+	case "", "stdout", "file":
+	default:
+		errs = append(errs, fmt.Errorf("unknown trace_exporter %q, expected \"stdout\" or \"file\"", conf.TraceExporter))
+	}
+
+	switch conf.Backend {
+	case "", "openai":
+		switch conf.TextProvider {
+		case "", "responses", "chat":
+		case "anthropic":
+			// The default text model is an OpenAI model
+			if conf.TextModel == "" {
+				errs = append(errs, errors.New("text_model is not set, it is needed when using the anthropic text_provider"))
+			}
+		default:
+			errs = append(errs, fmt.Errorf("unknown text_provider %q, expected \"responses\", \"chat\" or \"anthropic\"", conf.TextProvider))
+		}
+		for _, name := range slices.Sorted(maps.Keys(conf.PromptModels)) {
+			if !slices.Contains(openai.PromptNames(), name) {
+				errs = append(errs, fmt.Errorf("prompt_models contains unknown prompt %q", name))
+			}
+		}
+		if _, err := conf.promptCacheTTLs(); err != nil {
+			errs = append(errs, err)
+		}
+
+		if conf.TextModelKey == "" && conf.TextModelURL == "" {
+			errs = append(errs, errors.New("text_model_key is not set"))
+		}
+		if conf.EmbeddingKey == "" && conf.EmbeddingURL == "" {
+			errs = append(errs, errors.New("embedding_key is not set"))
+		}
+		// The default models only exist on the OpenAI API, other APIs need to be told which model to use
+		if conf.TextModelURL != "" && conf.TextModel == "" {
+			errs = append(errs, errors.New("text_model is not set, it is needed when using text_model_url"))
+		}
+		if conf.EmbeddingURL != "" && conf.EmbeddingModel == "" {
+			errs = append(errs, errors.New("embedding_model is not set, it is needed when using embedding_url"))
+		}
+	case "fake":
+	default:
+		errs = append(errs, fmt.Errorf("unknown LLM backend %q, expected \"openai\" or \"fake\"", conf.Backend))
+	}
+
+	if conf.CassetteMode != "" {
+		if _, err := cassette.ParseMode(conf.CassetteMode); err != nil {
+			errs = append(errs, err)
+		}
+		if conf.CassetteFile == "" {
+			errs = append(errs, errors.New("cassette_file is not set"))
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,252 @@
+// Package accounting keeps track of the tokens, cost and latency of the calls made to language models.
+package accounting
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The name calls are accounted under when the persona or phase that made them is not known
+const Unattributed = "other"
+
+type contextKey int
+
+const (
+	personaKey contextKey = iota
+	phaseKey
+)
+
+// WithPersona attributes the calls made with ctx to persona.
+func WithPersona(ctx context.Context, persona string) context.Context {
+	return context.WithValue(ctx, personaKey, persona)
+}
+
+// WithPhase attributes the calls made with ctx to phase, like "perceive" or "reflect".
+func WithPhase(ctx context.Context, phase string) context.Context {
+	return context.WithValue(ctx, phaseKey, phase)
+}
+
+func attribution(ctx context.Context, key contextKey) string {
+	if v, ok := ctx.Value(key).(string); ok && v != "" {
+		return v
+	}
+
+	return Unattributed
+}
+
+// Price is the price of a model in US dollars per million tokens.
+type Price struct {
+	Input  float64 `json:"input"`
+	Output float64 `json:"output"`
+}
+
+// DefaultPrices are the list prices of the OpenAI and Anthropic models, models with a suffix like a date use the price
+// of their base model.
+var DefaultPrices = map[string]Price{
+	"gpt-5":                  {Input: 1.25, Output: 10},
+	"gpt-5-mini":             {Input: 0.25, Output: 2},
+	"gpt-5-nano":             {Input: 0.05, Output: 0.40},
+	"gpt-4.1":                {Input: 2, Output: 8},
+	"gpt-4.1-mini":           {Input: 0.40, Output: 1.60},
+	"gpt-4.1-nano":           {Input: 0.10, Output: 0.40},
+	"gpt-4o":                 {Input: 2.50, Output: 10},
+	"gpt-4o-mini":            {Input: 0.15, Output: 0.60},
+	"text-embedding-3-small": {Input: 0.02},
+	"text-embedding-3-large": {Input: 0.13},
+	"text-embedding-ada-002": {Input: 0.10},
+	"claude-opus-4":          {Input: 15, Output: 75},
+	"claude-sonnet-4":        {Input: 3, Output: 15},
+	"claude-haiku-4-5":       {Input: 1, Output: 5},
+	"claude-3-5-haiku":       {Input: 0.80, Output: 4},
+}
+
+// Call describes a single call to a model, including all of its retries.
+type Call struct {
+	Model  string
+	Prompt string
+	// How many requests were sent, every request after the first is a retry
+	Attempts     int
+	InputTokens  int
+	OutputTokens int
+	Latency      time.Duration
+	Failed       bool
+	// Whether the response came from the prompt cache, the tokens are then those the cached response cost originally
+	Cached bool
+}
+
+// Totals sums up a number of calls.
+type Totals struct {
+	Calls        int           `json:"calls"`
+	Retries      int           `json:"retries"`
+	Failures     int           `json:"failures"`
+	InputTokens  int           `json:"input_tokens"`
+	OutputTokens int           `json:"output_tokens"`
+	Cost         float64       `json:"cost_usd"`
+	Latency      time.Duration `json:"latency"`
+	// Calls answered from the prompt cache and what they would have cost, these are not part of the other totals
+	CacheHits int     `json:"cache_hits"`
+	SavedCost float64 `json:"saved_cost_usd"`
+}
+
+func (t *Totals) add(call Call, cost float64) {
+	if call.Cached {
+		t.CacheHits += 1
+		t.SavedCost += cost
+		return
+	}
+
+	t.Calls += 1
+	t.Retries += max(call.Attempts-1, 0)
+	if call.Failed {
+		t.Failures += 1
+	}
+	t.InputTokens += call.InputTokens
+	t.OutputTokens += call.OutputTokens
+	t.Cost += cost
+	t.Latency += call.Latency
+}
+
+// Sub returns the difference between t and earlier totals u.
+func (t Totals) Sub(u Totals) Totals {
+	return Totals{
+		Calls:        t.Calls - u.Calls,
+		Retries:      t.Retries - u.Retries,
+		Failures:     t.Failures - u.Failures,
+		InputTokens:  t.InputTokens - u.InputTokens,
+		OutputTokens: t.OutputTokens - u.OutputTokens,
+		Cost:         t.Cost - u.Cost,
+		Latency:      t.Latency - u.Latency,
+		CacheHits:    t.CacheHits - u.CacheHits,
+		SavedCost:    t.SavedCost - u.SavedCost,
+	}
+}
+
+// Report breaks down the totals of all calls by persona, phase and prompt.
+type Report struct {
+	Total    Totals            `json:"total"`
+	Personas map[string]Totals `json:"personas"`
+	Phases   map[string]Totals `json:"phases"`
+	Prompts  map[string]Totals `json:"prompts"`
+}
+
+func addTo(m map[string]Totals, key string, call Call, cost float64) {
+	t := m[key]
+	t.add(call, cost)
+	m[key] = t
+}
+
+type TrackerOpt func(t *Tracker)
+
+// WithPrices replaces the prices used to estimate the cost of calls.
+func WithPrices(prices map[string]Price) TrackerOpt {
+	return func(t *Tracker) {
+		t.prices = prices
+	}
+}
+
+// WithBudget sets how many US dollars may be spent, a budget of 0 is unlimited.
+func WithBudget(budget float64) TrackerOpt {
+	return func(t *Tracker) {
+		t.budget = budget
+	}
+}
+
+func WithLogger(logger *slog.Logger) TrackerOpt {
+	return func(t *Tracker) {
+		t.logger = logger
+	}
+}
+
+// Tracker sums up the calls made to models, it is safe for concurrent use.
+type Tracker struct {
+	prices map[string]Price
+	budget float64
+	logger *slog.Logger
+
+	mu     sync.Mutex
+	report Report
+	// Models without a price, which are only warned about once
+	unpriced map[string]bool
+}
+
+func NewTracker(opts ...TrackerOpt) *Tracker {
+	t := &Tracker{
+		prices: DefaultPrices,
+		logger: slog.Default(),
+		report: Report{
+			Personas: map[string]Totals{},
+			Phases:   map[string]Totals{},
+			Prompts:  map[string]Totals{},
+		},
+		unpriced: map[string]bool{},
+	}
+
+	for _, opt := range opts {
+		opt(t)
+	}
+
+	return t
+}
+
+// price looks up the price of model, falling back to the longest model name model starts with, t.mu must be held.
+func (t *Tracker) price(model string) (Price, bool) {
+	if p, ok := t.prices[model]; ok {
+		return p, true
+	}
+
+	var best string
+	for name := range t.prices {
+		if strings.HasPrefix(model, name+"-") && len(name) > len(best) {
+			best = name
+		}
+	}
+
+	p, ok := t.prices[best]
+	return p, ok
+}
+
+// Record adds call to the totals of the persona and phase ctx is attributed to, and the prompt of the call.
+func (t *Tracker) Record(ctx context.Context, call Call) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	price, ok := t.price(call.Model)
+	if !ok && !t.unpriced[call.Model] {
+		t.unpriced[call.Model] = true
+		t.logger.Warn("usage_unknown_price",
+			slog.String("type", "usage"),
+			slog.String("model", call.Model),
+		)
+	}
+	cost := (float64(call.InputTokens)*price.Input + float64(call.OutputTokens)*price.Output) / 1_000_000
+
+	t.report.Total.add(call, cost)
+	addTo(t.report.Personas, attribution(ctx, personaKey), call, cost)
+	addTo(t.report.Phases, attribution(ctx, phaseKey), call, cost)
+	addTo(t.report.Prompts, call.Prompt, call, cost)
+}
+
+// Report returns the totals of all calls recorded so far.
+func (t *Tracker) Report() Report {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return Report{
+		Total:    t.report.Total,
+		Personas: maps.Clone(t.report.Personas),
+		Phases:   maps.Clone(t.report.Phases),
+		Prompts:  maps.Clone(t.report.Prompts),
+	}
+}
+
+// OverBudget reports whether the estimated cost of all calls exceeds the budget.
+func (t *Tracker) OverBudget() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.budget > 0 && t.report.Total.Cost > t.budget
+}
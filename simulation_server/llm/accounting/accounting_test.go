@@ -0,0 +1,61 @@
+package accounting_test
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/fvdveen/generative_agents/simulation_server/llm/accounting"
+)
+
+func TestTracker(t *testing.T) {
+	tracker := accounting.NewTracker(
+		accounting.WithPrices(map[string]accounting.Price{"model": {Input: 1, Output: 10}}),
+		accounting.WithBudget(0.5),
+		accounting.WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))),
+	)
+
+	ctx := accounting.WithPhase(accounting.WithPersona(context.Background(), "Isabella Rodriguez"), "reflect")
+	tracker.Record(ctx, accounting.Call{Model: "model-2025-08-07", Prompt: "insight", Attempts: 3, InputTokens: 100_000, OutputTokens: 10_000, Latency: time.Second})
+	tracker.Record(context.Background(), accounting.Call{Model: "unknown", Prompt: "insight", Attempts: 1, InputTokens: 100_000, Failed: true})
+
+	report := tracker.Report()
+	want := accounting.Totals{Calls: 2, Retries: 2, Failures: 1, InputTokens: 200_000, OutputTokens: 10_000, Cost: 0.2, Latency: time.Second}
+	got := report.Total
+	if math.Abs(got.Cost-want.Cost) > 1e-9 {
+		t.Errorf("got a total cost of %v, want %v", got.Cost, want.Cost)
+	}
+	got.Cost, want.Cost = 0, 0
+	if got != want {
+		t.Errorf("got total %+v, want %+v", got, want)
+	}
+	if got := report.Personas["Isabella Rodriguez"].Calls; got != 1 {
+		t.Errorf("got %d calls for Isabella Rodriguez, want 1", got)
+	}
+	if got := report.Phases[accounting.Unattributed].InputTokens; got != 100_000 {
+		t.Errorf("got %d input tokens for unattributed calls, want 100000", got)
+	}
+	if got := report.Prompts["insight"].Calls; got != 2 {
+		t.Errorf("got %d calls for prompt insight, want 2", got)
+	}
+	if tracker.OverBudget() {
+		t.Errorf("tracker is over budget at $%.2f, budget is $0.50", report.Total.Cost)
+	}
+
+	// Cached responses are free, they only count as hits
+	tracker.Record(ctx, accounting.Call{Model: "model", Prompt: "insight", Attempts: 1, OutputTokens: 50_000, Cached: true})
+	if got := tracker.Report().Total; got.Calls != 2 || got.CacheHits != 1 || math.Abs(got.SavedCost-0.5) > 1e-9 {
+		t.Errorf("got %d calls, %d cache hits and $%.2f saved, want 2 calls, 1 cache hit and $0.50 saved", got.Calls, got.CacheHits, got.SavedCost)
+	}
+	if tracker.OverBudget() {
+		t.Errorf("tracker is over budget after a cache hit at $%.2f, budget is $0.50", tracker.Report().Total.Cost)
+	}
+
+	tracker.Record(ctx, accounting.Call{Model: "model", Prompt: "insight", Attempts: 1, OutputTokens: 50_000})
+	if !tracker.OverBudget() {
+		t.Errorf("tracker is not over budget at $%.2f, budget is $0.50", tracker.Report().Total.Cost)
+	}
+}
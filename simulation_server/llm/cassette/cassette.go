@@ -0,0 +1,331 @@
+// Package cassette records the calls made to a llm.Cognition and llm.Embedder so they can be replayed later.
+//
+// A cassette is a JSONL file with one entry per call, containing the (reduced) inputs of the call,
+// the prompts that were sent to the model together with their raw responses, and the decoded output.
+// When replaying, calls are matched by their method and inputs and the stored output is returned,
+// so a simulation can be re-run without network access and produce exactly the same results.
+package cassette
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"sync"
+
+	"github.com/fvdveen/generative_agents/simulation_server/llm"
+)
+
+type Mode string
+
+const (
+	// ModeRecord forwards every call to the wrapped cognition and appends it to the cassette.
+	ModeRecord Mode = "record"
+	// ModeReplay answers every call from the cassette.
+	ModeReplay Mode = "replay"
+)
+
+func ParseMode(s string) (Mode, error) {
+	switch Mode(s) {
+	case ModeRecord, ModeReplay:
+		return Mode(s), nil
+	default:
+		return "", fmt.Errorf("unknown cassette mode %q", s)
+	}
+}
+
+// Prompt is a single prompt that was sent to the model during a call.
+type Prompt struct {
+	Name string `json:"name"`
+	// Hash of the prompt text, makes it easy to spot differences between cassettes
+	Hash     string `json:"hash"`
+	Text     string `json:"text"`
+	Response string `json:"response"`
+}
+
+// Entry is a single recorded call to a Cognition or Embedder.
+type Entry struct {
+	Seq     int             `json:"seq"`
+	Method  string          `json:"method"`
+	Inputs  json.RawMessage `json:"inputs"`
+	Prompts []Prompt        `json:"prompts,omitempty"`
+	Output  json.RawMessage `json:"output"`
+
+	// The amount of prompts that have been replayed for this entry
+	replayed int
+}
+
+// entryKey is the context key of the entry of the call that is currently in progress
+type entryKey struct{}
+
+func entryFromContext(ctx context.Context) *Entry {
+	e, _ := ctx.Value(entryKey{}).(*Entry)
+	return e
+}
+
+func (e *Entry) key() string {
+	return e.Method + "\x00" + string(e.Inputs)
+}
+
+// MismatchError is returned when a call or prompt does not match the contents of the cassette.
+type MismatchError struct {
+	Seq    int
+	Method string
+	What   string
+	Diff   string
+}
+
+func (e *MismatchError) Error() string {
+	if e.Diff == "" {
+		return fmt.Sprintf("cassette mismatch in %s (entry %d): %s", e.Method, e.Seq, e.What)
+	}
+	return fmt.Sprintf("cassette mismatch in %s (entry %d): %s:\n%s", e.Method, e.Seq, e.What, e.Diff)
+}
+
+type Cassette struct {
+	mode Mode
+
+	mu   sync.Mutex
+	file *os.File
+	seq  int
+
+	// Recorded entries that have not been replayed yet, by key and by method
+	byKey    map[string][]*Entry
+	byMethod map[string][]*Entry
+}
+
+// Open opens the cassette at path, when recording the file is truncated.
+func Open(path string, mode Mode) (*Cassette, error) {
+	c := &Cassette{
+		mode:     mode,
+		byKey:    map[string][]*Entry{},
+		byMethod: map[string][]*Entry{},
+	}
+
+	switch mode {
+	case ModeRecord:
+		f, err := os.Create(path)
+		if err != nil {
+			return nil, fmt.Errorf("could not create cassette: %w", err)
+		}
+		c.file = f
+	case ModeReplay:
+		if err := c.load(path); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown cassette mode %q", mode)
+	}
+
+	return c, nil
+}
+
+func (c *Cassette) load(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("could not open cassette: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	// Entries contain full prompts and embeddings, so lines can get long
+	scanner.Buffer(make([]byte, 0, 1024*1024), 64*1024*1024)
+
+	line := 0
+	for scanner.Scan() {
+		line += 1
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		entry := &Entry{}
+		if err := json.Unmarshal(scanner.Bytes(), entry); err != nil {
+			return fmt.Errorf("could not parse cassette line %d: %w", line, err)
+		}
+
+		c.byKey[entry.key()] = append(c.byKey[entry.key()], entry)
+		c.byMethod[entry.Method] = append(c.byMethod[entry.Method], entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("could not read cassette: %w", err)
+	}
+
+	return nil
+}
+
+func (c *Cassette) Mode() Mode {
+	return c.mode
+}
+
+func (c *Cassette) Close() error {
+	if c.file == nil {
+		return nil
+	}
+	return c.file.Close()
+}
+
+// write appends the entry to the cassette file.
+// Every entry is written (and flushed) on its own so a crashed simulation still leaves a usable cassette.
+func (c *Cassette) write(e *Entry) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.seq += 1
+	e.Seq = c.seq
+
+	b, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("could not marshal cassette entry: %w", err)
+	}
+
+	if _, err := c.file.Write(append(b, '\n')); err != nil {
+		return fmt.Errorf("could not write cassette entry: %w", err)
+	}
+
+	return nil
+}
+
+// next removes and returns the first unreplayed entry matching method and inputs.
+func (c *Cassette) next(method string, inputs json.RawMessage) (*Entry, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := (&Entry{Method: method, Inputs: inputs}).key()
+
+	queue := c.byKey[key]
+	if len(queue) == 0 {
+		pending := c.byMethod[method]
+		if len(pending) == 0 {
+			return nil, &MismatchError{Method: method, What: "no recorded calls left for this method"}
+		}
+
+		return nil, &MismatchError{
+			Seq:    pending[0].Seq,
+			Method: method,
+			What:   "inputs differ from the next recorded call",
+			Diff:   diffJSON(pending[0].Inputs, inputs),
+		}
+	}
+
+	entry := queue[0]
+	c.byKey[key] = queue[1:]
+
+	pending := c.byMethod[method]
+	for i, e := range pending {
+		if e == entry {
+			c.byMethod[method] = append(pending[:i:i], pending[i+1:]...)
+			break
+		}
+	}
+
+	return entry, nil
+}
+
+// BeforePrompt implements llm.PromptHook.
+func (c *Cassette) BeforePrompt(ctx context.Context, name, prompt string) (string, bool, error) {
+	if c.mode != ModeReplay {
+		return "", false, nil
+	}
+
+	entry := entryFromContext(ctx)
+	if entry == nil {
+		return "", false, errors.New("prompt sent outside of a cassette call")
+	}
+
+	if entry.replayed >= len(entry.Prompts) {
+		return "", false, &MismatchError{Seq: entry.Seq, Method: entry.Method, What: fmt.Sprintf("unexpected prompt %s, only %d prompts were recorded", name, len(entry.Prompts))}
+	}
+
+	recorded := entry.Prompts[entry.replayed]
+	entry.replayed += 1
+
+	if recorded.Name != name {
+		return "", false, &MismatchError{Seq: entry.Seq, Method: entry.Method, What: fmt.Sprintf("expected prompt %s, got %s", recorded.Name, name)}
+	}
+	if recorded.Text != prompt {
+		return "", false, &MismatchError{Seq: entry.Seq, Method: entry.Method, What: fmt.Sprintf("prompt %s differs", name), Diff: diffLines(recorded.Text, prompt)}
+	}
+
+	return recorded.Response, true, nil
+}
+
+// AfterPrompt implements llm.PromptHook.
+func (c *Cassette) AfterPrompt(ctx context.Context, name, prompt, response string) {
+	entry := entryFromContext(ctx)
+	if c.mode != ModeRecord || entry == nil {
+		return
+	}
+
+	entry.Prompts = append(entry.Prompts, Prompt{
+		Name:     name,
+		Hash:     hashString(prompt),
+		Text:     prompt,
+		Response: response,
+	})
+}
+
+func hashString(s string) string {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(s))
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+// Wrap returns a Cognition and Embedder that go through the cassette.
+// When replaying, cognition and embedder may be nil, in that case the outputs are returned without
+// reproducing the prompts. If they are set they are called as well, so every prompt they render is compared
+// with the recorded prompt.
+func (c *Cassette) Wrap(cognition llm.Cognition, embedder llm.Embedder) (llm.Cognition, llm.Embedder) {
+	return &recorder{c: c, cognition: cognition}, &embedRecorder{c: c, embedder: embedder}
+}
+
+// call runs a single call through the cassette.
+// The entry of the call is passed to fn through its context, so the prompts it sends can be matched with the call.
+// fn is only called during replay when live is set.
+func call[T any](ctx context.Context, c *Cassette, method string, inputs any, live bool, fn func(ctx context.Context) (T, error)) (T, error) {
+	var output T
+
+	in, err := json.Marshal(inputs)
+	if err != nil {
+		return output, fmt.Errorf("could not marshal %s inputs: %w", method, err)
+	}
+
+	switch c.mode {
+	case ModeRecord:
+		entry := &Entry{Method: method, Inputs: in}
+
+		output, err := fn(context.WithValue(ctx, entryKey{}, entry))
+		if err != nil {
+			// Failed calls are not recorded, when replaying they would fail differently anyway
+			return output, err
+		}
+
+		if entry.Output, err = json.Marshal(output); err != nil {
+			return output, fmt.Errorf("could not marshal %s output: %w", method, err)
+		}
+
+		return output, c.write(entry)
+	default:
+		entry, err := c.next(method, in)
+		if err != nil {
+			return output, err
+		}
+
+		if live {
+			if _, err := fn(context.WithValue(ctx, entryKey{}, entry)); err != nil {
+				return output, err
+			}
+			if entry.replayed != len(entry.Prompts) {
+				return output, &MismatchError{Seq: entry.Seq, Method: method, What: fmt.Sprintf("only %d of %d recorded prompts were sent", entry.replayed, len(entry.Prompts))}
+			}
+		}
+
+		if err := json.Unmarshal(entry.Output, &output); err != nil {
+			return output, fmt.Errorf("could not unmarshal %s output of entry %d: %w", method, entry.Seq, err)
+		}
+
+		return output, nil
+	}
+}
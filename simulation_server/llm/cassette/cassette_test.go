@@ -0,0 +1,133 @@
+package cassette_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fvdveen/generative_agents/simulation_server/agent"
+	"github.com/fvdveen/generative_agents/simulation_server/llm"
+	"github.com/fvdveen/generative_agents/simulation_server/llm/cassette"
+	"github.com/fvdveen/generative_agents/simulation_server/llm/fake"
+	"github.com/fvdveen/generative_agents/simulation_server/memory"
+)
+
+func makePersona(c llm.Cognition, e llm.Embedder) *agent.Persona {
+	state := agent.State{
+		FullName:    "Isabella Rodriguez",
+		Lifestyle:   "Isabella Rodriguez goes to bed around 11pm, awakes up around 6am.",
+		CurrentTime: time.Date(2023, time.February, 13, 0, 0, 0, 0, time.UTC),
+	}
+
+	assoc := memory.NewAssociative(map[string][]float64{}, map[string]int{}, map[string]int{})
+	return agent.New(state.FullName, assoc, memory.NewSpatial(), state, e, c)
+}
+
+func TestRecordReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.jsonl")
+
+	rec, err := cassette.Open(path, cassette.ModeRecord)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := fake.New()
+	c, e := rec.Wrap(f, f)
+	p := makePersona(c, e)
+
+	ctx := context.Background()
+	wakeUp, err := c.GenerateWakeUpHour(ctx, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	schedule, err := c.GenerateHourlySchedule(ctx, p, wakeUp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	embedding, err := e.GenerateEmbedding(ctx, "Isabella is making coffee")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rec.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	play, err := cassette.Open(path, cassette.ModeReplay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, e = play.Wrap(nil, nil)
+	p = makePersona(c, e)
+
+	if got, err := c.GenerateWakeUpHour(ctx, p); err != nil || !got.Equal(wakeUp) {
+		t.Fatalf("Replayed wake up hour differs, got: %v (%v), want: %v", got, err, wakeUp)
+	}
+	if got, err := c.GenerateHourlySchedule(ctx, p, wakeUp); err != nil || !reflect.DeepEqual(got, schedule) {
+		t.Fatalf("Replayed schedule differs, got: %v (%v), want: %v", got, err, schedule)
+	}
+	if got, err := e.GenerateEmbedding(ctx, "Isabella is making coffee"); err != nil || !reflect.DeepEqual(got, embedding) {
+		t.Fatalf("Replayed embedding differs (%v)", err)
+	}
+
+	if _, err := c.GenerateWakeUpHour(ctx, p); err == nil {
+		t.Fatalf("Replaying more calls than were recorded should fail")
+	}
+}
+
+// prompting renders a prompt for GenerateActivityPronunciato, like a model backed Cognition would
+type prompting struct {
+	*fake.Client
+	hook   llm.PromptHook
+	prompt string
+}
+
+func (c *prompting) GenerateActivityPronunciato(ctx context.Context, p llm.Persona, activity string) (string, error) {
+	prompt := c.prompt + "\nActivity: " + activity
+	resp, ok, err := c.hook.BeforePrompt(ctx, "generate_pronunciatio_v1", prompt)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		if resp, err = c.Client.GenerateActivityPronunciato(ctx, p, activity); err != nil {
+			return "", err
+		}
+		c.hook.AfterPrompt(ctx, "generate_pronunciatio_v1", prompt, resp)
+	}
+	return resp, nil
+}
+
+func TestReplayPromptMismatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cassette.jsonl")
+
+	rec, err := cassette.Open(path, cassette.ModeRecord)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inner := &prompting{Client: fake.New(), hook: rec, prompt: "Convert the activity to emojis."}
+	c, e := rec.Wrap(inner, inner)
+	if _, err := c.GenerateActivityPronunciato(context.Background(), makePersona(c, e), "sleeping"); err != nil {
+		t.Fatal(err)
+	}
+	if err := rec.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	play, err := cassette.Open(path, cassette.ModeReplay)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inner = &prompting{Client: fake.New(), hook: play, prompt: "Convert the activity to two emojis."}
+	c, e = play.Wrap(inner, inner)
+
+	_, err = c.GenerateActivityPronunciato(context.Background(), makePersona(c, e), "sleeping")
+	var mismatch *cassette.MismatchError
+	if !errors.As(err, &mismatch) {
+		t.Fatalf("Expected a mismatch error, got: %v", err)
+	}
+	if !strings.Contains(mismatch.Diff, "- Convert the activity to emojis.") || !strings.Contains(mismatch.Diff, "+ Convert the activity to two emojis.") {
+		t.Fatalf("Diff does not contain the changed line:\n%s", mismatch.Diff)
+	}
+}
@@ -0,0 +1,58 @@
+package cassette
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+// diffLines returns a line based diff between want and got, lines prefixed with - are only in want and + only in got.
+func diffLines(want, got string) string {
+	a := strings.Split(want, "\n")
+	b := strings.Split(got, "\n")
+
+	// Longest common subsequence table, lcs[i][j] is the LCS length of a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var out strings.Builder
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			i, j = i+1, j+1
+		case j < len(b) && (i == len(a) || lcs[i][j+1] >= lcs[i+1][j]):
+			out.WriteString("+ " + b[j] + "\n")
+			j += 1
+		default:
+			out.WriteString("- " + a[i] + "\n")
+			i += 1
+		}
+	}
+
+	return out.String()
+}
+
+// diffJSON indents both JSON documents and diffs them line by line.
+func diffJSON(want, got json.RawMessage) string {
+	indent := func(raw json.RawMessage) string {
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, raw, "", "  "); err != nil {
+			return string(raw)
+		}
+		return buf.String()
+	}
+
+	return diffLines(indent(want), indent(got))
+}
@@ -0,0 +1,336 @@
+package cassette
+
+import (
+	"context"
+	"time"
+
+	"github.com/fvdveen/generative_agents/simulation_server/llm"
+	"github.com/fvdveen/generative_agents/simulation_server/memory"
+)
+
+// persona is what is stored of a llm.Persona in the inputs of a call.
+// The full state of a persona is far too large to store for every call, the prompts contain the relevant parts anyway.
+type persona struct {
+	Name        string
+	CurrentTime time.Time
+}
+
+func ref(p llm.Persona) persona {
+	return persona{Name: p.Name(), CurrentTime: p.CurrentTime()}
+}
+
+// utterance wraps the two outputs of GenerateOneUtterance
+type utterance struct {
+	Utterance memory.Utterance
+	End       bool
+}
+
+// dayInterpretation wraps the two outputs of GenerateDayInterpretation
+type dayInterpretation struct {
+	Feelings string
+	Wants    string
+}
+
+type recorder struct {
+	c         *Cassette
+	cognition llm.Cognition
+}
+
+func (r *recorder) live() bool {
+	return r.cognition != nil
+}
+
+func (r *recorder) GenerateImportanceScore(ctx context.Context, p llm.Persona, nt memory.NodeType, description string) (int, error) {
+	inputs := map[string]any{"persona": ref(p), "node_type": nt, "description": description}
+	return call(ctx, r.c, "GenerateImportanceScore", inputs, r.live(), func(ctx context.Context) (int, error) {
+		return r.cognition.GenerateImportanceScore(ctx, p, nt, description)
+	})
+}
+
+func (r *recorder) GenerateImportanceScoreChat(ctx context.Context, p llm.Persona, transcript []memory.Utterance, description string) (int, error) {
+	inputs := map[string]any{"persona": ref(p), "transcript": transcript, "description": description}
+	return call(ctx, r.c, "GenerateImportanceScoreChat", inputs, r.live(), func(ctx context.Context) (int, error) {
+		return r.cognition.GenerateImportanceScoreChat(ctx, p, transcript, description)
+	})
+}
+
+func (r *recorder) GenerateValenceScore(ctx context.Context, p llm.Persona, nt memory.NodeType, description string) (int, error) {
+	inputs := map[string]any{"persona": ref(p), "node_type": nt, "description": description}
+	return call(ctx, r.c, "GenerateValenceScore", inputs, r.live(), func(ctx context.Context) (int, error) {
+		return r.cognition.GenerateValenceScore(ctx, p, nt, description)
+	})
+}
+
+func (r *recorder) GenerateValenceScoreChat(ctx context.Context, p llm.Persona, transcript []memory.Utterance, description string) (int, error) {
+	inputs := map[string]any{"persona": ref(p), "transcript": transcript, "description": description}
+	return call(ctx, r.c, "GenerateValenceScoreChat", inputs, r.live(), func(ctx context.Context) (int, error) {
+		return r.cognition.GenerateValenceScoreChat(ctx, p, transcript, description)
+	})
+}
+
+func (r *recorder) GenerateWakeUpHour(ctx context.Context, p llm.Persona) (time.Time, error) {
+	inputs := map[string]any{"persona": ref(p)}
+	return call(ctx, r.c, "GenerateWakeUpHour", inputs, r.live(), func(ctx context.Context) (time.Time, error) {
+		return r.cognition.GenerateWakeUpHour(ctx, p)
+	})
+}
+
+func (r *recorder) GenerateDailyPlan(ctx context.Context, p llm.Persona, wakeUpHour time.Time) ([]string, error) {
+	inputs := map[string]any{"persona": ref(p), "wake_up_hour": wakeUpHour}
+	return call(ctx, r.c, "GenerateDailyPlan", inputs, r.live(), func(ctx context.Context) ([]string, error) {
+		return r.cognition.GenerateDailyPlan(ctx, p, wakeUpHour)
+	})
+}
+
+func (r *recorder) GenerateHourlySchedule(ctx context.Context, p llm.Persona, wakeUpHour time.Time) ([]llm.Plan, error) {
+	inputs := map[string]any{"persona": ref(p), "wake_up_hour": wakeUpHour}
+	return call(ctx, r.c, "GenerateHourlySchedule", inputs, r.live(), func(ctx context.Context) ([]llm.Plan, error) {
+		return r.cognition.GenerateHourlySchedule(ctx, p, wakeUpHour)
+	})
+}
+
+func (r *recorder) GeneratePlanDecomposition(ctx context.Context, p llm.Persona, plan llm.Plan) ([]llm.Plan, error) {
+	inputs := map[string]any{"persona": ref(p), "plan": plan}
+	return call(ctx, r.c, "GeneratePlanDecomposition", inputs, r.live(), func(ctx context.Context) ([]llm.Plan, error) {
+		return r.cognition.GeneratePlanDecomposition(ctx, p, plan)
+	})
+}
+
+func (r *recorder) GenerateReactionScheduleUpdate(ctx context.Context, p llm.Persona, insertedActivity llm.Plan, startTime, endTime time.Time) ([]llm.Plan, error) {
+	inputs := map[string]any{"persona": ref(p), "inserted_activity": insertedActivity, "start_time": startTime, "end_time": endTime}
+	return call(ctx, r.c, "GenerateReactionScheduleUpdate", inputs, r.live(), func(ctx context.Context) ([]llm.Plan, error) {
+		return r.cognition.GenerateReactionScheduleUpdate(ctx, p, insertedActivity, startTime, endTime)
+	})
+}
+
+func (r *recorder) GenerateActivitySector(ctx context.Context, p llm.Persona, maze llm.Maze, activity string, world string) (string, error) {
+	inputs := map[string]any{"persona": ref(p), "activity": activity, "world": world}
+	return call(ctx, r.c, "GenerateActivitySector", inputs, r.live(), func(ctx context.Context) (string, error) {
+		return r.cognition.GenerateActivitySector(ctx, p, maze, activity, world)
+	})
+}
+
+func (r *recorder) GenerateActivityArena(ctx context.Context, p llm.Persona, maze llm.Maze, activity string, world string, sector string) (string, error) {
+	inputs := map[string]any{"persona": ref(p), "activity": activity, "world": world, "sector": sector}
+	return call(ctx, r.c, "GenerateActivityArena", inputs, r.live(), func(ctx context.Context) (string, error) {
+		return r.cognition.GenerateActivityArena(ctx, p, maze, activity, world, sector)
+	})
+}
+
+func (r *recorder) GenerateActivityObject(ctx context.Context, p llm.Persona, maze llm.Maze, activity string, path memory.Path) (string, error) {
+	inputs := map[string]any{"persona": ref(p), "activity": activity, "path": path}
+	return call(ctx, r.c, "GenerateActivityObject", inputs, r.live(), func(ctx context.Context) (string, error) {
+		return r.cognition.GenerateActivityObject(ctx, p, maze, activity, path)
+	})
+}
+
+func (r *recorder) GenerateActivityPronunciato(ctx context.Context, p llm.Persona, activity string) (string, error) {
+	inputs := map[string]any{"persona": ref(p), "activity": activity}
+	return call(ctx, r.c, "GenerateActivityPronunciato", inputs, r.live(), func(ctx context.Context) (string, error) {
+		return r.cognition.GenerateActivityPronunciato(ctx, p, activity)
+	})
+}
+
+func (r *recorder) GenerateActivitySPO(ctx context.Context, p llm.Persona, activity string) (memory.SPO, error) {
+	inputs := map[string]any{"persona": ref(p), "activity": activity}
+	return call(ctx, r.c, "GenerateActivitySPO", inputs, r.live(), func(ctx context.Context) (memory.SPO, error) {
+		return r.cognition.GenerateActivitySPO(ctx, p, activity)
+	})
+}
+
+func (r *recorder) GenerateActivityObjectDescription(ctx context.Context, p llm.Persona, object string, activity string) (string, error) {
+	inputs := map[string]any{"persona": ref(p), "object": object, "activity": activity}
+	return call(ctx, r.c, "GenerateActivityObjectDescription", inputs, r.live(), func(ctx context.Context) (string, error) {
+		return r.cognition.GenerateActivityObjectDescription(ctx, p, object, activity)
+	})
+}
+
+func (r *recorder) GenerateActivityObjectPronunciato(ctx context.Context, p llm.Persona, activityObjectDescription string) (string, error) {
+	inputs := map[string]any{"persona": ref(p), "description": activityObjectDescription}
+	return call(ctx, r.c, "GenerateActivityObjectPronunciato", inputs, r.live(), func(ctx context.Context) (string, error) {
+		return r.cognition.GenerateActivityObjectPronunciato(ctx, p, activityObjectDescription)
+	})
+}
+
+func (r *recorder) GenerateActivityObjectSPO(ctx context.Context, p llm.Persona, object string, activityObjectDescription string) (memory.SPO, error) {
+	inputs := map[string]any{"persona": ref(p), "object": object, "description": activityObjectDescription}
+	return call(ctx, r.c, "GenerateActivityObjectSPO", inputs, r.live(), func(ctx context.Context) (memory.SPO, error) {
+		return r.cognition.GenerateActivityObjectSPO(ctx, p, object, activityObjectDescription)
+	})
+}
+
+func (r *recorder) GenerateDecideToTalk(ctx context.Context, init, target llm.Persona, events, thoughts []memory.NodeId) (bool, error) {
+	inputs := map[string]any{"init": ref(init), "target": ref(target), "events": events, "thoughts": thoughts}
+	return call(ctx, r.c, "GenerateDecideToTalk", inputs, r.live(), func(ctx context.Context) (bool, error) {
+		return r.cognition.GenerateDecideToTalk(ctx, init, target, events, thoughts)
+	})
+}
+
+func (r *recorder) GenerateDecideToWait(ctx context.Context, init, target llm.Persona, events, thoughts []memory.NodeId) (bool, error) {
+	inputs := map[string]any{"init": ref(init), "target": ref(target), "events": events, "thoughts": thoughts}
+	return call(ctx, r.c, "GenerateDecideToWait", inputs, r.live(), func(ctx context.Context) (bool, error) {
+		return r.cognition.GenerateDecideToWait(ctx, init, target, events, thoughts)
+	})
+}
+
+func (r *recorder) GenerateConversationSummary(ctx context.Context, p llm.Persona, conversation []memory.Utterance) (string, error) {
+	inputs := map[string]any{"persona": ref(p), "conversation": conversation}
+	return call(ctx, r.c, "GenerateConversationSummary", inputs, r.live(), func(ctx context.Context) (string, error) {
+		return r.cognition.GenerateConversationSummary(ctx, p, conversation)
+	})
+}
+
+func (r *recorder) GeneratePlanningThoughtAfterConversation(ctx context.Context, p llm.Persona, conversation []memory.Utterance) (string, error) {
+	inputs := map[string]any{"persona": ref(p), "conversation": conversation}
+	return call(ctx, r.c, "GeneratePlanningThoughtAfterConversation", inputs, r.live(), func(ctx context.Context) (string, error) {
+		return r.cognition.GeneratePlanningThoughtAfterConversation(ctx, p, conversation)
+	})
+}
+
+func (r *recorder) GenerateMemoAfterConversation(ctx context.Context, p llm.Persona, conversation []memory.Utterance) (string, error) {
+	inputs := map[string]any{"persona": ref(p), "conversation": conversation}
+	return call(ctx, r.c, "GenerateMemoAfterConversation", inputs, r.live(), func(ctx context.Context) (string, error) {
+		return r.cognition.GenerateMemoAfterConversation(ctx, p, conversation)
+	})
+}
+
+func (r *recorder) GenerateRelationshipSummary(ctx context.Context, init, target llm.Persona, memories []memory.NodeId) (string, error) {
+	inputs := map[string]any{"init": ref(init), "target": ref(target), "memories": memories}
+	return call(ctx, r.c, "GenerateRelationshipSummary", inputs, r.live(), func(ctx context.Context) (string, error) {
+		return r.cognition.GenerateRelationshipSummary(ctx, init, target, memories)
+	})
+}
+
+func (r *recorder) GenerateOneUtterance(ctx context.Context, init, target llm.Persona, maze llm.Maze, currentChat []memory.Utterance, relevant []memory.NodeId, relationship string) (memory.Utterance, bool, error) {
+	inputs := map[string]any{"init": ref(init), "target": ref(target), "current_chat": currentChat, "relevant": relevant, "relationship": relationship}
+	out, err := call(ctx, r.c, "GenerateOneUtterance", inputs, r.live(), func(ctx context.Context) (utterance, error) {
+		utt, end, err := r.cognition.GenerateOneUtterance(ctx, init, target, maze, currentChat, relevant, relationship)
+		return utterance{Utterance: utt, End: end}, err
+	})
+	return out.Utterance, out.End, err
+}
+
+func (r *recorder) GenerateFocalPoints(ctx context.Context, p llm.Persona, statements []memory.NodeId, numFocalPoints int) ([]string, error) {
+	inputs := map[string]any{"persona": ref(p), "statements": statements, "num_focal_points": numFocalPoints}
+	return call(ctx, r.c, "GenerateFocalPoints", inputs, r.live(), func(ctx context.Context) ([]string, error) {
+		return r.cognition.GenerateFocalPoints(ctx, p, statements, numFocalPoints)
+	})
+}
+
+func (r *recorder) GenerateInsightAndEvidence(ctx context.Context, p llm.Persona, nodes []memory.NodeId, insightCount int) (map[string][]memory.NodeId, error) {
+	inputs := map[string]any{"persona": ref(p), "nodes": nodes, "insight_count": insightCount}
+	return call(ctx, r.c, "GenerateInsightAndEvidence", inputs, r.live(), func(ctx context.Context) (map[string][]memory.NodeId, error) {
+		return r.cognition.GenerateInsightAndEvidence(ctx, p, nodes, insightCount)
+	})
+}
+
+func (r *recorder) GeneratePlanningNote(ctx context.Context, p llm.Persona, statements []string) (string, error) {
+	inputs := map[string]any{"persona": ref(p), "statements": statements}
+	return call(ctx, r.c, "GeneratePlanningNote", inputs, r.live(), func(ctx context.Context) (string, error) {
+		return r.cognition.GeneratePlanningNote(ctx, p, statements)
+	})
+}
+
+func (r *recorder) GeneratePlanningFeelings(ctx context.Context, p llm.Persona, statements []string) (string, error) {
+	inputs := map[string]any{"persona": ref(p), "statements": statements}
+	return call(ctx, r.c, "GeneratePlanningFeelings", inputs, r.live(), func(ctx context.Context) (string, error) {
+		return r.cognition.GeneratePlanningFeelings(ctx, p, statements)
+	})
+}
+
+func (r *recorder) GenerateCurrentPlans(ctx context.Context, p llm.Persona, plans, thoughts string) (string, error) {
+	inputs := map[string]any{"persona": ref(p), "plans": plans, "thoughts": thoughts}
+	return call(ctx, r.c, "GenerateCurrentPlans", inputs, r.live(), func(ctx context.Context) (string, error) {
+		return r.cognition.GenerateCurrentPlans(ctx, p, plans, thoughts)
+	})
+}
+
+func (r *recorder) GenerateNewDailyRequirements(ctx context.Context, p llm.Persona) (string, error) {
+	inputs := map[string]any{"persona": ref(p)}
+	return call(ctx, r.c, "GenerateNewDailyRequirements", inputs, r.live(), func(ctx context.Context) (string, error) {
+		return r.cognition.GenerateNewDailyRequirements(ctx, p)
+	})
+}
+
+func (r *recorder) GenerateDaySummary(ctx context.Context, p llm.Persona, eventCount int) ([]string, error) {
+	inputs := map[string]any{"persona": ref(p), "event_count": eventCount}
+	return call(ctx, r.c, "GenerateDaySummary", inputs, r.live(), func(ctx context.Context) ([]string, error) {
+		return r.cognition.GenerateDaySummary(ctx, p, eventCount)
+	})
+}
+
+func (r *recorder) GenerateDayInterpretation(ctx context.Context, p llm.Persona, events []string) (string, string, error) {
+	inputs := map[string]any{"persona": ref(p), "events": events}
+	out, err := call(ctx, r.c, "GenerateDayInterpretation", inputs, r.live(), func(ctx context.Context) (dayInterpretation, error) {
+		feelings, wants, err := r.cognition.GenerateDayInterpretation(ctx, p, events)
+		return dayInterpretation{Feelings: feelings, Wants: wants}, err
+	})
+	return out.Feelings, out.Wants, err
+}
+
+func (r *recorder) GenerateExpandedMemoryDescription(ctx context.Context, p llm.Persona, chat []memory.Utterance, description string) (string, error) {
+	inputs := map[string]any{"persona": ref(p), "chat": chat, "description": description}
+	return call(ctx, r.c, "GenerateExpandedMemoryDescription", inputs, r.live(), func(ctx context.Context) (string, error) {
+		return r.cognition.GenerateExpandedMemoryDescription(ctx, p, chat, description)
+	})
+}
+
+func (r *recorder) GenerateWhisperedThought(ctx context.Context, p llm.Persona, whisper string) (string, error) {
+	inputs := map[string]any{"persona": ref(p), "whisper": whisper}
+	return call(ctx, r.c, "GenerateWhisperedThought", inputs, r.live(), func(ctx context.Context) (string, error) {
+		return r.cognition.GenerateWhisperedThought(ctx, p, whisper)
+	})
+}
+
+func (r *recorder) GenerateInterviewSummary(ctx context.Context, p llm.Persona, question string, memories []memory.NodeId) (string, error) {
+	inputs := map[string]any{"persona": ref(p), "question": question, "memories": memories}
+	return call(ctx, r.c, "GenerateInterviewSummary", inputs, r.live(), func(ctx context.Context) (string, error) {
+		return r.cognition.GenerateInterviewSummary(ctx, p, question, memories)
+	})
+}
+
+func (r *recorder) GenerateInterviewAnswer(ctx context.Context, p llm.Persona, interviewer string, conversation []memory.Utterance, summary string) (string, error) {
+	inputs := map[string]any{"persona": ref(p), "interviewer": interviewer, "conversation": conversation, "summary": summary}
+	return call(ctx, r.c, "GenerateInterviewAnswer", inputs, r.live(), func(ctx context.Context) (string, error) {
+		return r.cognition.GenerateInterviewAnswer(ctx, p, interviewer, conversation, summary)
+	})
+}
+
+type embedRecorder struct {
+	c        *Cassette
+	embedder llm.Embedder
+}
+
+func (r *embedRecorder) GenerateEmbedding(ctx context.Context, str string) ([]float64, error) {
+	return call(ctx, r.c, "GenerateEmbedding", str, r.embedder != nil, func(ctx context.Context) ([]float64, error) {
+		return r.embedder.GenerateEmbedding(ctx, str)
+	})
+}
+
+// GenerateEmbeddings records every embedding as its own GenerateEmbedding call, so replaying does not depend on how
+// the embeddings were batched. When recording the embeddings are still generated with a single request.
+func (r *embedRecorder) GenerateEmbeddings(ctx context.Context, strs []string) ([][]float64, error) {
+	var generated [][]float64
+	if batch, ok := r.embedder.(llm.BatchEmbedder); ok && r.c.mode == ModeRecord {
+		var err error
+		if generated, err = batch.GenerateEmbeddings(ctx, strs); err != nil {
+			return nil, err
+		}
+	}
+
+	embeddings := make([][]float64, 0, len(strs))
+	for i, str := range strs {
+		embedding, err := call(ctx, r.c, "GenerateEmbedding", str, r.embedder != nil, func(ctx context.Context) ([]float64, error) {
+			if generated != nil {
+				return generated[i], nil
+			}
+			return r.embedder.GenerateEmbedding(ctx, str)
+		})
+		if err != nil {
+			return nil, err
+		}
+		embeddings = append(embeddings, embedding)
+	}
+
+	return embeddings, nil
+}
@@ -0,0 +1,147 @@
+// Package embedding reduces the amount of embeddings that have to be requested, by batching and caching them.
+package embedding
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/fvdveen/generative_agents/simulation_server/llm"
+)
+
+// The most strings sent in a single request by default, the OpenAI API accepts up to 2048
+const defaultMaxBatch = 256
+
+type BatcherOpt func(b *Batcher)
+
+// WithMaxBatch sets the most strings sent to the embedder in a single request.
+func WithMaxBatch(n int) BatcherOpt {
+	return func(b *Batcher) {
+		b.maxBatch = n
+	}
+}
+
+// Batcher is an llm.BatchEmbedder that groups requests into a single request to a llm.BatchEmbedder.
+// Requests are sent right away when no request is in flight, requests arriving while one is in flight are sent
+// together once it finishes. The strings of a GenerateEmbeddings call are always sent together, so a persona
+// requests all embeddings of a perceive phase at once and concurrently moving personas share requests.
+type Batcher struct {
+	embedder llm.BatchEmbedder
+	maxBatch int
+
+	mu      sync.Mutex
+	pending []*pendingEmbedding
+	// Whether a goroutine is sending the pending requests
+	sending bool
+}
+
+type pendingEmbedding struct {
+	ctx       context.Context
+	str       string
+	done      chan struct{}
+	embedding []float64
+	err       error
+}
+
+func NewBatcher(embedder llm.BatchEmbedder, opts ...BatcherOpt) *Batcher {
+	b := &Batcher{embedder: embedder, maxBatch: defaultMaxBatch}
+	for _, opt := range opts {
+		opt(b)
+	}
+
+	return b
+}
+
+func (b *Batcher) GenerateEmbedding(ctx context.Context, str string) ([]float64, error) {
+	embeddings, err := b.GenerateEmbeddings(ctx, []string{str})
+	if err != nil {
+		return nil, err
+	}
+
+	return embeddings[0], nil
+}
+
+func (b *Batcher) GenerateEmbeddings(ctx context.Context, strs []string) ([][]float64, error) {
+	reqs := make([]*pendingEmbedding, 0, len(strs))
+	for _, str := range strs {
+		reqs = append(reqs, &pendingEmbedding{ctx: ctx, str: str, done: make(chan struct{})})
+	}
+
+	b.mu.Lock()
+	b.pending = append(b.pending, reqs...)
+	if !b.sending {
+		b.sending = true
+		go b.send()
+	}
+	b.mu.Unlock()
+
+	embeddings := make([][]float64, 0, len(reqs))
+	for _, req := range reqs {
+		select {
+		case <-req.done:
+			if req.err != nil {
+				return nil, req.err
+			}
+			embeddings = append(embeddings, req.embedding)
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	return embeddings, nil
+}
+
+// send sends the pending requests in batches until there are none left.
+func (b *Batcher) send() {
+	for {
+		b.mu.Lock()
+		if len(b.pending) == 0 {
+			b.sending = false
+			b.mu.Unlock()
+			return
+		}
+		n := min(len(b.pending), b.maxBatch)
+		batch := b.pending[:n]
+		b.pending = b.pending[n:]
+		b.mu.Unlock()
+
+		b.sendBatch(batch)
+	}
+}
+
+func (b *Batcher) sendBatch(batch []*pendingEmbedding) {
+	// Identical strings, like the idle objects every persona sees, are only sent once
+	strs := []string{}
+	index := map[string]int{}
+	for _, req := range batch {
+		if req.ctx.Err() != nil {
+			continue
+		}
+		if _, ok := index[req.str]; !ok {
+			index[req.str] = len(strs)
+			strs = append(strs, req.str)
+		}
+	}
+
+	var embeddings [][]float64
+	var err error
+	if len(strs) > 0 {
+		// The requests of the batch may belong to different personas, the batch is sent with the context of the first
+		// request, without its cancellation so the other requests are not cancelled along with it
+		embeddings, err = b.embedder.GenerateEmbeddings(context.WithoutCancel(batch[0].ctx), strs)
+		if err != nil {
+			err = fmt.Errorf("could not generate embeddings: %w", err)
+		}
+	}
+
+	for _, req := range batch {
+		if i, ok := index[req.str]; ok && err == nil {
+			req.embedding = embeddings[i]
+		} else if err != nil {
+			req.err = err
+		} else {
+			req.err = req.ctx.Err()
+		}
+		close(req.done)
+	}
+}
@@ -0,0 +1,151 @@
+package embedding
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/fvdveen/generative_agents/simulation_server/llm"
+	_ "modernc.org/sqlite"
+)
+
+const cacheSchema = `
+CREATE TABLE IF NOT EXISTS embeddings (
+	key TEXT PRIMARY KEY,
+	model TEXT NOT NULL,
+	text TEXT NOT NULL,
+	vector BLOB NOT NULL
+);
+`
+
+// Cache is an llm.Embedder that stores every embedding it generates in a SQLite database, keyed by the model and text,
+// so an embedding is only generated once for all personas, runs and simulations sharing the database.
+type Cache struct {
+	db       *sql.DB
+	model    string
+	embedder llm.Embedder
+}
+
+// OpenCache opens the cache in file, creating it if it does not exist yet. Embeddings missing from the cache are
+// generated by embedder, model is the model embedder uses, as embeddings of different models cannot be mixed.
+func OpenCache(file string, model string, embedder llm.Embedder) (*Cache, error) {
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		return nil, fmt.Errorf("could not create cache folder: %w", err)
+	}
+
+	db, err := sql.Open("sqlite", file+"?_pragma=journal_mode(WAL)&_pragma=synchronous(NORMAL)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, fmt.Errorf("could not open cache: %w", err)
+	}
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(cacheSchema); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("could not create tables: %w", err)
+	}
+
+	return &Cache{db: db, model: model, embedder: embedder}, nil
+}
+
+func (c *Cache) Close() error {
+	return c.db.Close()
+}
+
+// key returns the key of the embedding of str.
+func (c *Cache) key(str string) string {
+	sum := sha256.Sum256([]byte(c.model + "\x00" + str))
+	return hex.EncodeToString(sum[:])
+}
+
+func (c *Cache) GenerateEmbedding(ctx context.Context, str string) ([]float64, error) {
+	embeddings, err := c.GenerateEmbeddings(ctx, []string{str})
+	if err != nil {
+		return nil, err
+	}
+
+	return embeddings[0], nil
+}
+
+// GenerateEmbeddings looks up the embeddings of strs, the missing embeddings are generated with a single request when
+// the embedder of the cache is a llm.BatchEmbedder.
+func (c *Cache) GenerateEmbeddings(ctx context.Context, strs []string) ([][]float64, error) {
+	embeddings := make([][]float64, len(strs))
+	missing := []string{}
+	for i, str := range strs {
+		var vector []byte
+		err := c.db.QueryRowContext(ctx, "SELECT vector FROM embeddings WHERE key = ?", c.key(str)).Scan(&vector)
+		if err == nil {
+			embeddings[i] = DecodeVector(vector)
+		} else if !errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("could not read embedding cache: %w", err)
+		} else if !slices.Contains(missing, str) {
+			missing = append(missing, str)
+		}
+	}
+	if len(missing) == 0 {
+		return embeddings, nil
+	}
+
+	generated, err := c.generate(ctx, missing)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, str := range missing {
+		// Concurrent requests for the same text can both miss the cache, they generate the same embedding
+		if _, err := c.db.ExecContext(ctx, "INSERT OR IGNORE INTO embeddings (key, model, text, vector) VALUES (?, ?, ?, ?)", c.key(str), c.model, str, EncodeVector(generated[i])); err != nil {
+			return nil, fmt.Errorf("could not write embedding cache: %w", err)
+		}
+	}
+	for i, str := range strs {
+		if embeddings[i] == nil {
+			embeddings[i] = generated[slices.Index(missing, str)]
+		}
+	}
+
+	return embeddings, nil
+}
+
+// generate generates the embeddings of strs with the embedder of the cache.
+func (c *Cache) generate(ctx context.Context, strs []string) ([][]float64, error) {
+	if batch, ok := c.embedder.(llm.BatchEmbedder); ok {
+		return batch.GenerateEmbeddings(ctx, strs)
+	}
+
+	embeddings := make([][]float64, 0, len(strs))
+	for _, str := range strs {
+		embedding, err := c.embedder.GenerateEmbedding(ctx, str)
+		if err != nil {
+			return nil, err
+		}
+		embeddings = append(embeddings, embedding)
+	}
+
+	return embeddings, nil
+}
+
+// EncodeVector encodes an embedding as little endian float64s, the way embeddings are stored in SQLite.
+func EncodeVector(vector []float64) []byte {
+	data := make([]byte, 8*len(vector))
+	for i, v := range vector {
+		binary.LittleEndian.PutUint64(data[8*i:], math.Float64bits(v))
+	}
+	return data
+}
+
+// DecodeVector decodes an embedding encoded by EncodeVector.
+func DecodeVector(data []byte) []float64 {
+	vector := make([]float64, len(data)/8)
+	for i := range vector {
+		vector[i] = math.Float64frombits(binary.LittleEndian.Uint64(data[8*i:]))
+	}
+	return vector
+}
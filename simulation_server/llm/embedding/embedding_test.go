@@ -0,0 +1,168 @@
+package embedding
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+)
+
+// countingEmbedder embeds a string as its length and keeps track of the requests it receives.
+type countingEmbedder struct {
+	mu       sync.Mutex
+	requests [][]string
+	// Closed to let the first request finish
+	release chan struct{}
+}
+
+func (e *countingEmbedder) GenerateEmbedding(ctx context.Context, str string) ([]float64, error) {
+	embeddings, err := e.GenerateEmbeddings(ctx, []string{str})
+	if err != nil {
+		return nil, err
+	}
+	return embeddings[0], nil
+}
+
+func (e *countingEmbedder) GenerateEmbeddings(ctx context.Context, strs []string) ([][]float64, error) {
+	e.mu.Lock()
+	e.requests = append(e.requests, strs)
+	first := len(e.requests) == 1
+	e.mu.Unlock()
+
+	if first && e.release != nil {
+		<-e.release
+	}
+
+	embeddings := make([][]float64, len(strs))
+	for i, str := range strs {
+		embeddings[i] = []float64{float64(len(str))}
+	}
+	return embeddings, nil
+}
+
+func TestBatcher(t *testing.T) {
+	embedder := &countingEmbedder{release: make(chan struct{})}
+	batcher := NewBatcher(embedder)
+
+	embed := func(str string, wg *sync.WaitGroup) {
+		defer wg.Done()
+		got, err := batcher.GenerateEmbedding(context.Background(), str)
+		if err != nil {
+			t.Errorf("could not generate embedding: %v", err)
+		} else if got[0] != float64(len(str)) {
+			t.Errorf("Wrong embedding for %q, got: %v", str, got)
+		}
+	}
+
+	// The first request is in flight whilst the others arrive, so they are sent together once it finishes
+	var first, rest sync.WaitGroup
+	first.Add(1)
+	go embed("bed is idle", &first)
+	for len(requests(embedder)) == 0 {
+	}
+	for _, str := range []string{"desk is idle", "bed is idle", "desk is idle", "Isabella Rodriguez is sleeping"} {
+		rest.Add(1)
+		go embed(str, &rest)
+	}
+	for pending(batcher) < 4 {
+	}
+	close(embedder.release)
+	first.Wait()
+	rest.Wait()
+
+	reqs := requests(embedder)
+	if len(reqs) != 2 {
+		t.Fatalf("Wrong amount of requests, got: %d, want: %d (%v)", len(reqs), 2, reqs)
+	}
+	slices.Sort(reqs[1])
+	if want := []string{"Isabella Rodriguez is sleeping", "bed is idle", "desk is idle"}; !slices.Equal(reqs[1], want) {
+		t.Errorf("Wrong batch, got: %q, want: %q", reqs[1], want)
+	}
+}
+
+func requests(e *countingEmbedder) [][]string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return slices.Clone(e.requests)
+}
+
+func pending(b *Batcher) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.pending)
+}
+
+func TestCache(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "cache.sqlite")
+	embedder := &countingEmbedder{}
+
+	cache, err := OpenCache(file, "model", embedder)
+	if err != nil {
+		t.Fatalf("could not open cache: %v", err)
+	}
+	for range 2 {
+		if _, err := cache.GenerateEmbedding(context.Background(), "bed is idle"); err != nil {
+			t.Fatalf("could not generate embedding: %v", err)
+		}
+	}
+	_ = cache.Close()
+
+	cache, err = OpenCache(file, "model", embedder)
+	if err != nil {
+		t.Fatalf("could not reopen cache: %v", err)
+	}
+	got, err := cache.GenerateEmbedding(context.Background(), "bed is idle")
+	if err != nil || got[0] != float64(len("bed is idle")) {
+		t.Fatalf("Wrong cached embedding, got: %v, %v", got, err)
+	}
+	_ = cache.Close()
+	if n := len(requests(embedder)); n != 1 {
+		t.Errorf("Wrong amount of requests, got: %d, want: %d", n, 1)
+	}
+
+	other, err := OpenCache(file, "other model", embedder)
+	if err != nil {
+		t.Fatalf("could not open cache: %v", err)
+	}
+	defer other.Close()
+	if _, err := other.GenerateEmbedding(context.Background(), "bed is idle"); err != nil {
+		t.Fatalf("could not generate embedding: %v", err)
+	}
+	if n := len(requests(embedder)); n != 2 {
+		t.Errorf("Expected the embeddings of another model to be generated again, got %d requests", n)
+	}
+}
+
+func TestCacheBatches(t *testing.T) {
+	embedder := &countingEmbedder{}
+	cache, err := OpenCache(filepath.Join(t.TempDir(), "cache.sqlite"), "model", NewBatcher(embedder))
+	if err != nil {
+		t.Fatalf("could not open cache: %v", err)
+	}
+	defer cache.Close()
+
+	if _, err := cache.GenerateEmbedding(context.Background(), "bed is idle"); err != nil {
+		t.Fatalf("could not generate embedding: %v", err)
+	}
+
+	// A single caller gets the embeddings missing from the cache with a single request
+	strs := []string{"desk is idle", "bed is idle", "Isabella Rodriguez is sleeping", "desk is idle"}
+	got, err := cache.GenerateEmbeddings(context.Background(), strs)
+	if err != nil {
+		t.Fatalf("could not generate embeddings: %v", err)
+	}
+	for i, str := range strs {
+		if got[i][0] != float64(len(str)) {
+			t.Errorf("Wrong embedding for %q, got: %v", str, got[i])
+		}
+	}
+
+	reqs := requests(embedder)
+	if len(reqs) != 2 {
+		t.Fatalf("Wrong amount of requests, got: %d, want: %d (%v)", len(reqs), 2, reqs)
+	}
+	if want := []string{"desk is idle", "Isabella Rodriguez is sleeping"}; !slices.Equal(reqs[1], want) {
+		t.Errorf("Wrong batch, got: %q, want: %q", reqs[1], want)
+	}
+}
@@ -0,0 +1,344 @@
+// Package fake implements llm.Cognition and llm.Embedder without any language model.
+//
+// Every answer is produced by simple rules and a hash of the inputs, so the same
+// simulation state always produces the same answers. This makes it possible to run
+// full simulations in tests, in CI or on a laptop without network access.
+package fake
+
+import (
+	"context"
+	"hash/fnv"
+	"log/slog"
+	"math"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/fvdveen/generative_agents/simulation_server/llm"
+	"github.com/fvdveen/generative_agents/simulation_server/memory"
+)
+
+type ClientOpt func(c *Client)
+
+// WithSeed changes the seed mixed into every hash, different seeds result in different (but still deterministic) choices.
+func WithSeed(seed uint64) ClientOpt {
+	return func(c *Client) {
+		c.seed = seed
+	}
+}
+
+// WithDimensions sets the length of the generated embeddings.
+// The default matches text-embedding-ada-002 so the fake embedder can be mixed with stored embeddings.
+func WithDimensions(n int) ClientOpt {
+	return func(c *Client) {
+		c.dimensions = n
+	}
+}
+
+func WithLogger(logger *slog.Logger) ClientOpt {
+	return func(c *Client) {
+		c.logger = logger
+	}
+}
+
+type Client struct {
+	logger *slog.Logger
+
+	seed       uint64
+	dimensions int
+}
+
+func New(opts ...ClientOpt) *Client {
+	client := &Client{dimensions: 1536, logger: slog.Default()}
+
+	for _, opt := range opts {
+		opt(client)
+	}
+
+	return client
+}
+
+// hash combines the seed with all parts into a single deterministic value.
+func (c *Client) hash(parts ...string) uint64 {
+	h := fnv.New64a()
+
+	var seed [8]byte
+	for i := range seed {
+		seed[i] = byte(c.seed >> (8 * i))
+	}
+	_, _ = h.Write(seed[:])
+
+	for _, part := range parts {
+		_, _ = h.Write([]byte(part))
+		// Separate the parts so ("ab", "c") and ("a", "bc") hash differently
+		_, _ = h.Write([]byte{0})
+	}
+
+	return h.Sum64()
+}
+
+// pick deterministically selects one of the options based off of the hash of the parts.
+func pick[T any](c *Client, options []T, parts ...string) T {
+	return options[c.hash(parts...)%uint64(len(options))]
+}
+
+func (c *Client) log(method string, p llm.Persona) {
+	c.logger.Debug("fake_llm_call",
+		slog.String("type", "llm_call"),
+		slog.String("method", method),
+		slog.String("persona", p.Name()),
+	)
+}
+
+var wordRe = regexp.MustCompile(`[a-z0-9']+`)
+
+func words(s string) []string {
+	return wordRe.FindAllString(strings.ToLower(s), -1)
+}
+
+// GenerateEmbedding implements llm.Embedder.
+//
+// Words and word pairs are hashed into buckets of the vector (the hashing trick),
+// so texts that share words end up with a high cosine similarity.
+func (c *Client) GenerateEmbedding(ctx context.Context, str string) ([]float64, error) {
+	embedding := make([]float64, c.dimensions)
+
+	add := func(feature string, weight float64) {
+		h := c.hash(feature)
+		sign := 1.0
+		if h&(1<<63) != 0 {
+			sign = -1.0
+		}
+		embedding[h%uint64(c.dimensions)] += sign * weight
+	}
+
+	ws := words(str)
+	for i, w := range ws {
+		add(w, 1)
+		if i > 0 {
+			add(ws[i-1]+" "+w, 0.5)
+		}
+	}
+
+	var norm float64
+	for _, v := range embedding {
+		norm += v * v
+	}
+
+	// Empty strings (or features that cancel out) would result in a zero vector,
+	// which has no defined cosine similarity, so fall back to hashing the whole string
+	if norm == 0 {
+		embedding[c.hash(str)%uint64(c.dimensions)] = 1
+		norm = 1
+	}
+
+	norm = math.Sqrt(norm)
+	for i := range embedding {
+		embedding[i] /= norm
+	}
+
+	return embedding, nil
+}
+
+// unquote strips the quotes that llm.Persona adds to the names of known locations.
+func unquote(strs []string) []string {
+	out := make([]string, 0, len(strs))
+	for _, str := range strs {
+		out = append(out, strings.Trim(str, "\""))
+	}
+
+	// The persona returns known locations in map order, sort them so our choices are deterministic
+	slices.Sort(out)
+
+	return out
+}
+
+// mostRelevant returns the candidate sharing the most words with the query,
+// ties (including no overlap at all) are broken by hashing.
+func (c *Client) mostRelevant(candidates []string, query string, parts ...string) string {
+	queryWords := map[string]struct{}{}
+	for _, w := range words(query) {
+		queryWords[w] = struct{}{}
+	}
+
+	best := []string{}
+	bestScore := -1
+	for _, candidate := range candidates {
+		score := 0
+		for _, w := range words(candidate) {
+			if _, ok := queryWords[w]; ok {
+				score += 1
+			}
+		}
+
+		if score > bestScore {
+			best = []string{candidate}
+			bestScore = score
+		} else if score == bestScore {
+			best = append(best, candidate)
+		}
+	}
+
+	return pick(c, best, append(parts, query)...)
+}
+
+func firstName(name string) string {
+	first, _, _ := strings.Cut(name, " ")
+	return first
+}
+
+func clamp(v, lo, hi int) int {
+	return max(lo, min(v, hi))
+}
+
+func containsAny(s string, substrs ...string) bool {
+	s = strings.ToLower(s)
+	for _, substr := range substrs {
+		if strings.Contains(s, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+// isHomeActivity reports whether the activity should take place in the persona's living area.
+func isHomeActivity(activity string) bool {
+	return containsAny(activity, "sleep", "bed", "morning routine", "wake", "waking", "shower", "breakfast", "dinner")
+}
+
+// locationHints maps words in an activity to words that are likely part of the location it should happen at.
+var locationHints = map[string]string{
+	"sleep":     "bed bedroom",
+	"bed":       "bed bedroom",
+	"breakfast": "kitchen cafe refrigerator",
+	"lunch":     "kitchen cafe",
+	"dinner":    "kitchen",
+	"cook":      "kitchen stove",
+	"coffee":    "cafe coffee",
+	"shower":    "bathroom shower",
+	"bath":      "bathroom",
+	"read":      "library bookshelf desk",
+	"study":     "library desk",
+	"write":     "desk",
+	"paint":     "easel",
+	"music":     "piano guitar",
+	"shop":      "store shelf",
+	"work":      "desk counter",
+	"relax":     "common room couch",
+}
+
+func expandLocationQuery(activity string) string {
+	query := []string{activity}
+	for _, w := range words(activity) {
+		for hint, locations := range locationHints {
+			if strings.HasPrefix(w, hint) {
+				query = append(query, locations)
+			}
+		}
+	}
+
+	return strings.Join(query, " ")
+}
+
+// Matches the "main activity (sub activity)" format created by plan decomposition
+var actionRe = regexp.MustCompile(`^(.*) \((.*)\)$`)
+
+func subActivity(activity string) string {
+	if m := actionRe.FindStringSubmatch(activity); m != nil {
+		return m[2]
+	}
+	return activity
+}
+
+var emojis = []struct {
+	keywords []string
+	emoji    string
+}{
+	{[]string{"sleep", "bed", "nap"}, "😴"},
+	{[]string{"chat", "convers", "talk"}, "💬"},
+	{[]string{"wait"}, "⌛"},
+	{[]string{"breakfast", "lunch", "dinner", "eat", "meal"}, "🍽️"},
+	{[]string{"cook"}, "🍳"},
+	{[]string{"coffee", "cafe"}, "☕"},
+	{[]string{"party", "celebrat"}, "🎉"},
+	{[]string{"read", "book", "study"}, "📚"},
+	{[]string{"writ", "journal"}, "✍️"},
+	{[]string{"paint", "draw"}, "🎨"},
+	{[]string{"music", "piano", "guitar", "sing"}, "🎵"},
+	{[]string{"walk", "stroll"}, "🚶"},
+	{[]string{"shower", "bath", "wash"}, "🚿"},
+	{[]string{"work", "counter", "serv"}, "💼"},
+	{[]string{"idle"}, "💤"},
+}
+
+func pronunciato(activity string) string {
+	out := ""
+	count := 0
+	for _, e := range emojis {
+		if containsAny(activity, e.keywords...) {
+			out += e.emoji
+			count += 1
+			if count == 2 {
+				break
+			}
+		}
+	}
+
+	if out == "" {
+		return "🙂"
+	}
+	return out
+}
+
+var (
+	importantWords = []string{"party", "valentine", "birthday", "wedding", "love", "date", "fight", "argu", "accident", "died", "election", "exam", "interview", "promotion", "secret"}
+	positiveWords  = []string{"party", "love", "happy", "enjoy", "great", "celebrat", "friend", "welcome", "fun", "delicious", "relax", "excit"}
+	negativeWords  = []string{"sad", "angry", "fight", "argu", "tired", "late", "problem", "lost", "sick", "worr", "lonely", "stress"}
+)
+
+func countWords(s string, keywords []string) int {
+	s = strings.ToLower(s)
+	count := 0
+	for _, kw := range keywords {
+		count += strings.Count(s, kw)
+	}
+	return count
+}
+
+func (c *Client) importance(p llm.Persona, nt memory.NodeType, description string) int {
+	if strings.Contains(description, "is idle") {
+		return 1
+	}
+	if containsAny(description, "sleeping") {
+		return 1
+	}
+
+	score := 2
+	switch nt {
+	case memory.NodeTypeThought:
+		score += 1
+	case memory.NodeTypeChat:
+		score += 2
+	}
+	score += 3 * countWords(description, importantWords)
+	score += int(c.hash(p.Name(), description) % 2)
+
+	return clamp(score, 0, 10)
+}
+
+func valence(description string) int {
+	if strings.Contains(description, "is idle") {
+		return 0
+	}
+
+	score := 2*countWords(description, positiveWords) - 2*countWords(description, negativeWords)
+	return clamp(score, -10, 10)
+}
+
+func transcript(chat []memory.Utterance) string {
+	lines := make([]string, 0, len(chat))
+	for _, utt := range chat {
+		lines = append(lines, utt.Speaker+": "+utt.Sentence)
+	}
+	return strings.Join(lines, "\n")
+}
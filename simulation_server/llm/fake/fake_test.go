@@ -0,0 +1,151 @@
+package fake_test
+
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/fvdveen/generative_agents/simulation_server/agent"
+	"github.com/fvdveen/generative_agents/simulation_server/llm"
+	"github.com/fvdveen/generative_agents/simulation_server/llm/fake"
+	"github.com/fvdveen/generative_agents/simulation_server/memory"
+)
+
+func makePersona(c *fake.Client, state agent.State) *agent.Persona {
+	state.FullName = "Isabella Rodriguez"
+	state.Lifestyle = "Isabella Rodriguez goes to bed around 11pm, awakes up around 6am."
+	state.DailyPlanRequirements = "Isabella Rodriguez opens Hobbs Cafe at 8am everyday, and works at the counter until 8pm."
+	if state.CurrentTime.IsZero() {
+		state.CurrentTime = time.Date(2023, time.February, 13, 0, 0, 0, 0, time.UTC)
+	}
+
+	assoc := memory.NewAssociative(map[string][]float64{}, map[string]int{}, map[string]int{})
+	return agent.New(state.FullName, assoc, memory.NewSpatial(), state, c, c)
+}
+
+func sum(plans []llm.Plan) (total int) {
+	for _, plan := range plans {
+		total += plan.Duration
+	}
+	return total
+}
+
+func TestHourlyScheduleCoversDay(t *testing.T) {
+	c := fake.New()
+	p := makePersona(c, agent.State{})
+
+	wakeUp, err := c.GenerateWakeUpHour(context.Background(), p)
+	if err != nil {
+		t.Fatalf("could not generate wake up hour: %v", err)
+	}
+	if wakeUp.Hour() != 6 {
+		t.Fatalf("Wrong wake up hour, got: %d, want: 6", wakeUp.Hour())
+	}
+
+	schedule, err := c.GenerateHourlySchedule(context.Background(), p, wakeUp)
+	if err != nil {
+		t.Fatalf("could not generate hourly schedule: %v", err)
+	}
+	if total := sum(schedule); total != 24*60 {
+		t.Fatalf("Schedule does not cover a day, got: %d minutes, want: %d", total, 24*60)
+	}
+	if schedule[0].Activity != "sleeping" || schedule[0].Duration != 6*60 {
+		t.Fatalf("Schedule should start by sleeping until 6am, got: %v", schedule[0])
+	}
+}
+
+func TestPlanDecompositionKeepsDuration(t *testing.T) {
+	c := fake.New()
+	p := makePersona(c, agent.State{})
+
+	for _, duration := range []int{60, 61, 95, 180, 600} {
+		tasks, err := c.GeneratePlanDecomposition(context.Background(), p, llm.Plan{Activity: "working at the counter", Duration: duration})
+		if err != nil {
+			t.Fatalf("could not decompose %d minutes: %v", duration, err)
+		}
+		if total := sum(tasks); total != duration {
+			t.Fatalf("Decomposition of %d minutes sums to %d minutes", duration, total)
+		}
+	}
+}
+
+func TestReactionScheduleUpdateKeepsDuration(t *testing.T) {
+	c := fake.New()
+	schedule := []llm.Plan{
+		{Activity: "sleeping", Duration: 360},
+		{Activity: "having breakfast", Duration: 60},
+		{Activity: "working at the counter", Duration: 300},
+		{Activity: "having lunch", Duration: 60},
+		{Activity: "sleeping", Duration: 660},
+	}
+	day := time.Date(2023, time.February, 13, 0, 0, 0, 0, time.UTC)
+	p := makePersona(c, agent.State{
+		CurrentTime:   day.Add(8*time.Hour + 30*time.Minute),
+		DailySchedule: schedule,
+	})
+
+	// The window covers the breakfast and work plans
+	plans, err := c.GenerateReactionScheduleUpdate(context.Background(), p, llm.Plan{Activity: "chatting", Duration: 20}, day.Add(6*time.Hour), day.Add(12*time.Hour))
+	if err != nil {
+		t.Fatalf("could not update schedule: %v", err)
+	}
+	if total := sum(plans); total != 360 {
+		t.Fatalf("Reaction schedule has wrong duration, got: %d, want: 360", total)
+	}
+
+	found := false
+	for _, plan := range plans {
+		if plan.Activity == "chatting" && plan.Duration == 20 {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("Reaction schedule does not contain inserted activity: %v", plans)
+	}
+}
+
+func embed(t *testing.T, c *fake.Client, str string) []float64 {
+	t.Helper()
+
+	e, err := c.GenerateEmbedding(context.Background(), str)
+	if err != nil {
+		t.Fatalf("could not generate embedding: %v", err)
+	}
+	return e
+}
+
+func cosine(a, b []float64) float64 {
+	var dot, na, nb float64
+	for i := range a {
+		dot += a[i] * b[i]
+		na += a[i] * a[i]
+		nb += b[i] * b[i]
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
+
+func TestEmbeddingsAreDeterministic(t *testing.T) {
+	a := embed(t, fake.New(), "bed is idle")
+	b := embed(t, fake.New(), "bed is idle")
+
+	if len(a) != 1536 {
+		t.Fatalf("Wrong embedding length, got: %d, want: 1536", len(a))
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("Embeddings differ at index %d: %f != %f", i, a[i], b[i])
+		}
+	}
+
+	c := fake.New(fake.WithDimensions(64))
+	similar := cosine(embed(t, c, "Isabella is making coffee"), embed(t, c, "Isabella is making coffee for Klaus"))
+	unrelated := cosine(embed(t, c, "Isabella is making coffee"), embed(t, c, "the weather was stormy"))
+	if similar <= unrelated {
+		t.Fatalf("Similar texts should be closer than unrelated texts, got: %f <= %f", similar, unrelated)
+	}
+
+	if e := embed(t, c, ""); cosine(e, e) == 0 || math.IsNaN(cosine(e, e)) {
+		t.Fatalf("Empty string embedding has zero norm")
+	}
+}
@@ -0,0 +1,638 @@
+package fake
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fvdveen/generative_agents/simulation_server/llm"
+	"github.com/fvdveen/generative_agents/simulation_server/memory"
+)
+
+const hourFormat = "3:04pm"
+
+// Generates an importance score for a memory of a specific type based off of the
+// persona's personality and the event description.
+func (c *Client) GenerateImportanceScore(ctx context.Context, p llm.Persona, nt memory.NodeType, description string) (int, error) {
+	c.log("GenerateImportanceScore", p)
+	return c.importance(p, nt, description), nil
+}
+
+func (c *Client) GenerateImportanceScoreChat(ctx context.Context, p llm.Persona, chat []memory.Utterance, description string) (int, error) {
+	c.log("GenerateImportanceScoreChat", p)
+	return c.importance(p, memory.NodeTypeChat, description+"\n"+transcript(chat)), nil
+}
+
+// GenerateValenceScore implements llm.Cognition.
+func (c *Client) GenerateValenceScore(ctx context.Context, p llm.Persona, nt memory.NodeType, description string) (int, error) {
+	c.log("GenerateValenceScore", p)
+	return valence(description), nil
+}
+
+func (c *Client) GenerateValenceScoreChat(ctx context.Context, p llm.Persona, chat []memory.Utterance, description string) (int, error) {
+	c.log("GenerateValenceScoreChat", p)
+	return valence(description + "\n" + transcript(chat)), nil
+}
+
+var (
+	wakeUpRe = regexp.MustCompile(`(?i)(?:wake|wakes|awake|awakes|wakes up|gets up)\D*?(\d{1,2})(?::(\d{2}))?\s*(am|pm)`)
+	bedRe    = regexp.MustCompile(`(?i)(?:bed|sleep)\D*?(\d{1,2})(?::(\d{2}))?\s*(am|pm)`)
+)
+
+// parseHour finds an hour like "6am" or "10:30 pm" after the words matched by re, returning the hour in 24h format.
+func parseHour(re *regexp.Regexp, s string) (int, bool) {
+	m := re.FindStringSubmatch(s)
+	if m == nil {
+		return 0, false
+	}
+
+	hour, err := strconv.Atoi(m[1])
+	if err != nil || hour < 1 || hour > 12 {
+		return 0, false
+	}
+
+	hour %= 12
+	if strings.ToLower(m[3]) == "pm" {
+		hour += 12
+	}
+
+	return hour, true
+}
+
+func wakeUpHour(p llm.Persona) int {
+	if hour, ok := parseHour(wakeUpRe, p.Lifestyle()); ok {
+		return hour
+	}
+	return 7
+}
+
+func bedHour(p llm.Persona, wakeUp int) int {
+	hour, ok := parseHour(bedRe, p.Lifestyle())
+	// Going to bed after midnight does not fit in a single day schedule so clamp it
+	if !ok || hour <= wakeUp+2 {
+		return 23
+	}
+	return hour
+}
+
+// Generates the wake up hour for the next day based off of the persona's personality.
+func (c *Client) GenerateWakeUpHour(ctx context.Context, p llm.Persona) (time.Time, error) {
+	c.log("GenerateWakeUpHour", p)
+	return time.Date(0, time.January, 1, wakeUpHour(p), 0, 0, 0, time.UTC), nil
+}
+
+var workRe = regexp.MustCompile(`(?i)\bworks? (at|on|in) ([^,.]+)`)
+
+func workActivity(p llm.Persona) string {
+	if m := workRe.FindStringSubmatch(p.DailyPlanRequirements()); m != nil {
+		// Cut off trailing time information such as "until 8pm"
+		place, _, _ := strings.Cut(m[2], " until ")
+		place, _, _ = strings.Cut(place, " from ")
+		return fmt.Sprintf("working %s %s", strings.ToLower(m[1]), strings.TrimSpace(place))
+	}
+
+	return "working on their daily tasks"
+}
+
+// hourlyActivities returns the activity for every hour of the day.
+func (c *Client) hourlyActivities(p llm.Persona, wakeUp time.Time) [24]string {
+	wake := wakeUp.Hour()
+	bed := bedHour(p, wake)
+	work := workActivity(p)
+	leisure := []string{"reading a book", "taking a walk around the neighborhood", "relaxing at home"}
+
+	var hours [24]string
+	for h := range hours {
+		switch {
+		case h < wake || h >= bed:
+			hours[h] = "sleeping"
+		case h == wake:
+			hours[h] = "waking up and completing the morning routine"
+		case h == wake+1:
+			hours[h] = "having breakfast"
+		case h == 12:
+			hours[h] = "having lunch"
+		case h == 18:
+			hours[h] = "having dinner"
+		case h == bed-1:
+			hours[h] = "getting ready for bed"
+		case h < 18:
+			hours[h] = work
+		default:
+			hours[h] = pick(c, leisure, p.Name(), p.CurrentTime().Format(time.DateOnly), strconv.Itoa(h))
+		}
+	}
+
+	return hours
+}
+
+// Generates the first daily plan for a persona.
+func (c *Client) GenerateDailyPlan(ctx context.Context, p llm.Persona, wakeUpHour time.Time) ([]string, error) {
+	c.log("GenerateDailyPlan", p)
+
+	hours := c.hourlyActivities(p, wakeUpHour)
+	plan := []string{}
+	for h := 0; h < len(hours); h += 1 {
+		if hours[h] == "sleeping" {
+			continue
+		}
+
+		start := h
+		for h+1 < len(hours) && hours[h+1] == hours[start] {
+			h += 1
+		}
+
+		at := time.Date(0, time.January, 1, start, 0, 0, 0, time.UTC).Format(hourFormat)
+		plan = append(plan, fmt.Sprintf("%s at %s", hours[start], at))
+	}
+
+	bed := bedHour(p, wakeUpHour.Hour())
+	plan = append(plan, fmt.Sprintf("go to bed at %s", time.Date(0, time.January, 1, bed, 0, 0, 0, time.UTC).Format(hourFormat)))
+
+	return plan, nil
+}
+
+// Generates an hour schedule for a new day.
+func (c *Client) GenerateHourlySchedule(ctx context.Context, p llm.Persona, wakeUpHour time.Time) ([]llm.Plan, error) {
+	c.log("GenerateHourlySchedule", p)
+
+	plans := []llm.Plan{}
+	for _, activity := range c.hourlyActivities(p, wakeUpHour) {
+		if len(plans) > 0 && plans[len(plans)-1].Activity == activity {
+			plans[len(plans)-1].Duration += 60
+		} else {
+			plans = append(plans, llm.Plan{Activity: activity, Duration: 60})
+		}
+	}
+
+	return plans, nil
+}
+
+var subTasks = []string{
+	"getting started",
+	"focusing on the main task",
+	"taking a short break",
+	"continuing the work",
+	"tidying up",
+	"wrapping up",
+}
+
+// Generates a list of sub-plans that the given plan should consist of
+func (c *Client) GeneratePlanDecomposition(ctx context.Context, p llm.Persona, plan llm.Plan) ([]llm.Plan, error) {
+	c.log("GeneratePlanDecomposition", p)
+
+	n := clamp(plan.Duration/30, 1, len(subTasks))
+	chunk := plan.Duration / n
+
+	tasks := make([]llm.Plan, 0, n)
+	for i := range n {
+		duration := chunk
+		if i == n-1 {
+			// Put any remainder in the last task so the durations sum up to the original duration
+			duration = plan.Duration - chunk*(n-1)
+		}
+
+		task := subTasks[i]
+		if i == n-1 && n > 1 {
+			task = subTasks[len(subTasks)-1]
+		}
+
+		tasks = append(tasks, llm.Plan{
+			Activity: fmt.Sprintf("%s (%s)", plan.Activity, task),
+			Duration: duration,
+		})
+	}
+
+	return tasks, nil
+}
+
+// Generates an updated schedule in response to an event
+//
+// The returned plans cover exactly the plans in the daily schedule that overlap [startTime, endTime),
+// the part before the current time is kept, the inserted activity replaces the start of the remaining part.
+func (c *Client) GenerateReactionScheduleUpdate(ctx context.Context, p llm.Persona, inserted llm.Plan, startTime, endTime time.Time) ([]llm.Plan, error) {
+	c.log("GenerateReactionScheduleUpdate", p)
+
+	planningFrom := p.CurrentTime().Truncate(time.Minute)
+
+	past := []llm.Plan{}
+	remaining := []llm.Plan{}
+
+	planStart := p.StartOfDay()
+	for _, plan := range p.DailySchedule() {
+		planEnd := planStart.Add(time.Duration(plan.Duration) * time.Minute)
+
+		if planEnd.After(startTime) && planStart.Before(endTime) {
+			elapsed := clamp(int(planningFrom.Sub(planStart)/time.Minute), 0, plan.Duration)
+			if elapsed > 0 {
+				past = append(past, llm.Plan{Activity: plan.Activity, Duration: elapsed})
+			}
+			if rem := plan.Duration - elapsed; rem > 0 {
+				remaining = append(remaining, llm.Plan{Activity: plan.Activity, Duration: rem})
+			}
+		}
+
+		planStart = planEnd
+	}
+
+	total := 0
+	for _, plan := range remaining {
+		total += plan.Duration
+	}
+
+	out := past
+	insertedDuration := clamp(inserted.Duration, 0, total)
+	if insertedDuration > 0 {
+		out = append(out, llm.Plan{Activity: inserted.Activity, Duration: insertedDuration})
+	}
+
+	// Skip the part of the remaining plans that is taken up by the inserted activity
+	skip := insertedDuration
+	for _, plan := range remaining {
+		if skip >= plan.Duration {
+			skip -= plan.Duration
+			continue
+		}
+
+		plan.Duration -= skip
+		skip = 0
+		out = append(out, plan)
+	}
+
+	return out, nil
+}
+
+// Generates the sector an activity should take place in
+func (c *Client) GenerateActivitySector(ctx context.Context, p llm.Persona, maze llm.Maze, activity string, world string) (string, error) {
+	c.log("GenerateActivitySector", p)
+
+	path := maze.GetTile(p.Position()).Path
+	exists := func(sector string) bool {
+		return maze.Exists(path.AtLevel(memory.PathLevelSector).Copy(memory.PathWithSector(sector)))
+	}
+
+	living := p.LivingArea().Get(memory.PathLevelSector)
+	if isHomeActivity(activity) && exists(living) {
+		return living, nil
+	}
+
+	candidates := slices.DeleteFunc(unquote(p.KnownSectors(path)), func(s string) bool { return !exists(s) })
+	if len(candidates) == 0 {
+		if sector := path.Get(memory.PathLevelSector); sector != "" {
+			return sector, nil
+		}
+		return living, nil
+	}
+
+	query := expandLocationQuery(activity) + " " + p.DailyPlanRequirements()
+	return c.mostRelevant(candidates, query, p.Name(), "sector"), nil
+}
+
+// Generates the arena an activity should take place in
+func (c *Client) GenerateActivityArena(ctx context.Context, p llm.Persona, maze llm.Maze, activity string, world string, sector string) (string, error) {
+	c.log("GenerateActivityArena", p)
+
+	path := maze.GetTile(p.Position()).Path
+	exists := func(arena string) bool {
+		return maze.Exists(path.AtLevel(memory.PathLevelArena).Copy(memory.PathWithSector(sector), memory.PathWithArena(arena)))
+	}
+
+	if p.LivingArea().Get(memory.PathLevelSector) == sector && isHomeActivity(activity) {
+		if living := p.LivingArea().Get(memory.PathLevelArena); exists(living) {
+			return living, nil
+		}
+	}
+
+	known := p.KnownArenas(memory.NewPath(memory.PathWithWorld(world), memory.PathWithSector(sector)))
+	candidates := slices.DeleteFunc(unquote(known), func(a string) bool { return !exists(a) })
+	if len(candidates) == 0 {
+		return p.LivingArea().Get(memory.PathLevelArena), nil
+	}
+
+	return c.mostRelevant(candidates, expandLocationQuery(activity), p.Name(), sector, "arena"), nil
+}
+
+// Generates the object that should be used for an activity
+func (c *Client) GenerateActivityObject(ctx context.Context, p llm.Persona, maze llm.Maze, activity string, path memory.Path) (string, error) {
+	c.log("GenerateActivityObject", p)
+
+	exists := func(object string) bool {
+		return maze.Exists(path.AtLevel(memory.PathLevelObject).Copy(memory.PathWithObject(object)))
+	}
+
+	candidates := slices.DeleteFunc(unquote(p.KnownObjects(path)), func(o string) bool { return !exists(o) })
+	if len(candidates) == 0 {
+		// Without an object the activity takes place somewhere in the arena
+		return "", nil
+	}
+
+	return c.mostRelevant(candidates, expandLocationQuery(activity), p.Name(), path.ToString(), "object"), nil
+}
+
+// Generates a pronunciato (2 emojis) representing the current activity taking place
+func (c *Client) GenerateActivityPronunciato(ctx context.Context, p llm.Persona, activity string) (string, error) {
+	c.log("GenerateActivityPronunciato", p)
+	return pronunciato(activity), nil
+}
+
+func spo(subject, activity string) memory.SPO {
+	ws := strings.Fields(subActivity(activity))
+	if len(ws) == 0 {
+		return memory.SPO{Subject: subject, Predicate: "is", Object: "idle"}
+	}
+
+	object := "None"
+	if len(ws) > 1 {
+		object = strings.Join(ws[1:min(len(ws), 5)], " ")
+	}
+
+	return memory.SPO{Subject: subject, Predicate: ws[0], Object: object}
+}
+
+// Generates a SPO (activity subject-predicate-object) triple
+func (c *Client) GenerateActivitySPO(ctx context.Context, p llm.Persona, activity string) (memory.SPO, error) {
+	c.log("GenerateActivitySPO", p)
+	return spo(p.Name(), activity), nil
+}
+
+// Generates a description for the object that is used in the current activity
+func (c *Client) GenerateActivityObjectDescription(ctx context.Context, p llm.Persona, object string, activity string) (string, error) {
+	c.log("GenerateActivityObjectDescription", p)
+
+	switch {
+	case containsAny(activity, "sleep") && containsAny(object, "bed"):
+		return "being slept in", nil
+	case containsAny(activity, "cook", "breakfast", "lunch", "dinner"):
+		return "being used to prepare food", nil
+	default:
+		return "being used", nil
+	}
+}
+
+// Generates a pronunciato (2 emojis) representing t for the object that is used in the current activity
+func (c *Client) GenerateActivityObjectPronunciato(ctx context.Context, p llm.Persona, activityObjectDescription string) (string, error) {
+	c.log("GenerateActivityObjectPronunciato", p)
+	return pronunciato(activityObjectDescription), nil
+}
+
+// Generates a SPO (activity subject-predicate-object) triple
+func (c *Client) GenerateActivityObjectSPO(ctx context.Context, p llm.Persona, object string, activityObjectDescription string) (memory.SPO, error) {
+	c.log("GenerateActivityObjectSPO", p)
+	return spo(object, activityObjectDescription), nil
+}
+
+// Generates whether Persona init wants to talk to persona target
+func (c *Client) GenerateDecideToTalk(ctx context.Context, init, target llm.Persona, events, thoughts []memory.NodeId) (bool, error) {
+	c.log("GenerateDecideToTalk", init)
+
+	// Don't chat with the same persona over and over again
+	if id, ok := init.LastChat(target.Name()); ok {
+		if init.CurrentTime().Sub(init.GetMemory(id).Created) < 2*time.Hour {
+			return false, nil
+		}
+	}
+
+	return c.hash(init.Name(), target.Name(), init.CurrentTime().Format("2006-01-02 15"))%4 == 0, nil
+}
+
+// Generates whether init should wait until target has finished their activity before approaching them,
+// or init should continue with their own activity.
+func (c *Client) GenerateDecideToWait(ctx context.Context, init, target llm.Persona, events, thoughts []memory.NodeId) (wait bool, err error) {
+	c.log("GenerateDecideToWait", init)
+	return c.hash(init.Name(), target.Name(), init.CurrentTime().Format(time.DateTime))%5 == 0, nil
+}
+
+// Generates a summary for a conversation that a persona had
+func (c *Client) GenerateConversationSummary(ctx context.Context, p llm.Persona, conversation []memory.Utterance) (string, error) {
+	c.log("GenerateConversationSummary", p)
+
+	others := []string{}
+	for _, utt := range conversation {
+		if utt.Speaker != p.Name() && !slices.Contains(others, utt.Speaker) {
+			others = append(others, utt.Speaker)
+		}
+	}
+
+	if len(others) == 0 {
+		return "conversing about their day", nil
+	}
+	return fmt.Sprintf("conversing with %s about their day", strings.Join(others, " and ")), nil
+}
+
+// Generates a change in planning for p that should be remembered based off of a conversation
+func (c *Client) GeneratePlanningThoughtAfterConversation(ctx context.Context, p llm.Persona, conversation []memory.Utterance) (string, error) {
+	c.log("GeneratePlanningThoughtAfterConversation", p)
+	return fmt.Sprintf("%s does not need to change any plans.", firstName(p.Name())), nil
+}
+
+// Generates anything noteworthy that should be remembered after a conversation
+func (c *Client) GenerateMemoAfterConversation(ctx context.Context, p llm.Persona, conversation []memory.Utterance) (string, error) {
+	c.log("GenerateMemoAfterConversation", p)
+	return "had a pleasant conversation", nil
+}
+
+// Generates a summary of a relationship between init and target given the memories that init has of target
+func (c *Client) GenerateRelationshipSummary(ctx context.Context, init, target llm.Persona, memories []memory.NodeId) (string, error) {
+	c.log("GenerateRelationshipSummary", init)
+
+	if len(memories) == 0 {
+		return fmt.Sprintf("%s and %s do not know each other well yet.", init.Name(), target.Name()), nil
+	}
+	return fmt.Sprintf("%s has %d memories involving %s.", init.Name(), len(memories), target.Name()), nil
+}
+
+// Generates one utterance in a conversation
+func (c *Client) GenerateOneUtterance(ctx context.Context, init, target llm.Persona, maze llm.Maze, currentChat []memory.Utterance, relevant []memory.NodeId, relationship string) (utt memory.Utterance, endConversation bool, err error) {
+	c.log("GenerateOneUtterance", init)
+
+	var sentence string
+	switch len(currentChat) {
+	case 0:
+		sentence = fmt.Sprintf("Hi %s! How is %s going?", firstName(target.Name()), subActivity(target.ActivityDescription()))
+	case 1:
+		sentence = fmt.Sprintf("Pretty well, thanks! I'm busy %s.", subActivity(init.ActivityDescription()))
+	case 2:
+		sentence = "That sounds nice."
+		if len(relevant) > 0 {
+			sentence = fmt.Sprintf("That sounds nice. By the way, I remember that %s.", init.GetMemory(relevant[0]).Description)
+		}
+	default:
+		sentence = "It was good to catch up, see you later!"
+	}
+
+	return memory.Utterance{
+		Speaker:  init.Name(),
+		Sentence: sentence,
+	}, len(currentChat) >= 3, nil
+}
+
+// Generates a list of focal points to address during reflection
+func (c *Client) GenerateFocalPoints(ctx context.Context, p llm.Persona, statements []memory.NodeId, numFocalPoints int) ([]string, error) {
+	c.log("GenerateFocalPoints", p)
+
+	nodes := slices.Clone(statements)
+	slices.SortStableFunc(nodes, func(a, b memory.NodeId) int {
+		return cmp.Compare(p.GetMemory(b).Importance, p.GetMemory(a).Importance)
+	})
+
+	focalPoints := []string{}
+	for _, node := range nodes[:min(len(nodes), numFocalPoints)] {
+		focalPoints = append(focalPoints, fmt.Sprintf("What does it mean for %s that %s?", p.Name(), p.GetMemory(node).Description))
+	}
+
+	return focalPoints, nil
+}
+
+// Generates insights based off of the evidence presented in nodes
+func (c *Client) GenerateInsightAndEvidence(ctx context.Context, p llm.Persona, nodes []memory.NodeId, insightCount int) (map[string][]memory.NodeId, error) {
+	c.log("GenerateInsightAndEvidence", p)
+
+	evidence := map[string][]memory.NodeId{}
+	for _, node := range nodes {
+		for _, kw := range p.GetMemory(node).Keywords {
+			kw = strings.ToLower(kw)
+			if kw == "" || kw == "none" || kw == "idle" || kw == strings.ToLower(p.Name()) {
+				continue
+			}
+			if !slices.Contains(evidence[kw], node) {
+				evidence[kw] = append(evidence[kw], node)
+			}
+		}
+	}
+
+	keywords := make([]string, 0, len(evidence))
+	for kw := range evidence {
+		keywords = append(keywords, kw)
+	}
+	slices.SortFunc(keywords, func(a, b string) int {
+		if c := cmp.Compare(len(evidence[b]), len(evidence[a])); c != 0 {
+			return c
+		}
+		return cmp.Compare(a, b)
+	})
+
+	insights := map[string][]memory.NodeId{}
+	for _, kw := range keywords[:min(len(keywords), insightCount)] {
+		insight := fmt.Sprintf("%s has been paying attention to %s", p.Name(), kw)
+		insights[insight] = evidence[kw][:min(len(evidence[kw]), 5)]
+	}
+
+	return insights, nil
+}
+
+// Generates information the agent should remember when planning for the next day
+func (c *Client) GeneratePlanningNote(ctx context.Context, p llm.Persona, statements []string) (string, error) {
+	c.log("GeneratePlanningNote", p)
+
+	if len(statements) == 0 {
+		return fmt.Sprintf("%s has nothing in particular to remember for today.", p.Name()), nil
+	}
+	return fmt.Sprintf("%s should remember %d recent events while planning today.", p.Name(), len(statements)), nil
+}
+
+// Generates the feelings an agent has about their days up till now
+func (c *Client) GeneratePlanningFeelings(ctx context.Context, p llm.Persona, statements []string) (string, error) {
+	c.log("GeneratePlanningFeelings", p)
+
+	score := valence(strings.Join(statements, "\n"))
+	switch {
+	case score > 0:
+		return fmt.Sprintf("%s feels good about the past days.", p.Name()), nil
+	case score < 0:
+		return fmt.Sprintf("%s feels a bit down about the past days.", p.Name()), nil
+	default:
+		return fmt.Sprintf("%s feels content about the past days.", p.Name()), nil
+	}
+}
+
+// Generates a new set of plans for an agent
+func (c *Client) GenerateCurrentPlans(ctx context.Context, p llm.Persona, plans, thoughts string) (string, error) {
+	c.log("GenerateCurrentPlans", p)
+	// Keeping the plans stable makes the persona's behaviour predictable across days
+	return p.CurrentPlans(), nil
+}
+
+// Generates new daily requirements
+func (c *Client) GenerateNewDailyRequirements(ctx context.Context, p llm.Persona) (string, error) {
+	c.log("GenerateNewDailyRequirements", p)
+	return p.DailyPlanRequirements(), nil
+}
+
+// Generates the eventCount most memorable events of the day of p from their daily schedule, the activities
+// that took up the most time are the most memorable
+func (c *Client) GenerateDaySummary(ctx context.Context, p llm.Persona, eventCount int) ([]string, error) {
+	c.log("GenerateDaySummary", p)
+
+	minutes := map[string]int{}
+	activities := []string{}
+	for _, plan := range p.DailySchedule() {
+		// The tasks an activity was decomposed into count towards the activity
+		activity, _, _ := strings.Cut(plan.Activity, " (")
+		if containsAny(activity, "sleeping") {
+			continue
+		}
+		if _, ok := minutes[activity]; !ok {
+			activities = append(activities, activity)
+		}
+		minutes[activity] += plan.Duration
+	}
+	slices.SortStableFunc(activities, func(a, b string) int {
+		return cmp.Compare(minutes[b], minutes[a])
+	})
+
+	events := []string{}
+	for _, activity := range activities[:min(len(activities), eventCount)] {
+		events = append(events, fmt.Sprintf("%s spent %d minutes %s.", firstName(p.Name()), minutes[activity], activity))
+	}
+
+	return events, nil
+}
+
+// Generates how p feels about their day and what they want tomorrow to be like given the memorable events of the day
+func (c *Client) GenerateDayInterpretation(ctx context.Context, p llm.Persona, events []string) (string, string, error) {
+	c.log("GenerateDayInterpretation", p)
+
+	name := firstName(p.Name())
+	score := valence(strings.Join(events, "\n"))
+	switch {
+	case score > 0:
+		return fmt.Sprintf("%s feels good about today.", name), fmt.Sprintf("%s wants tomorrow to be just like today.", name), nil
+	case score < 0:
+		return fmt.Sprintf("%s feels a bit down about today.", name), fmt.Sprintf("%s wants tomorrow to go better than today.", name), nil
+	default:
+		return fmt.Sprintf("%s feels content about today.", name), fmt.Sprintf("%s wants a calm day tomorrow.", name), nil
+	}
+}
+
+// Generates a expanded memory description based off of a chat (if any) and a description
+func (c *Client) GenerateExpandedMemoryDescription(ctx context.Context, p llm.Persona, chat []memory.Utterance, description string) (string, error) {
+	c.log("GenerateExpandedMemoryDescription", p)
+	return fmt.Sprintf("%s This really stuck with %s.", description, firstName(p.Name())), nil
+}
+
+// Generates a statement about p based off of a thought whispered to them
+func (c *Client) GenerateWhisperedThought(ctx context.Context, p llm.Persona, whisper string) (string, error) {
+	c.log("GenerateWhisperedThought", p)
+	return fmt.Sprintf("%s thinks: %s", p.Name(), strings.TrimSpace(whisper)), nil
+}
+
+// Generates a summary of the memories relevant to answering an interview question
+func (c *Client) GenerateInterviewSummary(ctx context.Context, p llm.Persona, question string, memories []memory.NodeId) (string, error) {
+	c.log("GenerateInterviewSummary", p)
+
+	if len(memories) == 0 {
+		return fmt.Sprintf("%s does not remember anything about this.", p.Name()), nil
+	}
+	return p.GetMemory(memories[0]).Description, nil
+}
+
+// Generates the answer of p to the last question in an interview, the answer may only use the summary of relevant memories
+func (c *Client) GenerateInterviewAnswer(ctx context.Context, p llm.Persona, interviewer string, conversation []memory.Utterance, summary string) (string, error) {
+	c.log("GenerateInterviewAnswer", p)
+	return fmt.Sprintf("Well %s, all I can tell you is this: %s", firstName(interviewer), summary), nil
+}
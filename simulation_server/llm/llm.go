@@ -1,6 +1,7 @@
 package llm
 
 import (
+	"context"
 	"time"
 
 	"github.com/fvdveen/generative_agents/simulation_server/maze"
@@ -8,7 +9,24 @@ import (
 )
 
 type Embedder interface {
-	GenerateEmbedding(string) []float64
+	GenerateEmbedding(ctx context.Context, str string) ([]float64, error)
+}
+
+// BatchEmbedder is an Embedder that can generate the embeddings of multiple strings at once.
+type BatchEmbedder interface {
+	Embedder
+	// GenerateEmbeddings returns the embedding of every string in strs, in the same order.
+	GenerateEmbeddings(ctx context.Context, strs []string) ([][]float64, error)
+}
+
+// PromptHook observes the prompts a Cognition sends to its model.
+type PromptHook interface {
+	// BeforePrompt is called with every rendered prompt before it is sent,
+	// if ok is true the returned response is used instead of querying the model.
+	// ctx is the context passed to the Cognition method that rendered the prompt.
+	BeforePrompt(ctx context.Context, name, prompt string) (response string, ok bool, err error)
+	// AfterPrompt is called with the raw response of the model once it passed validation.
+	AfterPrompt(ctx context.Context, name, prompt, response string)
 }
 
 type Persona interface {
@@ -57,80 +75,94 @@ type Plan struct {
 	Duration int
 }
 
+// Cognition generates the decisions of personas, usually by prompting a language model.
+// All methods return an error when the model could not be queried or the ctx was cancelled.
 type Cognition interface {
 	// Generates an importance score for a memory of a specific type based off of the
 	// persona's personality and the event description.
-	GenerateImportanceScore(p Persona, nt memory.NodeType, description string) int
+	GenerateImportanceScore(ctx context.Context, p Persona, nt memory.NodeType, description string) (int, error)
 	// Generates an importance score for a chat based off of the description, transcript and the persona's personality
-	GenerateImportanceScoreChat(p Persona, transcript []memory.Utterance, description string) int
+	GenerateImportanceScoreChat(ctx context.Context, p Persona, transcript []memory.Utterance, description string) (int, error)
 	// Generates a valence score for a memory of an agent
-	GenerateValenceScore(p Persona, nt memory.NodeType, description string) int
+	GenerateValenceScore(ctx context.Context, p Persona, nt memory.NodeType, description string) (int, error)
 	// Generates a valnce score for a chat based off of the description, transcript and the persona's personality
-	GenerateValenceScoreChat(p Persona, transcript []memory.Utterance, description string) int
+	GenerateValenceScoreChat(ctx context.Context, p Persona, transcript []memory.Utterance, description string) (int, error)
 
 	// Generates the wake up hour for the next day based off of the persona's personality.
-	GenerateWakeUpHour(p Persona) time.Time
+	GenerateWakeUpHour(ctx context.Context, p Persona) (time.Time, error)
 	// Generates the first daily plan for a persona.
-	GenerateDailyPlan(p Persona, wakeUpHour time.Time) []string
+	GenerateDailyPlan(ctx context.Context, p Persona, wakeUpHour time.Time) ([]string, error)
 	// Generates an hour schedule for a new day.
-	GenerateHourlySchedule(p Persona, wakeUpHour time.Time) []Plan
+	GenerateHourlySchedule(ctx context.Context, p Persona, wakeUpHour time.Time) ([]Plan, error)
 
 	// Generates a list of sub-plans that the given plan should consist of
-	GeneratePlanDecomposition(p Persona, plan Plan) []Plan
+	GeneratePlanDecomposition(ctx context.Context, p Persona, plan Plan) ([]Plan, error)
 
 	// Generates an updated schedule in response to an event
-	GenerateReactionScheduleUpdate(p Persona, insertedActivity Plan, startTime, endTime time.Time) []Plan
+	GenerateReactionScheduleUpdate(ctx context.Context, p Persona, insertedActivity Plan, startTime, endTime time.Time) ([]Plan, error)
 
 	// Generates the sector an activity should take place in
-	GenerateActivitySector(p Persona, maze Maze, activity string, world string) string
+	GenerateActivitySector(ctx context.Context, p Persona, maze Maze, activity string, world string) (string, error)
 	// Generates the arena an activity should take place in
-	GenerateActivityArena(p Persona, maze Maze, activity string, world string, sector string) string
+	GenerateActivityArena(ctx context.Context, p Persona, maze Maze, activity string, world string, sector string) (string, error)
 	// Generates the object that should be used for an activity
-	GenerateActivityObject(p Persona, maze Maze, activity string, path memory.Path) string
+	GenerateActivityObject(ctx context.Context, p Persona, maze Maze, activity string, path memory.Path) (string, error)
 	// Generates a pronunciato (2 emojis) representing the current activity taking place
-	GenerateActivityPronunciato(p Persona, activity string) string
+	GenerateActivityPronunciato(ctx context.Context, p Persona, activity string) (string, error)
 	// Generates a SPO (activity subject-predicate-object) triple
-	GenerateActivitySPO(p Persona, activity string) memory.SPO
+	GenerateActivitySPO(ctx context.Context, p Persona, activity string) (memory.SPO, error)
 
 	// Generates a description for the object that is used in the current activity
-	GenerateActivityObjectDescription(p Persona, object string, activity string) string
+	GenerateActivityObjectDescription(ctx context.Context, p Persona, object string, activity string) (string, error)
 	// Generates a pronunciato (2 emojis) representing t for the object that is used in the current activity
-	GenerateActivityObjectPronunciato(p Persona, activityObjectDescription string) string
+	GenerateActivityObjectPronunciato(ctx context.Context, p Persona, activityObjectDescription string) (string, error)
 	// Generates a SPO (activity subject-predicate-object) triple
-	GenerateActivityObjectSPO(p Persona, object string, activityObjectDescription string) memory.SPO
+	GenerateActivityObjectSPO(ctx context.Context, p Persona, object string, activityObjectDescription string) (memory.SPO, error)
 
 	// Generates whether Persona init wants to talk to persona target
-	GenerateDecideToTalk(init, target Persona, events, thoughts []memory.NodeId) bool
+	GenerateDecideToTalk(ctx context.Context, init, target Persona, events, thoughts []memory.NodeId) (bool, error)
 	// Generates whether init should wait until target has finished their activity before approaching them,
 	// or init should continue with their own activity.
 	// NOTE(Friso): In the original code this is called generate_decide_to_react, but this name is more apt.
-	GenerateDecideToWait(init, target Persona, events, thoughts []memory.NodeId) (wait bool)
+	GenerateDecideToWait(ctx context.Context, init, target Persona, events, thoughts []memory.NodeId) (wait bool, err error)
 
 	// Generates a summary for a conversation that a persona had
-	GenerateConversationSummary(p Persona, conversation []memory.Utterance) string
+	GenerateConversationSummary(ctx context.Context, p Persona, conversation []memory.Utterance) (string, error)
 	// Generates a change in planning for p that should be remembered based off of a conversation
-	GeneratePlanningThoughtAfterConversation(p Persona, conversation []memory.Utterance) string
+	GeneratePlanningThoughtAfterConversation(ctx context.Context, p Persona, conversation []memory.Utterance) (string, error)
 	// Generates anything noteworthy that should be remembered after a conversation
-	GenerateMemoAfterConversation(p Persona, conversation []memory.Utterance) string
+	GenerateMemoAfterConversation(ctx context.Context, p Persona, conversation []memory.Utterance) (string, error)
 	// Generates a summary of a relationship between init and target given the memories that init has of target
-	GenerateRelationshipSummary(init, target Persona, memories []memory.NodeId) string
+	GenerateRelationshipSummary(ctx context.Context, init, target Persona, memories []memory.NodeId) (string, error)
 	// Generates one utterance in a conversation
-	GenerateOneUtterance(init, target Persona, maze Maze, currentChat []memory.Utterance, relevant []memory.NodeId, relationship string) (utt memory.Utterance, endConversation bool)
+	GenerateOneUtterance(ctx context.Context, init, target Persona, maze Maze, currentChat []memory.Utterance, relevant []memory.NodeId, relationship string) (utt memory.Utterance, endConversation bool, err error)
 
 	// Generates a list of focal points to address during reflection
-	GenerateFocalPoints(p Persona, statements []memory.NodeId, numFocalPoints int) []string
+	GenerateFocalPoints(ctx context.Context, p Persona, statements []memory.NodeId, numFocalPoints int) ([]string, error)
 	// Generates insights based off of the evidence presented in nodes
-	GenerateInsightAndEvidence(p Persona, nodes []memory.NodeId, insightCount int) map[string][]memory.NodeId
+	GenerateInsightAndEvidence(ctx context.Context, p Persona, nodes []memory.NodeId, insightCount int) (map[string][]memory.NodeId, error)
 
 	// Generates information the agent should remember when planning for the next day
-	GeneratePlanningNote(p Persona, statements []string) string
+	GeneratePlanningNote(ctx context.Context, p Persona, statements []string) (string, error)
 	// Generates the feelings an agent has about their days up till now
-	GeneratePlanningFeelings(p Persona, statements []string) string
+	GeneratePlanningFeelings(ctx context.Context, p Persona, statements []string) (string, error)
 	// Generates a new set of plans for an agent
-	GenerateCurrentPlans(p Persona, plans, thoughts string) string
+	GenerateCurrentPlans(ctx context.Context, p Persona, plans, thoughts string) (string, error)
 	// Generates new daily requirements
-	GenerateNewDailyRequirements(p Persona) string
+	GenerateNewDailyRequirements(ctx context.Context, p Persona) (string, error)
+
+	// Generates the eventCount most memorable events of the day of p from their daily schedule
+	GenerateDaySummary(ctx context.Context, p Persona, eventCount int) ([]string, error)
+	// Generates how p feels about their day and what they want tomorrow to be like given the memorable events of the day
+	GenerateDayInterpretation(ctx context.Context, p Persona, events []string) (feelings, wants string, err error)
 
 	// Generates a expanded memory description based off of a chat (if any) and a description
-	GenerateExpandedMemoryDescription(p Persona, chat []memory.Utterance, description string) string
+	GenerateExpandedMemoryDescription(ctx context.Context, p Persona, chat []memory.Utterance, description string) (string, error)
+
+	// Generates a statement about p based off of a thought whispered to them
+	GenerateWhisperedThought(ctx context.Context, p Persona, whisper string) (string, error)
+	// Generates a summary of the memories relevant to answering an interview question
+	GenerateInterviewSummary(ctx context.Context, p Persona, question string, memories []memory.NodeId) (string, error)
+	// Generates the answer of p to the last question in an interview, the answer may only use the summary of relevant memories
+	GenerateInterviewAnswer(ctx context.Context, p Persona, interviewer string, conversation []memory.Utterance, summary string) (string, error)
 }
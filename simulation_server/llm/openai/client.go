@@ -9,19 +9,29 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"maps"
+	"reflect"
+	"slices"
+	"strconv"
 	"strings"
 	"sync/atomic"
 	"text/template"
 	"time"
 
+	"github.com/fvdveen/generative_agents/simulation_server/llm"
+	"github.com/fvdveen/generative_agents/simulation_server/llm/accounting"
+	"github.com/fvdveen/generative_agents/simulation_server/llm/promptcache"
+	"github.com/fvdveen/generative_agents/simulation_server/llm/provider"
 	"github.com/fvdveen/generative_agents/simulation_server/memory"
+	"github.com/fvdveen/generative_agents/simulation_server/metrics"
+	"github.com/fvdveen/generative_agents/simulation_server/tracing"
 	"github.com/xeipuuv/gojsonschema"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/openai/openai-go/v3"
 	"github.com/openai/openai-go/v3/option"
-	"github.com/openai/openai-go/v3/packages/param"
-	"github.com/openai/openai-go/v3/responses"
-	"github.com/openai/openai-go/v3/shared"
 )
 
 const (
@@ -145,22 +155,81 @@ func WithEmbeddingsModel(model string) ClientOpt {
 	}
 }
 
+// WithPromptHook makes the client report every prompt to hook, which can also supply responses in place of the model.
+func WithPromptHook(hook llm.PromptHook) ClientOpt {
+	return func(c *Client) {
+		c.hook = hook
+	}
+}
+
+// WithUsageTracker makes the client record the tokens and latency of every call it makes to the model in tracker.
+func WithUsageTracker(tracker *accounting.Tracker) ClientOpt {
+	return func(c *Client) {
+		c.usage = tracker
+	}
+}
+
+// WithProvider makes the client send its prompts to provider, by default the OpenAI Responses API is used.
+// The API key and URL of the client are then only used for embeddings.
+func WithProvider(p llm.Provider) ClientOpt {
+	return func(c *Client) {
+		c.provider = p
+	}
+}
+
+// WithPromptModels sends the prompts in models to the model they map to instead of the text model,
+// for example to use a smaller model for simple prompts.
+func WithPromptModels(models map[string]string) ClientOpt {
+	return func(c *Client) {
+		c.promptModels = models
+	}
+}
+
+// WithPromptCache answers prompts from cache when they were sent before, and caches the responses of the model.
+func WithPromptCache(cache *promptcache.Cache) ClientOpt {
+	return func(c *Client) {
+		c.cache = cache
+	}
+}
+
+// WithMetrics makes the client report every call it makes to the model to recorder.
+func WithMetrics(recorder metrics.Recorder) ClientOpt {
+	return func(c *Client) {
+		c.metrics = recorder
+	}
+}
+
+// WithTracer makes the client trace every call it makes to the model, and every attempt of a call, with tracer.
+func WithTracer(tracer trace.Tracer) ClientOpt {
+	return func(c *Client) {
+		c.tracer = tracer
+	}
+}
+
 type Client struct {
-	client openai.Client
-	logger *slog.Logger
+	// Used for embeddings
+	client   openai.Client
+	provider llm.Provider
+	logger   *slog.Logger
+	hook     llm.PromptHook
+	usage    *accounting.Tracker
+	cache    *promptcache.Cache
+	metrics  metrics.Recorder
+	tracer   trace.Tracer
 
 	apiKey string
 	url    string
 
 	textModel      string
 	embeddingModel string
+	promptModels   map[string]string
 	maxRetries     int
 
 	llmSeq atomic.Uint64
 }
 
 func New(opts ...ClientOpt) *Client {
-	client := &Client{textModel: "gpt-5-nano", embeddingModel: "text-embedding-ada-002", maxRetries: 8, logger: slog.Default()}
+	client := &Client{textModel: "gpt-5-nano", embeddingModel: "text-embedding-ada-002", maxRetries: 8, logger: slog.Default(), tracer: tracing.Nop()}
 
 	for _, opt := range opts {
 		opt(client)
@@ -173,72 +242,43 @@ func New(opts ...ClientOpt) *Client {
 
 	client.client = openai.NewClient(openaiOpts...)
 
-	return client
-}
+	if client.provider == nil {
+		client.provider = provider.NewResponses(provider.WithAPIKey(client.apiKey), provider.WithURL(client.url))
+	}
 
-func (c *Client) newID() string {
-	n := c.llmSeq.Add(1)
-	return fmt.Sprintf("llm-%d", n)
+	return client
 }
 
-func (c *Client) responseParams(input responses.ResponseNewParamsInputUnion, schema schema) responses.ResponseNewParams {
-	var r responses.ResponseNewParams
-
-	if c.textModel == "gpt-5-nano" {
-		r = responses.ResponseNewParams{
-			Model:     c.textModel,
-			Reasoning: shared.ReasoningParam{Effort: "low"},
-			Input:     input,
-			Text: responses.ResponseTextConfigParam{
-				Format: responses.ResponseFormatTextConfigParamOfJSONSchema(schema.Name, schema.Schema),
-			},
-		}
-	} else {
-		r = responses.ResponseNewParams{
-			Model:     c.textModel,
-			Reasoning: shared.ReasoningParam{Effort: "medium"},
-			Input:     input,
-			Text: responses.ResponseTextConfigParam{
-				Format: responses.ResponseFormatTextConfigParamOfJSONSchema(schema.Name, schema.Schema),
-			},
-			Temperature: param.NewOpt(0.5),
-			TopP:        param.NewOpt(0.9),
-		}
+// model returns the model the prompt with name is sent to.
+func (c *Client) model(name string) string {
+	if model, ok := c.promptModels[name]; ok {
+		return model
 	}
 
-	return r
+	return c.textModel
 }
 
-func inputMsg(role responses.EasyInputMessageRole, content string) responses.ResponseInputItemUnionParam {
-	return responses.ResponseInputItemUnionParam{
-		OfMessage: &responses.EasyInputMessageParam{
-			Role: role,
-			Type: responses.EasyInputMessageTypeMessage,
-			Content: responses.EasyInputMessageContentUnionParam{
-				OfString: param.NewOpt(content),
-			},
-		},
-	}
+// PromptNames returns the names of all prompts the client can send, which can be routed to other models with WithPromptModels.
+func PromptNames() []string {
+	return slices.Sorted(maps.Keys(prompts))
 }
 
-func outputMsg(id, text string) responses.ResponseInputItemUnionParam {
-	return responses.ResponseInputItemUnionParam{
-		OfOutputMessage: &responses.ResponseOutputMessageParam{
-			ID:     id,
-			Status: responses.ResponseOutputMessageStatusCompleted,
-			Content: []responses.ResponseOutputMessageContentUnionParam{
-				{
-					OfOutputText: &responses.ResponseOutputTextParam{
-						Text:        text,
-						Annotations: []responses.ResponseOutputTextAnnotationUnionParam{},
-					},
-				},
-			},
-		},
+// record adds call to the usage tracker and metrics of the client, if it has them.
+func (c *Client) record(ctx context.Context, call accounting.Call) {
+	if c.usage != nil {
+		c.usage.Record(ctx, call)
+	}
+	if c.metrics != nil {
+		c.metrics.LLMCall(call)
 	}
 }
 
-func appendRetryMessages(current responses.ResponseInputParam, id, badResponse string, errMsgs []string) responses.ResponseInputParam {
+func (c *Client) newID() string {
+	n := c.llmSeq.Add(1)
+	return fmt.Sprintf("llm-%d", n)
+}
+
+func appendRetryMessages(current []llm.Message, badResponse string, errMsgs []string) []llm.Message {
 	var sb strings.Builder
 	sb.WriteString("The generated response was invalid. Please fix the following errors and return only valid JSON:\n")
 	for _, e := range errMsgs {
@@ -248,18 +288,18 @@ func appendRetryMessages(current responses.ResponseInputParam, id, badResponse s
 	}
 
 	return append(current,
-		outputMsg(id, badResponse),
-		inputMsg(responses.EasyInputMessageRoleUser, sb.String()),
+		llm.Message{Role: llm.RoleAssistant, Content: badResponse},
+		llm.Message{Role: llm.RoleUser, Content: sb.String()},
 	)
 }
 
-func (c *Client) doRequest(ctx context.Context, input responses.ResponseNewParamsInputUnion, schema schema, output any) (*responses.Response, error) {
-	resp, err := c.client.Responses.New(ctx, c.responseParams(input, schema))
+func (c *Client) doRequest(ctx context.Context, model string, conversation []llm.Message, schema schema, output any) (*llm.Response, error) {
+	resp, err := c.provider.Complete(ctx, llm.Request{Model: model, Messages: conversation, SchemaName: schema.Name, Schema: schema.Schema})
 	if err != nil {
-		return resp, fmt.Errorf("could not execute prompt: %w", err)
+		return nil, fmt.Errorf("could not execute prompt: %w", err)
 	}
 
-	raw := resp.OutputText()
+	raw := resp.Text
 
 	if err := json.Unmarshal([]byte(raw), output); err != nil {
 		// The model may have wrapped the JSON in surrounding text; try extracting
@@ -267,13 +307,13 @@ func (c *Client) doRequest(ctx context.Context, input responses.ResponseNewParam
 		extracted := extractJSON(raw)
 		if extracted != raw {
 			if err2 := json.Unmarshal([]byte(extracted), output); err2 == nil {
-				return resp, nil
+				return &resp, nil
 			}
 		}
-		return resp, fmt.Errorf("could not unmarshal json: %w", err)
+		return &resp, fmt.Errorf("could not unmarshal json: %w", err)
 	}
 
-	return resp, nil
+	return &resp, nil
 }
 
 func extractJSON(s string) string {
@@ -315,9 +355,9 @@ func hashString(s string) string {
 }
 
 // doRequestWithRetry calls doRequest with retry logic for JSON unmarshalling or validation failures
-func (c *Client) doRequestWithRetry(ctx context.Context, prompt prompt, params any, output any, validationFn func() error) error {
+func (c *Client) doRequestWithRetry(ctx context.Context, prompt prompt, params any, output any, validationFn func() error) (err error) {
 	var lastErr error
-	var lastResp *responses.Response
+	var lastResp *llm.Response
 
 	var wr strings.Builder
 	if err := prompt.template.Execute(&wr, params); err != nil {
@@ -327,12 +367,21 @@ func (c *Client) doRequestWithRetry(ctx context.Context, prompt prompt, params a
 	promptText := wr.String()
 
 	llmID := c.newID()
+	model := c.model(prompt.name)
+	ctx, span := c.tracer.Start(ctx, prompt.name, trace.WithAttributes(
+		attribute.String("llm.id", llmID),
+		attribute.String("llm.prompt", prompt.name),
+		attribute.String("llm.model", model),
+	))
+	defer func() { tracing.End(span, err) }()
+
 	log := c.logger.With(
 		slog.String("llm_id", llmID),
 		slog.String("prompt_name", prompt.name),
+		slog.String("model", model),
 		slog.Int("max_retries", c.maxRetries),
 		slog.String("type", "llm_call"),
-	)
+	).With(tracing.LogAttrs(ctx)...)
 
 	log.Info("llm_call_start",
 		slog.String("type", "llm_call"),
@@ -341,27 +390,78 @@ func (c *Client) doRequestWithRetry(ctx context.Context, prompt prompt, params a
 		slog.Int("prompt_length", len(promptText)),
 	)
 
-	start := time.Now()
-	conversation := responses.ResponseInputParam{
-		inputMsg(responses.EasyInputMessageRoleUser, promptText),
+	if c.hook != nil {
+		raw, ok, err := c.hook.BeforePrompt(ctx, prompt.name, promptText)
+		if err != nil {
+			log.Error("llm_call_fail",
+				"type", "llm_call",
+				"phase", "hook",
+				"err", err,
+			)
+			return fmt.Errorf("prompt hook failed: %w", err)
+		}
+		if ok {
+			if err := decodeHookResponse(prompt, raw, output, validationFn); err != nil {
+				return fmt.Errorf("could not use response supplied by prompt hook: %w", err)
+			}
+			span.SetAttributes(attribute.Bool("llm.hook", true))
+			log.Info("llm_call_ok",
+				"type", "llm_call",
+				"phase", "hook",
+				"response_hash", hashString(raw),
+				"response_len", len(raw),
+			)
+			return nil
+		}
 	}
-	currentInput := responses.ResponseNewParamsInputUnion{
-		OfInputItemList: conversation,
+
+	start := time.Now()
+	call := accounting.Call{Model: model, Prompt: prompt.name}
+	defer func() {
+		call.Latency = time.Since(start)
+		c.record(ctx, call)
+		span.SetAttributes(
+			attribute.Int("llm.attempts", call.Attempts),
+			attribute.Int("llm.input_tokens", call.InputTokens),
+			attribute.Int("llm.output_tokens", call.OutputTokens),
+			attribute.Bool("llm.cached", call.Cached),
+		)
+	}()
+
+	if c.cache != nil && c.cache.Caches(prompt.name) {
+		if c.useCachedResponse(ctx, log, prompt, model, promptText, output, validationFn, &call) {
+			return nil
+		}
 	}
-	var resp *responses.Response
-	var err error
+
+	conversation := []llm.Message{{Role: llm.RoleUser, Content: promptText}}
+	var resp *llm.Response
 	for attempt := 0; attempt < c.maxRetries; attempt++ {
-		resp, err = c.doRequest(ctx, currentInput, prompt.schema, output)
+		attemptCtx, attemptSpan := c.tracer.Start(ctx, "llm_attempt", trace.WithAttributes(attribute.Int("llm.attempt", attempt+1)))
+		resp, err = c.doRequest(attemptCtx, model, conversation, prompt.schema, output)
 		lastResp = resp
+		if resp != nil {
+			attemptSpan.SetAttributes(
+				attribute.Int("llm.input_tokens", resp.InputTokens),
+				attribute.Int("llm.output_tokens", resp.OutputTokens),
+			)
+		}
+
+		// Every attempt is billed, so the tokens of the retries are added up
+		call.Attempts = attempt + 1
+		if resp != nil {
+			call.InputTokens += resp.InputTokens
+			call.OutputTokens += resp.OutputTokens
+		}
 
 		l := log
 		if resp != nil {
 			l = l.With(
-				"input_tokens", int(resp.Usage.InputTokens),
-				"output_tokens", int(resp.Usage.OutputTokens),
-				"total_tokens", int(resp.Usage.TotalTokens),
-				"response_hash", hashString(resp.OutputText()),
-				"response_len", len(resp.OutputText()),
+				"input_tokens", resp.InputTokens,
+				"output_tokens", resp.OutputTokens,
+				"total_tokens", resp.InputTokens+resp.OutputTokens,
+				"response_hash", hashString(resp.Text),
+				"response_len", len(resp.Text),
 			)
 		}
 
@@ -370,8 +470,8 @@ func (c *Client) doRequestWithRetry(ctx context.Context, prompt prompt, params a
 
 			// retry on JSON unmarshalling errors, feeding the bad response + error back
 			if isJSONUnmarshalError(err) && resp != nil {
-				conversation = appendRetryMessages(conversation, fmt.Sprintf("resp_%d", attempt), resp.OutputText(), []string{err.Error(), "Hint: only return a valid JSON object, _DO NOT_ include surrounding markdown or text"})
-				currentInput = responses.ResponseNewParamsInputUnion{OfInputItemList: conversation}
+				conversation = appendRetryMessages(conversation, resp.Text, []string{err.Error(), "Hint: only return a valid JSON object, _DO NOT_ include surrounding markdown or text"})
+				endRetriedAttempt(attemptSpan, "json_unmarshal")
 				l.Warn("llm_retry",
 					slog.String("phase", "retry"),
 					slog.Int("attempt", attempt+1),
@@ -388,10 +488,12 @@ func (c *Client) doRequestWithRetry(ctx context.Context, prompt prompt, params a
 				"total_latency", time.Since(start),
 				"err", err,
 			)
+			tracing.End(attemptSpan, err)
+			call.Failed = true
 			return err
 		}
 
-		errs, valid, err := prompt.validateJSON(extractJSON(resp.OutputText()))
+		errs, valid, err := prompt.validateJSON(extractJSON(resp.Text))
 		if err != nil {
 			l.Error("llm_json_validation_error",
 				"type", "llm_call",
@@ -408,8 +510,8 @@ func (c *Client) doRequestWithRetry(ctx context.Context, prompt prompt, params a
 			for i, e := range errs {
 				errMsgs[i] = fmt.Sprintf("%s: %s", e.Field(), e.Description())
 			}
-			conversation = appendRetryMessages(conversation, fmt.Sprintf("resp_%d", attempt), resp.OutputText(), errMsgs)
-			currentInput = responses.ResponseNewParamsInputUnion{OfInputItemList: conversation}
+			conversation = appendRetryMessages(conversation, resp.Text, errMsgs)
+			endRetriedAttempt(attemptSpan, "json_validation")
 			l.Warn("llm_retry",
 				slog.String("phase", "retry"),
 				slog.Int("attempt", attempt+1),
@@ -424,28 +526,43 @@ func (c *Client) doRequestWithRetry(ctx context.Context, prompt prompt, params a
 		if validationFn != nil {
 			if err := validationFn(); err != nil {
 				lastErr = err
-				conversation = appendRetryMessages(conversation, fmt.Sprintf("resp_%d", attempt), resp.OutputText(), []string{err.Error()})
-				currentInput = responses.ResponseNewParamsInputUnion{OfInputItemList: conversation}
+				conversation = appendRetryMessages(conversation, resp.Text, []string{err.Error()})
+				endRetriedAttempt(attemptSpan, "validation")
 				l.Warn("llm_retry",
 					"type", "llm_call",
 					"phase", "retry",
 					"attempt", attempt+1,
 					"reason", "validation",
 					"err", err,
-					"response_hash", hashString(resp.OutputText()),
-					"response_len", len(resp.OutputText()),
+					"response_hash", hashString(resp.Text),
+					"response_len", len(resp.Text),
 				)
 				continue
 			}
 		}
 
+		attemptSpan.End()
+		if c.hook != nil {
+			c.hook.AfterPrompt(ctx, prompt.name, promptText, resp.Text)
+		}
+		if c.cache != nil {
+			entry := promptcache.Entry{Response: resp.Text, InputTokens: call.InputTokens, OutputTokens: call.OutputTokens}
+			if err := c.cache.Put(ctx, model, prompt.name, promptText, entry); err != nil {
+				l.Warn("llm_cache_fail",
+					"type", "llm_call",
+					"phase", "cache",
+					"err", err,
+				)
+			}
+		}
+
 		l.Info("llm_call_ok",
 			"type", "llm_call",
 			"phase", "ok",
 			"attempts_total", attempt+1,
 			"total_latency", time.Since(start),
-			"response_hash", hashString(resp.OutputText()),
-			"response_len", len(resp.OutputText()),
+			"response_hash", hashString(resp.Text),
+			"response_len", len(resp.Text),
 		)
 		// Success
 		return nil
@@ -454,14 +571,14 @@ func (c *Client) doRequestWithRetry(ctx context.Context, prompt prompt, params a
 	l := log
 	if resp != nil {
 		l = l.With(
-			slog.Int("input_tokens", int(resp.Usage.InputTokens)),
-			slog.Int("output_tokens", int(resp.Usage.OutputTokens)),
-			slog.Int("total_tokens", int(resp.Usage.TotalTokens)),
+			slog.Int("input_tokens", resp.InputTokens),
+			slog.Int("output_tokens", resp.OutputTokens),
+			slog.Int("total_tokens", resp.InputTokens+resp.OutputTokens),
 		)
 	}
 
 	if lastResp != nil {
-		l = l.With("output_raw", lastResp.OutputText())
+		l = l.With("output_raw", lastResp.Text)
 	}
 
 	l.Error("llm_call_fail",
@@ -473,20 +590,102 @@ func (c *Client) doRequestWithRetry(ctx context.Context, prompt prompt, params a
 		"err", lastErr,
 	)
 
+	call.Failed = true
 	return fmt.Errorf("failed after %d retries: %w", c.maxRetries, lastErr)
 }
 
+// endRetriedAttempt ends the span of an attempt that is retried because of reason.
+func endRetriedAttempt(span trace.Span, reason string) {
+	span.SetAttributes(attribute.String("llm.retry_reason", reason))
+	span.SetStatus(codes.Error, reason)
+	span.End()
+}
+
+// useCachedResponse decodes the cached response to promptText into output, if there is one. A cached response that is
+// no longer valid, for example because the validation of the prompt changed, is ignored so the model is asked again.
+// Failing to read the cache is not fatal either.
+func (c *Client) useCachedResponse(ctx context.Context, log *slog.Logger, prompt prompt, model string, promptText string, output any, validationFn func() error, call *accounting.Call) bool {
+	entry, ok, err := c.cache.Get(ctx, model, prompt.name, promptText)
+	if err != nil {
+		log.Warn("llm_cache_fail",
+			"type", "llm_call",
+			"phase", "cache",
+			"err", err,
+		)
+		return false
+	}
+	if !ok {
+		return false
+	}
+
+	if err := decodeHookResponse(prompt, entry.Response, output, validationFn); err != nil {
+		log.Warn("llm_cache_invalid",
+			"type", "llm_call",
+			"phase", "cache",
+			"err", err,
+		)
+		// The output may be partly filled in by the cached response, it is reset so fields the model
+		// leaves out are not kept
+		reflect.ValueOf(output).Elem().SetZero()
+		return false
+	}
+
+	if c.hook != nil {
+		c.hook.AfterPrompt(ctx, prompt.name, promptText, entry.Response)
+	}
+
+	call.Cached = true
+	call.Attempts = 1
+	call.InputTokens = entry.InputTokens
+	call.OutputTokens = entry.OutputTokens
+
+	log.Info("llm_call_ok",
+		"type", "llm_call",
+		"phase", "cache",
+		"cached_at", entry.CreatedAt,
+		"input_tokens", entry.InputTokens,
+		"output_tokens", entry.OutputTokens,
+		"response_hash", hashString(entry.Response),
+		"response_len", len(entry.Response),
+	)
+
+	return true
+}
+
+// decodeHookResponse decodes and validates a response that did not come from the model, but from a llm.PromptHook
+func decodeHookResponse(prompt prompt, raw string, output any, validationFn func() error) error {
+	extracted := extractJSON(raw)
+	if err := json.Unmarshal([]byte(extracted), output); err != nil {
+		return fmt.Errorf("could not unmarshal json: %w", err)
+	}
+
+	errs, valid, err := prompt.validateJSON(extracted)
+	if err != nil {
+		return err
+	}
+	if !valid {
+		return fmt.Errorf("response does not match schema: %d validation errors", len(errs))
+	}
+
+	if validationFn != nil {
+		return validationFn()
+	}
+
+	return nil
+}
+
 func validationSlogIssues(errs []gojsonschema.ResultError) slog.Value {
 	attrs := make([]slog.Attr, 0, len(errs))
 
-	for _, e := range errs {
+	for i, e := range errs {
 		path := e.Field()
 		if path == "" {
 			path = "(root)"
 		}
 
+		// Every issue needs its own key, the JSON logs would otherwise only keep the last one
 		attrs = append(attrs, slog.Group(
-			"issue",
+			"issue_"+strconv.Itoa(i),
 			slog.String("path", path),
 			slog.String("message", e.Description()),
 			slog.Any("details", e.Details()),
@@ -496,18 +695,56 @@ func validationSlogIssues(errs []gojsonschema.ResultError) slog.Value {
 	return slog.GroupValue(attrs...)
 }
 
-func (c *Client) GenerateEmbedding(str string) []float64 {
-	str = strings.Replace(str, "\n", " ", -1)
-	res, err := c.client.Embeddings.New(context.Background(), openai.EmbeddingNewParams{
+func (c *Client) GenerateEmbedding(ctx context.Context, str string) ([]float64, error) {
+	embeddings, err := c.GenerateEmbeddings(ctx, []string{str})
+	if err != nil {
+		return nil, fmt.Errorf("could not generate embeddings for %s: %w", str, err)
+	}
+
+	return embeddings[0], nil
+}
+
+// GenerateEmbeddings generates the embeddings of strs with a single request, it implements llm.BatchEmbedder.
+func (c *Client) GenerateEmbeddings(ctx context.Context, strs []string) ([][]float64, error) {
+	inputs := make([]string, len(strs))
+	for i, str := range strs {
+		inputs[i] = strings.Replace(str, "\n", " ", -1)
+	}
+
+	start := time.Now()
+	res, err := c.client.Embeddings.New(ctx, openai.EmbeddingNewParams{
 		Input: openai.EmbeddingNewParamsInputUnion{
-			OfString: param.NewOpt(str),
+			OfArrayOfStrings: inputs,
 		},
 		Model:          c.embeddingModel,
 		EncodingFormat: "float",
 	})
+
+	call := accounting.Call{Model: c.embeddingModel, Prompt: "embedding", Attempts: 1, Latency: time.Since(start), Failed: err != nil}
+	if res != nil {
+		call.InputTokens = int(res.Usage.PromptTokens)
+	}
+	c.record(ctx, call)
+
 	if err != nil {
-		panic(fmt.Sprintf("Could not generate embeddings for %s: %v", str, err))
+		return nil, err
+	}
+	if len(res.Data) != len(strs) {
+		return nil, fmt.Errorf("got %d embeddings for %d inputs", len(res.Data), len(strs))
+	}
+
+	embeddings := make([][]float64, len(strs))
+	for _, data := range res.Data {
+		if data.Index < 0 || int(data.Index) >= len(strs) {
+			return nil, fmt.Errorf("got an embedding for input %d of %d", data.Index, len(strs))
+		}
+		embeddings[data.Index] = data.Embedding
 	}
 
-	return res.Data[0].Embedding
+	return embeddings, nil
+}
+
+// EmbeddingModel returns the model used to generate embeddings.
+func (c *Client) EmbeddingModel() string {
+	return c.embeddingModel
 }
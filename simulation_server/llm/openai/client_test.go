@@ -0,0 +1,271 @@
+package openai_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fvdveen/generative_agents/simulation_server/agent"
+	"github.com/fvdveen/generative_agents/simulation_server/llm"
+	"github.com/fvdveen/generative_agents/simulation_server/llm/accounting"
+	"github.com/fvdveen/generative_agents/simulation_server/llm/openai"
+	"github.com/fvdveen/generative_agents/simulation_server/llm/promptcache"
+	"github.com/fvdveen/generative_agents/simulation_server/memory"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// The prompt GenerateImportanceScore sends for events
+const importancePrompt = "poignancy_event_v2"
+
+// stubProvider answers every request with the next of its responses, the last response is repeated once they run out.
+type stubProvider struct {
+	responses []stubResponse
+	requests  []llm.Request
+}
+
+type stubResponse struct {
+	resp llm.Response
+	err  error
+}
+
+func (p *stubProvider) Complete(ctx context.Context, req llm.Request) (llm.Response, error) {
+	r := p.responses[min(len(p.requests), len(p.responses)-1)]
+	p.requests = append(p.requests, req)
+	return r.resp, r.err
+}
+
+// importance is a valid response to the importance prompt.
+func importance(n int) stubResponse {
+	return stubResponse{resp: llm.Response{Text: fmt.Sprintf(`{"reasoning": "", "poignancy": %d}`, n), InputTokens: 100, OutputTokens: 10}}
+}
+
+// logged reports whether logs contains a JSON record of the event msg in phase.
+func logged(t *testing.T, logs *bytes.Buffer, msg, phase string) bool {
+	t.Helper()
+
+	for line := range strings.Lines(logs.String()) {
+		var record struct {
+			Msg   string `json:"msg"`
+			Phase string `json:"phase"`
+		}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("could not parse log record: %v", err)
+		}
+		if record.Msg == msg && record.Phase == phase {
+			return true
+		}
+	}
+
+	return false
+}
+
+func makePersona() *agent.Persona {
+	state := agent.State{
+		FullName:    "Isabella Rodriguez",
+		CurrentTime: time.Date(2023, time.February, 13, 0, 0, 0, 0, time.UTC),
+	}
+
+	assoc := memory.NewAssociative(map[string][]float64{}, map[string]int{}, map[string]int{})
+	return agent.New(state.FullName, assoc, memory.NewSpatial(), state, nil, nil)
+}
+
+func TestPromptCache(t *testing.T) {
+	ctx := context.Background()
+	p := makePersona()
+
+	cache, err := promptcache.Open(filepath.Join(t.TempDir(), "prompts.sqlite"), map[string]time.Duration{importancePrompt: 0})
+	if err != nil {
+		t.Fatalf("could not open cache: %v", err)
+	}
+	defer cache.Close()
+
+	var logs bytes.Buffer
+	tracker := accounting.NewTracker()
+	newClient := func(provider llm.Provider) *openai.Client {
+		return openai.New(
+			openai.WithProvider(provider),
+			openai.WithPromptCache(cache),
+			openai.WithUsageTracker(tracker),
+			openai.WithLogger(slog.New(slog.NewJSONHandler(&logs, nil))),
+		)
+	}
+
+	// Failed calls are not cached
+	failing := &stubProvider{responses: []stubResponse{{err: errors.New("unavailable")}}}
+	if _, err := newClient(failing).GenerateImportanceScore(ctx, p, memory.NodeTypeEvent, "bed is idle"); err == nil {
+		t.Fatalf("Expected the call to fail")
+	}
+
+	provider := &stubProvider{responses: []stubResponse{importance(7)}}
+	c := newClient(provider)
+	for range 2 {
+		got, err := c.GenerateImportanceScore(ctx, p, memory.NodeTypeEvent, "bed is idle")
+		if err != nil {
+			t.Fatalf("could not generate importance: %v", err)
+		}
+		if got != 7 {
+			t.Errorf("Wrong importance, got: %d, want: %d", got, 7)
+		}
+	}
+	if len(provider.requests) != 1 {
+		t.Fatalf("Expected the second call to be answered from the cache, got %d requests", len(provider.requests))
+	}
+
+	total := tracker.Report().Total
+	if total.Calls != 2 || total.Failures != 1 || total.CacheHits != 1 {
+		t.Errorf("Wrong usage, got: %+v, want 2 calls, 1 failure and 1 cache hit", total)
+	}
+	if !logged(t, &logs, "llm_call_ok", "cache") {
+		t.Errorf("Expected the cache hit to be logged, got:\n%s", logs.String())
+	}
+
+	// A cached response that is no longer valid is asked for again
+	promptText := provider.requests[0].Messages[0].Content
+	if err := cache.Put(ctx, "gpt-5-nano", importancePrompt, promptText, promptcache.Entry{Response: `{"poignancy": "high"}`}); err != nil {
+		t.Fatalf("could not write cache: %v", err)
+	}
+	provider.responses = []stubResponse{importance(3)}
+	got, err := c.GenerateImportanceScore(ctx, p, memory.NodeTypeEvent, "bed is idle")
+	if err != nil {
+		t.Fatalf("could not generate importance: %v", err)
+	}
+	if got != 3 || len(provider.requests) != 2 {
+		t.Errorf("Expected the invalid cached response to be replaced, got: %d after %d requests", got, len(provider.requests))
+	}
+	if !logged(t, &logs, "llm_cache_invalid", "cache") {
+		t.Errorf("Expected the invalid cached response to be logged, got:\n%s", logs.String())
+	}
+
+	entry, ok, err := cache.Get(ctx, "gpt-5-nano", importancePrompt, promptText)
+	if err != nil || !ok || !strings.Contains(entry.Response, `"poignancy": 3`) {
+		t.Errorf("Expected the new response to be cached, got: %+v, %v, %v", entry, ok, err)
+	}
+}
+
+func TestAttemptSpans(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tracer := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)).Tracer("test")
+
+	// The first response is not JSON, so it is retried
+	provider := &stubProvider{responses: []stubResponse{
+		{resp: llm.Response{Text: "seven", InputTokens: 100, OutputTokens: 1}},
+		importance(7),
+	}}
+	c := openai.New(openai.WithProvider(provider), openai.WithTracer(tracer))
+	if _, err := c.GenerateImportanceScore(context.Background(), makePersona(), memory.NodeTypeEvent, "bed is idle"); err != nil {
+		t.Fatalf("could not generate importance: %v", err)
+	}
+
+	spans := exporter.GetSpans()
+	var prompt tracetest.SpanStub
+	var attempts []tracetest.SpanStub
+	for _, span := range spans {
+		switch span.Name {
+		case importancePrompt:
+			prompt = span
+		case "llm_attempt":
+			attempts = append(attempts, span)
+		}
+	}
+	if !prompt.SpanContext.IsValid() {
+		t.Fatalf("Expected a span for the prompt, got: %v", spans)
+	}
+	if len(attempts) != 2 {
+		t.Fatalf("Wrong amount of attempt spans, got: %d, want: %d", len(attempts), 2)
+	}
+	for _, attempt := range attempts {
+		if attempt.Parent.SpanID() != prompt.SpanContext.SpanID() {
+			t.Errorf("Expected the attempt spans to be children of the prompt span")
+		}
+	}
+
+	if got := spanAttribute(attempts[0].Attributes, "llm.retry_reason"); got.AsString() != "json_unmarshal" {
+		t.Errorf("Wrong retry reason of the first attempt, got: %q, want: %q", got.AsString(), "json_unmarshal")
+	}
+	if got := spanAttribute(attempts[1].Attributes, "llm.retry_reason"); got.Type() != attribute.INVALID {
+		t.Errorf("Expected the last attempt not to be retried, got reason: %q", got.AsString())
+	}
+	for i, attempt := range attempts {
+		if got := spanAttribute(attempt.Attributes, "llm.input_tokens"); got.AsInt64() != 100 {
+			t.Errorf("Wrong input tokens of attempt %d, got: %d, want: %d", i+1, got.AsInt64(), 100)
+		}
+	}
+
+	if got := spanAttribute(prompt.Attributes, "llm.id"); got.AsString() == "" {
+		t.Errorf("Expected the prompt span to have an llm.id")
+	}
+	for key, want := range map[attribute.Key]int64{"llm.attempts": 2, "llm.input_tokens": 200, "llm.output_tokens": 11} {
+		if got := spanAttribute(prompt.Attributes, key); got.AsInt64() != want {
+			t.Errorf("Wrong %s of the prompt span, got: %d, want: %d", key, got.AsInt64(), want)
+		}
+	}
+}
+
+// spanAttribute returns the value of the attribute with key, it is invalid when there is no such attribute.
+func spanAttribute(attrs []attribute.KeyValue, key attribute.Key) attribute.Value {
+	for _, attr := range attrs {
+		if attr.Key == key {
+			return attr.Value
+		}
+	}
+
+	return attribute.Value{}
+}
+
+func TestUsageOfRetries(t *testing.T) {
+	invalid := stubResponse{resp: llm.Response{Text: `{"reasoning": ""}`, InputTokens: 100, OutputTokens: 5}}
+
+	tests := []struct {
+		name      string
+		responses []stubResponse
+		want      accounting.Totals
+	}{
+		{
+			name:      "retried",
+			responses: []stubResponse{invalid, importance(7)},
+			want:      accounting.Totals{Calls: 1, Retries: 1, InputTokens: 200, OutputTokens: 15},
+		},
+		{
+			name:      "out of retries",
+			responses: []stubResponse{invalid},
+			want:      accounting.Totals{Calls: 1, Retries: 7, Failures: 1, InputTokens: 800, OutputTokens: 40},
+		},
+		{
+			name:      "provider error",
+			responses: []stubResponse{invalid, {err: errors.New("unavailable")}},
+			want:      accounting.Totals{Calls: 1, Retries: 1, Failures: 1, InputTokens: 100, OutputTokens: 5},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracker := accounting.NewTracker()
+			c := openai.New(openai.WithProvider(&stubProvider{responses: tt.responses}), openai.WithUsageTracker(tracker))
+			_, err := c.GenerateImportanceScore(context.Background(), makePersona(), memory.NodeTypeEvent, "bed is idle")
+			if failed := err != nil; failed != (tt.want.Failures > 0) {
+				t.Fatalf("Wrong result, got error: %v", err)
+			}
+
+			got := tracker.Report().Total
+			// Every attempt is billed, gpt-5-nano costs $0.05 per million input tokens and $0.40 per million output tokens
+			tt.want.Cost = (float64(tt.want.InputTokens)*0.05 + float64(tt.want.OutputTokens)*0.40) / 1_000_000
+			if math.Abs(got.Cost-tt.want.Cost) > 1e-12 {
+				t.Errorf("Wrong cost, got: %v, want: %v", got.Cost, tt.want.Cost)
+			}
+			got.Cost, got.Latency = tt.want.Cost, 0
+			if got != tt.want {
+				t.Errorf("Wrong usage, got: %+v, want: %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -13,21 +13,21 @@ import (
 
 // Generates an importance score for a memory of a specific type based off of the
 // persona's personality and the event description.
-func (c *Client) GenerateImportanceScore(p llm.Persona, nt memory.NodeType, description string) int {
+func (c *Client) GenerateImportanceScore(ctx context.Context, p llm.Persona, nt memory.NodeType, description string) (int, error) {
 	switch nt {
 	case memory.NodeTypeChat:
 		fmt.Println("chat importance scores should be generated by GenerateImportanceScoreChat not GenerateImportanceScore")
-		return c.GenerateImportanceScoreChat(p, p.CurrentChat(), description)
+		return c.GenerateImportanceScoreChat(ctx, p, p.CurrentChat(), description)
 	case memory.NodeTypeEvent:
-		return c.generateImportanceEvent(p, description)
+		return c.generateImportanceEvent(ctx, p, description)
 	case memory.NodeTypeThought:
-		return c.generateImportanceThought(p, description)
+		return c.generateImportanceThought(ctx, p, description)
 	default:
-		panic(fmt.Sprintf("unexpected memory.NodeType: %#v", nt))
+		return 0, fmt.Errorf("unexpected memory.NodeType: %#v", nt)
 	}
 }
 
-func (c *Client) generateImportanceThought(p llm.Persona, thought string) int {
+func (c *Client) generateImportanceThought(ctx context.Context, p llm.Persona, thought string) (int, error) {
 	prompt := prompts["poignancy_thought_v1"]
 
 	in := GeneratePoignancyThoughtV1Input{
@@ -36,14 +36,14 @@ func (c *Client) generateImportanceThought(p llm.Persona, thought string) int {
 	}
 
 	var out PoignancyThoughtV1Output
-	if err := c.doRequestWithRetry(context.Background(), prompt, in, &out, nil); err != nil {
-		panic(fmt.Sprintf("could not perform request: %v", err))
+	if err := c.doRequestWithRetry(ctx, prompt, in, &out, nil); err != nil {
+		return 0, fmt.Errorf("could not perform request: %w", err)
 	}
 
-	return out.Poignancy
+	return out.Poignancy, nil
 }
 
-func (c *Client) generateImportanceEvent(p llm.Persona, event string) int {
+func (c *Client) generateImportanceEvent(ctx context.Context, p llm.Persona, event string) (int, error) {
 	prompt := prompts["poignancy_event_v2"]
 
 	in := GeneratePoignancyEventV1Input{
@@ -52,14 +52,14 @@ func (c *Client) generateImportanceEvent(p llm.Persona, event string) int {
 	}
 
 	var out PoignancyEventV1Output
-	if err := c.doRequestWithRetry(context.Background(), prompt, in, &out, nil); err != nil {
-		panic(fmt.Sprintf("could not perform request: %v", err))
+	if err := c.doRequestWithRetry(ctx, prompt, in, &out, nil); err != nil {
+		return 0, fmt.Errorf("could not perform request: %w", err)
 	}
 
-	return out.Poignancy
+	return out.Poignancy, nil
 }
 
-func (c *Client) GenerateImportanceScoreChat(p llm.Persona, transcript []memory.Utterance, description string) int {
+func (c *Client) GenerateImportanceScoreChat(ctx context.Context, p llm.Persona, transcript []memory.Utterance, description string) (int, error) {
 	prompt := prompts["poignancy_chat_v1"]
 
 	in := GeneratePoignancyChatV1Input{
@@ -69,29 +69,29 @@ func (c *Client) GenerateImportanceScoreChat(p llm.Persona, transcript []memory.
 	}
 
 	var out PoignancyChatV1Output
-	if err := c.doRequestWithRetry(context.Background(), prompt, in, &out, nil); err != nil {
-		panic(fmt.Sprintf("could not perform request: %v", err))
+	if err := c.doRequestWithRetry(ctx, prompt, in, &out, nil); err != nil {
+		return 0, fmt.Errorf("could not perform request: %w", err)
 	}
 
-	return out.Poignancy
+	return out.Poignancy, nil
 }
 
 // GenerateValenceScore implements llm.Cognition.
-func (c *Client) GenerateValenceScore(p llm.Persona, nt memory.NodeType, description string) int {
+func (c *Client) GenerateValenceScore(ctx context.Context, p llm.Persona, nt memory.NodeType, description string) (int, error) {
 	switch nt {
 	case memory.NodeTypeChat:
 		fmt.Println("chat valence scores should be generated by GenerateValenceScoreChat not GenerateValenceScore")
-		return c.GenerateValenceScoreChat(p, p.CurrentChat(), description)
+		return c.GenerateValenceScoreChat(ctx, p, p.CurrentChat(), description)
 	case memory.NodeTypeEvent:
-		return c.generateValenceEvent(p, description)
+		return c.generateValenceEvent(ctx, p, description)
 	case memory.NodeTypeThought:
-		return c.generateValenceThought(p, description)
+		return c.generateValenceThought(ctx, p, description)
 	default:
-		panic(fmt.Sprintf("unexpected memory.NodeType: %#v", nt))
+		return 0, fmt.Errorf("unexpected memory.NodeType: %#v", nt)
 	}
 }
 
-func (c *Client) generateValenceThought(p llm.Persona, description string) int {
+func (c *Client) generateValenceThought(ctx context.Context, p llm.Persona, description string) (int, error) {
 	prompt := prompts["valence_thought_v1"]
 
 	in := GenerateValenceThoughtV1Input{
@@ -100,14 +100,14 @@ func (c *Client) generateValenceThought(p llm.Persona, description string) int {
 	}
 
 	var out ValenceThoughtV1Output
-	if err := c.doRequestWithRetry(context.Background(), prompt, in, &out, nil); err != nil {
-		panic(fmt.Sprintf("could not perform request: %v", err))
+	if err := c.doRequestWithRetry(ctx, prompt, in, &out, nil); err != nil {
+		return 0, fmt.Errorf("could not perform request: %w", err)
 	}
 
-	return out.Valence
+	return out.Valence, nil
 }
 
-func (c *Client) generateValenceEvent(p llm.Persona, description string) int {
+func (c *Client) generateValenceEvent(ctx context.Context, p llm.Persona, description string) (int, error) {
 	prompt := prompts["valence_event_v2"]
 
 	in := GenerateValenceEventV1Input{
@@ -116,14 +116,14 @@ func (c *Client) generateValenceEvent(p llm.Persona, description string) int {
 	}
 
 	var out ValenceEventV1Output
-	if err := c.doRequestWithRetry(context.Background(), prompt, in, &out, nil); err != nil {
-		panic(fmt.Sprintf("could not perform request: %v", err))
+	if err := c.doRequestWithRetry(ctx, prompt, in, &out, nil); err != nil {
+		return 0, fmt.Errorf("could not perform request: %w", err)
 	}
 
-	return out.Valence
+	return out.Valence, nil
 }
 
-func (c *Client) GenerateValenceScoreChat(p llm.Persona, transcript []memory.Utterance, description string) int {
+func (c *Client) GenerateValenceScoreChat(ctx context.Context, p llm.Persona, transcript []memory.Utterance, description string) (int, error) {
 	prompt := prompts["valence_chat_v1"]
 
 	in := GenerateValenceChatV1Input{
@@ -133,15 +133,15 @@ func (c *Client) GenerateValenceScoreChat(p llm.Persona, transcript []memory.Utt
 	}
 
 	var out ValenceChatV1Output
-	if err := c.doRequestWithRetry(context.Background(), prompt, in, &out, nil); err != nil {
-		panic(fmt.Sprintf("could not perform request: %v", err))
+	if err := c.doRequestWithRetry(ctx, prompt, in, &out, nil); err != nil {
+		return 0, fmt.Errorf("could not perform request: %w", err)
 	}
 
-	return out.Valence
+	return out.Valence, nil
 }
 
 // Generates the wake up hour for the next day based off of the persona's personality.
-func (c *Client) GenerateWakeUpHour(p llm.Persona) time.Time {
+func (c *Client) GenerateWakeUpHour(ctx context.Context, p llm.Persona) (time.Time, error) {
 	prompt := prompts["wake_up_hour_v2"]
 
 	in := WakeUpHourV2Input{
@@ -149,20 +149,20 @@ func (c *Client) GenerateWakeUpHour(p llm.Persona) time.Time {
 	}
 
 	var out WakeUpHourV2Output
-	if err := c.doRequestWithRetry(context.Background(), prompt, in, &out, nil); err != nil {
-		panic(fmt.Sprintf("could not perform request: %v", err))
+	if err := c.doRequestWithRetry(ctx, prompt, in, &out, nil); err != nil {
+		return time.Time{}, fmt.Errorf("could not perform request: %w", err)
 	}
 
 	time, err := time.Parse(hourFormat, strings.ToLower(strings.Replace(out.WakeUpTime, " ", "", -1)))
 	if err != nil {
-		panic(fmt.Sprintf("could not parse output time: %v", err))
+		return time, fmt.Errorf("could not parse output time: %w", err)
 	}
 
-	return time
+	return time, nil
 }
 
 // Generates the first daily plan for a persona.
-func (c *Client) GenerateDailyPlan(p llm.Persona, wakeUpHour time.Time) []string {
+func (c *Client) GenerateDailyPlan(ctx context.Context, p llm.Persona, wakeUpHour time.Time) ([]string, error) {
 	prompt := prompts["daily_planning_v7"]
 
 	in := DailyPlanningV7Input{
@@ -172,15 +172,15 @@ func (c *Client) GenerateDailyPlan(p llm.Persona, wakeUpHour time.Time) []string
 	}
 
 	var out DailyPlanningV7Output
-	if err := c.doRequestWithRetry(context.Background(), prompt, in, &out, nil); err != nil {
-		panic(fmt.Sprintf("could not perform request: %v", err))
+	if err := c.doRequestWithRetry(ctx, prompt, in, &out, nil); err != nil {
+		return nil, fmt.Errorf("could not perform request: %w", err)
 	}
 
-	return out.Schedule
+	return out.Schedule, nil
 }
 
 // Generates an hour schedule for a new day.
-func (c *Client) GenerateHourlySchedule(p llm.Persona, wakeUpHour time.Time) []llm.Plan {
+func (c *Client) GenerateHourlySchedule(ctx context.Context, p llm.Persona, wakeUpHour time.Time) ([]llm.Plan, error) {
 	prompt := prompts["generate_hourly_schedule_v2"]
 
 	in := GenerateHourlyScheduleV2Input{
@@ -211,8 +211,8 @@ func (c *Client) GenerateHourlySchedule(p llm.Persona, wakeUpHour time.Time) []l
 		return nil
 	}
 
-	if err := c.doRequestWithRetry(context.Background(), prompt, in, &out, validationFn); err != nil {
-		panic(fmt.Sprintf("could not perform request: %v", err))
+	if err := c.doRequestWithRetry(ctx, prompt, in, &out, validationFn); err != nil {
+		return nil, fmt.Errorf("could not perform request: %w", err)
 	}
 
 	plans := []llm.Plan{}
@@ -225,11 +225,11 @@ func (c *Client) GenerateHourlySchedule(p llm.Persona, wakeUpHour time.Time) []l
 		}
 	}
 
-	return plans
+	return plans, nil
 }
 
 // Generates a list of sub-plans that the given plan should consist of
-func (c *Client) GeneratePlanDecomposition(p llm.Persona, plan llm.Plan) []llm.Plan {
+func (c *Client) GeneratePlanDecomposition(ctx context.Context, p llm.Persona, plan llm.Plan) ([]llm.Plan, error) {
 	prompt := prompts["task_decomp_v3"]
 
 	in := TaskDecompV3Input{
@@ -302,8 +302,8 @@ func (c *Client) GeneratePlanDecomposition(p llm.Persona, plan llm.Plan) []llm.P
 		return nil
 	}
 
-	if err := c.doRequestWithRetry(context.Background(), prompt, in, &out, validationFn); err != nil {
-		panic(fmt.Sprintf("could not perform request: %v", err))
+	if err := c.doRequestWithRetry(ctx, prompt, in, &out, validationFn); err != nil {
+		return nil, fmt.Errorf("could not perform request: %w", err)
 	}
 
 	tasks := []llm.Plan{}
@@ -314,11 +314,11 @@ func (c *Client) GeneratePlanDecomposition(p llm.Persona, plan llm.Plan) []llm.P
 		})
 	}
 
-	return tasks
+	return tasks, nil
 }
 
 // Generates an updated schedule in response to an event
-func (c *Client) GenerateReactionScheduleUpdate(p llm.Persona, inserted llm.Plan, startTime, endTime time.Time) []llm.Plan {
+func (c *Client) GenerateReactionScheduleUpdate(ctx context.Context, p llm.Persona, inserted llm.Plan, startTime, endTime time.Time) ([]llm.Plan, error) {
 	prompt := prompts["new_decomp_schedule_v2"]
 
 	originalPlans := []NewDecompScheduleV2InputPlans{}
@@ -484,8 +484,8 @@ func (c *Client) GenerateReactionScheduleUpdate(p llm.Persona, inserted llm.Plan
 		)
 	}
 
-	if err := c.doRequestWithRetry(context.Background(), prompt, in, &out, validationFn); err != nil {
-		panic(fmt.Sprintf("could not perform request: %v", err))
+	if err := c.doRequestWithRetry(ctx, prompt, in, &out, validationFn); err != nil {
+		return nil, fmt.Errorf("could not perform request: %w", err)
 	}
 
 	for _, plan := range out.Schedule {
@@ -507,16 +507,16 @@ func (c *Client) GenerateReactionScheduleUpdate(p llm.Persona, inserted llm.Plan
 	}
 
 	if totalDur != originalDur {
-		panic(fmt.Sprintf("generated reaction schedule has wrong duration, expected: %d, got: %d", originalDur, totalDur))
+		return nil, fmt.Errorf("generated reaction schedule has wrong duration, expected: %d, got: %d", originalDur, totalDur)
 	}
 
-	return generatedPlans
+	return generatedPlans, nil
 }
 
 var actionRe = regexp.MustCompile(`^(.*) \((.*)\)$`)
 
 // Generates the sector an activity should take place in
-func (c *Client) GenerateActivitySector(p llm.Persona, maze llm.Maze, activity string, world string) string {
+func (c *Client) GenerateActivitySector(ctx context.Context, p llm.Persona, maze llm.Maze, activity string, world string) (string, error) {
 	prompt := prompts["action_location_sector_v3"]
 
 	action, subAction := activity, activity
@@ -548,15 +548,15 @@ func (c *Client) GenerateActivitySector(p llm.Persona, maze llm.Maze, activity s
 		return nil
 	}
 
-	if err := c.doRequestWithRetry(context.Background(), prompt, in, &out, validationFn); err != nil {
-		panic(fmt.Sprintf("could not perform request: %v", err))
+	if err := c.doRequestWithRetry(ctx, prompt, in, &out, validationFn); err != nil {
+		return "", fmt.Errorf("could not perform request: %w", err)
 	}
 
-	return out.Output
+	return out.Output, nil
 }
 
 // Generates the arena an activity should take place in
-func (c *Client) GenerateActivityArena(p llm.Persona, maze llm.Maze, activity string, world string, sector string) string {
+func (c *Client) GenerateActivityArena(ctx context.Context, p llm.Persona, maze llm.Maze, activity string, world string, sector string) (string, error) {
 	prompt := prompts["action_location_arena_v1"]
 
 	action, subAction := activity, activity
@@ -592,15 +592,15 @@ func (c *Client) GenerateActivityArena(p llm.Persona, maze llm.Maze, activity st
 		return nil
 	}
 
-	if err := c.doRequestWithRetry(context.Background(), prompt, in, &out, validationFn); err != nil {
-		panic(fmt.Sprintf("could not perform request: %v", err))
+	if err := c.doRequestWithRetry(ctx, prompt, in, &out, validationFn); err != nil {
+		return "", fmt.Errorf("could not perform request: %w", err)
 	}
 
-	return out.Output
+	return out.Output, nil
 }
 
 // Generates the object that should be used for an activity
-func (c *Client) GenerateActivityObject(p llm.Persona, maze llm.Maze, activity string, path memory.Path) string {
+func (c *Client) GenerateActivityObject(ctx context.Context, p llm.Persona, maze llm.Maze, activity string, path memory.Path) (string, error) {
 	prompt := prompts["action_object_v3"]
 
 	in := ActionObjectV1Input{
@@ -621,15 +621,15 @@ func (c *Client) GenerateActivityObject(p llm.Persona, maze llm.Maze, activity s
 		return nil
 	}
 
-	if err := c.doRequestWithRetry(context.Background(), prompt, in, &out, validationFn); err != nil {
-		panic(fmt.Sprintf("could not perform request: %v", err))
+	if err := c.doRequestWithRetry(ctx, prompt, in, &out, validationFn); err != nil {
+		return "", fmt.Errorf("could not perform request: %w", err)
 	}
 
-	return out.Output
+	return out.Output, nil
 }
 
 // Generates a pronunciato (2 emojis) representing the current activity taking place
-func (c *Client) GenerateActivityPronunciato(p llm.Persona, activity string) string {
+func (c *Client) GenerateActivityPronunciato(ctx context.Context, p llm.Persona, activity string) (string, error) {
 	prompt := prompts["generate_pronunciatio_v2"]
 
 	in := GeneratePronunciatioV2Input{
@@ -637,15 +637,15 @@ func (c *Client) GenerateActivityPronunciato(p llm.Persona, activity string) str
 	}
 
 	var out GeneratePronunciatioV2Output
-	if err := c.doRequestWithRetry(context.Background(), prompt, in, &out, nil); err != nil {
-		panic(fmt.Sprintf("could not perform request: %v", err))
+	if err := c.doRequestWithRetry(ctx, prompt, in, &out, nil); err != nil {
+		return "", fmt.Errorf("could not perform request: %w", err)
 	}
 
-	return out.Emoji
+	return out.Emoji, nil
 }
 
 // Generates a SPO (activity subject-predicate-object) triple
-func (c *Client) GenerateActivitySPO(p llm.Persona, activity string) memory.SPO {
+func (c *Client) GenerateActivitySPO(ctx context.Context, p llm.Persona, activity string) (memory.SPO, error) {
 	prompt := prompts["generate_event_triple_v2"]
 
 	in := GenerateEventTripleV2Input{
@@ -663,19 +663,19 @@ func (c *Client) GenerateActivitySPO(p llm.Persona, activity string) memory.SPO
 		return nil
 	}
 
-	if err := c.doRequestWithRetry(context.Background(), prompt, in, &out, validationFn); err != nil {
-		panic(fmt.Sprintf("could not perform request: %v", err))
+	if err := c.doRequestWithRetry(ctx, prompt, in, &out, validationFn); err != nil {
+		return memory.SPO{}, fmt.Errorf("could not perform request: %w", err)
 	}
 
 	return memory.SPO{
 		Subject:   out.Subject,
 		Predicate: out.Predicate,
 		Object:    out.Object,
-	}
+	}, nil
 }
 
 // Generates a description for the object that is used in the current activity
-func (c *Client) GenerateActivityObjectDescription(p llm.Persona, object string, activity string) string {
+func (c *Client) GenerateActivityObjectDescription(ctx context.Context, p llm.Persona, object string, activity string) (string, error) {
 	prompt := prompts["generate_obj_event_v2"]
 
 	in := GenerateObjEventV2Input{
@@ -685,15 +685,15 @@ func (c *Client) GenerateActivityObjectDescription(p llm.Persona, object string,
 	}
 
 	var out GenerateObjEventV2Output
-	if err := c.doRequestWithRetry(context.Background(), prompt, in, &out, nil); err != nil {
-		panic(fmt.Sprintf("could not perform request: %v", err))
+	if err := c.doRequestWithRetry(ctx, prompt, in, &out, nil); err != nil {
+		return "", fmt.Errorf("could not perform request: %w", err)
 	}
 
-	return out.State
+	return out.State, nil
 }
 
 // Generates a pronunciato (2 emojis) representing t for the object that is used in the current activity
-func (c *Client) GenerateActivityObjectPronunciato(p llm.Persona, activityObjectDescription string) string {
+func (c *Client) GenerateActivityObjectPronunciato(ctx context.Context, p llm.Persona, activityObjectDescription string) (string, error) {
 	prompt := prompts["generate_pronunciatio_v2"]
 
 	in := GeneratePronunciatioV2Input{
@@ -701,15 +701,15 @@ func (c *Client) GenerateActivityObjectPronunciato(p llm.Persona, activityObject
 	}
 
 	var out GeneratePronunciatioV2Output
-	if err := c.doRequestWithRetry(context.Background(), prompt, in, &out, nil); err != nil {
-		panic(fmt.Sprintf("could not perform request: %v", err))
+	if err := c.doRequestWithRetry(ctx, prompt, in, &out, nil); err != nil {
+		return "", fmt.Errorf("could not perform request: %w", err)
 	}
 
-	return out.Emoji
+	return out.Emoji, nil
 }
 
 // Generates a SPO (activity subject-predicate-object) triple
-func (c *Client) GenerateActivityObjectSPO(p llm.Persona, object string, activityObjectDescription string) memory.SPO {
+func (c *Client) GenerateActivityObjectSPO(ctx context.Context, p llm.Persona, object string, activityObjectDescription string) (memory.SPO, error) {
 	prompt := prompts["generate_event_triple_v2"]
 
 	in := GenerateEventTripleV2Input{
@@ -727,39 +727,39 @@ func (c *Client) GenerateActivityObjectSPO(p llm.Persona, object string, activit
 		return nil
 	}
 
-	if err := c.doRequestWithRetry(context.Background(), prompt, in, &out, validationFn); err != nil {
-		panic(fmt.Sprintf("could not perform request: %v", err))
+	if err := c.doRequestWithRetry(ctx, prompt, in, &out, validationFn); err != nil {
+		return memory.SPO{}, fmt.Errorf("could not perform request: %w", err)
 	}
 
 	return memory.SPO{
 		Subject:   out.Subject,
 		Predicate: out.Predicate,
 		Object:    out.Object,
-	}
+	}, nil
 }
 
 // Generates whether Persona init wants to talk to persona target
-func (c *Client) GenerateDecideToTalk(init, target llm.Persona, events, thoughts []memory.NodeId) bool {
+func (c *Client) GenerateDecideToTalk(ctx context.Context, init, target llm.Persona, events, thoughts []memory.NodeId) (bool, error) {
 	prompt := prompts["decide_to_talk_v3"]
 
-	var ctx strings.Builder
+	var promptContext strings.Builder
 	if len(events) != 0 {
-		ctx.WriteString("Observations: ")
+		promptContext.WriteString("Observations: ")
 		for _, node := range events {
 			event := init.GetMemory(node)
 			desc := strings.Replace(event.Description, "is", "was", 1)
-			ctx.WriteString(desc + ". ")
+			promptContext.WriteString(desc + ". ")
 		}
 	}
 	if len(thoughts) != 0 {
-		ctx.WriteString(", Thoughts: ")
+		promptContext.WriteString(", Thoughts: ")
 		for _, node := range thoughts {
 			thought := init.GetMemory(node)
-			ctx.WriteString(thought.Description + ". ")
+			promptContext.WriteString(thought.Description + ". ")
 		}
 	}
 	if len(thoughts) == 0 && len(events) == 0 {
-		ctx.WriteString("None")
+		promptContext.WriteString("None")
 	}
 
 	var lastChatTime, lastChatTopic string
@@ -801,44 +801,44 @@ func (c *Client) GenerateDecideToTalk(init, target llm.Persona, events, thoughts
 		Target:          target,
 		InitiatorStatus: initStat,
 		TargetStatus:    targetStat,
-		Context:         ctx.String(),
+		Context:         promptContext.String(),
 		CurrentTime:     init.CurrentTime().Format(hourFormat24),
 		LastChatTime:    lastChatTime,
 		LastChatTopic:   lastChatTopic,
 	}
 
 	var out DecideToTalkV3Output
-	if err := c.doRequestWithRetry(context.Background(), prompt, in, &out, nil); err != nil {
-		panic(fmt.Sprintf("could not perform request: %v", err))
+	if err := c.doRequestWithRetry(ctx, prompt, in, &out, nil); err != nil {
+		return false, fmt.Errorf("could not perform request: %w", err)
 	}
 
-	return strings.ToLower(out.ShouldTalk) == "yes"
+	return strings.ToLower(out.ShouldTalk) == "yes", nil
 }
 
 // Generates whether init should wait until target has finished their activity before approaching them,
 // or init should continue with their own activity.
 // NOTE(Friso): In the original code this is called generate_decide_to_react, but this name is more apt.
-func (c *Client) GenerateDecideToWait(init, target llm.Persona, events, thoughts []memory.NodeId) (wait bool) {
+func (c *Client) GenerateDecideToWait(ctx context.Context, init, target llm.Persona, events, thoughts []memory.NodeId) (wait bool, err error) {
 	prompt := prompts["decide_to_react_v2"]
 
-	var ctx strings.Builder
+	var promptContext strings.Builder
 	if len(events) != 0 {
-		ctx.WriteString("Observations: ")
+		promptContext.WriteString("Observations: ")
 		for _, node := range events {
 			event := init.GetMemory(node)
 			desc := strings.Replace(event.Description, "is", "was", 1)
-			ctx.WriteString(desc + ". ")
+			promptContext.WriteString(desc + ". ")
 		}
 	}
 	if len(thoughts) != 0 {
-		ctx.WriteString(", Thoughts: ")
+		promptContext.WriteString(", Thoughts: ")
 		for _, node := range thoughts {
 			thought := init.GetMemory(node)
-			ctx.WriteString(thought.Description + ". ")
+			promptContext.WriteString(thought.Description + ". ")
 		}
 	}
 	if len(thoughts) == 0 && len(events) == 0 {
-		ctx.WriteString("None")
+		promptContext.WriteString("None")
 	}
 
 	initStat, targetStat := init.ActivityDescription(), target.ActivityDescription()
@@ -872,20 +872,20 @@ func (c *Client) GenerateDecideToWait(init, target llm.Persona, events, thoughts
 		Target:          target,
 		InitiatorStatus: initStat,
 		TargetStatus:    targetStat,
-		Context:         ctx.String(),
+		Context:         promptContext.String(),
 		CurrentTime:     init.CurrentTime().Format(hourFormat24),
 		TargetEndTime:   target.ActivityEndTime(target.DailyScheduleIdx()).Format(hourFormat24),
 	}
 
 	var out DecideToReactV2Output
-	if err := c.doRequestWithRetry(context.Background(), prompt, in, &out, nil); err != nil {
-		panic(fmt.Sprintf("could not perform request: %v", err))
+	if err := c.doRequestWithRetry(ctx, prompt, in, &out, nil); err != nil {
+		return false, fmt.Errorf("could not perform request: %w", err)
 	}
 
-	return out.Choice == 1
+	return out.Choice == 1, nil
 }
 
-func (c *Client) GenerateOneUtterance(init, target llm.Persona, maze llm.Maze, currentChat []memory.Utterance, relevant []memory.NodeId, relationship string) (utt memory.Utterance, endConversation bool) {
+func (c *Client) GenerateOneUtterance(ctx context.Context, init, target llm.Persona, maze llm.Maze, currentChat []memory.Utterance, relevant []memory.NodeId, relationship string) (utt memory.Utterance, endConversation bool, err error) {
 	prompt := prompts["iterative_convo_v2"]
 
 	location := maze.GetTile(init.Position())
@@ -900,18 +900,18 @@ func (c *Client) GenerateOneUtterance(init, target llm.Persona, maze llm.Maze, c
 	}
 
 	var out IterativeConvoV2Output
-	if err := c.doRequestWithRetry(context.Background(), prompt, in, &out, nil); err != nil {
-		panic(fmt.Sprintf("could not perform request: %v", err))
+	if err := c.doRequestWithRetry(ctx, prompt, in, &out, nil); err != nil {
+		return memory.Utterance{}, false, fmt.Errorf("could not perform request: %w", err)
 	}
 
 	return memory.Utterance{
 		Speaker:  init.Name(),
 		Sentence: out.Utterance,
-	}, out.EndsConversation
+	}, out.EndsConversation, nil
 }
 
 // GenerateRelationshipSummary implements llm.Cognition.
-func (c *Client) GenerateRelationshipSummary(init llm.Persona, target llm.Persona, memories []memory.NodeId) string {
+func (c *Client) GenerateRelationshipSummary(ctx context.Context, init llm.Persona, target llm.Persona, memories []memory.NodeId) (string, error) {
 	prompt := prompts["summarize_chat_relationship_v2"]
 
 	in := SummarizeChatRelationshipV2Input{
@@ -921,15 +921,15 @@ func (c *Client) GenerateRelationshipSummary(init llm.Persona, target llm.Person
 	}
 
 	var out SummarizeChatRelationshipV2Output
-	if err := c.doRequestWithRetry(context.Background(), prompt, in, &out, nil); err != nil {
-		panic(fmt.Sprintf("could not perform request: %v", err))
+	if err := c.doRequestWithRetry(ctx, prompt, in, &out, nil); err != nil {
+		return "", fmt.Errorf("could not perform request: %w", err)
 	}
 
-	return out.RelationshipSummary
+	return out.RelationshipSummary, nil
 }
 
 // Generates a summary for a conversation that a persona had
-func (c *Client) GenerateConversationSummary(p llm.Persona, conversation []memory.Utterance) string {
+func (c *Client) GenerateConversationSummary(ctx context.Context, p llm.Persona, conversation []memory.Utterance) (string, error) {
 	prompt := prompts["summarize_conversation_v2"]
 
 	in := SummarizeConversationV2Input{
@@ -937,15 +937,15 @@ func (c *Client) GenerateConversationSummary(p llm.Persona, conversation []memor
 	}
 
 	var out SummarizeConversationV2Output
-	if err := c.doRequestWithRetry(context.Background(), prompt, in, &out, nil); err != nil {
-		panic(fmt.Sprintf("could not perform request: %v", err))
+	if err := c.doRequestWithRetry(ctx, prompt, in, &out, nil); err != nil {
+		return "", fmt.Errorf("could not perform request: %w", err)
 	}
 
-	return out.Summary
+	return out.Summary, nil
 }
 
 // Generates a change in planning for p that should be remembered based off of a conversation
-func (c *Client) GeneratePlanningThoughtAfterConversation(p llm.Persona, conversation []memory.Utterance) string {
+func (c *Client) GeneratePlanningThoughtAfterConversation(ctx context.Context, p llm.Persona, conversation []memory.Utterance) (string, error) {
 	prompt := prompts["planning_thought_on_convo_v2"]
 
 	in := PlanningThoughtOnConvoV2Input{
@@ -954,15 +954,15 @@ func (c *Client) GeneratePlanningThoughtAfterConversation(p llm.Persona, convers
 	}
 
 	var out PlanningThoughtOnConvoV2Output
-	if err := c.doRequestWithRetry(context.Background(), prompt, in, &out, nil); err != nil {
-		panic(fmt.Sprintf("could not perform request: %v", err))
+	if err := c.doRequestWithRetry(ctx, prompt, in, &out, nil); err != nil {
+		return "", fmt.Errorf("could not perform request: %w", err)
 	}
 
-	return out.PlanningThought
+	return out.PlanningThought, nil
 }
 
 // Generates anything noteworthy that should be remembered after a conversation
-func (c *Client) GenerateMemoAfterConversation(p llm.Persona, conversation []memory.Utterance) string {
+func (c *Client) GenerateMemoAfterConversation(ctx context.Context, p llm.Persona, conversation []memory.Utterance) (string, error) {
 	prompt := prompts["memo_on_convo_v1"]
 
 	in := MemoOnConvoV1Input{
@@ -971,15 +971,15 @@ func (c *Client) GenerateMemoAfterConversation(p llm.Persona, conversation []mem
 	}
 
 	var out MemoOnConvoV1Output
-	if err := c.doRequestWithRetry(context.Background(), prompt, in, &out, nil); err != nil {
-		panic(fmt.Sprintf("could not perform request: %v", err))
+	if err := c.doRequestWithRetry(ctx, prompt, in, &out, nil); err != nil {
+		return "", fmt.Errorf("could not perform request: %w", err)
 	}
 
-	return out.Memo
+	return out.Memo, nil
 }
 
 // Generates a list of focal points to address during reflection
-func (c *Client) GenerateFocalPoints(p llm.Persona, statements []memory.NodeId, numFocalPoints int) []string {
+func (c *Client) GenerateFocalPoints(ctx context.Context, p llm.Persona, statements []memory.NodeId, numFocalPoints int) ([]string, error) {
 	prompt := prompts["generate_focal_pt_v2"]
 
 	in := GenerateFocalPtV2Input{
@@ -989,15 +989,15 @@ func (c *Client) GenerateFocalPoints(p llm.Persona, statements []memory.NodeId,
 	}
 
 	var out GenerateFocalPtV2Output
-	if err := c.doRequestWithRetry(context.Background(), prompt, in, &out, nil); err != nil {
-		panic(fmt.Sprintf("could not perform request: %v", err))
+	if err := c.doRequestWithRetry(ctx, prompt, in, &out, nil); err != nil {
+		return nil, fmt.Errorf("could not perform request: %w", err)
 	}
 
-	return out.FocalPoints
+	return out.FocalPoints, nil
 }
 
 // Generates insights based off of the evidence presented in nodes
-func (c *Client) GenerateInsightAndEvidence(p llm.Persona, nodes []memory.NodeId, insightCount int) map[string][]memory.NodeId {
+func (c *Client) GenerateInsightAndEvidence(ctx context.Context, p llm.Persona, nodes []memory.NodeId, insightCount int) (map[string][]memory.NodeId, error) {
 	prompt := prompts["insight_and_evidence_v2"]
 
 	in := InsightAndEvidenceV2Input{
@@ -1019,8 +1019,8 @@ func (c *Client) GenerateInsightAndEvidence(p llm.Persona, nodes []memory.NodeId
 		return nil
 	}
 
-	if err := c.doRequestWithRetry(context.Background(), prompt, in, &out, validationFn); err != nil {
-		panic(fmt.Sprintf("could not perform request: %v", err))
+	if err := c.doRequestWithRetry(ctx, prompt, in, &out, validationFn); err != nil {
+		return nil, fmt.Errorf("could not perform request: %w", err)
 	}
 
 	insights := map[string][]memory.NodeId{}
@@ -1035,11 +1035,11 @@ func (c *Client) GenerateInsightAndEvidence(p llm.Persona, nodes []memory.NodeId
 		insights[i.Insight] = evidence
 	}
 
-	return insights
+	return insights, nil
 }
 
 // GeneratePlanningFeelings implements llm.Cognition.
-func (c *Client) GeneratePlanningFeelings(p llm.Persona, statements []string) string {
+func (c *Client) GeneratePlanningFeelings(ctx context.Context, p llm.Persona, statements []string) (string, error) {
 	prompt := prompts["describe_agent_feelings_v1"]
 
 	in := DescribeAgentFeelingsV1Input{
@@ -1048,15 +1048,15 @@ func (c *Client) GeneratePlanningFeelings(p llm.Persona, statements []string) st
 	}
 
 	var out DescribeAgentFeelingsV1Output
-	if err := c.doRequestWithRetry(context.Background(), prompt, in, &out, nil); err != nil {
-		panic(fmt.Sprintf("could not perform request: %v", err))
+	if err := c.doRequestWithRetry(ctx, prompt, in, &out, nil); err != nil {
+		return "", fmt.Errorf("could not perform request: %w", err)
 	}
 
-	return out.Feelings
+	return out.Feelings, nil
 }
 
 // GeneratePlanningNote implements llm.Cognition.
-func (c *Client) GeneratePlanningNote(p llm.Persona, statements []string) string {
+func (c *Client) GeneratePlanningNote(ctx context.Context, p llm.Persona, statements []string) (string, error) {
 	prompt := prompts["extract_scheduling_information_v1"]
 
 	in := ExtractSchedulingInformationV1Input{
@@ -1066,15 +1066,15 @@ func (c *Client) GeneratePlanningNote(p llm.Persona, statements []string) string
 	}
 
 	var out ExtractSchedulingInformationV1Output
-	if err := c.doRequestWithRetry(context.Background(), prompt, in, &out, nil); err != nil {
-		panic(fmt.Sprintf("could not perform request: %v", err))
+	if err := c.doRequestWithRetry(ctx, prompt, in, &out, nil); err != nil {
+		return "", fmt.Errorf("could not perform request: %w", err)
 	}
 
-	return out.Memory
+	return out.Memory, nil
 }
 
 // GenerateCurrentPlans implements llm.Cognition.
-func (c *Client) GenerateCurrentPlans(p llm.Persona, plans string, thoughts string) string {
+func (c *Client) GenerateCurrentPlans(ctx context.Context, p llm.Persona, plans string, thoughts string) (string, error) {
 	prompt := prompts["generate_currently_v1"]
 
 	in := GenerateCurrentlyV1Input{
@@ -1086,14 +1086,14 @@ func (c *Client) GenerateCurrentPlans(p llm.Persona, plans string, thoughts stri
 	}
 
 	var out GenerateCurrentlyV1Output
-	if err := c.doRequestWithRetry(context.Background(), prompt, in, &out, nil); err != nil {
-		panic(fmt.Sprintf("could not perform request: %v", err))
+	if err := c.doRequestWithRetry(ctx, prompt, in, &out, nil); err != nil {
+		return "", fmt.Errorf("could not perform request: %w", err)
 	}
 
-	return out.Status
+	return out.Status, nil
 }
 
-func (c *Client) GenerateNewDailyRequirements(p llm.Persona) string {
+func (c *Client) GenerateNewDailyRequirements(ctx context.Context, p llm.Persona) (string, error) {
 	prompt := prompts["revise_daily_requirements_v1"]
 
 	in := ReviseDailyRequirementsV1Input{
@@ -1102,14 +1102,60 @@ func (c *Client) GenerateNewDailyRequirements(p llm.Persona) string {
 	}
 
 	var out ReviseDailyRequirementsV1Output
-	if err := c.doRequestWithRetry(context.Background(), prompt, in, &out, nil); err != nil {
-		panic(fmt.Sprintf("could not perform request: %v", err))
+	if err := c.doRequestWithRetry(ctx, prompt, in, &out, nil); err != nil {
+		return "", fmt.Errorf("could not perform request: %w", err)
 	}
 
-	return out.Day
+	return out.Day, nil
 }
 
-func (c *Client) GenerateExpandedMemoryDescription(p llm.Persona, chat []memory.Utterance, description string) string {
+// GenerateDaySummary implements llm.Cognition.
+func (c *Client) GenerateDaySummary(ctx context.Context, p llm.Persona, eventCount int) ([]string, error) {
+	prompt := prompts["summarize_day_v1"]
+
+	in := SummarizeDayV1Input{
+		Persona:    p,
+		Date:       p.StartOfDay().Format("Monday January 02"),
+		EventCount: eventCount,
+	}
+
+	start := p.StartOfDay()
+	for _, plan := range p.DailySchedule() {
+		end := start.Add(time.Duration(plan.Duration) * time.Minute)
+		in.Schedule = append(in.Schedule, SummarizeDayV1InputActivity{
+			StartTime: start.Format(hourFormat24),
+			EndTime:   end.Format(hourFormat24),
+			Activity:  plan.Activity,
+		})
+		start = end
+	}
+
+	var out SummarizeDayV1Output
+	if err := c.doRequestWithRetry(ctx, prompt, in, &out, nil); err != nil {
+		return nil, fmt.Errorf("could not perform request: %w", err)
+	}
+
+	return out.MemorableEvents, nil
+}
+
+// GenerateDayInterpretation implements llm.Cognition.
+func (c *Client) GenerateDayInterpretation(ctx context.Context, p llm.Persona, events []string) (string, string, error) {
+	prompt := prompts["interpret_day_v1"]
+
+	in := InterpretDayV1Input{
+		Persona: p,
+		Events:  events,
+	}
+
+	var out InterpretDayV1Output
+	if err := c.doRequestWithRetry(ctx, prompt, in, &out, nil); err != nil {
+		return "", "", fmt.Errorf("could not perform request: %w", err)
+	}
+
+	return out.FeelingsAboutToday, out.WantsTomorrow, nil
+}
+
+func (c *Client) GenerateExpandedMemoryDescription(ctx context.Context, p llm.Persona, chat []memory.Utterance, description string) (string, error) {
 	prompt := prompts["expand_memory_description_v1"]
 
 	in := GenerateExpandedMemoryDescriptionV1Input{
@@ -1119,9 +1165,60 @@ func (c *Client) GenerateExpandedMemoryDescription(p llm.Persona, chat []memory.
 	}
 
 	var out GenerateExpandedMemoryDescriptionV1Output
-	if err := c.doRequestWithRetry(context.Background(), prompt, in, &out, nil); err != nil {
-		panic(fmt.Sprintf("could not perform request: %v", err))
+	if err := c.doRequestWithRetry(ctx, prompt, in, &out, nil); err != nil {
+		return "", fmt.Errorf("could not perform request: %w", err)
+	}
+
+	return out.Description, nil
+}
+
+func (c *Client) GenerateWhisperedThought(ctx context.Context, p llm.Persona, whisper string) (string, error) {
+	prompt := prompts["whisper_inner_thought_v1"]
+
+	in := WhisperInnerThoughtV1Input{
+		Persona: p,
+		Whisper: whisper,
+	}
+
+	var out WhisperInnerThoughtV1Output
+	if err := c.doRequestWithRetry(ctx, prompt, in, &out, nil); err != nil {
+		return "", fmt.Errorf("could not perform request: %w", err)
+	}
+
+	return out.Statement, nil
+}
+
+func (c *Client) GenerateInterviewSummary(ctx context.Context, p llm.Persona, question string, memories []memory.NodeId) (string, error) {
+	prompt := prompts["summarize_ideas_v1"]
+
+	in := SummarizeIdeasV1Input{
+		Persona:  p,
+		Question: question,
+		Memories: memories,
+	}
+
+	var out SummarizeIdeasV1Output
+	if err := c.doRequestWithRetry(ctx, prompt, in, &out, nil); err != nil {
+		return "", fmt.Errorf("could not perform request: %w", err)
+	}
+
+	return out.Summary, nil
+}
+
+func (c *Client) GenerateInterviewAnswer(ctx context.Context, p llm.Persona, interviewer string, conversation []memory.Utterance, summary string) (string, error) {
+	prompt := prompts["generate_next_convo_line_v1"]
+
+	in := GenerateNextConvoLineV1Input{
+		Persona:      p,
+		Interviewer:  interviewer,
+		Conversation: conversation,
+		Summary:      summary,
+	}
+
+	var out GenerateNextConvoLineV1Output
+	if err := c.doRequestWithRetry(ctx, prompt, in, &out, nil); err != nil {
+		return "", fmt.Errorf("could not perform request: %w", err)
 	}
 
-	return out.Description
+	return out.Utterance, nil
 }
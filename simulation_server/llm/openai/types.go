@@ -180,6 +180,24 @@ type InsightAndEvidenceV2Input struct {
 	Count      int
 }
 
+type WhisperInnerThoughtV1Input struct {
+	Persona llm.Persona
+	Whisper string
+}
+
+type SummarizeIdeasV1Input struct {
+	Persona  llm.Persona
+	Question string
+	Memories []memory.NodeId
+}
+
+type GenerateNextConvoLineV1Input struct {
+	Persona      llm.Persona
+	Interviewer  string
+	Conversation []memory.Utterance
+	Summary      string
+}
+
 type DescribeAgentFeelingsV1Input struct {
 	Persona    llm.Persona
 	Statements []string
@@ -208,6 +226,23 @@ type GenerateExpandedMemoryDescriptionV1Input struct {
 	Description string
 }
 
+type SummarizeDayV1InputActivity struct {
+	StartTime, EndTime string
+	Activity           string
+}
+
+type SummarizeDayV1Input struct {
+	Persona    llm.Persona
+	Date       string
+	Schedule   []SummarizeDayV1InputActivity
+	EventCount int
+}
+
+type InterpretDayV1Input struct {
+	Persona llm.Persona
+	Events  []string
+}
+
 // Output structs for prompts
 // ActionLocationObjectV2Output represents the output for ActionLocationObjectV2 prompt
 type ActionLocationObjectV2Output struct {
@@ -419,7 +454,9 @@ type SummarizeConversationV2Output struct {
 }
 
 // SummarizeDayV1Output represents the output for SummarizeDayV1 prompt
-type SummarizeDayV1Output []string
+type SummarizeDayV1Output struct {
+	MemorableEvents []string `json:"memorable_events"`
+}
 
 // SummarizeIdeasV1Output represents the output for SummarizeIdeasV1 prompt
 type SummarizeIdeasV1Output struct {
@@ -0,0 +1,125 @@
+// Package promptcache stores the responses of language models to prompts, so prompts that are sent again can be
+// answered without querying the model.
+package promptcache
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+const schema = `
+CREATE TABLE IF NOT EXISTS responses (
+	key TEXT PRIMARY KEY,
+	model TEXT NOT NULL,
+	prompt_name TEXT NOT NULL,
+	response TEXT NOT NULL,
+	input_tokens INTEGER NOT NULL,
+	output_tokens INTEGER NOT NULL,
+	created_at INTEGER NOT NULL
+);
+`
+
+// Entry is a cached response to a prompt.
+type Entry struct {
+	Response string
+	// The tokens it took to generate the response, including retries
+	InputTokens  int
+	OutputTokens int
+	CreatedAt    time.Time
+}
+
+// Cache stores responses to prompts in a SQLite database, keyed by the model, the name of the prompt and the prompt itself.
+// Only the prompts that were given a time to live are cached.
+type Cache struct {
+	db *sql.DB
+	// How long the responses to a prompt are kept, by name, 0 keeps them forever
+	ttls map[string]time.Duration
+	now  func() time.Time
+}
+
+// Open opens the cache in file, creating it if it does not exist yet.
+func Open(file string, ttls map[string]time.Duration) (*Cache, error) {
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		return nil, fmt.Errorf("could not create cache folder: %w", err)
+	}
+
+	db, err := sql.Open("sqlite", file+"?_pragma=journal_mode(WAL)&_pragma=synchronous(NORMAL)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, fmt.Errorf("could not open cache: %w", err)
+	}
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(schema); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("could not create tables: %w", err)
+	}
+
+	return &Cache{db: db, ttls: ttls, now: time.Now}, nil
+}
+
+func (c *Cache) Close() error {
+	return c.db.Close()
+}
+
+// Caches reports whether the responses to the prompt with name are cached.
+func (c *Cache) Caches(name string) bool {
+	_, ok := c.ttls[name]
+	return ok
+}
+
+func key(model, name, prompt string) string {
+	sum := sha256.Sum256([]byte(model + "\x00" + name + "\x00" + prompt))
+	return hex.EncodeToString(sum[:])
+}
+
+// Get returns the cached response of model to prompt, if it has not expired yet.
+func (c *Cache) Get(ctx context.Context, model, name, prompt string) (Entry, bool, error) {
+	ttl, ok := c.ttls[name]
+	if !ok {
+		return Entry{}, false, nil
+	}
+
+	var entry Entry
+	var createdAt int64
+	err := c.db.QueryRowContext(ctx,
+		"SELECT response, input_tokens, output_tokens, created_at FROM responses WHERE key = ?", key(model, name, prompt),
+	).Scan(&entry.Response, &entry.InputTokens, &entry.OutputTokens, &createdAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Entry{}, false, nil
+	} else if err != nil {
+		return Entry{}, false, fmt.Errorf("could not read prompt cache: %w", err)
+	}
+
+	entry.CreatedAt = time.Unix(createdAt, 0)
+	if ttl > 0 && c.now().Sub(entry.CreatedAt) > ttl {
+		return Entry{}, false, nil
+	}
+
+	return entry, true, nil
+}
+
+// Put caches the response of model to prompt, if the responses to the prompt are cached.
+func (c *Cache) Put(ctx context.Context, model, name, prompt string, entry Entry) error {
+	if !c.Caches(name) {
+		return nil
+	}
+
+	_, err := c.db.ExecContext(ctx,
+		"INSERT OR REPLACE INTO responses (key, model, prompt_name, response, input_tokens, output_tokens, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		key(model, name, prompt), model, name, entry.Response, entry.InputTokens, entry.OutputTokens, c.now().Unix(),
+	)
+	if err != nil {
+		return fmt.Errorf("could not write prompt cache: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,60 @@
+package promptcache
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCache(t *testing.T) {
+	ctx := context.Background()
+	file := filepath.Join(t.TempDir(), "cache.sqlite")
+
+	cache, err := Open(file, map[string]time.Duration{"wake_up_hour": time.Hour, "insight": 0})
+	if err != nil {
+		t.Fatalf("could not open cache: %v", err)
+	}
+	defer cache.Close()
+
+	now := time.Date(2023, time.February, 13, 8, 0, 0, 0, time.UTC)
+	cache.now = func() time.Time { return now }
+
+	entry := Entry{Response: `{"hour": 6}`, InputTokens: 100, OutputTokens: 10}
+	for _, name := range []string{"wake_up_hour", "insight", "daily_plan"} {
+		if err := cache.Put(ctx, "model", name, "prompt", entry); err != nil {
+			t.Fatalf("could not cache response: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name   string
+		model  string
+		prompt string
+		after  time.Duration
+		want   bool
+	}{
+		{name: "wake_up_hour", model: "model", prompt: "prompt", after: time.Minute, want: true},
+		{name: "wake_up_hour", model: "model", prompt: "prompt", after: 2 * time.Hour, want: false},
+		{name: "wake_up_hour", model: "other model", prompt: "prompt", want: false},
+		{name: "wake_up_hour", model: "model", prompt: "other prompt", want: false},
+		{name: "insight", model: "model", prompt: "prompt", after: 24 * 365 * time.Hour, want: true},
+		// Not cached, so never stored
+		{name: "daily_plan", model: "model", prompt: "prompt", want: false},
+	}
+
+	start := now
+	for _, test := range tests {
+		now = start.Add(test.after)
+		got, ok, err := cache.Get(ctx, test.model, test.name, test.prompt)
+		if err != nil {
+			t.Fatalf("could not read cache: %v", err)
+		}
+		if ok != test.want {
+			t.Errorf("Wrong hit for %s of %s after %s, got: %v, want: %v", test.name, test.model, test.after, ok, test.want)
+		}
+		if ok && (got.Response != entry.Response || got.InputTokens != entry.InputTokens || got.OutputTokens != entry.OutputTokens) {
+			t.Errorf("Wrong entry for %s, got: %+v, want: %+v", test.name, got, entry)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package llm
+
+import "context"
+
+type Role string
+
+const (
+	RoleUser      Role = "user"
+	RoleAssistant Role = "assistant"
+)
+
+// Message is a single message of a conversation with a text model.
+type Message struct {
+	Role    Role
+	Content string
+}
+
+// Request asks a text model to continue a conversation with a JSON document.
+type Request struct {
+	Model string
+	// The conversation so far, it starts with a message of the user and alternates between the user and the assistant
+	Messages []Message
+	// The name of the JSON schema, which only contains letters, digits and underscores
+	SchemaName string
+	// The JSON schema the response should follow, the response is not guaranteed to follow it
+	Schema map[string]any
+}
+
+// Response is the response of a text model to a Request.
+type Response struct {
+	// The JSON document generated by the model
+	Text         string
+	InputTokens  int
+	OutputTokens int
+}
+
+// Provider sends requests to text models served through a specific API.
+type Provider interface {
+	Complete(ctx context.Context, req Request) (Response, error)
+}
@@ -0,0 +1,149 @@
+package provider
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"strings"
+
+	"github.com/fvdveen/generative_agents/simulation_server/llm"
+)
+
+const anthropicVersion = "2023-06-01"
+
+// Anthropic sends requests to the Anthropic Messages API.
+// The API cannot be told to respond with JSON, so the model is made to call a tool whose input is the response instead.
+type Anthropic struct {
+	opts options
+}
+
+func NewAnthropic(opts ...Opt) *Anthropic {
+	o := newOptions(opts)
+	if o.url == "" {
+		o.url = "https://api.anthropic.com"
+	}
+
+	return &Anthropic{opts: o}
+}
+
+type anthropicMessage struct {
+	Role    llm.Role `json:"role"`
+	Content string   `json:"content"`
+}
+
+type anthropicTool struct {
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	InputSchema map[string]any `json:"input_schema"`
+}
+
+type anthropicToolChoice struct {
+	Type string `json:"type"`
+	Name string `json:"name"`
+}
+
+type anthropicRequest struct {
+	Model       string              `json:"model"`
+	MaxTokens   int                 `json:"max_tokens"`
+	Temperature float64             `json:"temperature"`
+	Messages    []anthropicMessage  `json:"messages"`
+	Tools       []anthropicTool     `json:"tools"`
+	ToolChoice  anthropicToolChoice `json:"tool_choice"`
+}
+
+type anthropicResponse struct {
+	Content []struct {
+		Type  string          `json:"type"`
+		Text  string          `json:"text"`
+		Input json.RawMessage `json:"input"`
+	} `json:"content"`
+	Usage struct {
+		InputTokens  int `json:"input_tokens"`
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
+}
+
+type anthropicError struct {
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (a *Anthropic) Complete(ctx context.Context, req llm.Request) (llm.Response, error) {
+	messages := make([]anthropicMessage, 0, len(req.Messages))
+	for _, msg := range req.Messages {
+		if msg.Role != llm.RoleUser && msg.Role != llm.RoleAssistant {
+			return llm.Response{}, fmt.Errorf("unknown role %q", msg.Role)
+		}
+		messages = append(messages, anthropicMessage(msg))
+	}
+
+	// The API only accepts the schema itself, not the version of JSON schema it is written in
+	schema := maps.Clone(req.Schema)
+	delete(schema, "$schema")
+
+	body, err := json.Marshal(anthropicRequest{
+		Model:       req.Model,
+		MaxTokens:   a.opts.maxTokens,
+		Temperature: temperature,
+		Messages:    messages,
+		Tools:       []anthropicTool{{Name: req.SchemaName, Description: "Respond with the requested information", InputSchema: schema}},
+		ToolChoice:  anthropicToolChoice{Type: "tool", Name: req.SchemaName},
+	})
+	if err != nil {
+		return llm.Response{}, fmt.Errorf("could not encode request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(a.opts.url, "/")+"/v1/messages", bytes.NewReader(body))
+	if err != nil {
+		return llm.Response{}, fmt.Errorf("could not create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("X-Api-Key", a.opts.apiKey)
+	httpReq.Header.Set("Anthropic-Version", anthropicVersion)
+
+	httpResp, err := a.opts.httpClient.Do(httpReq)
+	if err != nil {
+		return llm.Response{}, fmt.Errorf("could not send request: %w", err)
+	}
+	defer func() { _ = httpResp.Body.Close() }()
+
+	content, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return llm.Response{}, fmt.Errorf("could not read response: %w", err)
+	}
+	if httpResp.StatusCode != http.StatusOK {
+		var apiErr anthropicError
+		if err := json.Unmarshal(content, &apiErr); err != nil || apiErr.Error.Message == "" {
+			return llm.Response{}, fmt.Errorf("request failed with status %s", httpResp.Status)
+		}
+		return llm.Response{}, fmt.Errorf("request failed with status %s: %s: %s", httpResp.Status, apiErr.Error.Type, apiErr.Error.Message)
+	}
+
+	var resp anthropicResponse
+	if err := json.Unmarshal(content, &resp); err != nil {
+		return llm.Response{}, fmt.Errorf("could not decode response: %w", err)
+	}
+
+	// The model may explain itself before calling the tool, that text is only used when it did not call the tool
+	var text strings.Builder
+	for _, block := range resp.Content {
+		if block.Type == "tool_use" {
+			text.Reset()
+			text.Write(block.Input)
+			break
+		}
+		text.WriteString(block.Text)
+	}
+
+	return llm.Response{
+		Text:         text.String(),
+		InputTokens:  resp.Usage.InputTokens,
+		OutputTokens: resp.Usage.OutputTokens,
+	}, nil
+}
@@ -0,0 +1,65 @@
+package provider
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/fvdveen/generative_agents/simulation_server/llm"
+	"github.com/openai/openai-go/v3"
+	"github.com/openai/openai-go/v3/packages/param"
+	"github.com/openai/openai-go/v3/shared"
+)
+
+// Chat sends requests to the OpenAI Chat Completions API, which is also served by vLLM, the llama.cpp server and Ollama.
+type Chat struct {
+	client openai.Client
+}
+
+func NewChat(opts ...Opt) *Chat {
+	return &Chat{client: openai.NewClient(openaiOptions(newOptions(opts))...)}
+}
+
+func (c *Chat) Complete(ctx context.Context, req llm.Request) (llm.Response, error) {
+	messages := make([]openai.ChatCompletionMessageParamUnion, 0, len(req.Messages))
+	for _, msg := range req.Messages {
+		switch msg.Role {
+		case llm.RoleUser:
+			messages = append(messages, openai.UserMessage(msg.Content))
+		case llm.RoleAssistant:
+			messages = append(messages, openai.AssistantMessage(msg.Content))
+		default:
+			return llm.Response{}, fmt.Errorf("unknown role %q", msg.Role)
+		}
+	}
+
+	params := openai.ChatCompletionNewParams{
+		Model:    req.Model,
+		Messages: messages,
+		ResponseFormat: openai.ChatCompletionNewParamsResponseFormatUnion{
+			OfJSONSchema: &shared.ResponseFormatJSONSchemaParam{
+				JSONSchema: shared.ResponseFormatJSONSchemaJSONSchemaParam{Name: req.SchemaName, Schema: req.Schema},
+			},
+		},
+	}
+	if effort, ok := reasoningEffort(req.Model); ok {
+		params.ReasoningEffort = shared.ReasoningEffort(effort)
+	} else {
+		params.Temperature = param.NewOpt(temperature)
+		params.TopP = param.NewOpt(topP)
+	}
+
+	resp, err := c.client.Chat.Completions.New(ctx, params)
+	if err != nil {
+		return llm.Response{}, err
+	}
+	if len(resp.Choices) == 0 {
+		return llm.Response{}, errors.New("response contains no choices")
+	}
+
+	return llm.Response{
+		Text:         resp.Choices[0].Message.Content,
+		InputTokens:  int(resp.Usage.PromptTokens),
+		OutputTokens: int(resp.Usage.CompletionTokens),
+	}, nil
+}
@@ -0,0 +1,76 @@
+// Package provider implements llm.Provider for the APIs text models are commonly served through.
+package provider
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+type Opt func(o *options)
+
+type options struct {
+	apiKey     string
+	url        string
+	maxTokens  int
+	httpClient *http.Client
+}
+
+func newOptions(opts []Opt) options {
+	o := options{maxTokens: 4096, httpClient: http.DefaultClient}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	return o
+}
+
+func WithAPIKey(key string) Opt {
+	return func(o *options) {
+		o.apiKey = key
+	}
+}
+
+// WithURL sets the base URL of the API, the default is the URL of the official API of the provider.
+func WithURL(url string) Opt {
+	return func(o *options) {
+		o.url = url
+	}
+}
+
+// WithMaxTokens limits the length of responses, only APIs that require a limit use it.
+func WithMaxTokens(n int) Opt {
+	return func(o *options) {
+		o.maxTokens = n
+	}
+}
+
+func WithHTTPClient(client *http.Client) Opt {
+	return func(o *options) {
+		o.httpClient = client
+	}
+}
+
+// The sampling parameters used for models that accept them
+const (
+	temperature = 0.5
+	topP        = 0.9
+)
+
+var reasoningModel = regexp.MustCompile(`^(gpt-5|o\d)`)
+
+// reasoningEffort returns how much an OpenAI reasoning model should reason, and false if model is no reasoning model.
+// Reasoning models, gpt-5 and the o series, do not accept sampling parameters, other models do not accept a reasoning
+// effort. This differs from the original client, which sent the sampling parameters and a medium reasoning effort to
+// every model but gpt-5-nano: reasoning models now get no temperature and top_p, other models no reasoning effort.
+func reasoningEffort(model string) (string, bool) {
+	if !reasoningModel.MatchString(model) {
+		return "", false
+	}
+	// The smallest models reason poorly, reasoning longer mostly makes them slower
+	if strings.Contains(model, "nano") {
+		return "low", true
+	}
+
+	return "medium", true
+}
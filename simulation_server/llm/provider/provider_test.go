@@ -0,0 +1,191 @@
+package provider_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fvdveen/generative_agents/simulation_server/llm"
+	"github.com/fvdveen/generative_agents/simulation_server/llm/provider"
+)
+
+var request = llm.Request{
+	Model: "model",
+	Messages: []llm.Message{
+		{Role: llm.RoleUser, Content: "What emoji describes sleeping?"},
+		{Role: llm.RoleAssistant, Content: "sleeping"},
+		{Role: llm.RoleUser, Content: "Only respond with JSON"},
+	},
+	SchemaName: "generate_pronunciatio_v2",
+	Schema: map[string]any{
+		"$schema":    "http://json-schema.org/draft-07/schema#",
+		"type":       "object",
+		"properties": map[string]any{"emoji": map[string]any{"type": "string"}},
+	},
+}
+
+// serve starts a server answering requests to path with response, the body of the last request is stored in body.
+func serve(t *testing.T, path string, response string, body *map[string]any) string {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != path {
+			t.Errorf("Request sent to %s, want: %s", r.URL.Path, path)
+		}
+		if err := json.NewDecoder(r.Body).Decode(body); err != nil {
+			t.Errorf("could not decode request: %v", err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(response))
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv.URL
+}
+
+func TestProviders(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		response string
+		new      func(opts ...provider.Opt) llm.Provider
+		// The field of the request telling the model to respond with JSON
+		format string
+	}{
+		{
+			name:     "chat",
+			path:     "/chat/completions",
+			response: `{"id":"1","object":"chat.completion","model":"model","choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":"{\"emoji\":\"😴\"}"}}],"usage":{"prompt_tokens":20,"completion_tokens":5,"total_tokens":25}}`,
+			new:      func(opts ...provider.Opt) llm.Provider { return provider.NewChat(opts...) },
+			format:   "response_format",
+		},
+		{
+			name:     "responses",
+			path:     "/responses",
+			response: `{"id":"1","object":"response","status":"completed","model":"model","output":[{"type":"message","id":"m","status":"completed","role":"assistant","content":[{"type":"output_text","text":"{\"emoji\":\"😴\"}","annotations":[]}]}],"usage":{"input_tokens":20,"output_tokens":5,"total_tokens":25}}`,
+			new:      func(opts ...provider.Opt) llm.Provider { return provider.NewResponses(opts...) },
+			format:   "text",
+		},
+		{
+			name:     "anthropic",
+			path:     "/v1/messages",
+			response: `{"id":"1","type":"message","role":"assistant","content":[{"type":"text","text":"Let me answer."},{"type":"tool_use","id":"t","name":"generate_pronunciatio_v2","input":{"emoji":"😴"}}],"usage":{"input_tokens":20,"output_tokens":5}}`,
+			new:      func(opts ...provider.Opt) llm.Provider { return provider.NewAnthropic(opts...) },
+			format:   "tool_choice",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var body map[string]any
+			url := serve(t, test.path, test.response, &body)
+
+			resp, err := test.new(provider.WithURL(url), provider.WithAPIKey("key")).Complete(context.Background(), request)
+			if err != nil {
+				t.Fatalf("could not complete request: %v", err)
+			}
+
+			var out struct{ Emoji string }
+			if err := json.Unmarshal([]byte(resp.Text), &out); err != nil || out.Emoji != "😴" {
+				t.Errorf("Wrong response, got: %q", resp.Text)
+			}
+			if resp.InputTokens != 20 || resp.OutputTokens != 5 {
+				t.Errorf("Wrong usage, got: %d input and %d output tokens", resp.InputTokens, resp.OutputTokens)
+			}
+			if body["model"] != "model" {
+				t.Errorf("Wrong model, got: %v", body["model"])
+			}
+			if _, ok := body[test.format]; !ok {
+				t.Errorf("Expected the request to contain %s, got: %v", test.format, body)
+			}
+		})
+	}
+}
+
+func TestAnthropicError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"type":"error","error":{"type":"invalid_request_error","message":"max_tokens: field required"}}`))
+	}))
+	defer srv.Close()
+
+	_, err := provider.NewAnthropic(provider.WithURL(srv.URL)).Complete(context.Background(), request)
+	if err == nil {
+		t.Fatalf("Expected an error")
+	}
+	if want := "request failed with status 400 Bad Request: invalid_request_error: max_tokens: field required"; err.Error() != want {
+		t.Errorf("Wrong error, got: %q, want: %q", err, want)
+	}
+}
+
+func TestSamplingParameters(t *testing.T) {
+	const responsesResponse = `{"id":"1","object":"response","status":"completed","model":"model","output":[{"type":"message","id":"m","status":"completed","role":"assistant","content":[{"type":"output_text","text":"{}","annotations":[]}]}],"usage":{"input_tokens":20,"output_tokens":5,"total_tokens":25}}`
+	const chatResponse = `{"id":"1","object":"chat.completion","model":"model","choices":[{"index":0,"finish_reason":"stop","message":{"role":"assistant","content":"{}"}}],"usage":{"prompt_tokens":20,"completion_tokens":5,"total_tokens":25}}`
+
+	tests := []struct {
+		model string
+		// The reasoning effort sent, reasoning models get no sampling parameters and other models no reasoning effort
+		effort string
+	}{
+		{model: "gpt-5-nano", effort: "low"},
+		{model: "gpt-5-nano-2025-08-07", effort: "low"},
+		{model: "gpt-5", effort: "medium"},
+		{model: "gpt-5-mini", effort: "medium"},
+		{model: "o3", effort: "medium"},
+		{model: "o4-mini", effort: "medium"},
+		{model: "gpt-4.1"},
+		{model: "gpt-4o-mini"},
+		{model: "llama3.1:8b"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.model, func(t *testing.T) {
+			req := request
+			req.Model = test.model
+
+			var responsesBody, chatBody map[string]any
+			responsesURL := serve(t, "/responses", responsesResponse, &responsesBody)
+			chatURL := serve(t, "/chat/completions", chatResponse, &chatBody)
+			if _, err := provider.NewResponses(provider.WithURL(responsesURL)).Complete(context.Background(), req); err != nil {
+				t.Fatalf("could not complete request: %v", err)
+			}
+			if _, err := provider.NewChat(provider.WithURL(chatURL)).Complete(context.Background(), req); err != nil {
+				t.Fatalf("could not complete request: %v", err)
+			}
+
+			var responsesEffort any
+			if reasoning, ok := responsesBody["reasoning"].(map[string]any); ok {
+				responsesEffort = reasoning["effort"]
+			}
+			apis := map[string]struct {
+				body   map[string]any
+				effort any
+			}{
+				"responses": {responsesBody, responsesEffort},
+				"chat":      {chatBody, chatBody["reasoning_effort"]},
+			}
+			for api, sent := range apis {
+				body, effort := sent.body, sent.effort
+
+				if test.effort != "" {
+					if effort != test.effort {
+						t.Errorf("Wrong reasoning effort sent to the %s API, got: %v, want: %s", api, effort, test.effort)
+					}
+					if _, ok := body["temperature"]; ok {
+						t.Errorf("Expected no temperature to be sent to the %s API", api)
+					}
+					if _, ok := body["top_p"]; ok {
+						t.Errorf("Expected no top_p to be sent to the %s API", api)
+					}
+				} else {
+					if effort != nil {
+						t.Errorf("Expected no reasoning effort to be sent to the %s API, got: %v", api, effort)
+					}
+					if body["temperature"] != 0.5 || body["top_p"] != 0.9 {
+						t.Errorf("Wrong sampling parameters sent to the %s API, got temperature: %v, top_p: %v", api, body["temperature"], body["top_p"])
+					}
+				}
+			}
+		})
+	}
+}
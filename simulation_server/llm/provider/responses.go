@@ -0,0 +1,100 @@
+package provider
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/fvdveen/generative_agents/simulation_server/llm"
+	"github.com/openai/openai-go/v3"
+	"github.com/openai/openai-go/v3/option"
+	"github.com/openai/openai-go/v3/packages/param"
+	"github.com/openai/openai-go/v3/responses"
+	"github.com/openai/openai-go/v3/shared"
+)
+
+// Responses sends requests to the OpenAI Responses API.
+type Responses struct {
+	client openai.Client
+}
+
+func NewResponses(opts ...Opt) *Responses {
+	return &Responses{client: openai.NewClient(openaiOptions(newOptions(opts))...)}
+}
+
+// openaiOptions returns the options for the clients of the OpenAI SDK.
+func openaiOptions(o options) []option.RequestOption {
+	opts := []option.RequestOption{option.WithAPIKey(o.apiKey), option.WithHTTPClient(o.httpClient)}
+	if o.url != "" {
+		opts = append(opts, option.WithBaseURL(o.url))
+	}
+
+	return opts
+}
+
+func (r *Responses) Complete(ctx context.Context, req llm.Request) (llm.Response, error) {
+	input := make(responses.ResponseInputParam, 0, len(req.Messages))
+	for i, msg := range req.Messages {
+		switch msg.Role {
+		case llm.RoleUser:
+			input = append(input, inputMsg(responses.EasyInputMessageRoleUser, msg.Content))
+		case llm.RoleAssistant:
+			input = append(input, outputMsg(fmt.Sprintf("resp_%d", i/2), msg.Content))
+		default:
+			return llm.Response{}, fmt.Errorf("unknown role %q", msg.Role)
+		}
+	}
+
+	params := responses.ResponseNewParams{
+		Model: req.Model,
+		Input: responses.ResponseNewParamsInputUnion{OfInputItemList: input},
+		Text: responses.ResponseTextConfigParam{
+			Format: responses.ResponseFormatTextConfigParamOfJSONSchema(req.SchemaName, req.Schema),
+		},
+	}
+	if effort, ok := reasoningEffort(req.Model); ok {
+		params.Reasoning = shared.ReasoningParam{Effort: shared.ReasoningEffort(effort)}
+	} else {
+		params.Temperature = param.NewOpt(temperature)
+		params.TopP = param.NewOpt(topP)
+	}
+
+	resp, err := r.client.Responses.New(ctx, params)
+	if err != nil {
+		return llm.Response{}, err
+	}
+
+	return llm.Response{
+		Text:         resp.OutputText(),
+		InputTokens:  int(resp.Usage.InputTokens),
+		OutputTokens: int(resp.Usage.OutputTokens),
+	}, nil
+}
+
+func inputMsg(role responses.EasyInputMessageRole, content string) responses.ResponseInputItemUnionParam {
+	return responses.ResponseInputItemUnionParam{
+		OfMessage: &responses.EasyInputMessageParam{
+			Role: role,
+			Type: responses.EasyInputMessageTypeMessage,
+			Content: responses.EasyInputMessageContentUnionParam{
+				OfString: param.NewOpt(content),
+			},
+		},
+	}
+}
+
+func outputMsg(id, text string) responses.ResponseInputItemUnionParam {
+	return responses.ResponseInputItemUnionParam{
+		OfOutputMessage: &responses.ResponseOutputMessageParam{
+			ID:     id,
+			Status: responses.ResponseOutputMessageStatusCompleted,
+			Content: []responses.ResponseOutputMessageContentUnionParam{
+				{
+					OfOutputText: &responses.ResponseOutputTextParam{
+						Text:        text,
+						Annotations: []responses.ResponseOutputTextAnnotationUnionParam{},
+					},
+				},
+			},
+		},
+	}
+}
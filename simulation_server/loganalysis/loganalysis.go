@@ -0,0 +1,144 @@
+// Package loganalysis turns the logs of a run, as written by logging.NewRunLogs, into reports.
+package loganalysis
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Issue is a problem a response of a model had with the schema of its prompt.
+type Issue struct {
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+// RetrievedNode holds the scores a retrieved memory got.
+type RetrievedNode struct {
+	ID         int     `json:"id"`
+	Final      float64 `json:"final"`
+	Recency    float64 `json:"recency"`
+	Importance float64 `json:"importance"`
+	Valence    float64 `json:"valence"`
+	Relevancy  float64 `json:"relevancy"`
+}
+
+// Record is a single log record, only the attributes used by the reports are read.
+type Record struct {
+	Time    time.Time `json:"time"`
+	Level   string    `json:"level"`
+	Msg     string    `json:"msg"`
+	Step    *int      `json:"step"`
+	SimTime time.Time `json:"sim_time"`
+	Persona string    `json:"persona"`
+	Phase   string    `json:"phase"`
+	// Nanoseconds for persona steps and phases, minutes for activities
+	Duration int64  `json:"duration"`
+	Err      string `json:"err"`
+
+	// Calls to models
+	Prompt           string           `json:"prompt_name"`
+	Model            string           `json:"model"`
+	AttemptsTotal    int              `json:"attempts_total"`
+	TotalLatency     time.Duration    `json:"total_latency"`
+	Reason           string           `json:"reason"`
+	ValidationErrors map[string]Issue `json:"validation_errors"`
+
+	// Activities
+	NodeType     string `json:"node_type"`
+	Description  string `json:"description"`
+	Address      string `json:"address"`
+	StartTime    string `json:"start_time"`
+	ChattingWith string `json:"chatting_with"`
+
+	// Retrievals
+	FocalPoint string                   `json:"focal_point"`
+	Retrieved  map[string]RetrievedNode `json:"retrieved"`
+}
+
+// Read reads the records of the run in dir. The debug log holds every record, so it is read when the run has one,
+// otherwise the events are read, which leave out the phases and retrievals. The errors log only repeats the
+// warnings and errors that are in both.
+func Read(dir string) ([]Record, error) {
+	file := filepath.Join(dir, "debug.jsonl")
+	if _, err := os.Stat(file); errors.Is(err, os.ErrNotExist) {
+		file = filepath.Join(dir, "events.jsonl")
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("could not open logs: %w", err)
+	}
+	defer f.Close()
+
+	return Parse(f)
+}
+
+// Parse parses the JSON records in r, one per line.
+func Parse(r io.Reader) ([]Record, error) {
+	var records []Record
+	scanner := bufio.NewScanner(r)
+	// Prompts and responses can end up in a record, which easily exceed the default limit
+	scanner.Buffer(nil, 64<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+
+		// Records of other events may use the same attribute for something else, the attributes that
+		// do not fit are left empty and the rest of the record is kept
+		var record Record
+		var typeErr *json.UnmarshalTypeError
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil && !errors.As(err, &typeErr) {
+			return nil, fmt.Errorf("could not parse line %d: %w", line, err)
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("could not read logs: %w", err)
+	}
+
+	return records, nil
+}
+
+// Table is a report, which can be written as aligned text or as CSV.
+type Table struct {
+	Header []string
+	Rows   [][]string
+}
+
+// Limit returns t with only its first n rows, n <= 0 keeps all rows.
+func (t Table) Limit(n int) Table {
+	if n > 0 && len(t.Rows) > n {
+		t.Rows = t.Rows[:n]
+	}
+	return t
+}
+
+func (t Table) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(t.Header, "\t"))
+	for _, row := range t.Rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+func (t Table) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(t.Header); err != nil {
+		return err
+	}
+	if err := cw.WriteAll(t.Rows); err != nil {
+		return err
+	}
+	return cw.Error()
+}
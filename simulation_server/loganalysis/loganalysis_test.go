@@ -0,0 +1,133 @@
+package loganalysis_test
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fvdveen/generative_agents/simulation_server/loganalysis"
+)
+
+// writeRun logs a step like the simulation does, with a call that is retried once.
+func writeRun(t *testing.T) []loganalysis.Record {
+	t.Helper()
+
+	var buf bytes.Buffer
+	log := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	stepLog := log.With(slog.Int("step", 7), slog.String("type", "step"), slog.Time("sim_time", time.Date(2023, time.February, 13, 8, 0, 0, 0, time.UTC)))
+	stepLog.Info("step_start", slog.String("phase", "start"))
+
+	plog := stepLog.With(slog.String("persona", "Isabella Rodriguez"))
+	plog.Info("set_activity",
+		slog.String("type", "activity_set"),
+		slog.String("node_type", "activity"),
+		slog.String("description", "having breakfast"),
+		slog.String("address", "the Ville:Hobbs Cafe:cafe:counter"),
+		slog.String("start_time", "2023-02-13T08:00:00Z"),
+		slog.Int("duration", 30),
+	)
+	plog.Debug("persona_phase_done", slog.String("phase", "plan_activity"), slog.Duration("duration", 3*time.Second))
+	plog.Debug("retrieval",
+		slog.String("type", "retrieval"),
+		slog.String("focal_point", "Isabella Rodriguez is planning a Valentine's Day party"),
+		slog.Any("retrieved", slog.GroupValue(
+			slog.Group("node_3", slog.Int("id", 3), slog.Float64("final", 1.5), slog.Float64("recency", 0.5), slog.Float64("importance", 1), slog.Float64("valence", 0), slog.Float64("relevancy", 0)),
+			slog.Group("node_9", slog.Int("id", 9), slog.Float64("final", 2.5), slog.Float64("recency", 1), slog.Float64("importance", 0.5), slog.Float64("valence", 0), slog.Float64("relevancy", 1)),
+		)),
+	)
+
+	llmLog := log.With(slog.String("prompt_name", "daily_planning_v7"), slog.String("type", "llm_call"))
+	llmLog.Warn("llm_retry",
+		slog.String("phase", "retry"),
+		slog.String("reason", "json_validation"),
+		slog.Any("validation_errors", slog.GroupValue(
+			slog.Group("issue_0", slog.String("path", "activities.0.duration"), slog.String("message", "Invalid type")),
+			slog.Group("issue_1", slog.String("path", "activities.4.duration"), slog.String("message", "Invalid type")),
+		)),
+	)
+	llmLog.Info("llm_call_ok", "phase", "ok", "attempts_total", 2, "total_latency", 2*time.Second)
+	llmLog.Info("llm_call_ok", "phase", "cache")
+
+	plog.Info("persona_step_done", slog.Duration("duration", 4*time.Second), slog.Bool("ok", true))
+	stepLog.Info("step_end", slog.String("phase", "end"))
+
+	records, err := loganalysis.Parse(&buf)
+	if err != nil {
+		t.Fatalf("could not parse logs: %v", err)
+	}
+	return records
+}
+
+func TestReports(t *testing.T) {
+	records := writeRun(t)
+
+	tests := []struct {
+		name  string
+		table loganalysis.Table
+		want  [][]string
+	}{
+		{
+			name:  "steps",
+			table: loganalysis.Steps(records),
+			want:  [][]string{{"7", "2023-02-13 08:00:00", "ok", "", "Isabella Rodriguez (4s)", "Isabella Rodriguez/plan_activity (3s)", "2", "1", "0"}},
+		},
+		{
+			name:  "prompts",
+			table: loganalysis.Prompts(records),
+			want:  [][]string{{"daily_planning_v7", "1", "2", "0", "2s", "2s", "2s", "2s"}},
+		},
+		{
+			name:  "retries",
+			table: loganalysis.Retries(records),
+			want:  [][]string{{"daily_planning_v7", "json_validation", "activities.*.duration", "2", "Invalid type"}},
+		},
+		{
+			name:  "activities",
+			table: loganalysis.Activities(records, "Isabella Rodriguez"),
+			want:  [][]string{{"Isabella Rodriguez", "2023-02-13 08:00:00", "30m0s", "having breakfast", "the Ville:Hobbs Cafe:cafe:counter", ""}},
+		},
+		{
+			name:  "retrieval",
+			table: loganalysis.Retrievals(records, "valentine"),
+			want: [][]string{
+				{"7", "Isabella Rodriguez", "Isabella Rodriguez is planning a Valentine's Day party", "9", "2.500", "1.000", "0.500", "0.000", "1.000"},
+				{"7", "Isabella Rodriguez", "Isabella Rodriguez is planning a Valentine's Day party", "3", "1.500", "0.500", "1.000", "0.000", "0.000"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if len(test.table.Rows) != len(test.want) {
+				t.Fatalf("Wrong amount of rows, got: %q, want: %q", test.table.Rows, test.want)
+			}
+			for i, row := range test.table.Rows {
+				for j, cell := range row {
+					// The wall clock duration of a step differs between runs
+					if test.want[i][j] != "" && cell != test.want[i][j] {
+						t.Errorf("Wrong %s in row %d, got: %q, want: %q", test.table.Header[j], i, cell, test.want[i][j])
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	var buf bytes.Buffer
+	table := loganalysis.Activities(writeRun(t), "")
+	if err := table.WriteCSV(&buf); err != nil {
+		t.Fatalf("could not write csv: %v", err)
+	}
+
+	want := "persona,start_time,duration,description,address,chatting_with\nIsabella Rodriguez,2023-02-13 08:00:00,30m0s,having breakfast,the Ville:Hobbs Cafe:cafe:counter,\n"
+	if got := buf.String(); got != want {
+		t.Errorf("Wrong csv, got: %q, want: %q", got, want)
+	}
+	if strings.Count(buf.String(), "\n") != len(table.Rows)+1 {
+		t.Errorf("Expected a line for the header and every row")
+	}
+}
@@ -0,0 +1,281 @@
+package loganalysis
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func formatDuration(d time.Duration) string {
+	if d < time.Millisecond {
+		return d.Round(time.Microsecond).String()
+	}
+	return d.Round(time.Millisecond).String()
+}
+
+func formatScore(f float64) string {
+	return strconv.FormatFloat(f, 'f', 3, 64)
+}
+
+// Steps is a timeline of every step of the run: how long it took, which persona and phase took the longest and how
+// many calls were made to models. Calls are logged without their step, they are counted towards the step that was
+// running when they were logged.
+func Steps(records []Record) Table {
+	t := Table{Header: []string{"step", "sim_time", "status", "duration", "slowest_persona", "slowest_phase", "llm_calls", "retries", "failures"}}
+
+	type step struct {
+		number        int
+		simTime       time.Time
+		start         time.Time
+		personas      map[string]time.Duration
+		phase         string
+		phaseDuration time.Duration
+		calls         int
+		retries       int
+		failures      int
+	}
+	var curr *step
+
+	finish := func(status string, end time.Time) {
+		slowest := ""
+		for _, name := range slices.Sorted(maps.Keys(curr.personas)) {
+			if slowest == "" || curr.personas[name] > curr.personas[slowest] {
+				slowest = name
+			}
+		}
+		if slowest != "" {
+			slowest = fmt.Sprintf("%s (%s)", slowest, formatDuration(curr.personas[slowest]))
+		}
+		phase := ""
+		if curr.phase != "" {
+			phase = fmt.Sprintf("%s (%s)", curr.phase, formatDuration(curr.phaseDuration))
+		}
+
+		t.Rows = append(t.Rows, []string{
+			strconv.Itoa(curr.number),
+			curr.simTime.Format(time.DateTime),
+			status,
+			formatDuration(end.Sub(curr.start)),
+			slowest,
+			phase,
+			strconv.Itoa(curr.calls),
+			strconv.Itoa(curr.retries),
+			strconv.Itoa(curr.failures),
+		})
+		curr = nil
+	}
+
+	for _, r := range records {
+		if r.Msg == "step_start" && r.Step != nil {
+			curr = &step{number: *r.Step, simTime: r.SimTime, start: r.Time, personas: map[string]time.Duration{}}
+			continue
+		}
+		if curr == nil {
+			continue
+		}
+
+		switch r.Msg {
+		case "persona_step_done":
+			curr.personas[r.Persona] = time.Duration(r.Duration)
+		case "persona_phase_done":
+			if d := time.Duration(r.Duration); d > curr.phaseDuration {
+				curr.phase, curr.phaseDuration = r.Persona+"/"+r.Phase, d
+			}
+		case "llm_call_ok":
+			curr.calls += 1
+		case "llm_call_fail":
+			curr.calls += 1
+			curr.failures += 1
+		case "llm_retry":
+			curr.retries += 1
+		case "step_end":
+			finish("ok", r.Time)
+		case "step_fail":
+			finish("fail", r.Time)
+		}
+	}
+
+	return t
+}
+
+// Prompts sums up the calls made to models by prompt, the slowest prompts on average come first.
+// Responses supplied by a cassette or the prompt cache are left out, as no model was called.
+func Prompts(records []Record) Table {
+	type prompt struct {
+		latencies []time.Duration
+		total     time.Duration
+		attempts  int
+		failures  int
+	}
+	prompts := map[string]*prompt{}
+
+	for _, r := range records {
+		if (r.Msg != "llm_call_ok" && r.Msg != "llm_call_fail") || r.TotalLatency == 0 {
+			continue
+		}
+
+		p, ok := prompts[r.Prompt]
+		if !ok {
+			p = &prompt{}
+			prompts[r.Prompt] = p
+		}
+		p.latencies = append(p.latencies, r.TotalLatency)
+		p.total += r.TotalLatency
+		p.attempts += r.AttemptsTotal
+		if r.Msg == "llm_call_fail" {
+			p.failures += 1
+		}
+	}
+
+	mean := func(p *prompt) time.Duration {
+		return p.total / time.Duration(len(p.latencies))
+	}
+
+	names := slices.SortedFunc(maps.Keys(prompts), func(a, b string) int {
+		return cmp.Or(cmp.Compare(mean(prompts[b]), mean(prompts[a])), cmp.Compare(a, b))
+	})
+
+	t := Table{Header: []string{"prompt", "calls", "attempts", "failures", "mean", "p95", "max", "total"}}
+	for _, name := range names {
+		p := prompts[name]
+		slices.Sort(p.latencies)
+		n := len(p.latencies)
+
+		t.Rows = append(t.Rows, []string{
+			name,
+			strconv.Itoa(n),
+			strconv.Itoa(p.attempts),
+			strconv.Itoa(p.failures),
+			formatDuration(mean(p)),
+			formatDuration(p.latencies[(n*95+99)/100-1]),
+			formatDuration(p.latencies[n-1]),
+			formatDuration(p.total),
+		})
+	}
+
+	return t
+}
+
+// generalizePath replaces the array indices in the path of a validation error with *, like the 3 in "schedule.3.activity".
+func generalizePath(path string) string {
+	parts := strings.Split(path, ".")
+	for i, part := range parts {
+		if _, err := strconv.Atoi(part); err == nil {
+			parts[i] = "*"
+		}
+	}
+	return strings.Join(parts, ".")
+}
+
+// Retries counts the retries of every prompt by their reason and, for responses that did not match the schema of
+// their prompt, by the path of the problem. Array indices are left out of the paths, so problems with any element
+// of an array are counted together. The most frequent retries come first.
+func Retries(records []Record) Table {
+	type key struct{ prompt, reason, path string }
+	counts := map[key]int{}
+	examples := map[key]string{}
+
+	add := func(k key, example string) {
+		counts[k] += 1
+		if _, ok := examples[k]; !ok {
+			examples[k] = example
+		}
+	}
+
+	for _, r := range records {
+		if r.Msg != "llm_retry" {
+			continue
+		}
+
+		if len(r.ValidationErrors) == 0 {
+			add(key{r.Prompt, r.Reason, ""}, r.Err)
+			continue
+		}
+		for _, name := range slices.Sorted(maps.Keys(r.ValidationErrors)) {
+			issue := r.ValidationErrors[name]
+			add(key{r.Prompt, r.Reason, generalizePath(issue.Path)}, issue.Message)
+		}
+	}
+
+	keys := slices.SortedFunc(maps.Keys(counts), func(a, b key) int {
+		return cmp.Or(
+			cmp.Compare(counts[b], counts[a]),
+			strings.Compare(a.prompt, b.prompt),
+			strings.Compare(a.reason, b.reason),
+			strings.Compare(a.path, b.path),
+		)
+	})
+
+	t := Table{Header: []string{"prompt", "reason", "path", "retries", "example"}}
+	for _, k := range keys {
+		t.Rows = append(t.Rows, []string{k.prompt, k.reason, k.path, strconv.Itoa(counts[k]), examples[k]})
+	}
+
+	return t
+}
+
+// Activities rebuilds the activities of the personas, or only those of persona when it is not empty, in the order
+// they were started.
+func Activities(records []Record, persona string) Table {
+	t := Table{Header: []string{"persona", "start_time", "duration", "description", "address", "chatting_with"}}
+	for _, r := range records {
+		if r.Msg != "set_activity" || (persona != "" && r.Persona != persona) {
+			continue
+		}
+
+		start := r.StartTime
+		if parsed, err := time.Parse(time.RFC3339, r.StartTime); err == nil {
+			start = parsed.Format(time.DateTime)
+		}
+
+		t.Rows = append(t.Rows, []string{
+			r.Persona,
+			start,
+			(time.Duration(r.Duration) * time.Minute).String(),
+			r.Description,
+			r.Address,
+			r.ChattingWith,
+		})
+	}
+
+	return t
+}
+
+// Retrievals breaks down the scores of the memories retrieved for every focal point containing focalPoint, ignoring
+// case. The memories of a retrieval are ordered by their final score.
+func Retrievals(records []Record, focalPoint string) Table {
+	t := Table{Header: []string{"step", "persona", "focal_point", "node", "final", "recency", "importance", "valence", "relevancy"}}
+	for _, r := range records {
+		if r.Msg != "retrieval" || !strings.Contains(strings.ToLower(r.FocalPoint), strings.ToLower(focalPoint)) {
+			continue
+		}
+
+		step := ""
+		if r.Step != nil {
+			step = strconv.Itoa(*r.Step)
+		}
+
+		nodes := slices.SortedFunc(maps.Values(r.Retrieved), func(a, b RetrievedNode) int {
+			return cmp.Or(cmp.Compare(b.Final, a.Final), cmp.Compare(a.ID, b.ID))
+		})
+		for _, n := range nodes {
+			t.Rows = append(t.Rows, []string{
+				step,
+				r.Persona,
+				r.FocalPoint,
+				strconv.Itoa(n.ID),
+				formatScore(n.Final),
+				formatScore(n.Recency),
+				formatScore(n.Importance),
+				formatScore(n.Valence),
+				formatScore(n.Relevancy),
+			})
+		}
+	}
+
+	return t
+}
@@ -0,0 +1,63 @@
+package maze_test
+
+import (
+	"testing"
+
+	"github.com/fvdveen/generative_agents/simulation_server/maze"
+	simulationloader "github.com/fvdveen/generative_agents/simulation_server/simulation_loader"
+)
+
+func loadVillage(b *testing.B) *maze.Maze {
+	b.Helper()
+
+	m, err := simulationloader.LoadMaze("../../environment/frontend_server/static_dirs/assets/the_ville", "the_ville")
+	if err != nil {
+		b.Fatalf("could not load the village: %v", err)
+	}
+	return m
+}
+
+// Two tiles on opposite sides of the village, from Isabella's apartment to the college dorm
+var (
+	villageStart = maze.TilePos{X: 72, Y: 14}
+	villageEnd   = maze.TilePos{X: 126, Y: 46}
+)
+
+func BenchmarkPathfindVillage(b *testing.B) {
+	m := loadVillage(b)
+
+	for b.Loop() {
+		if _, ok := m.Pathfind(villageStart, villageEnd); !ok {
+			b.Fatalf("could not find path")
+		}
+	}
+}
+
+func BenchmarkDistanceFieldVillage(b *testing.B) {
+	m := loadVillage(b)
+
+	// Cycling through more targets than fit in the cache ensures the field is computed instead of read from the cache
+	targets := []maze.TilePos{}
+	for y := range 100 {
+		for x := range 140 {
+			if pos := (maze.TilePos{X: x, Y: y}); !m.GetTile(pos).Collision {
+				targets = append(targets, pos)
+			}
+		}
+	}
+
+	for i := 0; b.Loop(); i++ {
+		m.DistanceField(targets[i%len(targets)]).Distance(villageStart)
+	}
+}
+
+func BenchmarkDistanceFieldVillageCached(b *testing.B) {
+	m := loadVillage(b)
+	m.DistanceField(villageEnd)
+
+	for b.Loop() {
+		if _, ok := m.DistanceField(villageEnd).Distance(villageStart); !ok {
+			b.Fatalf("could not reach target")
+		}
+	}
+}
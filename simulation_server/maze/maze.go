@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"maps"
 	"math"
+	"slices"
 
 	"github.com/fvdveen/generative_agents/simulation_server/memory"
 )
@@ -45,6 +46,8 @@ type Maze struct {
 	tiles [][]Tile
 	// Maps a path to all tiles that correspond to that path
 	addressTiles map[memory.Path][]TilePos
+	// Distance fields of tiles that have been used as a target, shared with snapshots of the maze
+	fields *distanceFields
 }
 
 func (m Maze) Name() string {
@@ -109,6 +112,7 @@ func New(name, folder string, width, height int, tileSize int, collisionInfo [][
 		tileSize,
 		collisionInfo, tiles,
 		addressTiles,
+		newDistanceFields(),
 	}
 }
 
@@ -118,6 +122,21 @@ func (m *Maze) Exists(p memory.Path) bool {
 	return ok
 }
 
+// Snapshot returns a copy of the maze whose tile events are not affected by changes to m.
+// The snapshot should only be read, the parts of the maze that never change are shared with m.
+func (m *Maze) Snapshot() *Maze {
+	snapshot := *m
+	snapshot.tiles = make([][]Tile, len(m.tiles))
+	for y, row := range m.tiles {
+		snapshot.tiles[y] = slices.Clone(row)
+		for x := range row {
+			snapshot.tiles[y][x].Events = maps.Clone(row[x].Events)
+		}
+	}
+
+	return &snapshot
+}
+
 func (m *Maze) GetTile(pos TilePos) Tile {
 	return m.tiles[pos.Y][pos.X]
 }
@@ -178,3 +197,34 @@ func (m *Maze) TurnTileEventIdle(tile TilePos, ev Event) {
 		t.Events[Event{SPO: memory.SPO{Subject: ev.SPO.Subject}}] = struct{}{}
 	})
 }
+
+// Contains reports whether pos is a tile of the maze.
+func (m *Maze) Contains(pos TilePos) bool {
+	return pos.X >= 0 && pos.Y >= 0 && pos.X < m.width && pos.Y < m.height
+}
+
+// SpawningTiles returns the tiles inside area where personas can be spawned, ordered by row.
+func (m *Maze) SpawningTiles(area memory.Path) []TilePos {
+	tiles := []TilePos{}
+	for y, row := range m.tiles {
+		for x, tile := range row {
+			if tile.SpawningLocation != "" && !tile.Collision && tile.Path.Matches(area) {
+				tiles = append(tiles, TilePos{X: x, Y: y})
+			}
+		}
+	}
+
+	return tiles
+}
+
+// Locations returns every sector, arena and object of the maze inside area.
+func (m *Maze) Locations(area memory.Path) []memory.Path {
+	locations := []memory.Path{}
+	for p := range m.addressTiles {
+		if p.Matches(area) {
+			locations = append(locations, p)
+		}
+	}
+
+	return locations
+}
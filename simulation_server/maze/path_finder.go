@@ -1,75 +1,249 @@
 package maze
 
-import "slices"
-
-func (m *Maze) Pathfind(start, end TilePos) []TilePos {
-	makeStep := func(d [][]int, k int) {
-		for i := 0; i < len(d); i += 1 {
-			for j := 0; j < len(d[i]); j += 1 {
-				if d[i][j] != k {
-					continue
-				}
-				if i > 0 && d[i-1][j] == 0 && !m.collisionInfo[i-1][j] {
-					d[i-1][j] = k + 1
-				}
-				if j > 0 && d[i][j-1] == 0 && !m.collisionInfo[i][j-1] {
-					d[i][j-1] = k + 1
-				}
-				if i < len(d)-1 && d[i+1][j] == 0 && !m.collisionInfo[i+1][j] {
-					d[i+1][j] = k + 1
-				}
-				if j < len(d[i])-1 && d[i][j+1] == 0 && !m.collisionInfo[i][j+1] {
-					d[i][j+1] = k + 1
-				}
+import (
+	"slices"
+	"sync"
+)
+
+// How many distance fields are cached on a maze, a field for the full village takes roughly 56KB
+const distanceFieldCacheSize = 512
+
+func (m *Maze) walkable(pos TilePos) bool {
+	return m.Contains(pos) && !m.collisionInfo[pos.Y][pos.X]
+}
+
+// neighbours returns the tiles next to pos, in the order they are preferred when multiple paths are equally short.
+func neighbours(pos TilePos) [4]TilePos {
+	return [4]TilePos{
+		{X: pos.X, Y: pos.Y - 1},
+		{X: pos.X - 1, Y: pos.Y},
+		{X: pos.X, Y: pos.Y + 1},
+		{X: pos.X + 1, Y: pos.Y},
+	}
+}
+
+func manhattanDistance(a, b TilePos) int {
+	return abs(a.X-b.X) + abs(a.Y-b.Y)
+}
+
+func abs(i int) int {
+	if i < 0 {
+		return -i
+	}
+	return i
+}
+
+type searchNode struct {
+	pos  TilePos
+	g, f int
+}
+
+// searchQueue is a binary heap of nodes ordered by their f score, preferring nodes closer to the goal on ties.
+type searchQueue []searchNode
+
+func (q searchQueue) less(i, j int) bool {
+	if q[i].f != q[j].f {
+		return q[i].f < q[j].f
+	}
+	return q[i].g > q[j].g
+}
+
+func (q *searchQueue) push(n searchNode) {
+	*q = append(*q, n)
+	for i := len(*q) - 1; i > 0; {
+		parent := (i - 1) / 2
+		if !q.less(i, parent) {
+			break
+		}
+		(*q)[i], (*q)[parent] = (*q)[parent], (*q)[i]
+		i = parent
+	}
+}
+
+func (q *searchQueue) pop() searchNode {
+	old := *q
+	n := old[0]
+	last := len(old) - 1
+	old[0] = old[last]
+	*q = old[:last]
+
+	for i := 0; ; {
+		smallest, left, right := i, 2*i+1, 2*i+2
+		if left < last && q.less(left, smallest) {
+			smallest = left
+		}
+		if right < last && q.less(right, smallest) {
+			smallest = right
+		}
+		if smallest == i {
+			break
+		}
+		(*q)[i], (*q)[smallest] = (*q)[smallest], (*q)[i]
+		i = smallest
+	}
+
+	return n
+}
+
+// Pathfind finds a shortest path from start to end using A*, the path includes both start and end.
+// If end cannot be reached from start ok is false.
+func (m *Maze) Pathfind(start, end TilePos) (path []TilePos, ok bool) {
+	return m.PathfindAvoiding(start, end, nil)
+}
+
+// PathfindAvoiding finds a shortest path from start to end like Pathfind, treating every tile for which blocked returns true
+// as a wall. The start tile is never considered blocked, a nil blocked function blocks nothing.
+func (m *Maze) PathfindAvoiding(start, end TilePos, blocked func(TilePos) bool) (path []TilePos, ok bool) {
+	if !m.Contains(start) || !m.Contains(end) {
+		return nil, false
+	}
+	if start == end {
+		return []TilePos{start}, true
+	}
+	if !m.walkable(end) || (blocked != nil && blocked(end)) {
+		return nil, false
+	}
+
+	// The shortest known distance from start of every tile, -1 for tiles that have not been reached yet
+	dist := make([]int, m.width*m.height)
+	for i := range dist {
+		dist[i] = -1
+	}
+	closed := make([]bool, m.width*m.height)
+	idx := func(pos TilePos) int { return pos.Y*m.width + pos.X }
+
+	best := -1
+	dist[idx(start)] = 0
+	queue := &searchQueue{{pos: start, g: 0, f: manhattanDistance(start, end)}}
+	for len(*queue) > 0 {
+		node := queue.pop()
+		// We keep searching until no shorter path can be found, not just until end is reached.
+		// Thus every tile on a shortest path has been expanded, and the path can be picked the same way
+		// the original flood fill picked it, regardless of the order tiles were expanded in.
+		if best != -1 && node.f > best {
+			break
+		}
+		if closed[idx(node.pos)] {
+			continue
+		}
+		closed[idx(node.pos)] = true
+
+		if node.pos == end {
+			best = node.g
+			continue
+		}
+
+		for _, next := range neighbours(node.pos) {
+			if !m.walkable(next) || (blocked != nil && blocked(next)) {
+				continue
 			}
+			if d := dist[idx(next)]; d != -1 && d <= node.g+1 {
+				continue
+			}
+			dist[idx(next)] = node.g + 1
+			queue.push(searchNode{pos: next, g: node.g + 1, f: node.g + 1 + manhattanDistance(next, end)})
 		}
 	}
 
-	// NOTE(Friso): Remember the maze is height*width so we mirror that here
-	distMaze := make([][]int, 0, len(m.tiles))
-	for i := range m.tiles {
-		distMaze = append(distMaze, make([]int, len(m.tiles[i])))
-	}
-
-	// NOTE(Friso): Remember the maze is height*width
-	distMaze[start.Y][start.X] = 1
-
-	k := 0
-	loopMax := 150
-
-	// NOTE(Friso): Remember the maze is height*width
-	for distMaze[end.Y][end.X] == 0 && loopMax > 0 {
-		k += 1
-		makeStep(distMaze, k)
-
-		loopMax -= 1
-	}
-
-	// NOTE(Friso): Remember the maze is height*width
-	i, j := end.Y, end.X
-	k = distMaze[i][j]
-	path := append(make([]TilePos, 0, k), end)
-	for k > 1 {
-		if i > 0 && distMaze[i-1][j] == k-1 {
-			i = i - 1
-			path = append(path, TilePos{Y: i, X: j})
-			k -= 1
-		} else if j > 0 && distMaze[i][j-1] == k-1 {
-			j = j - 1
-			path = append(path, TilePos{Y: i, X: j})
-			k -= 1
-		} else if i < len(distMaze)-1 && distMaze[i+1][j] == k-1 {
-			i = i + 1
-			path = append(path, TilePos{Y: i, X: j})
-			k -= 1
-		} else if j < len(distMaze[i])-1 && distMaze[i][j+1] == k-1 {
-			j = j + 1
-			path = append(path, TilePos{Y: i, X: j})
-			k -= 1
+	if best == -1 {
+		return nil, false
+	}
+
+	// Walk back from end, always stepping to the first neighbour that is one step closer to start
+	path = append(make([]TilePos, 0, best+1), end)
+	for pos, k := end, best; k > 0; k -= 1 {
+		for _, next := range neighbours(pos) {
+			if m.Contains(next) && closed[idx(next)] && dist[idx(next)] == k-1 {
+				pos = next
+				break
+			}
 		}
+		path = append(path, pos)
 	}
 
 	slices.Reverse(path)
 
-	return path
+	return path, true
+}
+
+// DistanceField holds the walking distance from every tile of a maze to a single target tile.
+type DistanceField struct {
+	target TilePos
+	width  int
+	// The distance of every tile to target, -1 for tiles from which target cannot be reached
+	dist []int32
+}
+
+func (f *DistanceField) Target() TilePos {
+	return f.target
+}
+
+// Distance returns the amount of steps needed to walk from from to the target of the field.
+func (f *DistanceField) Distance(from TilePos) (int, bool) {
+	if from.X < 0 || from.Y < 0 || from.X >= f.width || from.Y*f.width+from.X >= len(f.dist) {
+		return 0, false
+	}
+
+	d := f.dist[from.Y*f.width+from.X]
+	return int(d), d != -1
+}
+
+// distanceFields caches the distance fields of a maze, evicting the oldest field when it is full.
+type distanceFields struct {
+	mu     sync.Mutex
+	fields map[TilePos]*DistanceField
+	order  []TilePos
+}
+
+func newDistanceFields() *distanceFields {
+	return &distanceFields{fields: map[TilePos]*DistanceField{}}
+}
+
+// DistanceField returns the distance field for target, fields are cached so asking for the same target again is cheap.
+// It is safe to call DistanceField from multiple goroutines.
+func (m *Maze) DistanceField(target TilePos) *DistanceField {
+	m.fields.mu.Lock()
+	defer m.fields.mu.Unlock()
+
+	if field, ok := m.fields.fields[target]; ok {
+		return field
+	}
+
+	field := m.computeDistanceField(target)
+	if len(m.fields.order) >= distanceFieldCacheSize {
+		delete(m.fields.fields, m.fields.order[0])
+		m.fields.order = m.fields.order[1:]
+	}
+	m.fields.fields[target] = field
+	m.fields.order = append(m.fields.order, target)
+
+	return field
+}
+
+func (m *Maze) computeDistanceField(target TilePos) *DistanceField {
+	field := &DistanceField{target: target, width: m.width, dist: make([]int32, m.width*m.height)}
+	for i := range field.dist {
+		field.dist[i] = -1
+	}
+	if !m.walkable(target) {
+		return field
+	}
+
+	field.dist[target.Y*m.width+target.X] = 0
+	queue := []TilePos{target}
+	for len(queue) > 0 {
+		pos := queue[0]
+		queue = queue[1:]
+		d := field.dist[pos.Y*m.width+pos.X]
+
+		for _, next := range neighbours(pos) {
+			if !m.walkable(next) || field.dist[next.Y*m.width+next.X] != -1 {
+				continue
+			}
+			field.dist[next.Y*m.width+next.X] = d + 1
+			queue = append(queue, next)
+		}
+	}
+
+	return field
 }
@@ -42,7 +42,10 @@ func TestSameSquare(t *testing.T) {
 
 	pos := maze.TilePos{Y: 0, X: 1}
 
-	path := m.Pathfind(pos, pos)
+	path, ok := m.Pathfind(pos, pos)
+	if !ok {
+		t.Fatalf("Could not find path to the same square")
+	}
 
 	if len(path) != 1 && path[0] != pos {
 		t.Fatalf("Wrond path: %v, expected [(0, 1)]", path)
@@ -55,7 +58,10 @@ func TestOppositeSideOfMap(t *testing.T) {
 	start := maze.TilePos{Y: 1, X: 0}
 	end := maze.TilePos{Y: 6, X: 12}
 
-	path := m.Pathfind(start, end)
+	path, ok := m.Pathfind(start, end)
+	if !ok {
+		t.Fatalf("Could not find path from %v to %v", start, end)
+	}
 	expected := []maze.TilePos{
 		{X: 0, Y: 1},
 		{X: 1, Y: 1},
@@ -91,3 +97,98 @@ func TestOppositeSideOfMap(t *testing.T) {
 		}
 	}
 }
+
+func TestUnreachable(t *testing.T) {
+	m := makeMaze()
+
+	// Walls can never be reached
+	if path, ok := m.Pathfind(maze.TilePos{Y: 1, X: 0}, maze.TilePos{Y: 1, X: 12}); ok {
+		t.Fatalf("Expected no path to a wall, got: %v", path)
+	}
+
+	field := m.DistanceField(maze.TilePos{Y: 6, X: 12})
+	if d, ok := field.Distance(maze.TilePos{Y: 0, X: 0}); ok {
+		t.Fatalf("Expected a wall to be unreachable, got distance %d", d)
+	}
+}
+
+func TestDistanceField(t *testing.T) {
+	m := makeMaze()
+
+	start := maze.TilePos{Y: 1, X: 0}
+	end := maze.TilePos{Y: 6, X: 12}
+
+	path, _ := m.Pathfind(start, end)
+	d, ok := m.DistanceField(end).Distance(start)
+	if !ok || d != len(path)-1 {
+		t.Fatalf("Wrong distance, got: %d (%v), want: %d", d, ok, len(path)-1)
+	}
+}
+
+// makeSnake creates a maze with a single corridor winding through it, so the path through it is longer than the maze is wide.
+func makeSnake(width, height int) *maze.Maze {
+	collision := make([][]bool, height)
+	tiles := make([][]maze.Tile, height)
+	for y := range height {
+		collision[y] = make([]bool, width)
+		tiles[y] = make([]maze.Tile, width)
+		for x := range width {
+			switch {
+			case y%4 == 1:
+				collision[y][x] = x != width-1
+			case y%4 == 3:
+				collision[y][x] = x != 0
+			}
+		}
+	}
+
+	return maze.New("", "", width, height, 1, collision, tiles)
+}
+
+func TestLongPath(t *testing.T) {
+	m := makeSnake(50, 21)
+
+	start := maze.TilePos{Y: 0, X: 0}
+	end := maze.TilePos{Y: 20, X: 0}
+
+	path, ok := m.Pathfind(start, end)
+	if !ok {
+		t.Fatalf("Could not find path from %v to %v", start, end)
+	}
+	// The path walks 10 corridors of 49 steps and 20 steps down between them, plus the start tile
+	if want := 10*49 + 20 + 1; len(path) != want {
+		t.Fatalf("Wrong path length, got: %d, want: %d", len(path), want)
+	}
+	for i := 1; i < len(path); i++ {
+		if path[i].EuclidianDistance(path[i-1]) != 1 {
+			t.Fatalf("Path is not connected at index %d: %v -> %v", i, path[i-1], path[i])
+		}
+	}
+}
+
+func TestPathfindAvoiding(t *testing.T) {
+	m := makeMaze()
+
+	start := maze.TilePos{Y: 4, X: 3}
+	end := maze.TilePos{Y: 4, X: 7}
+	obstacle := maze.TilePos{Y: 4, X: 5}
+	blocked := func(pos maze.TilePos) bool { return pos == obstacle }
+
+	path, ok := m.PathfindAvoiding(start, end, blocked)
+	if !ok {
+		t.Fatalf("Could not find path around %v", obstacle)
+	}
+	// Around the top of the walls in the middle is the shortest way around
+	if len(path) != 11 {
+		t.Fatalf("Wrong path length, got: %d, want: %d", len(path), 11)
+	}
+	for i, pos := range path {
+		if pos == obstacle {
+			t.Fatalf("Path walks through obstacle at index %d: %v", i, path)
+		}
+	}
+
+	if path, ok := m.PathfindAvoiding(start, obstacle, blocked); ok {
+		t.Fatalf("Expected no path to a blocked tile, got: %v", path)
+	}
+}
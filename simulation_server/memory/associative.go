@@ -79,6 +79,11 @@ type ConceptNode struct {
 	Evidence []NodeId
 	// If this is a chat node what was said in the conversation
 	Chat []Utterance
+
+	// How many other events were merged into this event
+	Merged int
+	// Forgotten nodes can no longer be retrieved, they are kept so the ids of the other nodes stay the same
+	Forgotten bool
 }
 
 func (node ConceptNode) SPOSummary() SPO {
@@ -103,13 +108,30 @@ type Associative struct {
 	kwStrengthThoughts map[string]int
 
 	embeddings map[string][]float64
+	// Index over the embeddings of all nodes
+	index *Index
+
+	// Increased on every change to a node, so storage can tell which nodes need to be saved
+	revision uint64
+	// The revision at which every node last changed, indexed by NodeId
+	changed []uint64
 }
 
-func NewAssociative(embeddings map[string][]float64, kwStrengthEvents map[string]int, kwStrengthThoughts map[string]int) *Associative {
+type AssociativeOpt func(*Associative)
+
+// WithIndex makes the memory use index, for example an index that was saved alongside the memory.
+// The index must contain exactly the nodes that are added to the memory afterwards.
+func WithIndex(index *Index) AssociativeOpt {
+	return func(a *Associative) {
+		a.index = index
+	}
+}
+
+func NewAssociative(embeddings map[string][]float64, kwStrengthEvents map[string]int, kwStrengthThoughts map[string]int, opts ...AssociativeOpt) *Associative {
 	// Size to initialize memory store slices to
 	initialMemorySize := 5
 
-	return &Associative{
+	store := &Associative{
 		// The node ID's start at 1, so create an empty node at index 0
 		nodes:              make([]ConceptNode, 1, initialMemorySize),
 		events:             make([]NodeId, 0, initialMemorySize),
@@ -121,13 +143,32 @@ func NewAssociative(embeddings map[string][]float64, kwStrengthEvents map[string
 		kwStrengthEvents:   kwStrengthEvents,
 		kwStrengthThoughts: kwStrengthThoughts,
 		embeddings:         embeddings,
+		index:              NewIndex(),
+		changed:            make([]uint64, 1, initialMemorySize),
+	}
+	for _, opt := range opts {
+		opt(store)
 	}
+
+	return store
 }
 
 func (store *Associative) Embeddings() map[string][]float64 {
 	return store.embeddings
 }
 
+func (store *Associative) Index() *Index {
+	return store.index
+}
+
+// NearestNodes returns the k nodes whose embedding is most similar to embedding, for which accept returns true.
+// The nodes are found using the index, so they are not guaranteed to be the most similar nodes.
+func (store *Associative) NearestNodes(embedding []float64, k int, accept func(ConceptNode) bool) []NodeId {
+	return store.index.Search(embedding, k, func(id NodeId) bool {
+		return accept(store.nodes[id])
+	})
+}
+
 func (store *Associative) EventKeywordStrength() map[string]int {
 	return store.kwStrengthEvents
 }
@@ -140,12 +181,52 @@ func (store *Associative) Nodes() []ConceptNode {
 	return store.nodes[1:]
 }
 
+// Count returns how many nodes of type t the memory holds.
+func (store *Associative) Count(t NodeType) int {
+	switch t {
+	case NodeTypeEvent:
+		return len(store.events)
+	case NodeTypeThought:
+		return len(store.thoughts)
+	case NodeTypeChat:
+		return len(store.chats)
+	default:
+		return 0
+	}
+}
+
 func (store *Associative) GetNode(node NodeId) ConceptNode {
 	return store.nodes[node]
 }
 
 func (store *Associative) UpdateNode(node NodeId, update func(*ConceptNode)) {
 	update(&store.nodes[node])
+	store.touch(node)
+}
+
+// touch marks node as changed.
+func (store *Associative) touch(node NodeId) {
+	store.revision += 1
+	for len(store.changed) <= int(node) {
+		store.changed = append(store.changed, 0)
+	}
+	store.changed[node] = store.revision
+}
+
+// Revision returns the current revision of the memory, it increases whenever a node is added or changed.
+func (store *Associative) Revision() uint64 {
+	return store.revision
+}
+
+// ChangedSince returns the nodes that were added or changed after revision.
+func (store *Associative) ChangedSince(revision uint64) []NodeId {
+	changed := []NodeId{}
+	for id, rev := range store.changed {
+		if rev > revision {
+			changed = append(changed, NodeId(id))
+		}
+	}
+	return changed
 }
 
 func (store *Associative) GetEmbedding(str string) ([]float64, bool) {
@@ -217,6 +298,8 @@ func (store *Associative) AddEvent(spo SPO, description, original string, keywor
 	}
 
 	store.embeddings[embeddingKey] = embedding
+	store.index.Add(node.Id, embedding)
+	store.touch(node.Id)
 
 	return node
 }
@@ -280,6 +363,8 @@ func (store *Associative) AddThought(spo SPO, description, original string, keyw
 	}
 
 	store.embeddings[embeddingKey] = embedding
+	store.index.Add(node.Id, embedding)
+	store.touch(node.Id)
 
 	return node
 }
@@ -329,6 +414,18 @@ func (store *Associative) AddChat(spo SPO, description, original string, keyword
 	}
 
 	store.embeddings[embeddingKey] = embedding
+	store.index.Add(node.Id, embedding)
+	store.touch(node.Id)
+
+	return node
+}
+
+// AddForgotten adds a node that has already been forgotten, so the nodes after it keep their ids when loading a memory.
+func (store *Associative) AddForgotten(node ConceptNode) ConceptNode {
+	node.Id = NodeId(len(store.nodes))
+	node.Forgotten = true
+	store.nodes = append(store.nodes, node)
+	store.touch(node.Id)
 
 	return node
 }
@@ -382,6 +479,10 @@ func (store *Associative) GetLatestThoughtIds() []NodeId {
 	return store.thoughts
 }
 
+func (store *Associative) GetLatestChatIds() []NodeId {
+	return store.chats
+}
+
 func (store *Associative) GetLastChat(name string) (NodeId, bool) {
 	if chats, ok := store.kwToChats[name]; ok {
 		return chats[0], true
@@ -0,0 +1,216 @@
+package memory
+
+import (
+	"slices"
+	"time"
+)
+
+// ForgettingPolicy configures which memories a persona forgets, the zero value forgets nothing.
+type ForgettingPolicy struct {
+	// Whether nodes are forgotten once their expiration has passed
+	Expire bool
+	// Events with at most this importance are forgotten when they have not been accessed for DecayAfter
+	DecayImportance int
+	// How long low importance events are remembered without being accessed, 0 disables decay
+	DecayAfter time.Duration
+	// Idle events about the same object that are older than this are merged into a single event, 0 disables merging
+	MergeIdleAfter time.Duration
+	// Whether forgotten nodes keep their contents, so they can still be inspected, or are emptied
+	Archive bool
+}
+
+// ForgetResult counts the nodes forgotten by a single application of a ForgettingPolicy.
+type ForgetResult struct {
+	Expired int
+	Decayed int
+	// The amount of idle events that were merged into another event
+	Merged int
+}
+
+// isIdle reports whether the event only describes an object being idle.
+func isIdle(node ConceptNode) bool {
+	return node.Predicate == "is" && node.Object == "idle"
+}
+
+// Forget applies policy to the memory at time now.
+func (store *Associative) Forget(policy ForgettingPolicy, now time.Time) ForgetResult {
+	var result ForgetResult
+	forget := []NodeId{}
+
+	for _, ids := range [][]NodeId{store.events, store.thoughts, store.chats} {
+		for _, id := range ids {
+			node := store.nodes[id]
+			if policy.Expire && node.Expiration != nil && !now.Before(*node.Expiration) {
+				forget = append(forget, id)
+				result.Expired += 1
+			} else if policy.DecayAfter > 0 && node.Type == NodeTypeEvent && !isIdle(node) &&
+				node.Importance <= policy.DecayImportance && now.Sub(node.LastAccessed) >= policy.DecayAfter {
+				forget = append(forget, id)
+				result.Decayed += 1
+			}
+		}
+	}
+	store.forgetNodes(forget, policy.Archive)
+
+	if policy.MergeIdleAfter > 0 {
+		// Events are stored newest first, so the first event of every object is the one the others are merged into
+		idle := map[string][]NodeId{}
+		subjects := []string{}
+		for _, id := range store.events {
+			node := store.nodes[id]
+			if !isIdle(node) || now.Sub(node.Created) < policy.MergeIdleAfter {
+				continue
+			}
+			if _, ok := idle[node.Subject]; !ok {
+				subjects = append(subjects, node.Subject)
+			}
+			idle[node.Subject] = append(idle[node.Subject], id)
+		}
+
+		merged := []NodeId{}
+		for _, subject := range subjects {
+			if ids := idle[subject]; len(ids) > 1 {
+				store.merge(ids[0], ids[1:])
+				merged = append(merged, ids[1:]...)
+			}
+		}
+		store.forgetNodes(merged, policy.Archive)
+		result.Merged = len(merged)
+	}
+
+	return result
+}
+
+// merge folds the events in others into the event into, it then covers the whole period of the events.
+// Nodes that used the other events as evidence use into instead.
+func (store *Associative) merge(into NodeId, others []NodeId) {
+	summary := &store.nodes[into]
+	for _, id := range others {
+		other := store.nodes[id]
+
+		summary.Merged += other.Merged + 1
+		if other.Created.Before(summary.Created) {
+			summary.Created = other.Created
+		}
+		if other.LastAccessed.After(summary.LastAccessed) {
+			summary.LastAccessed = other.LastAccessed
+		}
+		summary.Importance = max(summary.Importance, other.Importance)
+		for _, kw := range other.Keywords {
+			if !slices.Contains(summary.Keywords, kw) {
+				summary.Keywords = append(summary.Keywords, kw)
+			}
+		}
+		for _, ev := range other.Evidence {
+			if !slices.Contains(summary.Evidence, ev) {
+				summary.Evidence = append(summary.Evidence, ev)
+			}
+		}
+	}
+
+	for i := range store.nodes[1:] {
+		node := &store.nodes[i+1]
+		if node.Forgotten || node.Id == into {
+			continue
+		}
+
+		redirected := false
+		node.Evidence = slices.DeleteFunc(node.Evidence, func(ev NodeId) bool {
+			if slices.Contains(others, ev) {
+				redirected = true
+				return true
+			}
+			return false
+		})
+		if redirected {
+			if !slices.Contains(node.Evidence, into) {
+				node.Evidence = append(node.Evidence, into)
+			}
+			store.touch(node.Id)
+		}
+	}
+	store.touch(into)
+}
+
+// forgetNodes removes ids from the memory, they can no longer be retrieved and are no longer used as evidence.
+// The nodes themselves remain so node ids stay the same, when archive is false their contents are dropped.
+func (store *Associative) forgetNodes(ids []NodeId, archive bool) {
+	if len(ids) == 0 {
+		return
+	}
+
+	forgotten := make(map[NodeId]bool, len(ids))
+	for _, id := range ids {
+		forgotten[id] = true
+	}
+	isForgotten := func(id NodeId) bool { return forgotten[id] }
+
+	store.events = slices.DeleteFunc(store.events, isForgotten)
+	store.thoughts = slices.DeleteFunc(store.thoughts, isForgotten)
+	store.chats = slices.DeleteFunc(store.chats, isForgotten)
+
+	for _, id := range ids {
+		node := &store.nodes[id]
+
+		kwTo, kwStrength := store.kwToEvents, store.kwStrengthEvents
+		switch node.Type {
+		case NodeTypeThought:
+			kwTo, kwStrength = store.kwToThoughts, store.kwStrengthThoughts
+		case NodeTypeChat:
+			kwTo, kwStrength = store.kwToChats, nil
+		}
+		for _, kw := range node.Keywords {
+			if kwTo[kw] = slices.DeleteFunc(kwTo[kw], isForgotten); len(kwTo[kw]) == 0 {
+				delete(kwTo, kw)
+			}
+			// Only keywords of events that are not idle count towards the strength, see AddEvent and AddThought
+			if kwStrength != nil && node.Predicate != "is" && node.Object != "idle" {
+				if kwStrength[kw] -= 1; kwStrength[kw] <= 0 {
+					delete(kwStrength, kw)
+				}
+			}
+		}
+
+		store.index.Remove(id)
+		node.Forgotten = true
+		store.touch(id)
+	}
+
+	// Embeddings are shared between nodes with the same key, so they are only dropped when no remaining node uses them
+	used := map[string]bool{}
+	for i := range store.nodes[1:] {
+		node := &store.nodes[i+1]
+		if node.Forgotten {
+			continue
+		}
+
+		used[node.EmbeddingKey] = true
+		if evidence := len(node.Evidence); evidence > 0 {
+			if node.Evidence = slices.DeleteFunc(node.Evidence, isForgotten); len(node.Evidence) != evidence {
+				store.touch(node.Id)
+			}
+		}
+	}
+	for _, id := range ids {
+		node := &store.nodes[id]
+		if !used[node.EmbeddingKey] {
+			delete(store.embeddings, node.EmbeddingKey)
+		}
+
+		if !archive {
+			*node = ConceptNode{
+				Id:           node.Id,
+				NodeCount:    node.NodeCount,
+				TypeCount:    node.TypeCount,
+				Type:         node.Type,
+				Depth:        node.Depth,
+				Created:      node.Created,
+				LastAccessed: node.LastAccessed,
+				Forgotten:    true,
+				Keywords:     []string{},
+				Evidence:     []NodeId{},
+				Chat:         []Utterance{},
+			}
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package memory_test
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/fvdveen/generative_agents/simulation_server/memory"
+)
+
+var start = time.Date(2023, 2, 13, 0, 0, 0, 0, time.UTC)
+
+func addEvent(store *memory.Associative, subject, predicate, object string, importance int, created time.Time, embedding []float64) memory.NodeId {
+	description := subject + " " + predicate + " " + object
+	return store.AddEvent(memory.SPO{Subject: subject, Predicate: predicate, Object: object}, description, description,
+		[]string{subject, object}, importance, 0, []memory.NodeId{}, created, nil, description, embedding).Id
+}
+
+func TestForgetExpiredAndDecayed(t *testing.T) {
+	store := memory.NewAssociative(map[string][]float64{}, map[string]int{}, map[string]int{})
+
+	boring := addEvent(store, "bed", "is", "made", 1, start, []float64{1, 0})
+	important := addEvent(store, "house", "is", "on fire", 9, start, []float64{0, 1})
+	expiration := start.Add(24 * time.Hour)
+	thought := store.AddThought(memory.SPO{Subject: "Klaus", Predicate: "plan", Object: "today"}, "plan", "plan",
+		[]string{"plan"}, 5, 0, []memory.NodeId{boring}, start, &expiration, "plan", []float64{1, 1}).Id
+
+	result := store.Forget(memory.ForgettingPolicy{Expire: true, DecayImportance: 2, DecayAfter: 24 * time.Hour}, start.Add(48*time.Hour))
+	if result.Expired != 1 || result.Decayed != 1 {
+		t.Fatalf("Wrong amount of forgotten nodes, got: %+v", result)
+	}
+
+	if ids := store.GetLatestEventIds(); !slices.Equal(ids, []memory.NodeId{important}) {
+		t.Fatalf("Wrong events remembered, got: %v, want: %v", ids, []memory.NodeId{important})
+	}
+	if ids := store.GetLatestThoughtIds(); len(ids) != 0 {
+		t.Fatalf("Expected the thought to be forgotten, got: %v", ids)
+	}
+	if !store.GetNode(thought).Forgotten || store.GetNode(boring).Description != "" {
+		t.Fatalf("Expected the nodes to be forgotten and emptied")
+	}
+	if _, ok := store.GetEmbedding("bed is made"); ok {
+		t.Fatalf("Expected the embedding of a forgotten node to be dropped")
+	}
+	if _, ok := store.RetrieveRelevantEvents("bed", "", "")[boring]; ok {
+		t.Fatalf("Expected a forgotten node not to be found by keyword")
+	}
+	if strength := store.EventKeywordStrength()["bed"]; strength != 0 {
+		t.Fatalf("Expected the keyword strength to be lowered, got: %d", strength)
+	}
+	if nearest := store.NearestNodes([]float64{1, 0}, 10, func(memory.ConceptNode) bool { return true }); slices.Contains(nearest, boring) {
+		t.Fatalf("Expected a forgotten node not to be in the index, got: %v", nearest)
+	}
+}
+
+func TestForgetMergesIdleEvents(t *testing.T) {
+	store := memory.NewAssociative(map[string][]float64{}, map[string]int{}, map[string]int{})
+
+	first := addEvent(store, "bed", "is", "idle", 1, start, []float64{1, 0})
+	second := addEvent(store, "bed", "is", "idle", 1, start.Add(time.Hour), []float64{1, 0})
+	last := addEvent(store, "bed", "is", "idle", 1, start.Add(2*time.Hour), []float64{1, 0})
+	thought := store.AddThought(memory.SPO{Subject: "Klaus", Predicate: "is", Object: "tired"}, "tired", "tired",
+		[]string{"tired"}, 5, 0, []memory.NodeId{first, second}, start, nil, "tired", []float64{0, 1}).Id
+
+	result := store.Forget(memory.ForgettingPolicy{MergeIdleAfter: time.Hour}, start.Add(3*time.Hour))
+	if result.Merged != 2 {
+		t.Fatalf("Wrong amount of merged events, got: %d, want: %d", result.Merged, 2)
+	}
+
+	if ids := store.GetLatestEventIds(); !slices.Equal(ids, []memory.NodeId{last}) {
+		t.Fatalf("Wrong events remembered, got: %v, want: %v", ids, []memory.NodeId{last})
+	}
+	summary := store.GetNode(last)
+	if summary.Merged != 2 || !summary.Created.Equal(start) {
+		t.Fatalf("Summary does not cover the merged events, got: %+v", summary)
+	}
+	if evidence := store.GetNode(thought).Evidence; !slices.Equal(evidence, []memory.NodeId{last}) {
+		t.Fatalf("Evidence not redirected to summary, got: %v, want: %v", evidence, []memory.NodeId{last})
+	}
+	if _, ok := store.GetEmbedding("bed is idle"); !ok {
+		t.Fatalf("Expected the embedding shared with the summary to be kept")
+	}
+}
@@ -0,0 +1,480 @@
+package memory
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+)
+
+const (
+	// How many neighbours a node links to on every level but the bottom one
+	indexLinks = 16
+	// How many neighbours a node links to on the bottom level, which contains every node
+	indexBottomLinks = 2 * indexLinks
+	// How many candidates are considered when linking a new node, higher is slower but gives a better graph
+	indexEfConstruction = 100
+	// The minimum amount of candidates considered when searching
+	indexEfSearch = 100
+)
+
+// Index is an approximate nearest neighbour index over the embeddings of memory nodes, nodes are compared by their
+// cosine similarity. It is a HNSW graph: every node is part of the bottom level, and every level above it holds a
+// smaller part of the nodes of the level below it, so searches can quickly zoom in on the right part of the graph.
+//
+// Only the graph is persisted, the vectors are attached again when the nodes are added to the associative memory.
+type Index struct {
+	nodes map[NodeId]*indexNode
+	// The node at which every search starts, it is part of the highest level
+	entry NodeId
+	// The highest level of the graph, -1 if the graph is empty
+	maxLevel int
+
+	// Increased whenever the links of a node change, so storage can tell which nodes need to be saved
+	revision uint64
+	// The revision at which the links of every node last changed, this includes removed nodes
+	changed map[NodeId]uint64
+}
+
+type indexNode struct {
+	vector []float64
+	norm   float64
+	// The neighbours of the node on every level it is part of
+	links [][]NodeId
+}
+
+type scoredNode struct {
+	id  NodeId
+	sim float64
+}
+
+func NewIndex() *Index {
+	return &Index{nodes: map[NodeId]*indexNode{}, maxLevel: -1, changed: map[NodeId]uint64{}}
+}
+
+// NewIndexFromLinks creates an index from the links of every node on every level, as returned by Links.
+// The vectors are attached by adding the nodes again.
+func NewIndexFromLinks(entry NodeId, maxLevel int, links map[NodeId][][]NodeId) (*Index, error) {
+	nodes := make(map[NodeId]*indexNode, len(links))
+	for id, l := range links {
+		nodes[id] = &indexNode{links: l}
+	}
+
+	// Searches follow the links blindly, so a broken graph has to be rejected here
+	hasLevel := func(id NodeId, level int) bool {
+		n, ok := nodes[id]
+		return ok && len(n.links) > level
+	}
+	if len(nodes) == 0 {
+		maxLevel = -1
+	} else if !hasLevel(entry, maxLevel) {
+		return nil, fmt.Errorf("entry node %d is not part of level %d", entry, maxLevel)
+	}
+	for id, n := range nodes {
+		for level, links := range n.links {
+			for _, link := range links {
+				if !hasLevel(link, level) {
+					return nil, fmt.Errorf("node %d links to node %d, which is not part of level %d", id, link, level)
+				}
+			}
+		}
+	}
+
+	return &Index{nodes: nodes, entry: entry, maxLevel: maxLevel, changed: map[NodeId]uint64{}}, nil
+}
+
+func norm(vector []float64) float64 {
+	var sum float64
+	for _, v := range vector {
+		sum += v * v
+	}
+	return math.Sqrt(sum)
+}
+
+func (n *indexNode) similarity(vector []float64, norm float64) float64 {
+	var dot float64
+	for i := range vector {
+		dot += n.vector[i] * vector[i]
+	}
+	return dot / (n.norm * norm)
+}
+
+// level picks the highest level a node is part of, every level holds 1/indexLinks of the nodes of the level below it.
+// The level is derived from the id so the graph does not depend on anything but the order nodes are added in.
+func level(id NodeId) int {
+	// splitmix64, to spread the ids over the full range
+	h := uint64(id) + 0x9e3779b97f4a7c15
+	h = (h ^ (h >> 30)) * 0xbf58476d1ce4e5b9
+	h = (h ^ (h >> 27)) * 0x94d049bb133111eb
+	h ^= h >> 31
+
+	u := (float64(h>>11) + 1) / (1 << 53)
+	return int(-math.Log(u) / math.Log(indexLinks))
+}
+
+func maxLinks(level int) int {
+	if level == 0 {
+		return indexBottomLinks
+	}
+	return indexLinks
+}
+
+// Len returns the amount of nodes in the index.
+func (idx *Index) Len() int {
+	return len(idx.nodes)
+}
+
+// Contains reports whether node id is part of the index.
+func (idx *Index) Contains(id NodeId) bool {
+	_, ok := idx.nodes[id]
+	return ok
+}
+
+func (idx *Index) touch(id NodeId) {
+	idx.revision += 1
+	idx.changed[id] = idx.revision
+}
+
+// Revision returns the current revision of the index, it increases whenever the links of a node change.
+func (idx *Index) Revision() uint64 {
+	return idx.revision
+}
+
+// ChangedSince returns the nodes whose links changed after revision, including nodes that have been removed since.
+func (idx *Index) ChangedSince(revision uint64) []NodeId {
+	changed := []NodeId{}
+	for id, rev := range idx.changed {
+		if rev > revision {
+			changed = append(changed, id)
+		}
+	}
+	slices.Sort(changed)
+	return changed
+}
+
+// Links returns the neighbours of node id on every level it is part of, ok is false if the node is not part of the index.
+func (idx *Index) Links(id NodeId) (links [][]NodeId, ok bool) {
+	node, ok := idx.nodes[id]
+	if !ok {
+		return nil, false
+	}
+	return node.links, true
+}
+
+// Entry returns the node at which searches start and the highest level of the graph, which is -1 for an empty index.
+func (idx *Index) Entry() (NodeId, int) {
+	return idx.entry, idx.maxLevel
+}
+
+// Add adds node id to the index. If the node is already part of the index only its vector is updated,
+// empty and zero vectors cannot be compared and are not added.
+func (idx *Index) Add(id NodeId, vector []float64) {
+	norm := norm(vector)
+	if node, ok := idx.nodes[id]; ok {
+		if node.vector == nil && norm != 0 {
+			node.vector, node.norm = vector, norm
+		}
+		return
+	}
+	if norm == 0 {
+		return
+	}
+
+	nodeLevel := level(id)
+	node := &indexNode{vector: vector, norm: norm, links: make([][]NodeId, nodeLevel+1)}
+	idx.nodes[id] = node
+	idx.touch(id)
+
+	if idx.maxLevel == -1 {
+		idx.entry, idx.maxLevel = id, nodeLevel
+		return
+	}
+
+	entries := []scoredNode{{id: idx.entry, sim: idx.nodes[idx.entry].similarity(vector, norm)}}
+	for l := idx.maxLevel; l > nodeLevel; l -= 1 {
+		entries = idx.searchLevel(vector, norm, entries, 1, l, nil)[:1]
+	}
+
+	for l := min(nodeLevel, idx.maxLevel); l >= 0; l -= 1 {
+		found := idx.searchLevel(vector, norm, entries, indexEfConstruction, l, nil)
+		node.links[l] = idx.selectNeighbours(found, maxLinks(l))
+
+		for _, neighbourId := range node.links[l] {
+			neighbour := idx.nodes[neighbourId]
+			neighbour.links[l] = append(neighbour.links[l], id)
+			if len(neighbour.links[l]) > maxLinks(l) {
+				idx.shrink(neighbour, l)
+			}
+			idx.touch(neighbourId)
+		}
+
+		entries = found
+	}
+
+	if nodeLevel > idx.maxLevel {
+		idx.entry, idx.maxLevel = id, nodeLevel
+	}
+}
+
+// Remove removes node id from the index, the nodes that linked to it are linked to its neighbours instead.
+func (idx *Index) Remove(id NodeId) {
+	node, ok := idx.nodes[id]
+	if !ok {
+		return
+	}
+	delete(idx.nodes, id)
+	idx.touch(id)
+
+	// Links are not always mutual, so every node has to be checked
+	for otherId, other := range idx.nodes {
+		for l := range min(len(other.links), len(node.links)) {
+			if !slices.Contains(other.links[l], id) {
+				continue
+			}
+
+			candidates := []scoredNode{}
+			for _, link := range slices.Concat(other.links[l], node.links[l]) {
+				linked, ok := idx.nodes[link]
+				if !ok || linked == other || slices.ContainsFunc(candidates, func(c scoredNode) bool { return c.id == link }) {
+					continue
+				}
+				candidates = append(candidates, scoredNode{id: link, sim: linked.similarity(other.vector, other.norm)})
+			}
+			slices.SortFunc(candidates, compareScored)
+
+			other.links[l] = idx.selectNeighbours(candidates, maxLinks(l))
+			idx.touch(otherId)
+		}
+	}
+
+	if id != idx.entry {
+		return
+	}
+	idx.maxLevel = -1
+	for other, n := range idx.nodes {
+		if top := len(n.links) - 1; top > idx.maxLevel || (top == idx.maxLevel && other < idx.entry) {
+			idx.entry, idx.maxLevel = other, top
+		}
+	}
+}
+
+// Search returns the ids of the k nodes most similar to vector, most similar first.
+// Only nodes for which accept returns true are returned, a nil accept function accepts every node.
+func (idx *Index) Search(vector []float64, k int, accept func(NodeId) bool) []NodeId {
+	norm := norm(vector)
+	if idx.maxLevel == -1 || k <= 0 || norm == 0 {
+		return []NodeId{}
+	}
+
+	entries := []scoredNode{{id: idx.entry, sim: idx.nodes[idx.entry].similarity(vector, norm)}}
+	for l := idx.maxLevel; l > 0; l -= 1 {
+		entries = idx.searchLevel(vector, norm, entries, 1, l, nil)[:1]
+	}
+
+	found := idx.searchLevel(vector, norm, entries, max(k, indexEfSearch), 0, accept)
+
+	out := make([]NodeId, 0, min(k, len(found)))
+	for _, n := range found[:min(k, len(found))] {
+		out = append(out, n.id)
+	}
+	return out
+}
+
+// searchLevel finds the ef nodes on level most similar to vector, starting from entries, most similar first.
+// All nodes are walked through, but only nodes for which accept returns true end up in the results.
+func (idx *Index) searchLevel(vector []float64, norm float64, entries []scoredNode, ef int, level int, accept func(NodeId) bool) []scoredNode {
+	visited := make(map[NodeId]bool, ef*4)
+	// The nodes whose neighbours still need to be visited, most similar first
+	candidates := scoredQueue{closest: true}
+	// The best nodes found so far, least similar first so it can be dropped when a better node is found
+	results := scoredQueue{closest: false}
+
+	for _, e := range entries {
+		visited[e.id] = true
+		candidates.push(e)
+		if accept == nil || accept(e.id) {
+			results.push(e)
+		}
+	}
+	for results.len() > ef {
+		results.pop()
+	}
+
+	for candidates.len() > 0 {
+		c := candidates.pop()
+		if results.len() >= ef && c.sim < results.peek().sim {
+			break
+		}
+
+		for _, id := range idx.nodes[c.id].links[level] {
+			if visited[id] {
+				continue
+			}
+			visited[id] = true
+
+			n := scoredNode{id: id, sim: idx.nodes[id].similarity(vector, norm)}
+			if results.len() >= ef && n.sim <= results.peek().sim {
+				continue
+			}
+			candidates.push(n)
+			if accept == nil || accept(id) {
+				results.push(n)
+				if results.len() > ef {
+					results.pop()
+				}
+			}
+		}
+	}
+
+	out := results.items
+	slices.SortFunc(out, compareScored)
+	return out
+}
+
+// compareScored orders nodes most similar first, breaking ties by id so the order is stable.
+func compareScored(a, b scoredNode) int {
+	if c := cmp.Compare(b.sim, a.sim); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.id, b.id)
+}
+
+// selectNeighbours picks at most m of candidates to link to, candidates must be sorted most similar first.
+// A candidate is skipped when it is more similar to an already picked neighbour than to the node itself,
+// that way the links point in different directions, which keeps clusters of similar memories connected to the rest of the graph.
+func (idx *Index) selectNeighbours(candidates []scoredNode, m int) []NodeId {
+	selected := make([]NodeId, 0, m)
+	skipped := []NodeId{}
+
+	for _, c := range candidates {
+		if len(selected) >= m {
+			break
+		}
+
+		node := idx.nodes[c.id]
+		diverse := true
+		for _, s := range selected {
+			other := idx.nodes[s]
+			if node.similarity(other.vector, other.norm) > c.sim {
+				diverse = false
+				break
+			}
+		}
+
+		if diverse {
+			selected = append(selected, c.id)
+		} else {
+			skipped = append(skipped, c.id)
+		}
+	}
+
+	// Rather link to similar nodes than not at all
+	for _, id := range skipped {
+		if len(selected) >= m {
+			break
+		}
+		selected = append(selected, id)
+	}
+
+	return selected
+}
+
+// shrink drops links of node on level until it has no more links than allowed.
+func (idx *Index) shrink(node *indexNode, level int) {
+	candidates := make([]scoredNode, 0, len(node.links[level]))
+	for _, id := range node.links[level] {
+		candidates = append(candidates, scoredNode{id: id, sim: idx.nodes[id].similarity(node.vector, node.norm)})
+	}
+	slices.SortFunc(candidates, compareScored)
+
+	node.links[level] = idx.selectNeighbours(candidates, maxLinks(level))
+}
+
+type indexJSON struct {
+	Entry    NodeId                `json:"entry"`
+	MaxLevel int                   `json:"max_level"`
+	Links    map[NodeId][][]NodeId `json:"links"`
+}
+
+func (idx *Index) MarshalJSON() ([]byte, error) {
+	links := make(map[NodeId][][]NodeId, len(idx.nodes))
+	for id, n := range idx.nodes {
+		links[id] = n.links
+	}
+
+	return json.Marshal(indexJSON{Entry: idx.entry, MaxLevel: idx.maxLevel, Links: links})
+}
+
+func (idx *Index) UnmarshalJSON(data []byte) error {
+	var in indexJSON
+	if err := json.Unmarshal(data, &in); err != nil {
+		return err
+	}
+
+	loaded, err := NewIndexFromLinks(in.Entry, in.MaxLevel, in.Links)
+	if err != nil {
+		return err
+	}
+
+	*idx = *loaded
+	return nil
+}
+
+// scoredQueue is a binary heap of scored nodes, popping either the most or the least similar node first.
+type scoredQueue struct {
+	items   []scoredNode
+	closest bool
+}
+
+func (q *scoredQueue) len() int {
+	return len(q.items)
+}
+
+func (q *scoredQueue) less(i, j int) bool {
+	c := compareScored(q.items[i], q.items[j]) < 0
+	if q.closest {
+		return c
+	}
+	return !c
+}
+
+func (q *scoredQueue) peek() scoredNode {
+	return q.items[0]
+}
+
+func (q *scoredQueue) push(n scoredNode) {
+	q.items = append(q.items, n)
+	for i := len(q.items) - 1; i > 0; {
+		parent := (i - 1) / 2
+		if !q.less(i, parent) {
+			break
+		}
+		q.items[i], q.items[parent] = q.items[parent], q.items[i]
+		i = parent
+	}
+}
+
+func (q *scoredQueue) pop() scoredNode {
+	n := q.items[0]
+	last := len(q.items) - 1
+	q.items[0] = q.items[last]
+	q.items = q.items[:last]
+
+	for i := 0; ; {
+		smallest, left, right := i, 2*i+1, 2*i+2
+		if left < last && q.less(left, smallest) {
+			smallest = left
+		}
+		if right < last && q.less(right, smallest) {
+			smallest = right
+		}
+		if smallest == i {
+			break
+		}
+		q.items[i], q.items[smallest] = q.items[smallest], q.items[i]
+		i = smallest
+	}
+
+	return n
+}
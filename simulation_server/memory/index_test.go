@@ -0,0 +1,192 @@
+package memory_test
+
+import (
+	"cmp"
+	"encoding/json"
+	"math"
+	"math/rand"
+	"slices"
+	"testing"
+
+	"github.com/fvdveen/generative_agents/simulation_server/memory"
+)
+
+// randomVectors creates n vectors grouped around a few centers, like the embeddings of memories about the same things.
+func randomVectors(rng *rand.Rand, n, dims int) [][]float64 {
+	centers := make([][]float64, 20)
+	for i := range centers {
+		centers[i] = make([]float64, dims)
+		for j := range dims {
+			centers[i][j] = rng.NormFloat64()
+		}
+	}
+
+	vectors := make([][]float64, n)
+	for i := range vectors {
+		center := centers[rng.Intn(len(centers))]
+		vectors[i] = make([]float64, dims)
+		for j := range dims {
+			vectors[i][j] = center[j] + 0.5*rng.NormFloat64()
+		}
+	}
+
+	return vectors
+}
+
+func cosine(a, b []float64) float64 {
+	var dot, na, nb float64
+	for i := range a {
+		dot += a[i] * b[i]
+		na += a[i] * a[i]
+		nb += b[i] * b[i]
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
+
+// exactNearest returns the ids of the k vectors most similar to query, ids start at 1.
+func exactNearest(vectors [][]float64, query []float64, k int) []memory.NodeId {
+	ids := make([]memory.NodeId, len(vectors))
+	sims := make([]float64, len(vectors)+1)
+	for i := range vectors {
+		ids[i] = memory.NodeId(i + 1)
+		sims[i+1] = cosine(vectors[i], query)
+	}
+	slices.SortFunc(ids, func(a, b memory.NodeId) int {
+		return cmp.Compare(sims[b], sims[a])
+	})
+	return ids[:k]
+}
+
+func buildIndex(vectors [][]float64) *memory.Index {
+	index := memory.NewIndex()
+	for i, v := range vectors {
+		index.Add(memory.NodeId(i+1), v)
+	}
+	return index
+}
+
+func TestIndexRecall(t *testing.T) {
+	const k = 10
+	rng := rand.New(rand.NewSource(1))
+	vectors := randomVectors(rng, 2000, 64)
+	index := buildIndex(vectors)
+
+	found, total := 0, 0
+	for _, query := range randomVectors(rng, 50, 64) {
+		exact := exactNearest(vectors, query, k)
+		for _, id := range index.Search(query, k, nil) {
+			if slices.Contains(exact, id) {
+				found += 1
+			}
+		}
+		total += k
+	}
+
+	if recall := float64(found) / float64(total); recall < 0.9 {
+		t.Fatalf("Recall too low, got: %.2f, want at least 0.9", recall)
+	}
+}
+
+func TestIndexSearchFilter(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	vectors := randomVectors(rng, 500, 16)
+	index := buildIndex(vectors)
+
+	even := func(id memory.NodeId) bool { return id%2 == 0 }
+	result := index.Search(vectors[0], 20, even)
+	if len(result) != 20 {
+		t.Fatalf("Wrong amount of results, got: %d, want: %d", len(result), 20)
+	}
+	for _, id := range result {
+		if !even(id) {
+			t.Fatalf("Search returned filtered node %d", id)
+		}
+	}
+}
+
+func TestIndexJSON(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	vectors := randomVectors(rng, 500, 16)
+	index := buildIndex(vectors)
+
+	data, err := json.Marshal(index)
+	if err != nil {
+		t.Fatalf("Could not marshal index: %v", err)
+	}
+
+	loaded := memory.NewIndex()
+	if err := json.Unmarshal(data, loaded); err != nil {
+		t.Fatalf("Could not unmarshal index: %v", err)
+	}
+	// The vectors are not saved, they are attached again when the nodes are loaded
+	for i, v := range vectors {
+		loaded.Add(memory.NodeId(i+1), v)
+	}
+
+	query := randomVectors(rng, 1, 16)[0]
+	if want, got := index.Search(query, 10, nil), loaded.Search(query, 10, nil); !slices.Equal(want, got) {
+		t.Fatalf("Loaded index gives different results, got: %v, want: %v", got, want)
+	}
+
+	if err := json.Unmarshal([]byte(`{"entry": 1, "max_level": 0, "links": {"1": [[2]]}}`), memory.NewIndex()); err == nil {
+		t.Fatalf("Expected an error for an index linking to unknown nodes")
+	}
+}
+
+func BenchmarkIndexSearch(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	vectors := randomVectors(rng, 2000, 1536)
+	index := buildIndex(vectors)
+	query := randomVectors(rng, 1, 1536)[0]
+
+	for b.Loop() {
+		index.Search(query, 120, nil)
+	}
+}
+
+func BenchmarkExactSearch(b *testing.B) {
+	rng := rand.New(rand.NewSource(1))
+	vectors := randomVectors(rng, 2000, 1536)
+	query := randomVectors(rng, 1, 1536)[0]
+
+	for b.Loop() {
+		exactNearest(vectors, query, 120)
+	}
+}
+
+func TestIndexRemove(t *testing.T) {
+	const k = 10
+	rng := rand.New(rand.NewSource(1))
+	vectors := randomVectors(rng, 1000, 32)
+	index := buildIndex(vectors)
+
+	// Remove every other node, the remaining nodes must still be found
+	remaining := [][]float64{}
+	ids := []memory.NodeId{}
+	for i, v := range vectors {
+		if i%2 == 0 {
+			index.Remove(memory.NodeId(i + 1))
+		} else {
+			remaining = append(remaining, v)
+			ids = append(ids, memory.NodeId(i+1))
+		}
+	}
+
+	found, total := 0, 0
+	for _, query := range randomVectors(rng, 50, 32) {
+		exact := exactNearest(remaining, query, k)
+		for _, id := range index.Search(query, k, nil) {
+			if id%2 == 1 {
+				t.Fatalf("Search returned removed node %d", id)
+			}
+			if slices.Contains(exact, memory.NodeId(slices.Index(ids, id)+1)) {
+				found += 1
+			}
+		}
+		total += k
+	}
+
+	if recall := float64(found) / float64(total); recall < 0.9 {
+		t.Fatalf("Recall too low, got: %.2f, want at least 0.9", recall)
+	}
+}
@@ -147,6 +147,12 @@ func (p Path) ToString() string {
 	return str
 }
 
+// MarshalText encodes the path as returned by ToString.
+// There is no UnmarshalText as waiting paths cannot be parsed back by ParsePath.
+func (p Path) MarshalText() ([]byte, error) {
+	return []byte(p.ToString()), nil
+}
+
 func (p Path) HasState(state PathState) bool {
 	return p.Contains(state.ToString())
 }
@@ -0,0 +1,124 @@
+package metrics
+
+import (
+	"maps"
+	"sync"
+	"time"
+
+	"github.com/fvdveen/generative_agents/simulation_server/llm/accounting"
+)
+
+// Totals are the metrics recorded by a Memory.
+type Totals struct {
+	Steps int
+	// The total duration of every phase, by persona and phase
+	Phases map[string]map[string]time.Duration
+	// The calls, retries, failures and cache hits of every prompt
+	Calls     map[string]int
+	Retries   map[string]int
+	Failures  map[string]int
+	CacheHits map[string]int
+	// The reflections of every persona
+	Reflections map[string]int
+	// The last amount of nodes of every type, by persona and type
+	MemoryNodes  map[string]map[string]int
+	ActiveChats  int
+	SleepSkipped time.Duration
+}
+
+// Memory is a Recorder that keeps the metrics in memory, so tests can check them.
+type Memory struct {
+	mu     sync.Mutex
+	totals Totals
+}
+
+func NewMemory() *Memory {
+	return &Memory{totals: Totals{
+		Phases:      map[string]map[string]time.Duration{},
+		Calls:       map[string]int{},
+		Retries:     map[string]int{},
+		Failures:    map[string]int{},
+		CacheHits:   map[string]int{},
+		Reflections: map[string]int{},
+		MemoryNodes: map[string]map[string]int{},
+	}}
+}
+
+// Totals returns a copy of the metrics recorded so far.
+func (m *Memory) Totals() Totals {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t := m.totals
+	t.Phases = map[string]map[string]time.Duration{}
+	for persona, phases := range m.totals.Phases {
+		t.Phases[persona] = maps.Clone(phases)
+	}
+	t.MemoryNodes = map[string]map[string]int{}
+	for persona, nodes := range m.totals.MemoryNodes {
+		t.MemoryNodes[persona] = maps.Clone(nodes)
+	}
+	t.Calls = maps.Clone(m.totals.Calls)
+	t.Retries = maps.Clone(m.totals.Retries)
+	t.Failures = maps.Clone(m.totals.Failures)
+	t.CacheHits = maps.Clone(m.totals.CacheHits)
+	t.Reflections = maps.Clone(m.totals.Reflections)
+
+	return t
+}
+
+func (m *Memory) StepDone(time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.totals.Steps += 1
+}
+
+func (m *Memory) PhaseDone(persona string, phase string, duration time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.totals.Phases[persona] == nil {
+		m.totals.Phases[persona] = map[string]time.Duration{}
+	}
+	m.totals.Phases[persona][phase] += duration
+}
+
+func (m *Memory) LLMCall(call accounting.Call) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if call.Cached {
+		m.totals.CacheHits[call.Prompt] += 1
+		return
+	}
+	m.totals.Calls[call.Prompt] += 1
+	m.totals.Retries[call.Prompt] += max(call.Attempts-1, 0)
+	if call.Failed {
+		m.totals.Failures[call.Prompt] += 1
+	}
+}
+
+func (m *Memory) Reflection(persona string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.totals.Reflections[persona] += 1
+}
+
+func (m *Memory) MemoryNodes(persona string, nodeType string, count int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.totals.MemoryNodes[persona] == nil {
+		m.totals.MemoryNodes[persona] = map[string]int{}
+	}
+	m.totals.MemoryNodes[persona][nodeType] = count
+}
+
+func (m *Memory) ActiveChats(count int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.totals.ActiveChats = count
+}
+
+func (m *Memory) SleepSkipped(skipped time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.totals.SleepSkipped += skipped
+}
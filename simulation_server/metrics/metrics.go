@@ -0,0 +1,37 @@
+// Package metrics records the health of a running simulation, so long runs can be watched without reading the logs.
+package metrics
+
+import (
+	"time"
+
+	"github.com/fvdveen/generative_agents/simulation_server/llm/accounting"
+)
+
+// Recorder records the metrics of a simulation, implementations must be safe for concurrent use.
+type Recorder interface {
+	// StepDone records a step of the simulation that took duration to execute.
+	StepDone(duration time.Duration)
+	// PhaseDone records a phase of the step of persona, like "perceive" or "reflect", that took duration.
+	PhaseDone(persona string, phase string, duration time.Duration)
+	// LLMCall records a call to a language model, including its retries.
+	LLMCall(call accounting.Call)
+	// Reflection records persona reflecting on its recent memories.
+	Reflection(persona string)
+	// MemoryNodes records how many nodes of nodeType the associative memory of persona holds.
+	MemoryNodes(persona string, nodeType string, count int)
+	// ActiveChats records how many chats are going on.
+	ActiveChats(count int)
+	// SleepSkipped records the simulation skipping ahead while all personas are asleep.
+	SleepSkipped(skipped time.Duration)
+}
+
+// Nop is a Recorder that discards all metrics.
+type Nop struct{}
+
+func (Nop) StepDone(time.Duration)                  {}
+func (Nop) PhaseDone(string, string, time.Duration) {}
+func (Nop) LLMCall(accounting.Call)                 {}
+func (Nop) Reflection(string)                       {}
+func (Nop) MemoryNodes(string, string, int)         {}
+func (Nop) ActiveChats(int)                         {}
+func (Nop) SleepSkipped(time.Duration)              {}
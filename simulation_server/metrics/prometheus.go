@@ -0,0 +1,149 @@
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/fvdveen/generative_agents/simulation_server/llm/accounting"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "generative_agents"
+
+// Prometheus is a Recorder exposing the metrics in the Prometheus format, serve them with Handler.
+//
+// There is no series for the amount of steps per minute, it has to be derived from the step counter in Prometheus
+// as rate(generative_agents_steps_total[1m]) * 60.
+type Prometheus struct {
+	registry *prometheus.Registry
+
+	steps        prometheus.Counter
+	stepDuration prometheus.Histogram
+	phases       *prometheus.HistogramVec
+	calls        *prometheus.CounterVec
+	retries      *prometheus.CounterVec
+	failures     *prometheus.CounterVec
+	cacheHits    *prometheus.CounterVec
+	reflections  *prometheus.CounterVec
+	memoryNodes  *prometheus.GaugeVec
+	activeChats  prometheus.Gauge
+	sleepSkipped prometheus.Counter
+}
+
+func NewPrometheus() *Prometheus {
+	p := &Prometheus{
+		registry: prometheus.NewRegistry(),
+		steps: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "steps_total",
+			Help:      "Steps executed by the simulation.",
+		}),
+		stepDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "step_duration_seconds",
+			Help:      "Time it took to execute a step.",
+			Buckets:   prometheus.ExponentialBuckets(0.1, 2, 12),
+		}),
+		phases: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "persona_phase_duration_seconds",
+			Help:      "Time it took a persona to complete a phase of a step.",
+			Buckets:   prometheus.ExponentialBuckets(0.01, 2, 14),
+		}, []string{"persona", "phase"}),
+		calls: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "llm_calls_total",
+			Help:      "Calls made to language models, retries are counted separately.",
+		}, []string{"prompt"}),
+		retries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "llm_retries_total",
+			Help:      "Requests to language models that retried a call.",
+		}, []string{"prompt"}),
+		failures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "llm_failures_total",
+			Help:      "Calls to language models that failed after all retries.",
+		}, []string{"prompt"}),
+		cacheHits: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "llm_cache_hits_total",
+			Help:      "Calls answered from the prompt cache.",
+		}, []string{"prompt"}),
+		reflections: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "reflections_total",
+			Help:      "Times a persona reflected on its recent memories.",
+		}, []string{"persona"}),
+		memoryNodes: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "memory_nodes",
+			Help:      "Nodes in the associative memory of a persona.",
+		}, []string{"persona", "type"}),
+		activeChats: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "active_chats",
+			Help:      "Chats going on between personas.",
+		}),
+		sleepSkipped: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "sleep_skipped_seconds_total",
+			Help:      "Simulated time skipped while all personas were asleep.",
+		}),
+	}
+
+	p.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		p.steps, p.stepDuration, p.phases,
+		p.calls, p.retries, p.failures, p.cacheHits,
+		p.reflections, p.memoryNodes, p.activeChats, p.sleepSkipped,
+	)
+
+	return p
+}
+
+// Handler serves the metrics to Prometheus.
+func (p *Prometheus) Handler() http.Handler {
+	return promhttp.HandlerFor(p.registry, promhttp.HandlerOpts{})
+}
+
+func (p *Prometheus) StepDone(duration time.Duration) {
+	p.steps.Inc()
+	p.stepDuration.Observe(duration.Seconds())
+}
+
+func (p *Prometheus) PhaseDone(persona string, phase string, duration time.Duration) {
+	p.phases.WithLabelValues(persona, phase).Observe(duration.Seconds())
+}
+
+func (p *Prometheus) LLMCall(call accounting.Call) {
+	if call.Cached {
+		p.cacheHits.WithLabelValues(call.Prompt).Inc()
+		return
+	}
+
+	p.calls.WithLabelValues(call.Prompt).Inc()
+	p.retries.WithLabelValues(call.Prompt).Add(float64(max(call.Attempts-1, 0)))
+	if call.Failed {
+		p.failures.WithLabelValues(call.Prompt).Inc()
+	}
+}
+
+func (p *Prometheus) Reflection(persona string) {
+	p.reflections.WithLabelValues(persona).Inc()
+}
+
+func (p *Prometheus) MemoryNodes(persona string, nodeType string, count int) {
+	p.memoryNodes.WithLabelValues(persona, nodeType).Set(float64(count))
+}
+
+func (p *Prometheus) ActiveChats(count int) {
+	p.activeChats.Set(float64(count))
+}
+
+func (p *Prometheus) SleepSkipped(skipped time.Duration) {
+	p.sleepSkipped.Add(skipped.Seconds())
+}
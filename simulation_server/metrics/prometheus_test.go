@@ -0,0 +1,61 @@
+package metrics_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fvdveen/generative_agents/simulation_server/llm/accounting"
+	"github.com/fvdveen/generative_agents/simulation_server/metrics"
+)
+
+func TestPrometheus(t *testing.T) {
+	p := metrics.NewPrometheus()
+	p.StepDone(time.Second)
+	p.StepDone(2 * time.Second)
+	p.PhaseDone("Isabella Rodriguez", "perceive", time.Second)
+	p.LLMCall(accounting.Call{Prompt: "poignancy_event_v2", Attempts: 3, Failed: true})
+	p.LLMCall(accounting.Call{Prompt: "poignancy_event_v2", Attempts: 1, Cached: true})
+	p.Reflection("Isabella Rodriguez")
+	p.MemoryNodes("Isabella Rodriguez", "event", 12)
+	p.ActiveChats(1)
+	p.SleepSkipped(time.Hour)
+
+	srv := httptest.NewServer(p.Handler())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/metrics")
+	if err != nil {
+		t.Fatalf("could not scrape metrics: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("could not read metrics: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Wrong status, got: %s", resp.Status)
+	}
+
+	for _, want := range []string{
+		`generative_agents_steps_total 2`,
+		`generative_agents_step_duration_seconds_count 2`,
+		`generative_agents_step_duration_seconds_sum 3`,
+		`generative_agents_persona_phase_duration_seconds_count{persona="Isabella Rodriguez",phase="perceive"} 1`,
+		`generative_agents_llm_calls_total{prompt="poignancy_event_v2"} 1`,
+		`generative_agents_llm_retries_total{prompt="poignancy_event_v2"} 2`,
+		`generative_agents_llm_failures_total{prompt="poignancy_event_v2"} 1`,
+		`generative_agents_llm_cache_hits_total{prompt="poignancy_event_v2"} 1`,
+		`generative_agents_reflections_total{persona="Isabella Rodriguez"} 1`,
+		`generative_agents_memory_nodes{persona="Isabella Rodriguez",type="event"} 12`,
+		`generative_agents_active_chats 1`,
+		`generative_agents_sleep_skipped_seconds_total 3600`,
+	} {
+		if !strings.Contains(string(body), "\n"+want+"\n") {
+			t.Errorf("Expected the metrics to contain %s", want)
+		}
+	}
+}
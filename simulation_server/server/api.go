@@ -0,0 +1,382 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/fvdveen/generative_agents/simulation_server/memory"
+)
+
+// How many messages are buffered for a stream subscriber before it is disconnected for being too slow
+const streamBuffer = 64
+
+// API exposes a running simulation over HTTP, allowing it to be controlled and observed.
+//
+// The following endpoints are available:
+//
+//	GET  /status                   the current step, time and whether the simulation is paused
+//	POST /pause                    pause the simulation after the current step
+//	POST /resume                   resume a paused simulation
+//	POST /step?n=N                 execute N steps (default 1) and pause afterwards
+//	GET  /movements                the movements of the last executed step
+//	GET  /personas                 the names of all personas
+//	GET  /personas/{name}          the state of a persona
+//	GET  /personas/{name}/memories the associative memory of a persona
+//	POST /personas/{name}/interview ask a persona a question, without changing the persona
+//	POST /personas/{name}/whisper  plant a thought in the mind of a persona
+//	GET  /stream                   a WebSocket pushing the movements and new chats of every step
+type API struct {
+	control *Control
+	log     *slog.Logger
+
+	mu  sync.RWMutex
+	sim *Server
+	// The step the simulation executes next, kept separately so it can be read whilst a step is executing
+	next statusMessage
+	// The last step that was executed, nil if there is none
+	last *stepMessage
+
+	subsMu sync.Mutex
+	subs   map[chan []byte]struct{}
+}
+
+func NewAPI(control *Control, log *slog.Logger) *API {
+	return &API{
+		control: control,
+		log:     log,
+		subs:    map[chan []byte]struct{}{},
+	}
+}
+
+// Attach makes the API serve sim, sim is then controlled by the API's Control and reports its steps to the API.
+func (a *API) Attach(sim *Server) {
+	sim.Control = a.control
+	sim.Observer = a
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.sim = sim
+	a.next = statusMessage{Step: sim.Step, CurrentTime: sim.CurrentTime}
+}
+
+type statusMessage struct {
+	Step        int       `json:"step"`
+	CurrentTime time.Time `json:"current_time"`
+	Paused      bool      `json:"paused"`
+}
+
+type stepMessage struct {
+	Type string `json:"type"`
+	Step int    `json:"step"`
+	Movements
+}
+
+type interviewRequest struct {
+	// Who is asking the question, defaults to "Interviewer"
+	Interviewer string `json:"interviewer"`
+	Question    string `json:"question"`
+	// The earlier questions and answers of the interview, if any
+	Conversation []memory.Utterance `json:"conversation"`
+}
+
+type interviewResponse struct {
+	Answer string `json:"answer"`
+	// The interview including the question and answer, to be passed along with the next question
+	Conversation []memory.Utterance `json:"conversation"`
+}
+
+type whisperRequest struct {
+	Whisper string `json:"whisper"`
+}
+
+type chatMessage struct {
+	Type       string             `json:"type"`
+	Step       int                `json:"step"`
+	Persona    string             `json:"persona"`
+	Utterances []memory.Utterance `json:"utterances"`
+}
+
+func (a *API) Handler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /status", a.handleStatus)
+	mux.HandleFunc("POST /pause", func(w http.ResponseWriter, r *http.Request) {
+		a.control.Pause()
+		a.handleStatus(w, r)
+	})
+	mux.HandleFunc("POST /resume", func(w http.ResponseWriter, r *http.Request) {
+		a.control.Resume()
+		a.handleStatus(w, r)
+	})
+	mux.HandleFunc("POST /step", a.handleStep)
+	mux.HandleFunc("GET /movements", a.handleMovements)
+	mux.HandleFunc("GET /personas", a.handlePersonas)
+	mux.HandleFunc("GET /personas/{name}", a.handlePersona)
+	mux.HandleFunc("GET /personas/{name}/memories", a.handleMemories)
+	mux.HandleFunc("POST /personas/{name}/interview", a.handleInterview)
+	mux.HandleFunc("POST /personas/{name}/whisper", a.handleWhisper)
+	mux.HandleFunc("GET /stream", a.handleStream)
+
+	return mux
+}
+
+// StepDone publishes the movements of a step to the stream subscribers.
+func (a *API) StepDone(step int, movements Movements) {
+	msg := stepMessage{Type: "step", Step: step, Movements: movements}
+
+	a.mu.Lock()
+	prev := a.last
+	a.last = &msg
+	a.next = statusMessage{Step: step + 1, CurrentTime: movements.CurrentTime.Add(a.sim.TimeStep)}
+	a.mu.Unlock()
+
+	a.publish(msg)
+
+	// Chats are only published when they start, afterwards they are part of the movements until they end
+	published := [][]memory.Utterance{}
+	for _, name := range slices.Sorted(maps.Keys(movements.Personas)) {
+		chat := movements.Personas[name].Chat
+		if len(chat) == 0 {
+			continue
+		}
+		if prev != nil && slices.Equal(prev.Personas[name].Chat, chat) {
+			continue
+		}
+		if slices.ContainsFunc(published, func(c []memory.Utterance) bool { return slices.Equal(c, chat) }) {
+			continue
+		}
+
+		published = append(published, chat)
+		a.publish(chatMessage{Type: "chat", Step: step, Persona: name, Utterances: chat})
+	}
+}
+
+func (a *API) publish(msg any) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		a.log.Error("could not encode stream message", slog.Any("err", err))
+		return
+	}
+
+	a.subsMu.Lock()
+	defer a.subsMu.Unlock()
+	for sub := range a.subs {
+		select {
+		case sub <- data:
+		default:
+			// The subscriber cannot keep up, dropping it is better than stalling the simulation
+			delete(a.subs, sub)
+			close(sub)
+		}
+	}
+}
+
+// attached returns the attached simulation, writing an error to w if there is none.
+func (a *API) attached(w http.ResponseWriter) (*Server, bool) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if a.sim == nil {
+		http.Error(w, "no simulation is running", http.StatusServiceUnavailable)
+		return nil, false
+	}
+	return a.sim, true
+}
+
+// simulation calls fn with the attached simulation whilst no step is executing.
+func (a *API) simulation(w http.ResponseWriter, fn func(sim *Server)) {
+	sim, ok := a.attached(w)
+	if !ok {
+		return
+	}
+
+	sim.mu.RLock()
+	defer sim.mu.RUnlock()
+	fn(sim)
+}
+
+func (a *API) handleStatus(w http.ResponseWriter, r *http.Request) {
+	a.mu.RLock()
+	status := a.next
+	a.mu.RUnlock()
+
+	status.Paused = a.control.Paused()
+	writeJSON(w, status)
+}
+
+func (a *API) handleStep(w http.ResponseWriter, r *http.Request) {
+	n := 1
+	if str := r.URL.Query().Get("n"); str != "" {
+		var err error
+		if n, err = strconv.Atoi(str); err != nil || n < 1 {
+			http.Error(w, fmt.Sprintf("invalid step count %q", str), http.StatusBadRequest)
+			return
+		}
+	}
+
+	a.control.Step(n)
+	a.handleStatus(w, r)
+}
+
+func (a *API) handleMovements(w http.ResponseWriter, r *http.Request) {
+	a.mu.RLock()
+	last := a.last
+	a.mu.RUnlock()
+
+	if last == nil {
+		http.Error(w, "no step has been executed yet", http.StatusNotFound)
+		return
+	}
+
+	writeJSON(w, last)
+}
+
+func (a *API) handlePersonas(w http.ResponseWriter, r *http.Request) {
+	a.simulation(w, func(sim *Server) {
+		writeJSON(w, slices.Sorted(maps.Keys(sim.Personas)))
+	})
+}
+
+func (a *API) handlePersona(w http.ResponseWriter, r *http.Request) {
+	a.simulation(w, func(sim *Server) {
+		persona, ok := sim.Personas[r.PathValue("name")]
+		if !ok {
+			http.Error(w, fmt.Sprintf("unknown persona %q", r.PathValue("name")), http.StatusNotFound)
+			return
+		}
+
+		writeJSON(w, persona.State())
+	})
+}
+
+func (a *API) handleMemories(w http.ResponseWriter, r *http.Request) {
+	a.simulation(w, func(sim *Server) {
+		persona, ok := sim.Personas[r.PathValue("name")]
+		if !ok {
+			http.Error(w, fmt.Sprintf("unknown persona %q", r.PathValue("name")), http.StatusNotFound)
+			return
+		}
+
+		assoc, _ := persona.Memory()
+		writeJSON(w, assoc.Nodes())
+	})
+}
+
+func (a *API) handleInterview(w http.ResponseWriter, r *http.Request) {
+	var req interviewRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Question == "" {
+		http.Error(w, "expected a JSON body with a question", http.StatusBadRequest)
+		return
+	}
+	if req.Interviewer == "" {
+		req.Interviewer = "Interviewer"
+	}
+
+	sim, ok := a.attached(w)
+	if !ok {
+		return
+	}
+
+	name := r.PathValue("name")
+	conversation := append(req.Conversation, memory.Utterance{Speaker: req.Interviewer, Sentence: req.Question})
+	answer, err := sim.Interview(r.Context(), name, req.Interviewer, conversation)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	writeJSON(w, interviewResponse{
+		Answer:       answer,
+		Conversation: append(conversation, memory.Utterance{Speaker: name, Sentence: answer}),
+	})
+}
+
+func (a *API) handleWhisper(w http.ResponseWriter, r *http.Request) {
+	var req whisperRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Whisper == "" {
+		http.Error(w, "expected a JSON body with a whisper", http.StatusBadRequest)
+		return
+	}
+
+	sim, ok := a.attached(w)
+	if !ok {
+		return
+	}
+
+	if err := sim.Whisper(r.Context(), r.PathValue("name"), req.Whisper); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *API) handleStream(w http.ResponseWriter, r *http.Request) {
+	// Subscribe before accepting the connection, so clients do not miss steps executed right after connecting
+	sub := make(chan []byte, streamBuffer)
+	a.subsMu.Lock()
+	a.subs[sub] = struct{}{}
+	a.subsMu.Unlock()
+	defer func() {
+		a.subsMu.Lock()
+		defer a.subsMu.Unlock()
+		if _, ok := a.subs[sub]; ok {
+			delete(a.subs, sub)
+			close(sub)
+		}
+	}()
+
+	// The frontend and dashboards are served from other origins
+	conn, err := websocket.Accept(w, r, &websocket.AcceptOptions{InsecureSkipVerify: true})
+	if err != nil {
+		a.log.Warn("could not accept stream connection", slog.Any("err", err))
+		return
+	}
+	defer func() { _ = conn.CloseNow() }()
+
+	// We never expect messages from the client, CloseRead handles control frames and cancels ctx when the client leaves
+	ctx := conn.CloseRead(r.Context())
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case data, ok := <-sub:
+			if !ok {
+				_ = conn.Close(websocket.StatusPolicyViolation, "client too slow")
+				return
+			}
+
+			writeCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+			err := conn.Write(writeCtx, websocket.MessageText, data)
+			cancel()
+			if err != nil {
+				return
+			}
+		}
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	if errors.Is(err, ErrUnknownPersona) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
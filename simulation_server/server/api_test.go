@@ -0,0 +1,90 @@
+package server_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/fvdveen/generative_agents/simulation_server/server"
+)
+
+func TestAPIStep(t *testing.T) {
+	sim, _ := load(t)
+	startStep := sim.Step
+
+	control := server.NewControl()
+	control.Pause()
+	api := server.NewAPI(control, sim.Log)
+	api.Attach(sim)
+
+	srv := httptest.NewServer(api.Handler())
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	conn, _, err := websocket.Dial(ctx, "ws"+strings.TrimPrefix(srv.URL, "http")+"/stream", nil)
+	if err != nil {
+		t.Fatalf("could not connect to stream: %v", err)
+	}
+	defer func() { _ = conn.CloseNow() }()
+
+	done := make(chan error)
+	go func() { done <- sim.Run(ctx, 100) }()
+
+	resp, err := http.Post(srv.URL+"/step?n=2", "", nil)
+	if err != nil {
+		t.Fatalf("could not request steps: %v", err)
+	}
+	_ = resp.Body.Close()
+
+	for i := range 2 {
+		var msg struct {
+			Type string `json:"type"`
+			Step int    `json:"step"`
+		}
+		// Skip chat messages, only the steps are of interest
+		for msg.Type != "step" {
+			_, data, err := conn.Read(ctx)
+			if err != nil {
+				t.Fatalf("could not read from stream: %v", err)
+			}
+			if err := json.Unmarshal(data, &msg); err != nil {
+				t.Fatalf("could not decode stream message: %v", err)
+			}
+		}
+
+		if msg.Step != startStep+i {
+			t.Errorf("expected step %d, got %d", startStep+i, msg.Step)
+		}
+	}
+
+	resp, err = http.Get(srv.URL + "/status")
+	if err != nil {
+		t.Fatalf("could not get status: %v", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	var status struct {
+		Step   int  `json:"step"`
+		Paused bool `json:"paused"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		t.Fatalf("could not decode status: %v", err)
+	}
+	if status.Step != startStep+2 || !status.Paused {
+		t.Errorf("expected to be paused at step %d, got step %d, paused %v", startStep+2, status.Step, status.Paused)
+	}
+
+	// The simulation stays paused until it is stopped
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the simulation to be cancelled, got %v", err)
+	}
+}
@@ -0,0 +1,92 @@
+package server
+
+import (
+	"context"
+	"sync"
+)
+
+// Control pauses, resumes and single steps a running simulation.
+// A Control can outlive the simulation it controls, so it can be reused when a simulation is reloaded after a failed step.
+type Control struct {
+	mu     sync.Mutex
+	paused bool
+	// How many steps may still be executed whilst paused
+	steps int
+	// Closed whenever the control changes, waking up the simulation if it is waiting
+	changed chan struct{}
+}
+
+func NewControl() *Control {
+	return &Control{changed: make(chan struct{})}
+}
+
+// notify wakes up everyone waiting on the control, c.mu must be held.
+func (c *Control) notify() {
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+// Pause stops the simulation after the step it is currently executing.
+func (c *Control) Pause() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.paused = true
+	c.steps = 0
+	c.notify()
+}
+
+// Resume continues a paused simulation.
+func (c *Control) Resume() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.paused = false
+	c.steps = 0
+	c.notify()
+}
+
+// Step executes n more steps and pauses the simulation afterwards.
+func (c *Control) Step(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.paused {
+		c.paused = true
+		c.steps = 0
+	}
+	c.steps += n
+	c.notify()
+}
+
+// Paused reports whether the simulation is paused, it may still be executing steps requested with Step.
+func (c *Control) Paused() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.paused
+}
+
+// wait blocks until the simulation is allowed to execute a step.
+func (c *Control) wait(ctx context.Context) error {
+	for {
+		c.mu.Lock()
+		if !c.paused {
+			c.mu.Unlock()
+			return nil
+		}
+		if c.steps > 0 {
+			c.steps -= 1
+			c.mu.Unlock()
+			return nil
+		}
+		changed := c.changed
+		c.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		}
+	}
+}
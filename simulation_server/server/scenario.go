@@ -0,0 +1,309 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fvdveen/generative_agents/simulation_server/agent"
+	"github.com/fvdveen/generative_agents/simulation_server/llm/accounting"
+	"github.com/fvdveen/generative_agents/simulation_server/maze"
+	"gopkg.in/yaml.v3"
+)
+
+// The formats accepted for times in scenarios, the first one is the format used for the simulation time in meta.json
+var timeFormats = []string{"January 2, 2006, 15:04:05", "January 2, 2006, 15:04", "January 2, 2006"}
+
+// ParseTime parses a simulation time like "February 14, 2023, 18:00".
+func ParseTime(s string) (time.Time, error) {
+	for _, format := range timeFormats {
+		if t, err := time.Parse(format, s); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid time %q, expected a time like %q", s, "February 14, 2023, 18:00")
+}
+
+// The kinds of actions a scenario can take
+const (
+	// Plant Text as a thought of Persona
+	ActionThought = "thought"
+	// Have Persona remember Event as if they perceived it
+	ActionEvent = "event"
+	// Replace the current plans and/or the daily plan requirements of Persona
+	ActionPlans = "plans"
+	// Add Event to Tile
+	ActionAddTileEvent = "add_tile_event"
+	// Remove Event from Tile
+	ActionRemoveTileEvent = "remove_tile_event"
+	// Move Persona to Tile
+	ActionTeleport = "teleport"
+)
+
+// Scenario is a script of actions that are applied to the simulation at set times or steps.
+type Scenario struct {
+	Actions []Action `json:"actions" yaml:"actions"`
+}
+
+// Action is a single action of a scenario, it is applied at the start of the first step at or after At,
+// or at the start of step Step. Which fields are used depends on the Type of the action.
+type Action struct {
+	// The simulation time to apply the action at, like "February 14, 2023, 18:00"
+	At string `json:"at,omitempty" yaml:"at,omitempty"`
+	// The step to apply the action at, only used when At is not set
+	Step int `json:"step,omitempty" yaml:"step,omitempty"`
+
+	Type    string `json:"type" yaml:"type"`
+	Persona string `json:"persona,omitempty" yaml:"persona,omitempty"`
+	Text    string `json:"text,omitempty" yaml:"text,omitempty"`
+
+	CurrentPlans          *string `json:"current_plans,omitempty" yaml:"current_plans,omitempty"`
+	DailyPlanRequirements *string `json:"daily_plan_requirements,omitempty" yaml:"daily_plan_requirements,omitempty"`
+
+	Tile  *maze.TilePos  `json:"tile,omitempty" yaml:"tile,omitempty"`
+	Event *ScenarioEvent `json:"event,omitempty" yaml:"event,omitempty"`
+
+	at time.Time
+}
+
+// ScenarioEvent is an event used by an action.
+type ScenarioEvent struct {
+	Subject     string `json:"subject" yaml:"subject"`
+	Predicate   string `json:"predicate" yaml:"predicate"`
+	Object      string `json:"object" yaml:"object"`
+	Description string `json:"description" yaml:"description"`
+}
+
+func (e ScenarioEvent) event() maze.Event {
+	ev := maze.Event{Description: e.Description}
+	ev.SPO.Subject, ev.SPO.Predicate, ev.SPO.Object = e.Subject, e.Predicate, e.Object
+	return ev
+}
+
+// LoadScenario reads a scenario from a YAML or JSON file, the format is picked by the extension of the file.
+func LoadScenario(file string) (*Scenario, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("could not read scenario: %w", err)
+	}
+
+	var scenario Scenario
+	switch filepath.Ext(file) {
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(content))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&scenario)
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(content))
+		dec.KnownFields(true)
+		err = dec.Decode(&scenario)
+	default:
+		return nil, fmt.Errorf("unknown scenario format %q, expected .yaml, .yml or .json", filepath.Ext(file))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not parse scenario %s: %w", file, err)
+	}
+
+	if err := scenario.parse(); err != nil {
+		return nil, fmt.Errorf("invalid scenario %s: %w", file, err)
+	}
+
+	return &scenario, nil
+}
+
+// parse parses the times of the actions and checks whether they have the fields their type needs.
+func (sc *Scenario) parse() error {
+	var errs []error
+	for i := range sc.Actions {
+		action := &sc.Actions[i]
+		fail := func(format string, args ...any) {
+			errs = append(errs, fmt.Errorf("action %d (%s): %s", i, action.Type, fmt.Sprintf(format, args...)))
+		}
+
+		if action.At != "" {
+			at, err := ParseTime(action.At)
+			if err != nil {
+				fail("%v", err)
+			}
+			action.at = at
+		} else if action.Step < 0 {
+			fail("step must not be negative, got: %d", action.Step)
+		}
+
+		switch action.Type {
+		case ActionThought:
+			if action.Persona == "" || action.Text == "" {
+				fail("needs a persona and text")
+			}
+		case ActionEvent:
+			if action.Persona == "" || action.Event == nil {
+				fail("needs a persona and an event")
+			}
+		case ActionPlans:
+			if action.Persona == "" || (action.CurrentPlans == nil && action.DailyPlanRequirements == nil) {
+				fail("needs a persona and current_plans or daily_plan_requirements")
+			}
+		case ActionAddTileEvent, ActionRemoveTileEvent:
+			if action.Tile == nil || action.Event == nil {
+				fail("needs a tile and an event")
+			}
+		case ActionTeleport:
+			if action.Persona == "" || action.Tile == nil {
+				fail("needs a persona and a tile")
+			}
+		default:
+			fail("unknown action type %q", action.Type)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Check checks whether the actions of the scenario are valid, and the personas and tiles they refer to exist in the simulation.
+func (sc *Scenario) Check(s *Server) error {
+	if err := sc.parse(); err != nil {
+		return err
+	}
+
+	var errs []error
+	for i, action := range sc.Actions {
+		if _, ok := s.Personas[action.Persona]; action.Persona != "" && !ok {
+			errs = append(errs, fmt.Errorf("action %d (%s): %w: %s", i, action.Type, ErrUnknownPersona, action.Persona))
+		}
+		if action.Tile != nil && !s.Maze.Contains(*action.Tile) {
+			errs = append(errs, fmt.Errorf("action %d (%s): tile %v is not part of the maze", i, action.Type, *action.Tile))
+		} else if action.Type == ActionTeleport && s.Maze.GetTile(*action.Tile).Collision {
+			errs = append(errs, fmt.Errorf("action %d (%s): tile %v cannot be stood on", i, action.Type, *action.Tile))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// due reports whether the action is applied in the step that starts at time start, and has been moved forward to time curr
+// by skipping the night. Every step covers the time since the previous step, so actions are not lost when a step skips ahead.
+func (a Action) due(step int, start, curr time.Time, timeStep time.Duration) bool {
+	if a.At == "" {
+		return a.Step == step
+	}
+
+	return a.at.After(start.Add(-timeStep)) && !a.at.After(curr)
+}
+
+// past reports whether the action was applied in a step before step, which started at time start.
+func (a Action) past(step int, start time.Time, timeStep time.Duration) bool {
+	if a.At == "" {
+		return a.Step < step
+	}
+
+	return !a.at.After(start.Add(-timeStep))
+}
+
+// restoreScenario applies the tile actions of the scenario that were applied before the current step again.
+// Events on tiles are not stored with the simulation, so they are lost when it is loaded from storage.
+func (s *Server) restoreScenario() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, action := range s.Scenario.Actions {
+		if !action.past(s.Step, s.CurrentTime, s.TimeStep) {
+			continue
+		}
+
+		switch action.Type {
+		case ActionAddTileEvent:
+			s.Maze.AddEventToTile(*action.Tile, action.Event.event())
+		case ActionRemoveTileEvent:
+			s.Maze.RemoveEventFromTile(*action.Tile, action.Event.event())
+		}
+	}
+}
+
+// applyScenario applies the actions of the scenario that are due in the current step, start is the time of the step before
+// the night was skipped.
+func (s *Server) applyScenario(ctx context.Context, start time.Time) error {
+	for i, action := range s.Scenario.Actions {
+		if !action.due(s.Step, start, s.CurrentTime, s.TimeStep) {
+			continue
+		}
+
+		actionLog := s.Log.With(
+			slog.String("type", "scenario"),
+			slog.Int("step", s.Step),
+			slog.Time("sim_time", s.CurrentTime),
+			slog.Int("action", i),
+			slog.String("action_type", action.Type),
+		)
+		if action.Persona != "" {
+			actionLog = actionLog.With(slog.String("persona", action.Persona))
+		}
+
+		if err := s.applyAction(ctx, actionLog, action); err != nil {
+			return fmt.Errorf("could not apply scenario action %d (%s): %w", i, action.Type, err)
+		}
+
+		attrs := []any{}
+		if action.Text != "" {
+			attrs = append(attrs, slog.String("text", action.Text))
+		}
+		if action.Tile != nil {
+			attrs = append(attrs, slog.Any("tile", *action.Tile))
+		}
+		if action.Event != nil {
+			attrs = append(attrs, slog.Any("event", *action.Event))
+		}
+		actionLog.Info("scenario_action", attrs...)
+	}
+
+	return nil
+}
+
+func (s *Server) applyAction(ctx context.Context, actionLog *slog.Logger, action Action) error {
+	var persona *agent.Persona
+	if action.Persona != "" {
+		var ok bool
+		if persona, ok = s.Personas[action.Persona]; !ok {
+			return fmt.Errorf("%w: %s", ErrUnknownPersona, action.Persona)
+		}
+		persona.SetCtx(agent.MoveCtx{Log: actionLog})
+		ctx = accounting.WithPersona(ctx, action.Persona)
+	}
+	ctx = accounting.WithPhase(ctx, "scenario")
+	if action.Tile != nil && !s.Maze.Contains(*action.Tile) {
+		return fmt.Errorf("tile %v is not part of the maze", *action.Tile)
+	}
+
+	switch action.Type {
+	case ActionThought:
+		return persona.RememberThought(ctx, action.Text)
+	case ActionEvent:
+		return persona.RememberEvent(ctx, action.Event.event())
+	case ActionPlans:
+		if action.CurrentPlans != nil {
+			persona.SetCurrentPlans(*action.CurrentPlans)
+		}
+		if action.DailyPlanRequirements != nil {
+			persona.SetDailyPlanRequirements(*action.DailyPlanRequirements)
+		}
+	case ActionAddTileEvent:
+		s.Maze.AddEventToTile(*action.Tile, action.Event.event())
+	case ActionRemoveTileEvent:
+		s.Maze.RemoveEventFromTile(*action.Tile, action.Event.event())
+	case ActionTeleport:
+		s.Maze.RemoveSubjectEventsFromTile(persona.Position(), action.Persona)
+		persona.Teleport(*action.Tile)
+		s.PersonaPositions[action.Persona] = *action.Tile
+		s.Maze.AddEventToTile(*action.Tile, persona.GetCurrentEvent())
+	default:
+		return fmt.Errorf("unknown action type %q", action.Type)
+	}
+
+	return nil
+}
@@ -0,0 +1,87 @@
+package server_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fvdveen/generative_agents/simulation_server/maze"
+	"github.com/fvdveen/generative_agents/simulation_server/memory"
+	"github.com/fvdveen/generative_agents/simulation_server/server"
+)
+
+func TestScenario(t *testing.T) {
+	sim, storage := load(t)
+
+	target := sim.PersonaPositions["Isabella Rodriguez"]
+	file := filepath.Join(t.TempDir(), "scenario.yaml")
+	scenario := fmt.Sprintf(`actions:
+  - step: 1
+    type: thought
+    persona: Klaus Mueller
+    text: I should go to the Valentine's Day party at Hobbs Cafe
+  - step: 1
+    type: add_tile_event
+    tile: {x: %[1]d, y: %[2]d}
+    event: {subject: "the Ville:Hobbs Cafe:cafe:piano", predicate: is, object: played, description: being played}
+  - step: 2
+    type: teleport
+    persona: Klaus Mueller
+    tile: {x: %[1]d, y: %[2]d}
+  - at: "February 13, 2023, 00:01"
+    type: plans
+    persona: Maria Lopez
+    current_plans: Maria is going to the Valentine's Day party at Hobbs Cafe
+`, target.X, target.Y)
+	if err := os.WriteFile(file, []byte(scenario), 0o644); err != nil {
+		t.Fatalf("could not write scenario: %v", err)
+	}
+
+	var err error
+	if sim.Scenario, err = server.LoadScenario(file); err != nil {
+		t.Fatalf("could not load scenario: %v", err)
+	}
+	if err := sim.Run(context.Background(), 10); err != nil {
+		t.Fatalf("could not run simulation: %v", err)
+	}
+
+	associative, _ := sim.Personas["Klaus Mueller"].Memory()
+	remembered := false
+	for _, node := range associative.Nodes() {
+		remembered = remembered || node.Type == memory.NodeTypeThought && node.OriginalDescription == "I should go to the Valentine's Day party at Hobbs Cafe"
+	}
+	if !remembered {
+		t.Fatalf("Expected Klaus to remember the thought")
+	}
+
+	if _, ok := sim.Maze.GetTile(target).Events[maze.Event{SPO: memory.SPO{Subject: "the Ville:Hobbs Cafe:cafe:piano", Predicate: "is", Object: "played"}, Description: "being played"}]; !ok {
+		t.Fatalf("Expected the event to be added to tile %v", target)
+	}
+
+	if moved := storage.movements[2]["Klaus Mueller"].Tile; moved.EuclidianDistance(target) > 1.5 {
+		t.Fatalf("Expected Klaus to move from %v after teleporting, got: %v", target, moved)
+	}
+
+	if plans := sim.Personas["Maria Lopez"].CurrentPlans(); plans != "Maria is going to the Valentine's Day party at Hobbs Cafe" {
+		t.Fatalf("Wrong current plans, got: %q", plans)
+	}
+}
+
+func TestScenarioCheck(t *testing.T) {
+	sim, _ := load(t)
+
+	for _, action := range []server.Action{
+		{Step: 1, Type: server.ActionThought, Persona: "Nobody", Text: "hi"},
+		{Step: 1, Type: server.ActionTeleport, Persona: "Klaus Mueller", Tile: &maze.TilePos{X: -1, Y: 0}},
+		{Step: 1, Type: server.ActionTeleport, Persona: "Klaus Mueller"},
+		{At: "tomorrow", Type: server.ActionThought, Persona: "Klaus Mueller", Text: "hi"},
+		{Step: 1, Type: "dance"},
+	} {
+		scenario := &server.Scenario{Actions: []server.Action{action}}
+		if err := scenario.Check(sim); err == nil {
+			t.Fatalf("Expected %+v to be invalid", action)
+		}
+	}
+}
@@ -1,15 +1,24 @@
 package server
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"log"
 	"log/slog"
+	"maps"
+	"slices"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/fvdveen/generative_agents/simulation_server/agent"
+	"github.com/fvdveen/generative_agents/simulation_server/llm/accounting"
 	"github.com/fvdveen/generative_agents/simulation_server/maze"
 	"github.com/fvdveen/generative_agents/simulation_server/memory"
+	"github.com/fvdveen/generative_agents/simulation_server/metrics"
+	"github.com/fvdveen/generative_agents/simulation_server/tracing"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 type SimulationStorer interface {
@@ -18,7 +27,26 @@ type SimulationStorer interface {
 	Backup(step int) error
 }
 
+var ErrUnknownPersona = errors.New("unknown persona")
+
+// StepObserver is notified of every step the simulation executes.
+type StepObserver interface {
+	StepDone(step int, movements Movements)
+}
+
+// StepObservers notifies all of its observers of every step, in order.
+type StepObservers []StepObserver
+
+func (o StepObservers) StepDone(step int, movements Movements) {
+	for _, observer := range o {
+		observer.StepDone(step, movements)
+	}
+}
+
 type Server struct {
+	// Held whilst executing a step, the simulation may be read by other goroutines when holding it for reading
+	mu sync.RWMutex
+
 	CurrentTime time.Time
 	StartTime   time.Time
 	// How much time the simulation progresses each step
@@ -31,10 +59,24 @@ type Server struct {
 	ForkedSim        string
 	// After how many steps we make a backup of the simulation state
 	BackupInterval int
+	// How many personas are moved concurrently, values below 2 move personas one by one
+	Workers int
+	// Whether personas treat each other as obstacles, when false personas walk through each other
+	AvoidCollisions bool
 
 	Log *slog.Logger
 
 	Storage SimulationStorer
+	// Pauses and resumes the simulation when set
+	Control *Control
+	// Notified after every step when set
+	Observer StepObserver
+	// Applied at the start of every step when set
+	Scenario *Scenario
+	// Records the health of the simulation when set
+	Metrics metrics.Recorder
+	// Traces every step, the phases of the personas and their calls to models when set
+	Tracer trace.Tracer
 }
 
 func New() *Server {
@@ -42,36 +84,93 @@ func New() *Server {
 }
 
 type PersonaMovement struct {
-	Tile        maze.TilePos
-	Pronunciato string
-	Event       maze.Event
-	Chat        []memory.Utterance
+	Tile        maze.TilePos       `json:"tile"`
+	Pronunciato string             `json:"pronunciato"`
+	Event       maze.Event         `json:"event"`
+	Chat        []memory.Utterance `json:"chat"`
+	// Whether the persona had to plan a new path because its next tile was blocked by another persona
+	Replanned bool `json:"replanned"`
 }
 
 type Movements struct {
-	Personas    map[string]PersonaMovement
-	CurrentTime time.Time
+	Personas    map[string]PersonaMovement `json:"personas"`
+	CurrentTime time.Time                  `json:"current_time"`
+}
+
+// Run executes i steps, saving the simulation after every step.
+// If a step fails the in memory state of the simulation is no longer valid, and the simulation should be reloaded from
+// storage before running it again, as the storage still contains the state from before the failed step.
+func (s *Server) Run(ctx context.Context, i int) error {
+	done := 0
+	return s.run(ctx, func() bool {
+		done += 1
+		return done > i
+	})
+}
+
+// RunUntil executes steps until the simulation reaches time until, like Run.
+func (s *Server) RunUntil(ctx context.Context, until time.Time) error {
+	return s.run(ctx, func() bool {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+
+		return !s.CurrentTime.Before(until)
+	})
 }
 
-func (s *Server) Run(i int) error {
-	for range i {
+// run executes steps until stop returns true, stop is called before every step.
+func (s *Server) run(ctx context.Context, stop func() bool) error {
+	if s.Scenario != nil {
+		if err := s.Scenario.Check(s); err != nil {
+			return fmt.Errorf("invalid scenario: %w", err)
+		}
+		s.restoreScenario()
+	}
+
+	for !stop() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if s.Control != nil {
+			if err := s.Control.wait(ctx); err != nil {
+				return err
+			}
+		}
+
 		if s.Step%s.BackupInterval == 0 {
 			if err := s.Storage.Backup(s.Step); err != nil {
 				return fmt.Errorf("could not create server backup: %w", err)
 			}
 		}
 
-		s.ExecuteStep()
-		if err := s.Storage.SaveSimulation(s); err != nil {
-			log.Fatalf("could not save simulation: %v", err)
-			panic("unreachable")
+		if err := s.ExecuteStep(ctx); err != nil {
+			return fmt.Errorf("could not execute step %d: %w", s.Step, err)
+		}
+		s.mu.RLock()
+		err := s.Storage.SaveSimulation(s)
+		s.mu.RUnlock()
+		if err != nil {
+			return fmt.Errorf("could not save simulation: %w", err)
 		}
 	}
 
 	return nil
 }
 
-func (s *Server) ExecuteStep() {
+func (s *Server) ExecuteStep(ctx context.Context) (err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tracer := s.Tracer
+	if tracer == nil {
+		tracer = tracing.Nop()
+	}
+	ctx, span := tracer.Start(ctx, "step", trace.WithAttributes(
+		attribute.Int("simulation.step", s.Step),
+		attribute.String("simulation.time", s.CurrentTime.Format(time.DateTime)),
+	))
+	defer func() { tracing.End(span, err) }()
+
 	stepLog := s.Log.With(
 		slog.Int("step", s.Step),
 		slog.String("type", "step"),
@@ -79,14 +178,30 @@ func (s *Server) ExecuteStep() {
 	)
 
 	stepLog.Info("step_start", slog.String("phase", "start"))
+	started := time.Now()
 
+	start := s.CurrentTime
 	s.skipSleep(stepLog)
 
+	if s.Scenario != nil {
+		if err := s.applyScenario(ctx, start); err != nil {
+			stepLog.Error("step_fail",
+				slog.String("phase", "fail"),
+				slog.Any("err", err),
+			)
+			return err
+		}
+	}
+
 	gameObjectCleanup := map[maze.Event]maze.TilePos{}
 	movements := Movements{Personas: map[string]PersonaMovement{}, CurrentTime: s.CurrentTime}
 
+	// Personas are always handled in the same order so the maze ends up the same, regardless of how they are moved.
+	names := slices.Sorted(maps.Keys(s.Personas))
+
 	// If the persona is at their destination activate their object event
-	for _, persona := range s.Personas {
+	for _, name := range names {
+		persona := s.Personas[name]
 		if len(persona.PlannedPath()) != 0 {
 			continue
 		}
@@ -103,24 +218,33 @@ func (s *Server) ExecuteStep() {
 
 	for _, persona := range s.Personas {
 		ctx := agent.MoveCtx{
-			Log: stepLog,
+			Log:     stepLog,
+			Metrics: s.Metrics,
+			Tracer:  tracer,
 		}
 
 		persona.SetCtx(ctx)
 	}
 
-	for name, persona := range s.Personas {
-		next, pronunciato, event := persona.Move(s.Maze, s.Personas, s.PersonaPositions[name], s.CurrentTime)
+	if s.Workers < 2 {
+		err = s.movePersonas(ctx, names, movements)
+	} else {
+		err = s.movePersonasConcurrently(ctx, names, movements)
+	}
+	if err != nil {
+		stepLog.Error("step_fail",
+			slog.String("phase", "fail"),
+			slog.Any("err", err),
+		)
+		return err
+	}
 
-		movements.Personas[name] = PersonaMovement{
-			Tile:        next,
-			Pronunciato: pronunciato,
-			Event:       event,
-			Chat:        persona.GetChat(),
-		}
+	if s.AvoidCollisions {
+		s.resolveCollisions(names, movements)
 	}
 
-	for name, persona := range s.Personas {
+	for _, name := range names {
+		persona := s.Personas[name]
 		curr := persona.Position()
 		next := movements.Personas[name].Tile
 
@@ -138,17 +262,238 @@ func (s *Server) ExecuteStep() {
 	}
 
 	if err := s.Storage.SaveMovements(s.Step, movements.Personas, movements.CurrentTime); err != nil {
-		panic(fmt.Sprintf("Could not save movements: %v", err))
+		return fmt.Errorf("could not save movements: %w", err)
 	}
 
 	stepLog.Info("step_end",
 		slog.String("phase", "end"),
 	)
+	if s.Metrics != nil {
+		s.recordMetrics(names, time.Since(started))
+	}
+
+	if s.Observer != nil {
+		s.Observer.StepDone(s.Step, movements)
+	}
 
 	s.CurrentTime = s.CurrentTime.Add(s.TimeStep)
 	s.Step += 1
 
 	// TODO(Friso): Actually saving the updated state
+	return nil
+}
+
+// Interview has persona name answer the last question in conversation, without changing the simulation.
+func (s *Server) Interview(ctx context.Context, name string, interviewer string, conversation []memory.Utterance) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	persona, ok := s.Personas[name]
+	if !ok {
+		return "", fmt.Errorf("%w: %s", ErrUnknownPersona, name)
+	}
+	persona.SetCtx(agent.MoveCtx{Log: s.Log.With(slog.String("type", "interview"))})
+
+	return persona.Interview(accounting.WithPhase(accounting.WithPersona(ctx, name), "interview"), interviewer, conversation)
+}
+
+// Whisper plants a thought in the mind of persona name, the simulation is saved afterwards so the thought is not lost.
+func (s *Server) Whisper(ctx context.Context, name string, whisper string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	persona, ok := s.Personas[name]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownPersona, name)
+	}
+	persona.SetCtx(agent.MoveCtx{Log: s.Log.With(slog.String("type", "whisper"))})
+
+	if err := persona.Whisper(accounting.WithPhase(accounting.WithPersona(ctx, name), "whisper"), whisper); err != nil {
+		return err
+	}
+	if err := s.Storage.SaveSimulation(s); err != nil {
+		return fmt.Errorf("could not save simulation: %w", err)
+	}
+
+	return nil
+}
+
+// movePersonas moves the personas one by one, each persona sees the changes made by the personas before it.
+func (s *Server) movePersonas(ctx context.Context, names []string, movements Movements) error {
+	for _, name := range names {
+		persona := s.Personas[name]
+		next, pronunciato, event, err := persona.Move(ctx, s.Maze, s.Personas, s.PersonaPositions[name], s.CurrentTime)
+		if err != nil {
+			return fmt.Errorf("could not move persona %s: %w", name, err)
+		}
+
+		movements.Personas[name] = PersonaMovement{
+			Tile:        next,
+			Pronunciato: pronunciato,
+			Event:       event,
+			Chat:        persona.GetChat(),
+		}
+	}
+
+	return nil
+}
+
+// movePersonasConcurrently moves the personas in parallel, using at most s.Workers goroutines.
+// All personas see the same snapshot of the maze, and conflicting reactions are resolved in name order
+// so the outcome does not depend on how the goroutines are scheduled.
+func (s *Server) movePersonasConcurrently(ctx context.Context, names []string, movements Movements) error {
+	snapshot := s.Maze.Snapshot()
+
+	err := s.forEachPersona(ctx, names, func(ctx context.Context, name string) error {
+		if err := s.Personas[name].Prepare(ctx, snapshot, s.CurrentTime); err != nil {
+			return fmt.Errorf("could not move persona %s: %w", name, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	reactions := make([]agent.Reaction, len(names))
+	err = s.forEachPersona(ctx, names, func(ctx context.Context, name string) error {
+		reaction, err := s.Personas[name].DecideReaction(ctx, s.Personas)
+		if err != nil {
+			return fmt.Errorf("could not move persona %s: %w", name, err)
+		}
+		reactions[slices.Index(names, name)] = reaction
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	accepted := mergeReactions(reactions)
+	err = s.forEachPersona(ctx, slices.Sorted(maps.Keys(accepted)), func(ctx context.Context, name string) error {
+		if err := s.Personas[name].React(ctx, snapshot, accepted[name], s.Personas); err != nil {
+			return fmt.Errorf("could not move persona %s: %w", name, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	var mu sync.Mutex
+	return s.forEachPersona(ctx, names, func(ctx context.Context, name string) error {
+		persona := s.Personas[name]
+		next, pronunciato, event, err := persona.Finish(ctx, snapshot, s.Personas)
+		if err != nil {
+			return fmt.Errorf("could not move persona %s: %w", name, err)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		movements.Personas[name] = PersonaMovement{
+			Tile:        next,
+			Pronunciato: pronunciato,
+			Event:       event,
+			Chat:        persona.GetChat(),
+		}
+		return nil
+	})
+}
+
+// resolveCollisions makes sure no two personas move onto the same tile, and personas do not walk through each other.
+// Personas reserve the tile they move to in name order, a tile is blocked when it is reserved or when it holds a persona that
+// has not reserved a tile yet. A persona whose next tile is blocked plans a new path around the blocked tiles, or waits.
+func (s *Server) resolveCollisions(names []string, movements Movements) {
+	reserved := map[maze.TilePos]bool{}
+	for i, name := range names {
+		persona := s.Personas[name]
+		movement := movements.Personas[name]
+
+		blocked := func(pos maze.TilePos) bool {
+			if reserved[pos] {
+				return true
+			}
+			for _, other := range names[i+1:] {
+				if s.Personas[other].Position() == pos {
+					return true
+				}
+			}
+			return false
+		}
+
+		if movement.Tile != persona.Position() && blocked(movement.Tile) {
+			movement.Tile, movement.Replanned = persona.Replan(s.Maze, movement.Tile, blocked)
+			movements.Personas[name] = movement
+		}
+		reserved[movement.Tile] = true
+	}
+}
+
+// mergeReactions decides which reactions are performed, keyed by the reacting persona.
+// A persona can only be part of a single chat, when reactions conflict the persona first in name order wins.
+func mergeReactions(reactions []agent.Reaction) map[string]agent.Reaction {
+	reactions = slices.Clone(reactions)
+	slices.SortFunc(reactions, func(a, b agent.Reaction) int { return strings.Compare(a.Persona, b.Persona) })
+
+	accepted := map[string]agent.Reaction{}
+	chatting := map[string]bool{}
+	for _, r := range reactions {
+		if !r.IsChat() || chatting[r.Persona] || chatting[r.Target] {
+			continue
+		}
+
+		chatting[r.Persona], chatting[r.Target] = true, true
+		accepted[r.Persona] = r
+	}
+
+	// Personas that are pulled into a chat stop waiting
+	for _, r := range reactions {
+		if r.IsWait() && !chatting[r.Persona] {
+			accepted[r.Persona] = r
+		}
+	}
+
+	return accepted
+}
+
+// forEachPersona calls fn for every persona in names, running at most s.Workers calls at the same time.
+// When calls fail the error of the persona first in names is returned, the context passed to the other calls is then cancelled.
+func (s *Server) forEachPersona(ctx context.Context, names []string, fn func(ctx context.Context, name string) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	errs := make([]error, len(names))
+	sem := make(chan struct{}, s.Workers)
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			if err := ctx.Err(); err != nil {
+				errs[i] = err
+				return
+			}
+			if errs[i] = fn(ctx, name); errs[i] != nil {
+				cancel()
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Calls that were cancelled only fail because another call failed first, so that error is more interesting.
+	for _, err := range errs {
+		if err != nil && !errors.Is(err, context.Canceled) {
+			return err
+		}
+	}
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 func (s *Server) skipSleep(stepLog *slog.Logger) {
@@ -198,9 +543,32 @@ func (s *Server) skipSleep(stepLog *slog.Logger) {
 
 	stepLog.With(slog.String("type", "skip_sleep"), slog.Time("next_step_time", earliestWakeUpTime)).Debug("skipping sleep")
 
+	if s.Metrics != nil {
+		s.Metrics.SleepSkipped(earliestWakeUpTime.Sub(s.CurrentTime))
+	}
 	s.CurrentTime = earliestWakeUpTime
 	for _, p := range s.Personas {
 		// NOTE(Friso): Since we actually skipped sleep, we need to ensure that all time dependent state in the agents matches the expected values.
 		p.ResetChattingWithBuffer()
 	}
 }
+
+// recordMetrics records a step that took duration, along with the memories of the personas and the chats going on.
+func (s *Server) recordMetrics(names []string, duration time.Duration) {
+	s.Metrics.StepDone(duration)
+
+	chatting := 0
+	for _, name := range names {
+		persona := s.Personas[name]
+		if persona.State().ChattingWith != "" {
+			chatting += 1
+		}
+
+		assocMem, _ := persona.Memory()
+		for _, t := range []memory.NodeType{memory.NodeTypeEvent, memory.NodeTypeThought, memory.NodeTypeChat} {
+			s.Metrics.MemoryNodes(name, t.ToString(), assocMem.Count(t))
+		}
+	}
+	// Every chat is between two personas
+	s.Metrics.ActiveChats(chatting / 2)
+}
@@ -0,0 +1,216 @@
+package server_test
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"maps"
+	"testing"
+	"time"
+
+	"github.com/fvdveen/generative_agents/simulation_server/llm/fake"
+	"github.com/fvdveen/generative_agents/simulation_server/maze"
+	"github.com/fvdveen/generative_agents/simulation_server/metrics"
+	"github.com/fvdveen/generative_agents/simulation_server/server"
+	simulationloader "github.com/fvdveen/generative_agents/simulation_server/simulation_loader"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	simulationPath = "../../environment/frontend_server/storage/base_the_ville_isabella_maria_klaus"
+	mazeFolder     = "../../environment/frontend_server/static_dirs/assets"
+)
+
+// memoryStorage keeps the movements of every step in memory.
+type memoryStorage struct {
+	movements []map[string]server.PersonaMovement
+}
+
+func (m *memoryStorage) SaveMovements(step int, movements map[string]server.PersonaMovement, currTime time.Time) error {
+	m.movements = append(m.movements, maps.Clone(movements))
+	return nil
+}
+
+func (m *memoryStorage) SaveSimulation(srv *server.Server) error { return nil }
+func (m *memoryStorage) Backup(step int) error                   { return nil }
+
+// load loads the base simulation using the fake backend, storing its movements in memory.
+func load(t *testing.T) (*server.Server, *memoryStorage) {
+	t.Helper()
+
+	f := fake.New()
+	sim, err := simulationloader.LoadSimulation(simulationPath, mazeFolder, f, f, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("could not load simulation: %v", err)
+	}
+
+	storage := &memoryStorage{}
+	sim.Storage = storage
+	sim.BackupInterval = 100000
+
+	return sim, storage
+}
+
+func run(t *testing.T, workers, steps int) []map[string]server.PersonaMovement {
+	t.Helper()
+
+	sim, storage := load(t)
+	sim.Workers = workers
+
+	if err := sim.Run(context.Background(), steps); err != nil {
+		t.Fatalf("could not run simulation: %v", err)
+	}
+
+	return storage.movements
+}
+
+func TestConcurrentStepsAreDeterministic(t *testing.T) {
+	const steps = 200
+
+	first := run(t, 4, steps)
+	second := run(t, 4, steps)
+
+	for step := range steps {
+		for name, movement := range first[step] {
+			other := second[step][name]
+			if movement.Tile != other.Tile || movement.Event != other.Event {
+				t.Fatalf("step %d: %s moved to %v (%v) and %v (%v)", step, name, movement.Tile, movement.Event, other.Tile, other.Event)
+			}
+		}
+	}
+}
+
+func TestAvoidCollisions(t *testing.T) {
+	sim, storage := load(t)
+	sim.AvoidCollisions = true
+
+	// Swap the beds of Klaus and Maria, so they have to pass each other in the hallway of the dorm on their way to bed
+	klaus, maria := sim.Personas["Klaus Mueller"], sim.Personas["Maria Lopez"]
+	klausPos, mariaPos := klaus.Position(), maria.Position()
+	klaus.SetPosition(mariaPos)
+	maria.SetPosition(klausPos)
+	sim.PersonaPositions["Klaus Mueller"], sim.PersonaPositions["Maria Lopez"] = mariaPos, klausPos
+
+	prev := maps.Clone(sim.PersonaPositions)
+	if err := sim.Run(context.Background(), 50); err != nil {
+		t.Fatalf("could not run simulation: %v", err)
+	}
+
+	replanned := false
+	for step, movements := range storage.movements {
+		occupied := map[maze.TilePos]string{}
+		for name, movement := range movements {
+			if other, ok := occupied[movement.Tile]; ok {
+				t.Fatalf("step %d: %s and %s both moved to %v", step, name, other, movement.Tile)
+			}
+			occupied[movement.Tile] = name
+
+			for other, pos := range prev {
+				if other != name && pos == movement.Tile && movements[other].Tile == prev[name] {
+					t.Fatalf("step %d: %s and %s walked through each other", step, name, other)
+				}
+			}
+			replanned = replanned || movement.Replanned
+		}
+
+		for name, movement := range movements {
+			prev[name] = movement.Tile
+		}
+	}
+
+	if !replanned {
+		t.Fatalf("expected the personas to plan a way around each other")
+	}
+}
+
+func TestRunUntil(t *testing.T) {
+	sim, _ := load(t)
+
+	until := sim.CurrentTime.Add(time.Hour)
+	if err := sim.RunUntil(context.Background(), until); err != nil {
+		t.Fatalf("could not run simulation: %v", err)
+	}
+	// Sleeping personas make the simulation skip ahead, so it may end up past until
+	if sim.CurrentTime.Before(until) {
+		t.Fatalf("Stopped too early, got: %v, want: %v", sim.CurrentTime, until)
+	}
+
+	step := sim.Step
+	if err := sim.RunUntil(context.Background(), until); err != nil {
+		t.Fatalf("could not run simulation: %v", err)
+	}
+	if sim.Step != step {
+		t.Fatalf("Expected no steps once the simulation reached until, got: %d steps", sim.Step-step)
+	}
+}
+
+func TestMetrics(t *testing.T) {
+	sim, _ := load(t)
+	recorder := metrics.NewMemory()
+	sim.Metrics = recorder
+
+	const steps = 50
+	if err := sim.Run(context.Background(), steps); err != nil {
+		t.Fatalf("could not run simulation: %v", err)
+	}
+
+	totals := recorder.Totals()
+	if totals.Steps != steps {
+		t.Errorf("Wrong amount of steps, got: %d, want: %d", totals.Steps, steps)
+	}
+	for name := range sim.Personas {
+		for _, phase := range []string{"perceive", "retrieve", "plan_activity", "decide_reaction", "react", "reflect", "execute"} {
+			if _, ok := totals.Phases[name][phase]; !ok {
+				t.Errorf("Expected phase %s of %s to be recorded", phase, name)
+			}
+		}
+		if totals.MemoryNodes[name]["event"] == 0 {
+			t.Errorf("Expected the events in the memory of %s to be recorded, got: %v", name, totals.MemoryNodes[name])
+		}
+	}
+	// The simulation starts at night, with every persona asleep
+	if totals.SleepSkipped == 0 {
+		t.Errorf("Expected the skipped sleep to be recorded")
+	}
+}
+
+func TestTracing(t *testing.T) {
+	sim, _ := load(t)
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	sim.Tracer = provider.Tracer("test")
+
+	const steps = 3
+	if err := sim.Run(context.Background(), steps); err != nil {
+		t.Fatalf("could not run simulation: %v", err)
+	}
+
+	spans := exporter.GetSpans()
+	stepSpans := map[trace.SpanID]bool{}
+	for _, span := range spans {
+		if span.Name == "step" {
+			stepSpans[span.SpanContext.SpanID()] = true
+		}
+	}
+	if len(stepSpans) != steps {
+		t.Fatalf("Wrong amount of step spans, got: %d, want: %d", len(stepSpans), steps)
+	}
+
+	phases := map[string]int{}
+	for _, span := range spans {
+		if span.Name == "step" {
+			continue
+		}
+		if !stepSpans[span.Parent.SpanID()] {
+			t.Errorf("Expected span %s to be a child of a step", span.Name)
+		}
+		phases[span.Name] += 1
+	}
+	for _, phase := range []string{"perceive", "retrieve", "plan_activity", "decide_reaction", "react", "reflect", "execute"} {
+		if want := steps * len(sim.Personas); phases[phase] != want {
+			t.Errorf("Wrong amount of %s spans, got: %d, want: %d", phase, phases[phase], want)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package simulationloader_test
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fvdveen/generative_agents/simulation_server/llm"
+	"github.com/fvdveen/generative_agents/simulation_server/llm/fake"
+	"github.com/fvdveen/generative_agents/simulation_server/memory"
+	simulationloader "github.com/fvdveen/generative_agents/simulation_server/simulation_loader"
+)
+
+// planningCognition records the statements personas plan their day with.
+type planningCognition struct {
+	*fake.Client
+	statements map[string][]string
+}
+
+func (c *planningCognition) GeneratePlanningNote(ctx context.Context, p llm.Persona, statements []string) (string, error) {
+	c.statements[p.Name()] = statements
+	return c.Client.GeneratePlanningNote(ctx, p, statements)
+}
+
+func TestDiary(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	f := fake.New()
+	cognition := &planningCognition{Client: f, statements: map[string][]string{}}
+
+	folder := t.TempDir()
+	copySimulation(t, path.Join(folder, "test"))
+	// Going to bed early keeps the day short
+	setPersonaState(t, path.Join(folder, "test"), "lifestyle", `"wakes up around 1am and goes to bed around 4am"`)
+	storage := &simulationloader.FileStorage{SimulationsFolder: folder, BackupFolder: t.TempDir(), Simulation: "test", Maze: "the_ville"}
+
+	sim, err := simulationloader.LoadSimulation(path.Join(folder, "test"), mazeFolder, f, cognition, log)
+	if err != nil {
+		t.Fatalf("could not load simulation: %v", err)
+	}
+	sim.Storage, sim.BackupInterval = storage, 100000
+	if err := sim.RunUntil(context.Background(), time.Date(2023, time.February, 14, 1, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("could not run simulation: %v", err)
+	}
+
+	loaded, err := simulationloader.LoadSimulation(path.Join(folder, "test"), mazeFolder, f, f, log)
+	if err != nil {
+		t.Fatalf("could not load simulation: %v", err)
+	}
+	day := time.Date(2023, time.February, 13, 0, 0, 0, 0, time.UTC)
+	for name, persona := range loaded.Personas {
+		diary := persona.State().Diary
+		if len(diary) != 1 || !diary[0].Day.Equal(day) || len(diary[0].Events) == 0 || diary[0].Feelings == "" || diary[0].Wants == "" {
+			t.Errorf("Expected %s to have written about %v in their diary, got: %+v", name, day, diary)
+			continue
+		}
+
+		content, err := os.ReadFile(path.Join(folder, "test", "personas", name, "diary", "2023-02-13.md"))
+		if err != nil {
+			t.Errorf("Expected the diary of %s to be exported: %v", name, err)
+		} else if !strings.Contains(string(content), diary[0].Events[0]) || !strings.Contains(string(content), diary[0].Wants) {
+			t.Errorf("Wrong diary export of %s, got:\n%s", name, content)
+		}
+
+		// The diary thoughts build on the other thoughts of the day
+		assoc, _ := persona.Memory()
+		deepest, diaryDepth := 0, 0
+		for _, node := range assoc.Nodes() {
+			if node.Type != memory.NodeTypeThought {
+				continue
+			}
+			if slices.Contains(node.Keywords, "diary") {
+				diaryDepth = max(diaryDepth, node.Depth)
+			} else {
+				deepest = max(deepest, node.Depth)
+			}
+		}
+		if diaryDepth <= deepest {
+			t.Errorf("Expected the diary thoughts of %s to be deeper than their other thoughts, got: %d, want more than: %d", name, diaryDepth, deepest)
+		}
+
+		// The next morning the persona plans with what it wrote the night before
+		if !slices.ContainsFunc(cognition.statements[name], func(s string) bool { return strings.Contains(s, diary[0].Feelings) }) {
+			t.Errorf("Expected %s to plan with their diary, got statements: %v", name, cognition.statements[name])
+		}
+	}
+
+	// The database keeps every diary entry it saved, saving the simulation to files only writes the latest entry
+	setPersonaState(t, path.Join(folder, "test"), "diary", `[
+		{"day": "February 11, 2023", "events": ["Cooked dinner."], "feelings": "Tired.", "wants": "Rest."},
+		{"day": "February 12, 2023", "events": ["Went for a walk."], "feelings": "Calm.", "wants": "More walks."}
+	]`)
+	db, err := simulationloader.OpenSQLiteStorage(path.Join(t.TempDir(), "test.sqlite"), t.TempDir(), "test")
+	if err != nil {
+		t.Fatalf("could not open database: %v", err)
+	}
+	defer db.Close()
+	if err := db.Import(path.Join(folder, "test"), mazeFolder, log); err != nil {
+		t.Fatalf("could not import simulation: %v", err)
+	}
+	exported := t.TempDir()
+	if err := db.Export(&simulationloader.FileStorage{SimulationsFolder: exported, Simulation: "exported"}, mazeFolder, log); err != nil {
+		t.Fatalf("could not export simulation: %v", err)
+	}
+	for name := range loaded.Personas {
+		content, err := os.ReadFile(path.Join(exported, "exported", "personas", name, "diary", "2023-02-11.md"))
+		if err != nil || !strings.Contains(string(content), "Cooked dinner.") {
+			t.Errorf("Wrong exported diary of %s, got: %q, %v", name, content, err)
+		}
+	}
+}
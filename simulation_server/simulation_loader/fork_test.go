@@ -0,0 +1,113 @@
+package simulationloader_test
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/fvdveen/generative_agents/simulation_server/llm/fake"
+	simulationloader "github.com/fvdveen/generative_agents/simulation_server/simulation_loader"
+)
+
+func TestFileStorageForkAndRewind(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	f := fake.New()
+
+	folder := t.TempDir()
+	copySimulation(t, path.Join(folder, "test"))
+	storage := &simulationloader.FileStorage{SimulationsFolder: folder, BackupFolder: t.TempDir(), Simulation: "test", Maze: "the_ville"}
+
+	sim, err := simulationloader.LoadSimulation(path.Join(folder, "test"), mazeFolder, f, f, log)
+	if err != nil {
+		t.Fatalf("could not load simulation: %v", err)
+	}
+	sim.Storage, sim.BackupInterval = storage, 20
+	if err := sim.Run(context.Background(), 40); err != nil {
+		t.Fatalf("could not run simulation: %v", err)
+	}
+
+	if err := storage.Fork(20, "fork"); err != nil {
+		t.Fatalf("could not fork simulation: %v", err)
+	}
+	fork, err := simulationloader.LoadSimulation(path.Join(folder, "fork"), mazeFolder, f, f, log)
+	if err != nil {
+		t.Fatalf("could not load fork: %v", err)
+	}
+	if fork.Step != 20 || fork.ForkedSim != "test" {
+		t.Fatalf("Wrong fork, got step: %d, forked from: %s", fork.Step, fork.ForkedSim)
+	}
+	if _, err := os.Stat(path.Join(folder, "fork", "movement", "19.json")); err != nil {
+		t.Fatalf("Expected the movements before the fork to be copied: %v", err)
+	}
+	if err := storage.Fork(20, "fork"); err == nil {
+		t.Fatalf("Expected an error when forking to an existing simulation")
+	}
+
+	if err := storage.Rewind(20); err != nil {
+		t.Fatalf("could not rewind simulation: %v", err)
+	}
+	rewound, err := simulationloader.LoadSimulation(path.Join(folder, "test"), mazeFolder, f, f, log)
+	if err != nil {
+		t.Fatalf("could not load rewound simulation: %v", err)
+	}
+	if rewound.Step != 20 {
+		t.Fatalf("Wrong step after rewinding, got: %d, want: %d", rewound.Step, 20)
+	}
+	if _, err := os.Stat(path.Join(folder, "test", "movement", "20.json")); !os.IsNotExist(err) {
+		t.Fatalf("Expected the movements after the rewound step to be removed")
+	}
+}
+
+func TestSQLiteStorageForkAndRewind(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	f := fake.New()
+
+	folder := t.TempDir()
+	db, err := simulationloader.OpenSQLiteStorage(path.Join(folder, "test.sqlite"), t.TempDir(), "test")
+	if err != nil {
+		t.Fatalf("could not open database: %v", err)
+	}
+	defer db.Close()
+	if err := db.Import(simulationPath, mazeFolder, log); err != nil {
+		t.Fatalf("could not import simulation: %v", err)
+	}
+
+	sim, err := db.Load(mazeFolder, f, f, log)
+	if err != nil {
+		t.Fatalf("could not load simulation: %v", err)
+	}
+	sim.Storage, sim.BackupInterval = db, 20
+	if err := sim.Run(context.Background(), 40); err != nil {
+		t.Fatalf("could not run simulation: %v", err)
+	}
+
+	if err := db.Fork(20, path.Join(folder, "fork.sqlite")); err != nil {
+		t.Fatalf("could not fork simulation: %v", err)
+	}
+	forkDb, err := simulationloader.OpenSQLiteStorage(path.Join(folder, "fork.sqlite"), t.TempDir(), "fork")
+	if err != nil {
+		t.Fatalf("could not open fork: %v", err)
+	}
+	defer forkDb.Close()
+	fork, err := forkDb.Load(mazeFolder, f, f, log)
+	if err != nil {
+		t.Fatalf("could not load fork: %v", err)
+	}
+	if fork.Step != 20 || fork.ForkedSim != "test" {
+		t.Fatalf("Wrong fork, got step: %d, forked from: %s", fork.Step, fork.ForkedSim)
+	}
+
+	if err := db.Rewind(20); err != nil {
+		t.Fatalf("could not rewind simulation: %v", err)
+	}
+	rewound, err := db.Load(mazeFolder, f, f, log)
+	if err != nil {
+		t.Fatalf("could not load rewound simulation: %v", err)
+	}
+	if rewound.Step != 20 {
+		t.Fatalf("Wrong step after rewinding, got: %d, want: %d", rewound.Step, 20)
+	}
+}
@@ -86,7 +86,7 @@ func LoadMaze(mazePath string, mazeName string) (*maze.Maze, error) {
 		obs[row[0]] = row[len(row)-1]
 	}
 
-	filePath = path.Join(blocksFolder, "game_object_blocks.csv")
+	filePath = path.Join(blocksFolder, "spawning_location_blocks.csv")
 	spawningLocations, err := readCSVFile(filePath)
 	if err != nil {
 		return nil, fmt.Errorf("could not read csv file %s: %w", filePath, err)
@@ -0,0 +1,32 @@
+package simulationloader_test
+
+import (
+	"path"
+	"testing"
+
+	"github.com/fvdveen/generative_agents/simulation_server/memory"
+	simulationloader "github.com/fvdveen/generative_agents/simulation_server/simulation_loader"
+)
+
+func TestLoadMazeSpawningLocations(t *testing.T) {
+	m, err := simulationloader.LoadMaze(path.Join(mazeFolder, "the_ville"), "the_ville")
+	if err != nil {
+		t.Fatalf("could not load maze: %v", err)
+	}
+
+	room := memory.ParsePath("the Ville:Isabella Rodriguez's apartment:main room")
+	tiles := m.SpawningTiles(room)
+	if len(tiles) != 2 {
+		t.Fatalf("Wrong amount of spawning locations in %s, got: %d, want: %d", room.ToString(), len(tiles), 2)
+	}
+	for _, pos := range tiles {
+		if got := m.GetTile(pos).SpawningLocation; got != "sp-A" && got != "sp-B" {
+			t.Errorf("Wrong spawning location at %v, got: %q", pos, got)
+		}
+	}
+
+	// Every one of the 40 spawning locations of the_ville is a tile
+	if got := len(m.SpawningTiles(memory.ParsePath("the Ville"))); got != 40 {
+		t.Errorf("Wrong amount of spawning locations, got: %d, want: %d", got, 40)
+	}
+}
@@ -37,6 +37,11 @@ func LoadSpatialMemory(memFile string) (*memory.Spatial, error) {
 		return nil, err
 	}
 
+	return newSpatialMemory(locs), nil
+}
+
+// newSpatialMemory creates the spatial memory of a persona from the world -> sector -> arena -> objects tree it is saved as.
+func newSpatialMemory(locs map[string]map[string]map[string][]string) *memory.Spatial {
 	mem := memory.NewSpatial()
 	for w, sectors := range locs {
 		world := memory.NewPath(memory.PathWithWorld(w))
@@ -58,7 +63,7 @@ func LoadSpatialMemory(memFile string) (*memory.Spatial, error) {
 		}
 	}
 
-	return mem, nil
+	return mem
 }
 
 func extractEvidence(filling interface{}) ([]memory.NodeId, error) {
@@ -176,8 +181,28 @@ func LoadAssociativeMemory(folder string) (*memory.Associative, error) {
 		return nil, fmt.Errorf("could not unmarshal memory nodes json: %w", err)
 	}
 
-	store := memory.NewAssociative(embeddings, kws.Events, kws.Thoughts)
+	return newAssociativeMemory(embeddings, kws, memories, loadIndex(folder))
+}
+
+// newAssociativeMemory creates an associative memory from its saved parts. The index is only used if it matches the
+// memory nodes, otherwise it is rebuilt whilst the nodes are added, a nil index is always rebuilt.
+func newAssociativeMemory(embeddings map[string][]float64, kws KwStength, memories map[string]MemoryNode, index *memory.Index) (*memory.Associative, error) {
+	opts := []memory.AssociativeOpt{}
+	if index != nil && indexMatches(index, memories, embeddings) {
+		opts = append(opts, memory.WithIndex(index))
+	}
+
+	store := memory.NewAssociative(embeddings, kws.Events, kws.Thoughts, opts...)
 	for _, mem := range memoryNodeIterator(memories) {
+		if mem.Forgotten {
+			node, err := forgottenNode(mem)
+			if err != nil {
+				return nil, err
+			}
+			store.AddForgotten(node)
+			continue
+		}
+
 		switch mem.Type {
 		case "event":
 			evidence, err := extractEvidence(mem.Filling)
@@ -269,7 +294,94 @@ func LoadAssociativeMemory(folder string) (*memory.Associative, error) {
 		default:
 			panic(fmt.Sprintf("unknown memory type: %s", mem.Type))
 		}
+
+		store.UpdateNode(memory.NodeId(len(store.Nodes())), func(c *memory.ConceptNode) {
+			c.Merged = mem.Merged
+			if mem.LastAccessed != nil {
+				c.LastAccessed = time.Time(*mem.LastAccessed)
+			}
+		})
 	}
 
 	return store, nil
 }
+
+// forgottenNode converts a forgotten memory node, which is kept only so the ids of the other nodes stay the same.
+func forgottenNode(mem MemoryNode) (memory.ConceptNode, error) {
+	node := memory.ConceptNode{
+		NodeCount:           mem.NodeCount,
+		TypeCount:           mem.TypeCount,
+		Depth:               mem.Depth,
+		Created:             time.Time(mem.Created),
+		LastAccessed:        time.Time(mem.Created),
+		Subject:             mem.Subject,
+		Predicate:           mem.Predicate,
+		Object:              mem.Object,
+		Description:         mem.Description,
+		OriginalDescription: mem.OriginalDescription,
+		EmbeddingKey:        mem.EmbeddingKey,
+		Importance:          mem.Poignancy,
+		Valence:             mem.Valence,
+		Keywords:            mem.Keywords,
+		Evidence:            []memory.NodeId{},
+		Chat:                []memory.Utterance{},
+		Merged:              mem.Merged,
+	}
+	if mem.Expiration != nil {
+		exp := time.Time(*mem.Expiration)
+		node.Expiration = &exp
+	}
+	if mem.LastAccessed != nil {
+		node.LastAccessed = time.Time(*mem.LastAccessed)
+	}
+
+	var err error
+	switch mem.Type {
+	case "event":
+		node.Type = memory.NodeTypeEvent
+		node.Evidence, err = extractEvidence(mem.Filling)
+	case "thought":
+		node.Type = memory.NodeTypeThought
+		node.Evidence, err = extractEvidence(mem.Filling)
+	case "chat":
+		node.Type = memory.NodeTypeChat
+		if mem.Filling != nil {
+			node.Chat, err = extractChat(mem.Filling)
+		}
+	default:
+		return node, fmt.Errorf("unknown memory type: %s", mem.Type)
+	}
+
+	return node, err
+}
+
+// loadIndex loads the index saved alongside the embeddings, it returns nil if there is no valid index.
+func loadIndex(folder string) *memory.Index {
+	content, err := os.ReadFile(path.Join(folder, "embeddings_index.json"))
+	if err != nil {
+		return nil
+	}
+
+	index := memory.NewIndex()
+	if err := json.Unmarshal(content, index); err != nil {
+		return nil
+	}
+
+	return index
+}
+
+// indexMatches reports whether index contains exactly the memory nodes that have an embedding and have not been forgotten.
+func indexMatches(index *memory.Index, memories map[string]MemoryNode, embeddings map[string][]float64) bool {
+	indexed := 0
+	for i, mem := range memoryNodeIterator(memories) {
+		if mem.Forgotten || len(embeddings[mem.EmbeddingKey]) == 0 {
+			continue
+		}
+		if !index.Contains(memory.NodeId(i)) {
+			return false
+		}
+		indexed += 1
+	}
+
+	return indexed == index.Len()
+}
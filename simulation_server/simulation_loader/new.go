@@ -0,0 +1,279 @@
+package simulationloader
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fvdveen/generative_agents/simulation_server/agent"
+	"github.com/fvdveen/generative_agents/simulation_server/llm"
+	"github.com/fvdveen/generative_agents/simulation_server/llm/accounting"
+	"github.com/fvdveen/generative_agents/simulation_server/maze"
+	"github.com/fvdveen/generative_agents/simulation_server/memory"
+	"github.com/fvdveen/generative_agents/simulation_server/server"
+	"gopkg.in/yaml.v3"
+)
+
+// SimulationSpec describes a new simulation.
+type SimulationSpec struct {
+	// The date the simulation starts on, like "February 13, 2023"
+	StartDate string `json:"start_date" yaml:"start_date"`
+	// How many seconds pass every step, defaults to 10
+	SecondsPerStep int           `json:"sec_per_step,omitempty" yaml:"sec_per_step,omitempty"`
+	Personas       []PersonaSpec `json:"personas" yaml:"personas"`
+}
+
+// PersonaSpec describes a persona of a new simulation.
+type PersonaSpec struct {
+	Name      string `json:"name" yaml:"name"`
+	Age       int    `json:"age" yaml:"age"`
+	Innate    string `json:"innate" yaml:"innate"`
+	Learned   string `json:"learned" yaml:"learned"`
+	Currently string `json:"currently,omitempty" yaml:"currently,omitempty"`
+	Lifestyle string `json:"lifestyle" yaml:"lifestyle"`
+	// The arena the persona lives in, like "the Ville:Isabella Rodriguez's apartment:main room", the persona is spawned there
+	LivingArea            string `json:"living_area" yaml:"living_area"`
+	DailyPlanRequirements string `json:"daily_plan_req,omitempty" yaml:"daily_plan_req,omitempty"`
+	// The sectors or arenas the persona knows about besides their living area, the persona knows the entire world when empty
+	KnownPlaces []string `json:"known_places,omitempty" yaml:"known_places,omitempty"`
+	// What the persona remembers at the start of the simulation, every memory is a single sentence
+	Memories []string `json:"memories,omitempty" yaml:"memories,omitempty"`
+}
+
+// LoadSimulationSpec reads a simulation spec from a YAML or JSON file, the format is picked by the extension of the file.
+func LoadSimulationSpec(file string) (SimulationSpec, error) {
+	var spec SimulationSpec
+
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return spec, fmt.Errorf("could not read simulation spec: %w", err)
+	}
+
+	switch filepath.Ext(file) {
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(content))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&spec)
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(content))
+		dec.KnownFields(true)
+		err = dec.Decode(&spec)
+	default:
+		return spec, fmt.Errorf("unknown simulation spec format %q, expected .yaml, .yml or .json", filepath.Ext(file))
+	}
+	if err != nil {
+		return spec, fmt.Errorf("could not parse simulation spec %s: %w", file, err)
+	}
+
+	return spec, nil
+}
+
+// validate checks whether the spec describes a simulation that can be created in maze m.
+func (spec SimulationSpec) validate(m *maze.Maze) error {
+	var errs []error
+	if _, err := time.Parse(StartDateFormat, spec.StartDate); err != nil {
+		errs = append(errs, fmt.Errorf("invalid start_date %q, expected a date like %q", spec.StartDate, "February 13, 2023"))
+	}
+	if spec.SecondsPerStep < 0 {
+		errs = append(errs, fmt.Errorf("sec_per_step must not be negative, got: %d", spec.SecondsPerStep))
+	}
+	if len(spec.Personas) == 0 {
+		errs = append(errs, errors.New("no personas"))
+	}
+
+	names := map[string]bool{}
+	for i, p := range spec.Personas {
+		fail := func(format string, args ...any) {
+			errs = append(errs, fmt.Errorf("persona %d (%s): %s", i, p.Name, fmt.Sprintf(format, args...)))
+		}
+
+		if strings.TrimSpace(p.Name) == "" || filepath.Base(p.Name) != p.Name {
+			fail("invalid name")
+		} else if names[p.Name] {
+			fail("duplicate name")
+		}
+		names[p.Name] = true
+
+		if p.Age <= 0 {
+			fail("age must be positive, got: %d", p.Age)
+		}
+		if p.Innate == "" || p.Learned == "" || p.Lifestyle == "" {
+			fail("innate, learned and lifestyle must be set")
+		}
+
+		livingArea := memory.ParsePath(p.LivingArea)
+		if livingArea.Level() != memory.PathLevelArena || !m.Exists(livingArea) {
+			fail("living_area %q is not an arena of maze %s", p.LivingArea, m.Folder())
+		}
+		for _, place := range p.KnownPlaces {
+			if !m.Exists(memory.ParsePath(place)) {
+				fail("known place %q is not part of maze %s", place, m.Folder())
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Create creates a new simulation from spec in maze fs.Maze, the simulation must not exist yet.
+// The starting memories of the personas are remembered as thoughts, scored and embedded by cognition and embedder.
+func (fs *FileStorage) Create(ctx context.Context, spec SimulationSpec, mazeFolder string, embedder llm.Embedder, cognition llm.Cognition, logger *slog.Logger) error {
+	if filepath.Base(fs.Simulation) != fs.Simulation {
+		return fmt.Errorf("invalid simulation name %q", fs.Simulation)
+	}
+	if _, err := os.Stat(fs.simulationFolder()); err == nil {
+		return fmt.Errorf("simulation %s already exists", fs.Simulation)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("could not check for simulation %s: %w", fs.Simulation, err)
+	}
+
+	m, err := LoadMaze(path.Join(mazeFolder, fs.Maze), fs.Maze)
+	if err != nil {
+		return fmt.Errorf("could not load maze: %w", err)
+	}
+	if err := spec.validate(m); err != nil {
+		return fmt.Errorf("invalid simulation spec: %w", err)
+	}
+
+	if err := fs.create(ctx, spec, m, embedder, cognition, logger); err != nil {
+		_ = os.RemoveAll(fs.simulationFolder())
+		return err
+	}
+
+	return nil
+}
+
+func (fs *FileStorage) create(ctx context.Context, spec SimulationSpec, m *maze.Maze, embedder llm.Embedder, cognition llm.Cognition, logger *slog.Logger) error {
+	start, _ := time.Parse(StartDateFormat, spec.StartDate)
+	secondsPerStep := spec.SecondsPerStep
+	if secondsPerStep == 0 {
+		secondsPerStep = 10
+	}
+
+	srv := server.New()
+	srv.CurrentTime = start
+	srv.StartTime = start
+	srv.TimeStep = time.Duration(secondsPerStep) * time.Second
+	srv.Maze = m
+	srv.Personas = map[string]*agent.Persona{}
+	srv.PersonaPositions = map[string]maze.TilePos{}
+	srv.Log = logger
+
+	env := Environment{Personas: map[string]EnvironmentPersona{}}
+	for _, spec := range spec.Personas {
+		pos, err := spawnTile(m, memory.ParsePath(spec.LivingArea), srv.PersonaPositions)
+		if err != nil {
+			return fmt.Errorf("could not spawn persona %s: %w", spec.Name, err)
+		}
+
+		p, err := newPersona(ctx, spec, m, pos, start, embedder, cognition, logger)
+		if err != nil {
+			return fmt.Errorf("could not create persona %s: %w", spec.Name, err)
+		}
+
+		srv.Personas[spec.Name] = p
+		srv.PersonaPositions[spec.Name] = pos
+		env.Personas[spec.Name] = EnvironmentPersona{Maze: fs.Maze, X: pos.X, Y: pos.Y}
+	}
+
+	if err := writeJson(path.Join(fs.environmentFolder(), "0.json"), env); err != nil {
+		return fmt.Errorf("could not save environment: %w", err)
+	}
+	if err := fs.SaveSimulation(srv); err != nil {
+		return fmt.Errorf("could not save simulation: %w", err)
+	}
+
+	return nil
+}
+
+// spawnTile picks the first spawning location in the living area of a persona that is not taken by another persona.
+func spawnTile(m *maze.Maze, livingArea memory.Path, taken map[string]maze.TilePos) (maze.TilePos, error) {
+	// Fall back to the rest of the sector when the living area has no free spawning location
+	for _, area := range []memory.Path{livingArea, livingArea.AtLevel(memory.PathLevelSector)} {
+		for _, tile := range m.SpawningTiles(area) {
+			if !slices.Contains(slices.Collect(maps.Values(taken)), tile) {
+				return tile, nil
+			}
+		}
+	}
+
+	return maze.TilePos{}, fmt.Errorf("no free spawning location in %s", livingArea.ToString())
+}
+
+// newPersona creates the persona described by spec at position pos, remembering its starting memories at time start.
+func newPersona(ctx context.Context, spec PersonaSpec, m *maze.Maze, pos maze.TilePos, start time.Time, embedder llm.Embedder, cognition llm.Cognition, logger *slog.Logger) (*agent.Persona, error) {
+	livingArea := memory.ParsePath(spec.LivingArea)
+
+	spatial := memory.NewSpatial()
+	places := []memory.Path{livingArea}
+	if len(spec.KnownPlaces) == 0 {
+		places = append(places, livingArea.AtLevel(memory.PathLevelWorld))
+	}
+	for _, place := range spec.KnownPlaces {
+		places = append(places, memory.ParsePath(place))
+	}
+	for _, place := range places {
+		for _, location := range m.Locations(place) {
+			spatial.Register(location)
+		}
+	}
+
+	firstName, lastName, _ := strings.Cut(spec.Name, " ")
+	// The defaults are the same as those of the personas of the original simulations
+	state := PersonaState{
+		VisionR:                8,
+		AttBandwidth:           8,
+		Retention:              8,
+		CurrTime:               CurrentTime(start),
+		DailyPlanReq:           spec.DailyPlanRequirements,
+		Name:                   spec.Name,
+		FirstName:              firstName,
+		LastName:               lastName,
+		Age:                    spec.Age,
+		Innate:                 spec.Innate,
+		Learned:                spec.Learned,
+		Currently:              spec.Currently,
+		Lifestyle:              spec.Lifestyle,
+		LivingArea:             spec.LivingArea,
+		ConceptForget:          100,
+		DailyReflectionTime:    180,
+		DailyReflectionSize:    5,
+		OverlapReflectTh:       4,
+		KwStrgEventReflectTh:   10,
+		KwStrgThoughtReflectTh: 9,
+		RecencyW:               1,
+		RelevanceW:             1,
+		ImportanceW:            1,
+		RecencyDecay:           0.995,
+		ImportanceTriggerMax:   150,
+		ImportanceTriggerCurr:  150,
+		ThoughtCount:           5,
+		ActEvent:               SPO{Subject: spec.Name},
+		ChattingWithBuffer:     map[string]int{},
+	}
+
+	assoc := memory.NewAssociative(map[string][]float64{}, map[string]int{}, map[string]int{})
+	p := agent.New(spec.Name, assoc, spatial, *newState(state, pos), embedder, cognition)
+	p.SetCtx(agent.MoveCtx{Log: logger.With(slog.String("type", "new"))})
+	ctx = accounting.WithPhase(accounting.WithPersona(ctx, spec.Name), "new")
+	for _, sentence := range spec.Memories {
+		if err := p.RememberThought(ctx, sentence); err != nil {
+			return nil, err
+		}
+	}
+
+	// The memories are created at the start of the simulation, but the persona itself has to start without
+	// a time so it plans its first day
+	state.CurrTime = CurrentTime{}
+	return agent.New(spec.Name, assoc, spatial, *newState(state, pos), embedder, cognition), nil
+}
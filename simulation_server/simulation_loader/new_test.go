@@ -0,0 +1,133 @@
+package simulationloader_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/fvdveen/generative_agents/simulation_server/llm/fake"
+	"github.com/fvdveen/generative_agents/simulation_server/memory"
+	simulationloader "github.com/fvdveen/generative_agents/simulation_server/simulation_loader"
+)
+
+func TestFileStorageCreate(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	f := fake.New()
+
+	spec := simulationloader.SimulationSpec{
+		StartDate: "February 13, 2023",
+		Personas: []simulationloader.PersonaSpec{
+			{
+				Name:       "Isabella Rodriguez",
+				Age:        34,
+				Innate:     "friendly, outgoing, hospitable",
+				Learned:    "Isabella Rodriguez is a cafe owner of Hobbs Cafe who loves to make people feel welcome.",
+				Lifestyle:  "Isabella Rodriguez goes to bed around 11pm, awakes up around 6am.",
+				LivingArea: "the Ville:Isabella Rodriguez's apartment:main room",
+				Memories: []string{
+					"Isabella Rodriguez is planning a Valentine's Day party at Hobbs Cafe",
+					"Isabella Rodriguez knows Klaus Mueller from the cafe",
+				},
+			},
+			{
+				Name:        "Klaus Mueller",
+				Age:         20,
+				Innate:      "kind, inquisitive, passionate",
+				Learned:     "Klaus Mueller is a student at Oak Hill College studying sociology.",
+				Lifestyle:   "Klaus Mueller goes to bed around 11pm, awakes up around 7am.",
+				LivingArea:  "the Ville:Dorm for Oak Hill College:Klaus Mueller's room",
+				KnownPlaces: []string{"the Ville:Hobbs Cafe", "the Ville:Oak Hill College"},
+			},
+		},
+	}
+
+	folder := t.TempDir()
+	storage := &simulationloader.FileStorage{SimulationsFolder: folder, BackupFolder: t.TempDir(), Simulation: "new", Maze: "the_ville"}
+	if err := storage.Create(context.Background(), spec, mazeFolder, f, f, log); err != nil {
+		t.Fatalf("could not create simulation: %v", err)
+	}
+	if err := storage.Create(context.Background(), spec, mazeFolder, f, f, log); err == nil {
+		t.Fatalf("Expected an error when creating an existing simulation")
+	}
+
+	// Saving the same spatial memory twice writes the same file
+	again := &simulationloader.FileStorage{SimulationsFolder: folder, BackupFolder: t.TempDir(), Simulation: "again", Maze: "the_ville"}
+	if err := again.Create(context.Background(), spec, mazeFolder, f, f, log); err != nil {
+		t.Fatalf("could not create simulation: %v", err)
+	}
+	spatialFile := path.Join("personas", "Isabella Rodriguez", "bootstrap_memory", "spatial_memory.json")
+	first, err := os.ReadFile(path.Join(folder, "new", spatialFile))
+	if err != nil {
+		t.Fatalf("could not read spatial memory: %v", err)
+	}
+	second, err := os.ReadFile(path.Join(folder, "again", spatialFile))
+	if err != nil {
+		t.Fatalf("could not read spatial memory: %v", err)
+	}
+	if !bytes.Equal(first, second) {
+		t.Fatalf("Expected the spatial memory to be saved the same way every time")
+	}
+
+	sim, err := simulationloader.LoadSimulation(path.Join(folder, "new"), mazeFolder, f, f, log)
+	if err != nil {
+		t.Fatalf("could not load simulation: %v", err)
+	}
+	if len(sim.Personas) != 2 {
+		t.Fatalf("Wrong amount of personas, got: %d, want: %d", len(sim.Personas), 2)
+	}
+	for name, pos := range sim.PersonaPositions {
+		if tile := sim.Maze.GetTile(pos); tile.SpawningLocation == "" || !tile.Path.Matches(sim.Personas[name].LivingArea()) {
+			t.Fatalf("Expected %s to spawn in their living area, got: %s", name, tile.Path.ToString())
+		}
+	}
+
+	associative, spatial := sim.Personas["Isabella Rodriguez"].Memory()
+	thoughts := 0
+	for _, node := range associative.Nodes() {
+		if node.Type != memory.NodeTypeThought {
+			continue
+		}
+		thoughts += 1
+		if _, ok := associative.GetEmbeddingByNodeId(node.Id); !ok {
+			t.Fatalf("Expected thought %d to have an embedding", node.Id)
+		}
+	}
+	if thoughts != 2 {
+		t.Fatalf("Wrong amount of starting memories, got: %d, want: %d", thoughts, 2)
+	}
+	if len(spatial.GetKnown(memory.ParsePath("the Ville"), memory.PathLevelSector)) < 10 {
+		t.Fatalf("Expected Isabella to know the entire world")
+	}
+
+	_, spatial = sim.Personas["Klaus Mueller"].Memory()
+	if known := spatial.GetKnown(memory.ParsePath("the Ville"), memory.PathLevelSector); len(known) != 3 {
+		t.Fatalf("Wrong known sectors for Klaus, got: %v", known)
+	}
+
+	sim.Storage = storage
+	sim.BackupInterval = 1000
+	if err := sim.Run(context.Background(), 20); err != nil {
+		t.Fatalf("could not run simulation: %v", err)
+	}
+}
+
+func TestFileStorageCreateInvalid(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	f := fake.New()
+
+	spec := simulationloader.SimulationSpec{
+		StartDate: "yesterday",
+		Personas: []simulationloader.PersonaSpec{
+			{Name: "Nobody", LivingArea: "the Ville:Nowhere:room"},
+		},
+	}
+
+	storage := &simulationloader.FileStorage{SimulationsFolder: t.TempDir(), BackupFolder: t.TempDir(), Simulation: "new", Maze: "the_ville"}
+	if err := storage.Create(context.Background(), spec, mazeFolder, f, f, log); err == nil {
+		t.Fatalf("Expected an invalid spec")
+	}
+}
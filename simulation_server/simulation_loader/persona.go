@@ -46,6 +46,11 @@ func LoadState(stateFile string, position maze.TilePos) (*agent.State, error) {
 		return nil, fmt.Errorf("could not unmarshal state json: %w", err)
 	}
 
+	return newState(state, position), nil
+}
+
+// newState converts the saved state of a persona, the position of a persona is saved in the environment instead.
+func newState(state PersonaState, position maze.TilePos) *agent.State {
 	schedule := make([]llm.Plan, 0, len(state.FDailySchedule))
 	originalSchedule := make([]llm.Plan, 0, len(state.FDailyScheduleHourlyOrg))
 
@@ -85,6 +90,35 @@ func LoadState(stateFile string, position maze.TilePos) (*agent.State, error) {
 		chattingWith = *state.ChattingWith
 	}
 
+	var forgetting memory.ForgettingPolicy
+	if state.Forgetting != nil {
+		forgetting = memory.ForgettingPolicy{
+			Expire:          state.Forgetting.Expire,
+			DecayImportance: state.Forgetting.DecayImportance,
+			DecayAfter:      time.Duration(state.Forgetting.DecayAfterHours) * time.Hour,
+			MergeIdleAfter:  time.Duration(state.Forgetting.MergeIdleAfterHours) * time.Hour,
+			Archive:         state.Forgetting.Archive,
+		}
+	}
+
+	var reflection agent.ReflectionPolicy
+	if state.Reflection != nil {
+		reflection = agent.ReflectionPolicy{
+			FocalPoints:      state.Reflection.FocalPoints,
+			Insights:         state.Reflection.Insights,
+			Levels:           state.Reflection.Levels,
+			IgnoreImportance: state.Reflection.IgnoreImportance,
+			Daily:            state.Reflection.DailyAt != nil,
+			IdleAfter:        time.Duration(state.Reflection.IdleAfterMinutes) * time.Minute,
+		}
+		for _, t := range state.Reflection.Evidence {
+			reflection.Evidence = append(reflection.Evidence, memory.NodeType(t))
+		}
+		if state.Reflection.DailyAt != nil {
+			reflection.DailyAt = time.Duration(*state.Reflection.DailyAt)
+		}
+	}
+
 	s := &agent.State{
 		Position:                 position,
 		CurrentTime:              time.Time(state.CurrTime),
@@ -129,6 +163,8 @@ func LoadState(stateFile string, position maze.TilePos) (*agent.State, error) {
 		ValenceWeight:      state.ValenceW,
 		AsymetricEncoding:  state.AsymetricEncoding,
 		NegativityBias:     state.NegativityBias,
+		Forgetting:         forgetting,
+		Reflection:         reflection,
 		FirstName:          state.FirstName,
 		LastName:           state.LastName,
 		Age:                state.Age,
@@ -139,6 +175,21 @@ func LoadState(stateFile string, position maze.TilePos) (*agent.State, error) {
 		LivingArea:         memory.ParsePath(state.LivingArea),
 		FullName:           state.Name,
 	}
+	if state.LastDailyReflection != nil {
+		s.LastDailyReflection = time.Time(*state.LastDailyReflection)
+	}
+	for _, entry := range state.Diary {
+		s.Diary = append(s.Diary, newAgentDiaryEntry(entry))
+	}
 
-	return s, nil
+	return s
+}
+
+func newAgentDiaryEntry(entry DiaryEntry) agent.DiaryEntry {
+	return agent.DiaryEntry{
+		Day:      time.Time(entry.Day),
+		Events:   entry.Events,
+		Feelings: entry.Feelings,
+		Wants:    entry.Wants,
+	}
 }
@@ -0,0 +1,114 @@
+package simulationloader_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/fvdveen/generative_agents/simulation_server/agent"
+	"github.com/fvdveen/generative_agents/simulation_server/llm/fake"
+	"github.com/fvdveen/generative_agents/simulation_server/memory"
+	"github.com/fvdveen/generative_agents/simulation_server/metrics"
+	simulationloader "github.com/fvdveen/generative_agents/simulation_server/simulation_loader"
+)
+
+// setPersonaState sets field to the JSON value in the saved state of every persona of the simulation in folder.
+func setPersonaState(t *testing.T, folder string, field string, value string) {
+	t.Helper()
+
+	personas, err := os.ReadDir(path.Join(folder, "personas"))
+	if err != nil {
+		t.Fatalf("could not read personas: %v", err)
+	}
+	for _, persona := range personas {
+		file := path.Join(folder, "personas", persona.Name(), "bootstrap_memory", "scratch.json")
+		content, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("could not read state: %v", err)
+		}
+
+		var state map[string]any
+		if err := json.Unmarshal(content, &state); err != nil {
+			t.Fatalf("could not parse state: %v", err)
+		}
+		state[field] = json.RawMessage(value)
+		if content, err = json.Marshal(state); err != nil {
+			t.Fatalf("could not encode state: %v", err)
+		}
+		if err := os.WriteFile(file, content, 0o644); err != nil {
+			t.Fatalf("could not write state: %v", err)
+		}
+	}
+}
+
+func TestReflectionPolicy(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	f := fake.New()
+
+	folder := t.TempDir()
+	copySimulation(t, path.Join(folder, "test"))
+	setPersonaState(t, path.Join(folder, "test"), "reflection", `{"evidence": ["event", "chat"], "levels": 2, "insights": 2, "ignore_importance": true, "daily_at": "06:15"}`)
+	storage := &simulationloader.FileStorage{SimulationsFolder: folder, BackupFolder: t.TempDir(), Simulation: "test", Maze: "the_ville"}
+
+	sim, err := simulationloader.LoadSimulation(path.Join(folder, "test"), mazeFolder, f, f, log)
+	if err != nil {
+		t.Fatalf("could not load simulation: %v", err)
+	}
+	recorder := metrics.NewMemory()
+	sim.Storage, sim.Metrics, sim.BackupInterval = storage, recorder, 100000
+	if err := sim.RunUntil(context.Background(), time.Date(2023, time.February, 13, 6, 30, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("could not run simulation: %v", err)
+	}
+
+	// The daily trigger fires once, the importance of the perceived events is ignored
+	reflections := recorder.Totals().Reflections
+	if len(reflections) != len(sim.Personas) {
+		t.Errorf("Expected every persona to reflect, got: %v", reflections)
+	}
+	for name, count := range reflections {
+		if count != 1 {
+			t.Errorf("Wrong amount of reflections for %s, got: %d, want: 1", name, count)
+		}
+	}
+
+	loaded, err := simulationloader.LoadSimulation(path.Join(folder, "test"), mazeFolder, f, f, log)
+	if err != nil {
+		t.Fatalf("could not load simulation: %v", err)
+	}
+	want := agent.ReflectionPolicy{
+		Evidence:         []memory.NodeType{memory.NodeTypeEvent, memory.NodeTypeChat},
+		Insights:         2,
+		Levels:           2,
+		IgnoreImportance: true,
+		Daily:            true,
+		DailyAt:          6*time.Hour + 15*time.Minute,
+	}
+	for name, persona := range loaded.Personas {
+		state := persona.State()
+		if got := state.Reflection; !slices.Equal(got.Evidence, want.Evidence) || got.Insights != want.Insights || got.Levels != want.Levels ||
+			got.IgnoreImportance != want.IgnoreImportance || got.Daily != want.Daily || got.DailyAt != want.DailyAt {
+			t.Errorf("Wrong reflection policy for %s after saving, got: %+v, want: %+v", name, got, want)
+		}
+		if state.LastDailyReflection.Hour() != 6 || state.LastDailyReflection.Minute() != 15 {
+			t.Errorf("Expected %s to have reflected at 6:15, last reflected at: %v", name, state.LastDailyReflection)
+		}
+
+		// The second level reflects on the thoughts of the first, so its thoughts are deeper
+		assoc, _ := persona.Memory()
+		depths := map[int]bool{}
+		for _, node := range assoc.Nodes() {
+			if node.Type == memory.NodeTypeThought {
+				depths[node.Depth] = true
+			}
+		}
+		if !depths[1] || !depths[2] {
+			t.Errorf("Expected thoughts of depth 1 and 2 for %s, got depths: %v", name, depths)
+		}
+	}
+}
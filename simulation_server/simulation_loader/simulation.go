@@ -18,6 +18,14 @@ import (
 )
 
 func LoadSimulation(simulationPath string, mazeFolder string, embedder llm.Embedder, cognition llm.Cognition, logger *slog.Logger) (*server.Server, error) {
+	rolledBack, err := recoverStep(simulationPath)
+	if err != nil {
+		return nil, fmt.Errorf("could not recover unfinished step: %w", err)
+	}
+	if rolledBack {
+		logger.Warn("step_rolled_back", slog.String("type", "simulation"), slog.String("simulation", simulationPath))
+	}
+
 	content, err := os.ReadFile(path.Join(simulationPath, "reverie", "meta.json"))
 	if err != nil {
 		return nil, fmt.Errorf("could not read simulation meta file: %w", err)
@@ -28,11 +36,6 @@ func LoadSimulation(simulationPath string, mazeFolder string, embedder llm.Embed
 		return nil, fmt.Errorf("could not unmarshal meta file json: %w", err)
 	}
 
-	m, err := LoadMaze(path.Join(mazeFolder, meta.MazeName), meta.MazeName)
-	if err != nil {
-		return nil, fmt.Errorf("could not load maze: %w", err)
-	}
-
 	content, err = os.ReadFile(path.Join(simulationPath, "environment", fmt.Sprintf("%d.json", meta.Step)))
 	if err != nil {
 		return nil, fmt.Errorf("could not read simulation environment file: %w", err)
@@ -43,6 +46,20 @@ func LoadSimulation(simulationPath string, mazeFolder string, embedder llm.Embed
 		return nil, fmt.Errorf("could not unmarshal environment file: %w", err)
 	}
 
+	return newServer(meta, env, mazeFolder, logger, func(name string, pos maze.TilePos) (*agent.Persona, error) {
+		return LoadPersona(path.Join(simulationPath, "personas", name), pos, embedder, cognition, logger)
+	})
+}
+
+// newServer creates the simulation described by meta, with the personas at the positions in env.
+// Every persona is loaded by calling loadPersona.
+func newServer(meta SimulationMeta, env Environment, mazeFolder string, logger *slog.Logger,
+	loadPersona func(name string, pos maze.TilePos) (*agent.Persona, error)) (*server.Server, error) {
+	m, err := LoadMaze(path.Join(mazeFolder, meta.MazeName), meta.MazeName)
+	if err != nil {
+		return nil, fmt.Errorf("could not load maze: %w", err)
+	}
+
 	personas := map[string]*agent.Persona{}
 	personaTiles := map[string]maze.TilePos{}
 	for _, name := range meta.PersonaNames {
@@ -52,7 +69,7 @@ func LoadSimulation(simulationPath string, mazeFolder string, embedder llm.Embed
 		}
 
 		pos := maze.TilePos{X: envPersona.X, Y: envPersona.Y}
-		p, err := LoadPersona(path.Join(simulationPath, "personas", name), pos, embedder, cognition, logger)
+		p, err := loadPersona(name, pos)
 		if err != nil {
 			return nil, fmt.Errorf("could not load persona %s: %w", name, err)
 		}
@@ -0,0 +1,691 @@
+package simulationloader
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fvdveen/generative_agents/simulation_server/agent"
+	"github.com/fvdveen/generative_agents/simulation_server/llm"
+	"github.com/fvdveen/generative_agents/simulation_server/llm/embedding"
+	"github.com/fvdveen/generative_agents/simulation_server/maze"
+	"github.com/fvdveen/generative_agents/simulation_server/memory"
+	"github.com/fvdveen/generative_agents/simulation_server/server"
+
+	_ "modernc.org/sqlite"
+)
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS meta (
+	id INTEGER PRIMARY KEY CHECK (id = 1),
+	data TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS movements (
+	step INTEGER PRIMARY KEY,
+	data TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS environment (
+	step INTEGER PRIMARY KEY,
+	data TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS personas (
+	name TEXT PRIMARY KEY,
+	scratch TEXT NOT NULL,
+	spatial_memory TEXT NOT NULL,
+	kw_strength TEXT NOT NULL,
+	index_entry INTEGER NOT NULL,
+	index_max_level INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS nodes (
+	persona TEXT NOT NULL,
+	id INTEGER NOT NULL,
+	data TEXT NOT NULL,
+	PRIMARY KEY (persona, id)
+);
+CREATE TABLE IF NOT EXISTS embeddings (
+	persona TEXT NOT NULL,
+	key TEXT NOT NULL,
+	vector BLOB NOT NULL,
+	PRIMARY KEY (persona, key)
+);
+CREATE TABLE IF NOT EXISTS index_links (
+	persona TEXT NOT NULL,
+	id INTEGER NOT NULL,
+	links TEXT NOT NULL,
+	PRIMARY KEY (persona, id)
+);
+CREATE TABLE IF NOT EXISTS diary (
+	persona TEXT NOT NULL,
+	day TEXT NOT NULL,
+	data TEXT NOT NULL,
+	PRIMARY KEY (persona, day)
+);
+`
+
+// The tables of the database, in the order they are copied
+var sqliteTables = []string{"meta", "movements", "environment", "personas", "nodes", "embeddings", "index_links", "diary"}
+
+// SQLiteStorage stores a simulation in a single SQLite database.
+// Unlike FileStorage only what changed is written, memory nodes, embeddings and movements are appended as the
+// simulation runs, and every step is saved in a single transaction so a crash never leaves a half saved step behind.
+type SQLiteStorage struct {
+	mu sync.Mutex
+	db *sql.DB
+
+	BackupFolder string
+	Simulation   string
+	// The maze the personas are in, it is set when the simulation is loaded
+	Maze string
+
+	// The movements of the current step, they are saved together with the rest of the step
+	movements   *Movements
+	environment *Environment
+	step        int
+
+	// What has been saved of every persona, so only the changes have to be saved
+	saved map[string]*sqlitePersona
+}
+
+type sqlitePersona struct {
+	// The revision of the associative memory and its index that have been saved
+	memoryRevision uint64
+	indexRevision  uint64
+	// The embedding keys that have been saved
+	embeddings map[string]bool
+	// Whether the saved index no longer matches the memory and has to be replaced entirely
+	replaceIndex bool
+	// The day of the latest diary entry that has been saved
+	diaryDay time.Time
+}
+
+// OpenSQLiteStorage opens the database in file, creating it if it does not exist yet.
+func OpenSQLiteStorage(file string, backupFolder string, simulation string) (*SQLiteStorage, error) {
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		return nil, fmt.Errorf("could not create database folder: %w", err)
+	}
+
+	db, err := sql.Open("sqlite", file+"?_pragma=journal_mode(WAL)&_pragma=synchronous(NORMAL)&_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, fmt.Errorf("could not open database: %w", err)
+	}
+	// SQLite only allows a single writer anyway, and a single connection means pragmas only need to be set once
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("could not create tables: %w", err)
+	}
+
+	return &SQLiteStorage{
+		db:           db,
+		BackupFolder: backupFolder,
+		Simulation:   simulation,
+		saved:        map[string]*sqlitePersona{},
+	}, nil
+}
+
+func (s *SQLiteStorage) Close() error {
+	return s.db.Close()
+}
+
+func (s *SQLiteStorage) SaveMovements(step int, personaMovements map[string]server.PersonaMovement, currTime time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	movements, env := newMovements(personaMovements, currTime, s.Maze)
+	s.movements, s.environment, s.step = &movements, &env, step
+
+	return nil
+}
+
+func (s *SQLiteStorage) SaveSimulation(srv *server.Server) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("could not start transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if s.movements != nil {
+		if err := execJson(tx, "INSERT OR REPLACE INTO movements (step, data) VALUES (?, ?)", s.step, s.movements); err != nil {
+			return fmt.Errorf("could not save movements: %w", err)
+		}
+		if err := execJson(tx, "INSERT OR REPLACE INTO environment (step, data) VALUES (?, ?)", s.step+1, s.environment); err != nil {
+			return fmt.Errorf("could not save environment: %w", err)
+		}
+	}
+
+	// Changes are only marked as saved once the transaction is committed
+	saved := map[string]*sqlitePersona{}
+	for name, p := range srv.Personas {
+		if saved[name], err = s.savePersona(tx, p); err != nil {
+			return fmt.Errorf("could not save persona %s: %w", name, err)
+		}
+	}
+
+	if err := execJson(tx, "INSERT OR REPLACE INTO meta (id, data) VALUES (1, ?)", newSimulationMeta(srv)); err != nil {
+		return fmt.Errorf("could not save meta: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("could not commit transaction: %w", err)
+	}
+
+	s.movements, s.environment = nil, nil
+	for name, p := range saved {
+		s.saved[name] = p
+	}
+
+	return nil
+}
+
+func (s *SQLiteStorage) savePersona(tx *sql.Tx, p *agent.Persona) (*sqlitePersona, error) {
+	name := p.Name()
+	assoc, spatial := p.Memory()
+	index := assoc.Index()
+
+	prev, ok := s.saved[name]
+	if !ok {
+		prev = &sqlitePersona{embeddings: map[string]bool{}, replaceIndex: true}
+	}
+	saved := &sqlitePersona{
+		memoryRevision: assoc.Revision(),
+		indexRevision:  index.Revision(),
+		embeddings:     make(map[string]bool, len(prev.embeddings)),
+	}
+
+	scratch, err := json.Marshal(newPersonaState(p))
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal state: %w", err)
+	}
+	spatialMemory, err := json.Marshal(newSpatialMemoryTree(spatial))
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal spatial memory: %w", err)
+	}
+	kwStrength, err := json.Marshal(KwStength{Thoughts: assoc.ThoughtKeywordStrength(), Events: assoc.EventKeywordStrength()})
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal keyword strength: %w", err)
+	}
+	entry, maxLevel := index.Entry()
+
+	if _, err := tx.Exec(`INSERT OR REPLACE INTO personas (name, scratch, spatial_memory, kw_strength, index_entry, index_max_level)
+		VALUES (?, ?, ?, ?, ?, ?)`, name, string(scratch), string(spatialMemory), string(kwStrength), entry, maxLevel); err != nil {
+		return nil, fmt.Errorf("could not save state: %w", err)
+	}
+
+	for _, id := range assoc.ChangedSince(prev.memoryRevision) {
+		if id == 0 {
+			continue
+		}
+		if err := execJson(tx, "INSERT OR REPLACE INTO nodes (persona, id, data) VALUES (?, ?, ?)", name, id, newMemoryNode(assoc.GetNode(id))); err != nil {
+			return nil, fmt.Errorf("could not save memory node %d: %w", id, err)
+		}
+	}
+
+	embeddings := assoc.Embeddings()
+	for key, vector := range embeddings {
+		saved.embeddings[key] = true
+		if prev.embeddings[key] {
+			continue
+		}
+		if _, err := tx.Exec("INSERT OR REPLACE INTO embeddings (persona, key, vector) VALUES (?, ?, ?)", name, key, embedding.EncodeVector(vector)); err != nil {
+			return nil, fmt.Errorf("could not save embedding: %w", err)
+		}
+	}
+	for key := range prev.embeddings {
+		if _, ok := embeddings[key]; ok {
+			continue
+		}
+		if _, err := tx.Exec("DELETE FROM embeddings WHERE persona = ? AND key = ?", name, key); err != nil {
+			return nil, fmt.Errorf("could not delete embedding: %w", err)
+		}
+	}
+
+	// The persona only keeps its latest diary entries, the whole diary is kept in the diary table
+	saved.diaryDay = prev.diaryDay
+	for _, entry := range p.State().Diary {
+		if !entry.Day.After(prev.diaryDay) {
+			continue
+		}
+		if err := execJson(tx, "INSERT OR REPLACE INTO diary (persona, day, data) VALUES (?, ?, ?)", name, entry.Day.Format(time.DateOnly), newDiaryEntry(entry)); err != nil {
+			return nil, fmt.Errorf("could not save diary entry of %s: %w", entry.Day.Format(time.DateOnly), err)
+		}
+		saved.diaryDay = entry.Day
+	}
+
+	changed := index.ChangedSince(prev.indexRevision)
+	if prev.replaceIndex {
+		if _, err := tx.Exec("DELETE FROM index_links WHERE persona = ?", name); err != nil {
+			return nil, fmt.Errorf("could not delete index: %w", err)
+		}
+		changed = index.ChangedSince(0)
+	}
+	for _, id := range changed {
+		links, ok := index.Links(id)
+		if !ok {
+			if _, err := tx.Exec("DELETE FROM index_links WHERE persona = ? AND id = ?", name, id); err != nil {
+				return nil, fmt.Errorf("could not delete index node %d: %w", id, err)
+			}
+			continue
+		}
+		if err := execJson(tx, "INSERT OR REPLACE INTO index_links (persona, id, links) VALUES (?, ?, ?)", name, id, links); err != nil {
+			return nil, fmt.Errorf("could not save index node %d: %w", id, err)
+		}
+	}
+
+	return saved, nil
+}
+
+// execJson executes query with the last argument marshalled to JSON.
+func execJson(tx *sql.Tx, query string, args ...any) error {
+	data, err := json.Marshal(args[len(args)-1])
+	if err != nil {
+		return fmt.Errorf("could not marshal JSON: %w", err)
+	}
+	args[len(args)-1] = string(data)
+
+	_, err = tx.Exec(query, args...)
+	return err
+}
+
+func (s *SQLiteStorage) backupFile(step int) string {
+	return path.Join(s.BackupFolder, s.Simulation, fmt.Sprintf("%d.db", step))
+}
+
+// Backup copies the database to <BackupFolder>/<Simulation>/<step>.db.
+func (s *SQLiteStorage) Backup(step int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	dst := s.backupFile(step)
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return fmt.Errorf("could not create backup folder: %w", err)
+	}
+	// VACUUM INTO refuses to overwrite an existing file
+	if err := os.Remove(dst); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("could not remove old backup: %w", err)
+	}
+
+	if _, err := s.db.Exec("VACUUM INTO ?", dst); err != nil {
+		return fmt.Errorf("could not backup database: %w", err)
+	}
+
+	return nil
+}
+
+// Load loads the simulation from the database, like LoadSimulation does from a simulation folder.
+// Everything that is loaded counts as saved, so the next save only writes what changed after loading.
+func (s *SQLiteStorage) Load(mazeFolder string, embedder llm.Embedder, cognition llm.Cognition, logger *slog.Logger) (*server.Server, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var meta SimulationMeta
+	if err := s.queryJson("SELECT data FROM meta WHERE id = 1", &meta); err != nil {
+		return nil, fmt.Errorf("could not read simulation meta: %w", err)
+	}
+
+	var env Environment
+	if err := s.queryJson("SELECT data FROM environment WHERE step = ?", &env, meta.Step); err != nil {
+		return nil, fmt.Errorf("could not read simulation environment for step %d: %w", meta.Step, err)
+	}
+
+	saved := map[string]*sqlitePersona{}
+	srv, err := newServer(meta, env, mazeFolder, logger, func(name string, pos maze.TilePos) (*agent.Persona, error) {
+		p, personaSaved, err := s.loadPersona(name, pos, embedder, cognition)
+		saved[name] = personaSaved
+		return p, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.Maze = meta.MazeName
+	s.saved = saved
+	s.movements, s.environment = nil, nil
+
+	return srv, nil
+}
+
+func (s *SQLiteStorage) loadPersona(name string, pos maze.TilePos, embedder llm.Embedder, cognition llm.Cognition) (*agent.Persona, *sqlitePersona, error) {
+	var scratch, spatialMemory, kwStrength []byte
+	var entry memory.NodeId
+	var maxLevel int
+	if err := s.db.QueryRow("SELECT scratch, spatial_memory, kw_strength, index_entry, index_max_level FROM personas WHERE name = ?", name).
+		Scan(&scratch, &spatialMemory, &kwStrength, &entry, &maxLevel); err != nil {
+		return nil, nil, fmt.Errorf("could not read persona: %w", err)
+	}
+
+	var state PersonaState
+	if err := json.Unmarshal(scratch, &state); err != nil {
+		return nil, nil, fmt.Errorf("could not unmarshal state json: %w", err)
+	}
+	locs := map[string]map[string]map[string][]string{}
+	if err := json.Unmarshal(spatialMemory, &locs); err != nil {
+		return nil, nil, fmt.Errorf("could not unmarshal spatial memory json: %w", err)
+	}
+	kws := KwStength{Thoughts: map[string]int{}, Events: map[string]int{}}
+	if err := json.Unmarshal(kwStrength, &kws); err != nil {
+		return nil, nil, fmt.Errorf("could not unmarshal keyword strength json: %w", err)
+	}
+
+	embeddings := map[string][]float64{}
+	err := s.queryRows("SELECT key, vector FROM embeddings WHERE persona = ?", func(rows *sql.Rows) error {
+		var key string
+		var vector []byte
+		if err := rows.Scan(&key, &vector); err != nil {
+			return err
+		}
+		embeddings[key] = embedding.DecodeVector(vector)
+		return nil
+	}, name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not read embeddings: %w", err)
+	}
+
+	memories := map[string]MemoryNode{}
+	err = s.queryRows("SELECT id, data FROM nodes WHERE persona = ?", func(rows *sql.Rows) error {
+		var id int
+		var data []byte
+		if err := rows.Scan(&id, &data); err != nil {
+			return err
+		}
+		var node MemoryNode
+		if err := json.Unmarshal(data, &node); err != nil {
+			return fmt.Errorf("could not unmarshal memory node %d: %w", id, err)
+		}
+		memories["node_"+strconv.Itoa(id)] = node
+		return nil
+	}, name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not read memory nodes: %w", err)
+	}
+
+	links := map[memory.NodeId][][]memory.NodeId{}
+	err = s.queryRows("SELECT id, links FROM index_links WHERE persona = ?", func(rows *sql.Rows) error {
+		var id memory.NodeId
+		var data []byte
+		if err := rows.Scan(&id, &data); err != nil {
+			return err
+		}
+		var l [][]memory.NodeId
+		if err := json.Unmarshal(data, &l); err != nil {
+			return fmt.Errorf("could not unmarshal index node %d: %w", id, err)
+		}
+		links[id] = l
+		return nil
+	}, name)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not read index: %w", err)
+	}
+
+	// A broken index is rebuilt whilst loading the nodes, and replaced when the persona is saved
+	index, err := memory.NewIndexFromLinks(entry, maxLevel, links)
+	replaceIndex := err != nil || !indexMatches(index, memories, embeddings)
+	if replaceIndex {
+		index = nil
+	}
+
+	assoc, err := newAssociativeMemory(embeddings, kws, memories, index)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not load associative memory: %w", err)
+	}
+
+	saved := &sqlitePersona{
+		memoryRevision: assoc.Revision(),
+		indexRevision:  assoc.Index().Revision(),
+		embeddings:     make(map[string]bool, len(embeddings)),
+		replaceIndex:   replaceIndex,
+	}
+	for key := range embeddings {
+		saved.embeddings[key] = true
+	}
+
+	st := newState(state, pos)
+	return agent.New(st.FullName, assoc, newSpatialMemory(locs), *st, embedder, cognition), saved, nil
+}
+
+func (s *SQLiteStorage) queryJson(query string, v any, args ...any) error {
+	var data []byte
+	if err := s.db.QueryRow(query, args...).Scan(&data); err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+func (s *SQLiteStorage) queryRows(query string, scan func(*sql.Rows) error, args ...any) error {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := scan(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// Import replaces the simulation in the database with the simulation in the reverie folder simulationPath,
+// including the movements and environments of every step.
+func (s *SQLiteStorage) Import(simulationPath string, mazeFolder string, logger *slog.Logger) error {
+	srv, err := LoadSimulation(simulationPath, mazeFolder, nil, nil, logger)
+	if err != nil {
+		return fmt.Errorf("could not load simulation: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("could not start transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, table := range sqliteTables {
+		if _, err := tx.Exec("DELETE FROM " + table); err != nil {
+			return fmt.Errorf("could not clear %s: %w", table, err)
+		}
+	}
+
+	for _, table := range []string{"movement", "environment"} {
+		if err := importSteps(tx, path.Join(simulationPath, table), table); err != nil {
+			return err
+		}
+	}
+
+	s.saved = map[string]*sqlitePersona{}
+	for name, p := range srv.Personas {
+		if _, err := s.savePersona(tx, p); err != nil {
+			return fmt.Errorf("could not import persona %s: %w", name, err)
+		}
+	}
+
+	if err := execJson(tx, "INSERT INTO meta (id, data) VALUES (1, ?)", newSimulationMeta(srv)); err != nil {
+		return fmt.Errorf("could not import meta: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("could not commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+// importSteps copies every <step>.json file in folder into table, the files are stored as they are.
+func importSteps(tx *sql.Tx, folder string, table string) error {
+	entries, err := os.ReadDir(folder)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("could not read %s folder: %w", table, err)
+	}
+
+	// The table of the movement folder is called movements
+	if table == "movement" {
+		table = "movements"
+	}
+
+	for _, e := range entries {
+		step, err := strconv.Atoi(strings.TrimSuffix(e.Name(), ".json"))
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") || err != nil {
+			continue
+		}
+
+		data, err := os.ReadFile(path.Join(folder, e.Name()))
+		if err != nil {
+			return fmt.Errorf("could not read %s file for step %d: %w", table, step, err)
+		}
+		if !json.Valid(data) {
+			return fmt.Errorf("invalid %s file for step %d", table, step)
+		}
+
+		if _, err := tx.Exec("INSERT INTO "+table+" (step, data) VALUES (?, ?)", step, string(data)); err != nil {
+			return fmt.Errorf("could not import %s for step %d: %w", table, step, err)
+		}
+	}
+
+	return nil
+}
+
+// Export writes the simulation in the database to the reverie folder layout, so it can be viewed by the frontend.
+// fs decides where the simulation is written to.
+func (s *SQLiteStorage) Export(fs *FileStorage, mazeFolder string, logger *slog.Logger) error {
+	srv, err := s.Load(mazeFolder, nil, nil, logger)
+	if err != nil {
+		return fmt.Errorf("could not load simulation: %w", err)
+	}
+
+	if err := fs.SaveSimulation(srv); err != nil {
+		return fmt.Errorf("could not export simulation: %w", err)
+	}
+
+	for table, folder := range map[string]string{"movements": fs.movementFolder(), "environment": fs.environmentFolder()} {
+		err := s.queryRows("SELECT step, data FROM "+table, func(rows *sql.Rows) error {
+			var step int
+			var data []byte
+			if err := rows.Scan(&step, &data); err != nil {
+				return err
+			}
+			var out bytes.Buffer
+			if err := json.Indent(&out, data, "", "  "); err != nil {
+				return fmt.Errorf("invalid %s for step %d: %w", table, step, err)
+			}
+			return writeFileWithDirs(path.Join(folder, fmt.Sprintf("%d.json", step)), out.Bytes(), 0o644)
+		})
+		if err != nil {
+			return fmt.Errorf("could not export %s: %w", table, err)
+		}
+	}
+
+	err = s.queryRows("SELECT persona, data FROM diary", func(rows *sql.Rows) error {
+		var name string
+		var data []byte
+		if err := rows.Scan(&name, &data); err != nil {
+			return err
+		}
+		var entry DiaryEntry
+		if err := json.Unmarshal(data, &entry); err != nil {
+			return fmt.Errorf("invalid diary entry of %s: %w", name, err)
+		}
+		return writeFileWithDirs(fs.diaryFile(name, time.Time(entry.Day)), []byte(diaryMarkdown(name, newAgentDiaryEntry(entry))), 0o644)
+	})
+	if err != nil {
+		return fmt.Errorf("could not export diary: %w", err)
+	}
+
+	return nil
+}
+
+// Fork creates a new database in file from the backup of this simulation at step, the fork continues from that step
+// and records this simulation as the one it was forked from.
+func (s *SQLiteStorage) Fork(step int, file string) error {
+	backup := s.backupFile(step)
+	if _, err := os.Stat(backup); err != nil {
+		return fmt.Errorf("could not find backup of step %d: %w", step, err)
+	}
+	if _, err := os.Stat(file); err == nil {
+		return fmt.Errorf("database %s already exists", file)
+	}
+
+	if err := copyFile(backup, file, 0o644); err != nil {
+		return fmt.Errorf("could not copy backup: %w", err)
+	}
+
+	fork, err := OpenSQLiteStorage(file, s.BackupFolder, "")
+	if err != nil {
+		_ = os.Remove(file)
+		return err
+	}
+	defer fork.Close()
+
+	if _, err := fork.db.Exec("UPDATE meta SET data = json_set(data, '$.fork_sim_code', ?)", s.Simulation); err != nil {
+		return fmt.Errorf("could not save fork: %w", err)
+	}
+
+	return nil
+}
+
+// Rewind restores the database to the backup at step, everything that happened after that step is removed.
+// The simulation has to be loaded again afterwards.
+func (s *SQLiteStorage) Rewind(step int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	backup := s.backupFile(step)
+	if _, err := os.Stat(backup); err != nil {
+		return fmt.Errorf("could not find backup of step %d: %w", step, err)
+	}
+
+	ctx := context.Background()
+	// The backup is only attached to a single connection, so every statement has to use that connection
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("could not get connection: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "ATTACH DATABASE ? AS backup", backup); err != nil {
+		return fmt.Errorf("could not attach backup: %w", err)
+	}
+	defer func() { _, _ = conn.ExecContext(ctx, "DETACH DATABASE backup") }()
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("could not start transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, table := range sqliteTables {
+		if _, err := tx.Exec("DELETE FROM main." + table); err != nil {
+			return fmt.Errorf("could not clear %s: %w", table, err)
+		}
+		if _, err := tx.Exec("INSERT INTO main." + table + " SELECT * FROM backup." + table); err != nil {
+			return fmt.Errorf("could not restore %s: %w", table, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("could not commit transaction: %w", err)
+	}
+
+	s.saved = map[string]*sqlitePersona{}
+	s.movements, s.environment = nil, nil
+
+	return nil
+}
@@ -0,0 +1,129 @@
+package simulationloader_test
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"maps"
+	"os"
+	"path"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/fvdveen/generative_agents/simulation_server/llm/fake"
+	"github.com/fvdveen/generative_agents/simulation_server/server"
+	simulationloader "github.com/fvdveen/generative_agents/simulation_server/simulation_loader"
+)
+
+const (
+	simulationPath = "../../environment/frontend_server/storage/base_the_ville_isabella_maria_klaus"
+	mazeFolder     = "../../environment/frontend_server/static_dirs/assets"
+)
+
+// copySimulation copies the test simulation to dst, so a test can change it.
+func copySimulation(t *testing.T, dst string) {
+	t.Helper()
+
+	if err := os.CopyFS(dst, os.DirFS(simulationPath)); err != nil {
+		t.Fatalf("could not copy simulation: %v", err)
+	}
+}
+
+func TestSQLiteStorageResumes(t *testing.T) {
+	const steps = 1500
+
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	f := fake.New()
+
+	db, err := simulationloader.OpenSQLiteStorage(path.Join(t.TempDir(), "test.sqlite"), t.TempDir(), "test")
+	if err != nil {
+		t.Fatalf("could not open database: %v", err)
+	}
+	defer db.Close()
+
+	if err := db.Import(simulationPath, mazeFolder, log); err != nil {
+		t.Fatalf("could not import simulation: %v", err)
+	}
+
+	load := func() *server.Server {
+		sim, err := db.Load(mazeFolder, f, f, log)
+		if err != nil {
+			t.Fatalf("could not load simulation: %v", err)
+		}
+		sim.Storage, sim.BackupInterval = db, 100000
+		return sim
+	}
+
+	// Run twice, so the second run only saves what changed after loading
+	for range 2 {
+		sim := load()
+		if err := sim.Run(context.Background(), steps); err != nil {
+			t.Fatalf("could not run simulation: %v", err)
+		}
+
+		loaded := load()
+		if loaded.Step != sim.Step || !maps.Equal(loaded.PersonaPositions, sim.PersonaPositions) {
+			t.Fatalf("Wrong step or positions after loading, got: %d %v, want: %d %v",
+				loaded.Step, loaded.PersonaPositions, sim.Step, sim.PersonaPositions)
+		}
+		for name, p := range sim.Personas {
+			want, _ := p.Memory()
+			got, _ := loaded.Personas[name].Memory()
+
+			if len(got.Nodes()) != len(want.Nodes()) {
+				t.Fatalf("Wrong amount of nodes for %s, got: %d, want: %d", name, len(got.Nodes()), len(want.Nodes()))
+			}
+			for i, node := range want.Nodes() {
+				loadedNode := got.Nodes()[i]
+				if loadedNode.Description != node.Description || !slices.Equal(loadedNode.Evidence, node.Evidence) ||
+					!slices.Equal(loadedNode.Keywords, node.Keywords) || !loadedNode.LastAccessed.Equal(node.LastAccessed) {
+					t.Fatalf("Node %d of %s differs after loading, got: %+v, want: %+v", node.Id, name, loadedNode, node)
+				}
+			}
+			if !reflect.DeepEqual(got.Embeddings(), want.Embeddings()) {
+				t.Fatalf("Embeddings of %s differ after loading", name)
+			}
+			if entry, level := got.Index().Entry(); got.Index().Len() != want.Index().Len() || got.Index().Revision() != 0 {
+				t.Fatalf("Index of %s was not loaded, entry: %d, level: %d", name, entry, level)
+			}
+		}
+	}
+}
+
+func TestSQLiteStorageExport(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	db, err := simulationloader.OpenSQLiteStorage(path.Join(t.TempDir(), "test.sqlite"), t.TempDir(), "test")
+	if err != nil {
+		t.Fatalf("could not open database: %v", err)
+	}
+	defer db.Close()
+
+	if err := db.Import(simulationPath, mazeFolder, log); err != nil {
+		t.Fatalf("could not import simulation: %v", err)
+	}
+
+	folder := t.TempDir()
+	if err := db.Export(&simulationloader.FileStorage{SimulationsFolder: folder, Simulation: "exported"}, mazeFolder, log); err != nil {
+		t.Fatalf("could not export simulation: %v", err)
+	}
+
+	want, err := simulationloader.LoadSimulation(simulationPath, mazeFolder, nil, nil, log)
+	if err != nil {
+		t.Fatalf("could not load simulation: %v", err)
+	}
+	got, err := simulationloader.LoadSimulation(path.Join(folder, "exported"), mazeFolder, nil, nil, log)
+	if err != nil {
+		t.Fatalf("could not load exported simulation: %v", err)
+	}
+
+	if !maps.Equal(got.PersonaPositions, want.PersonaPositions) || got.Step != want.Step || !got.CurrentTime.Equal(want.CurrentTime) {
+		t.Fatalf("Exported simulation differs from the imported one")
+	}
+	for name, p := range want.Personas {
+		if !reflect.DeepEqual(got.Personas[name].State(), p.State()) {
+			t.Fatalf("State of %s differs after exporting, got: %+v, want: %+v", name, got.Personas[name].State(), p.State())
+		}
+	}
+}
@@ -0,0 +1,272 @@
+package simulationloader
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// The folder in a simulation holding the step that is being saved
+const pendingStepFolder = ".pending_step"
+
+// stepWriter saves the files of a single step, so that either all or none of them change even if the process crashes.
+// The new files are first written to the pending step folder, the files they replace are linked there as well,
+// and only then are the new files moved into place. The last file written, meta.json when saving a simulation,
+// marks the step as done, until it is moved into place recoverStep rolls the step back.
+type stepWriter struct {
+	simulation string
+	files      []pendingFile
+}
+
+type pendingFile struct {
+	// The path of the file relative to the simulation folder
+	Path string `json:"path"`
+	// Whether the file existed before the step, if not it is removed when rolling back
+	Existed bool `json:"existed"`
+}
+
+func (p pendingFile) target(simulation string) string {
+	return filepath.Join(simulation, p.Path)
+}
+
+func (p pendingFile) new(simulation string) string {
+	return filepath.Join(simulation, pendingStepFolder, "new", p.Path)
+}
+
+func (p pendingFile) old(simulation string) string {
+	return filepath.Join(simulation, pendingStepFolder, "old", p.Path)
+}
+
+func manifestFile(simulation string) string {
+	return filepath.Join(simulation, pendingStepFolder, "files.json")
+}
+
+// beginStep starts saving a step of the simulation in folder simulation.
+// A step that was left behind by an earlier failed save is rolled back first.
+func beginStep(simulation string) (*stepWriter, error) {
+	if _, err := recoverStep(simulation); err != nil {
+		return nil, fmt.Errorf("could not recover previous step: %w", err)
+	}
+
+	return &stepWriter{simulation: simulation}, nil
+}
+
+// writeJson writes v as JSON to the file p, which must be inside the simulation folder.
+// The file only changes once the step is committed.
+func (w *stepWriter) writeJson(p string, v any) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not marshal JSON: %w", err)
+	}
+
+	return w.writeFile(p, data)
+}
+
+// writeFile writes data to the file p, which must be inside the simulation folder.
+// The file only changes once the step is committed.
+func (w *stepWriter) writeFile(p string, data []byte) error {
+	rel, err := filepath.Rel(w.simulation, p)
+	if err != nil || !filepath.IsLocal(rel) {
+		return fmt.Errorf("file %s is not part of simulation %s", p, w.simulation)
+	}
+
+	file := pendingFile{Path: rel}
+	if err := writeFileSynced(file.new(w.simulation), data, 0o644); err != nil {
+		return fmt.Errorf("could not write file to %s: %w", p, err)
+	}
+	w.files = append(w.files, file)
+
+	return nil
+}
+
+// commit moves the files of the step into place.
+func (w *stepWriter) commit() error {
+	if len(w.files) == 0 {
+		return os.RemoveAll(filepath.Join(w.simulation, pendingStepFolder))
+	}
+	if err := w.prepare(); err != nil {
+		return err
+	}
+
+	for i := range w.files {
+		// Everything before the last file has to be in place before the step is marked as done
+		if i == len(w.files)-1 {
+			if err := syncDirs(w.simulation, w.files[:i]); err != nil {
+				return fmt.Errorf("could not sync step: %w", err)
+			}
+		}
+		if err := w.move(i); err != nil {
+			return err
+		}
+	}
+	if err := syncDirs(w.simulation, w.files[len(w.files)-1:]); err != nil {
+		return fmt.Errorf("could not sync step: %w", err)
+	}
+
+	return os.RemoveAll(filepath.Join(w.simulation, pendingStepFolder))
+}
+
+// prepare keeps the files that are replaced by the step, and writes down which files are part of it.
+// Until prepare is done no file of the simulation has been changed.
+func (w *stepWriter) prepare() error {
+	for i, file := range w.files {
+		info, err := os.Stat(file.target(w.simulation))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return fmt.Errorf("could not stat %s: %w", file.Path, err)
+		}
+
+		if err := os.MkdirAll(filepath.Dir(file.old(w.simulation)), 0o755); err != nil {
+			return err
+		}
+		// A hard link keeps the old contents around without copying them, as the new file is renamed over
+		// the old one instead of written into it. Not every file system supports them though.
+		if err := os.Link(file.target(w.simulation), file.old(w.simulation)); err != nil {
+			if err := copyFile(file.target(w.simulation), file.old(w.simulation), info.Mode()); err != nil {
+				return fmt.Errorf("could not keep old %s: %w", file.Path, err)
+			}
+		}
+		w.files[i].Existed = true
+	}
+
+	data, err := json.Marshal(w.files)
+	if err != nil {
+		return fmt.Errorf("could not marshal step files: %w", err)
+	}
+	if err := writeFileSynced(manifestFile(w.simulation), data, 0o644); err != nil {
+		return fmt.Errorf("could not write step files: %w", err)
+	}
+
+	return syncDir(filepath.Join(w.simulation, pendingStepFolder))
+}
+
+// move moves file i of the step into place.
+func (w *stepWriter) move(i int) error {
+	file := w.files[i]
+	if err := os.MkdirAll(filepath.Dir(file.target(w.simulation)), 0o755); err != nil {
+		return err
+	}
+	if err := os.Rename(file.new(w.simulation), file.target(w.simulation)); err != nil {
+		return fmt.Errorf("could not move %s into place: %w", file.Path, err)
+	}
+
+	return nil
+}
+
+// recoverStep finishes or rolls back a step that was being saved when the process stopped, rolledBack reports whether
+// a step was rolled back. A step is finished only if its last file was moved into place, otherwise every file of the
+// step is restored to what it was before the step.
+func recoverStep(simulation string) (rolledBack bool, err error) {
+	pending := filepath.Join(simulation, pendingStepFolder)
+	if _, err := os.Stat(pending); errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	content, err := os.ReadFile(manifestFile(simulation))
+	if errors.Is(err, os.ErrNotExist) {
+		// The step was not prepared yet, so none of the files of the simulation were changed
+		return true, os.RemoveAll(pending)
+	} else if err != nil {
+		return false, fmt.Errorf("could not read step files: %w", err)
+	}
+
+	var files []pendingFile
+	if err := json.Unmarshal(content, &files); err != nil {
+		return false, fmt.Errorf("could not unmarshal step files: %w", err)
+	}
+
+	done := true
+	if len(files) > 0 {
+		if _, err := os.Stat(files[len(files)-1].new(simulation)); err == nil {
+			done = false
+		}
+	}
+
+	if !done {
+		// Only files that are still there are renamed or removed, so rolling back can safely be repeated
+		// when the process stops again whilst recovering
+		for _, file := range files {
+			if file.Existed {
+				if err := os.Rename(file.old(simulation), file.target(simulation)); err != nil && !errors.Is(err, os.ErrNotExist) {
+					return false, fmt.Errorf("could not restore %s: %w", file.Path, err)
+				}
+			} else if err := os.Remove(file.target(simulation)); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return false, fmt.Errorf("could not remove %s: %w", file.Path, err)
+			}
+		}
+		if err := syncDirs(simulation, files); err != nil {
+			return false, err
+		}
+	}
+
+	return !done, os.RemoveAll(pending)
+}
+
+// writeFileSynced writes data to path, creating its folder if needed, and waits for it to be written to disk.
+func writeFileSynced(path string, data []byte, perm os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// writeFileAtomic replaces the file at path with data, readers see either the old or the new file but never a partial one.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	if err := writeFileSynced(tmp, data, perm); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+
+	return syncDir(filepath.Dir(path))
+}
+
+// syncDir makes sure the files that were renamed into or out of dir stay that way after a crash.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	return d.Sync()
+}
+
+// syncDirs syncs the folders containing files.
+func syncDirs(simulation string, files []pendingFile) error {
+	synced := map[string]bool{}
+	for _, file := range files {
+		dir := filepath.Dir(file.target(simulation))
+		if synced[dir] {
+			continue
+		}
+		synced[dir] = true
+
+		if err := syncDir(dir); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+
+	return nil
+}
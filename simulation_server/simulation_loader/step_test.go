@@ -0,0 +1,86 @@
+package simulationloader
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeStep saves a step changing a.json, creating new.json and changing meta.json last. The save stops after moving
+// moves files into place, or before preparing the step when moves is negative.
+func writeStep(t *testing.T, simulation string, moves int) {
+	t.Helper()
+
+	w, err := beginStep(simulation)
+	if err != nil {
+		t.Fatalf("could not begin step: %v", err)
+	}
+	for _, name := range []string{"a.json", "new.json", "meta.json"} {
+		if err := w.writeJson(filepath.Join(simulation, name), "new"); err != nil {
+			t.Fatalf("could not write %s: %v", name, err)
+		}
+	}
+
+	if moves < 0 {
+		return
+	}
+	if err := w.prepare(); err != nil {
+		t.Fatalf("could not prepare step: %v", err)
+	}
+	for i := range moves {
+		if err := w.move(i); err != nil {
+			t.Fatalf("could not move file %d: %v", i, err)
+		}
+	}
+}
+
+func TestRecoverStep(t *testing.T) {
+	for _, tt := range []struct {
+		name       string
+		moves      int
+		rolledBack bool
+	}{
+		{name: "not prepared", moves: -1, rolledBack: true},
+		{name: "prepared", moves: 0, rolledBack: true},
+		{name: "partially moved", moves: 2, rolledBack: true},
+		{name: "moved", moves: 3, rolledBack: false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			simulation := t.TempDir()
+			for _, name := range []string{"a.json", "meta.json"} {
+				if err := os.WriteFile(filepath.Join(simulation, name), []byte(`"old"`), 0o644); err != nil {
+					t.Fatalf("could not write %s: %v", name, err)
+				}
+			}
+
+			writeStep(t, simulation, tt.moves)
+
+			rolledBack, err := recoverStep(simulation)
+			if err != nil {
+				t.Fatalf("could not recover step: %v", err)
+			}
+			if rolledBack != tt.rolledBack {
+				t.Fatalf("Wrong recovery, got rolled back: %v, want: %v", rolledBack, tt.rolledBack)
+			}
+
+			want := map[string]string{"a.json": `"old"`, "meta.json": `"old"`}
+			if !tt.rolledBack {
+				want = map[string]string{"a.json": `"new"`, "new.json": `"new"`, "meta.json": `"new"`}
+			}
+			for _, name := range []string{"a.json", "new.json", "meta.json"} {
+				content, err := os.ReadFile(filepath.Join(simulation, name))
+				if os.IsNotExist(err) && want[name] == "" {
+					continue
+				} else if err != nil {
+					t.Fatalf("could not read %s: %v", name, err)
+				}
+				if string(content) != want[name] {
+					t.Fatalf("Wrong contents of %s, got: %s, want: %s", name, content, want[name])
+				}
+			}
+			if _, err := os.Stat(filepath.Join(simulation, pendingStepFolder)); !os.IsNotExist(err) {
+				t.Fatalf("Expected the pending step to be removed")
+			}
+		})
+	}
+}
@@ -5,10 +5,14 @@ import (
 	"fmt"
 	"io"
 	"io/fs"
+	"maps"
 	"os"
 	"path"
 	"path/filepath"
+	"reflect"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/fvdveen/generative_agents/simulation_server/agent"
@@ -21,6 +25,7 @@ type MovementPersona struct {
 	Pronunciato string      `json:"pronunciato"`
 	Description string      `json:"description"`
 	Chat        []Utterance `json:"chat"`
+	Replanned   bool        `json:"replanned,omitempty"`
 }
 
 type MovementMeta struct {
@@ -40,6 +45,10 @@ type FileStorage struct {
 	Maze       string
 }
 
+func (fs FileStorage) simulationFolder() string {
+	return path.Join(fs.SimulationsFolder, fs.Simulation)
+}
+
 func (fs FileStorage) movementFolder() string {
 	return path.Join(fs.SimulationsFolder, fs.Simulation, "movement")
 }
@@ -56,6 +65,11 @@ func (fs FileStorage) personaFolder(name string) string {
 	return path.Join(fs.SimulationsFolder, fs.Simulation, "personas", name, "bootstrap_memory")
 }
 
+// diaryFile returns the file the diary entry of name about day is written to.
+func (fs FileStorage) diaryFile(name string, day time.Time) string {
+	return path.Join(fs.SimulationsFolder, fs.Simulation, "personas", name, "diary", day.Format(time.DateOnly)+".md")
+}
+
 func (fs *FileStorage) backupFolder(step int) string {
 	return path.Join(fs.BackupFolder, fs.Simulation, strconv.Itoa(step))
 }
@@ -65,6 +79,24 @@ func (fs *FileStorage) backupStateFolder() string {
 }
 
 func (fs *FileStorage) SaveMovements(step int, personaMovements map[string]server.PersonaMovement, currTime time.Time) error {
+	movements, env := newMovements(personaMovements, currTime, fs.Maze)
+
+	p := path.Join(fs.movementFolder(), fmt.Sprintf("%d.json", step))
+	if err := writeJson(p, movements); err != nil {
+		return fmt.Errorf("Could not save movement: %w", err)
+	}
+
+	p = path.Join(fs.environmentFolder(), fmt.Sprintf("%d.json", step+1))
+	if err := writeJson(p, env); err != nil {
+		return fmt.Errorf("Could not write save environment: %w", err)
+	}
+
+	return nil
+}
+
+// newMovements converts the movements of a step to the movement file read by the frontend,
+// and the environment file holding the positions of the personas at the start of the next step.
+func newMovements(personaMovements map[string]server.PersonaMovement, currTime time.Time, mazeName string) (Movements, Environment) {
 	movements := Movements{
 		Personas: map[string]MovementPersona{},
 		Meta: MovementMeta{
@@ -85,13 +117,14 @@ func (fs *FileStorage) SaveMovements(step int, personaMovements map[string]serve
 			Pronunciato: m.Pronunciato,
 			Description: m.Event.Description,
 			Chat:        chat,
+			Replanned:   m.Replanned,
 		}
 	}
 
 	personas := map[string]EnvironmentPersona{}
 	for n, m := range personaMovements {
 		personas[n] = EnvironmentPersona{
-			Maze: fs.Maze,
+			Maze: mazeName,
 			X:    m.Tile.X,
 			Y:    m.Tile.Y,
 		}
@@ -101,30 +134,42 @@ func (fs *FileStorage) SaveMovements(step int, personaMovements map[string]serve
 		Personas: personas,
 	}
 
-	p := path.Join(fs.movementFolder(), fmt.Sprintf("%d.json", step))
-	if err := writeJson(p, movements); err != nil {
-		return fmt.Errorf("Could not save movement: %w", err)
+	return movements, env
+}
+
+// SaveSimulation saves the personas and meta.json as a single step, if it fails or the process crashes whilst saving
+// the simulation is rolled back to the previous step when it is loaded.
+func (fs *FileStorage) SaveSimulation(srv *server.Server) error {
+	w, err := beginStep(fs.simulationFolder())
+	if err != nil {
+		return err
 	}
 
-	p = path.Join(fs.environmentFolder(), fmt.Sprintf("%d.json", step+1))
-	if err := writeJson(p, env); err != nil {
-		return fmt.Errorf("Could not write save environment: %w", err)
+	for n, p := range srv.Personas {
+		if err := fs.savePersona(w, p); err != nil {
+			return fmt.Errorf("could not save persona %s: %w", n, err)
+		}
+	}
+
+	// meta.json is written last, it marks the step as done
+	if err := w.writeJson(path.Join(fs.metaFolder(), "meta.json"), newSimulationMeta(srv)); err != nil {
+		return fmt.Errorf("could not save meta: %w", err)
+	}
+
+	if err := w.commit(); err != nil {
+		return fmt.Errorf("could not commit step: %w", err)
 	}
 
 	return nil
 }
 
-func (fs *FileStorage) SaveSimulation(srv *server.Server) error {
+func newSimulationMeta(srv *server.Server) SimulationMeta {
 	names := make([]string, 0, len(srv.Personas))
-	for n, p := range srv.Personas {
+	for n := range srv.Personas {
 		names = append(names, n)
-
-		if err := fs.SavePersona(p); err != nil {
-			return fmt.Errorf("could not save persona %s: %w", n, err)
-		}
 	}
 
-	meta := SimulationMeta{
+	return SimulationMeta{
 		ForkSimCode:    srv.ForkedSim,
 		StartDate:      StartDate(srv.StartTime),
 		CurrTime:       CurrentTime(srv.CurrentTime),
@@ -133,15 +178,17 @@ func (fs *FileStorage) SaveSimulation(srv *server.Server) error {
 		PersonaNames:   names,
 		Step:           srv.Step,
 	}
+}
 
-	if err := writeJson(path.Join(fs.metaFolder(), "meta.json"), meta); err != nil {
-		return fmt.Errorf("could not save meta: %w", err)
+func (fs *FileStorage) savePersonaState(w *stepWriter, p *agent.Persona) error {
+	if err := w.writeJson(path.Join(fs.personaFolder(p.Name()), "scratch.json"), newPersonaState(p)); err != nil {
+		return fmt.Errorf("could not save persona %s state: %w", p.Name(), err)
 	}
 
 	return nil
 }
 
-func (fs *FileStorage) savePersonaState(p *agent.Persona) error {
+func newPersonaState(p *agent.Persona) PersonaState {
 	state := p.State()
 
 	sched := make([]Plan, 0, len(state.DailySchedule))
@@ -186,6 +233,35 @@ func (fs *FileStorage) savePersonaState(p *agent.Persona) error {
 		})
 	}
 
+	var forgetting *ForgettingPolicy
+	if state.Forgetting != (memory.ForgettingPolicy{}) {
+		forgetting = &ForgettingPolicy{
+			Expire:              state.Forgetting.Expire,
+			DecayImportance:     state.Forgetting.DecayImportance,
+			DecayAfterHours:     int(state.Forgetting.DecayAfter.Hours()),
+			MergeIdleAfterHours: int(state.Forgetting.MergeIdleAfter.Hours()),
+			Archive:             state.Forgetting.Archive,
+		}
+	}
+
+	var reflection *ReflectionPolicy
+	if !reflect.ValueOf(state.Reflection).IsZero() {
+		reflection = &ReflectionPolicy{
+			FocalPoints:      state.Reflection.FocalPoints,
+			Insights:         state.Reflection.Insights,
+			Levels:           state.Reflection.Levels,
+			IgnoreImportance: state.Reflection.IgnoreImportance,
+			IdleAfterMinutes: int(state.Reflection.IdleAfter.Minutes()),
+		}
+		for _, t := range state.Reflection.Evidence {
+			reflection.Evidence = append(reflection.Evidence, NodeType(t))
+		}
+		if state.Reflection.Daily {
+			dailyAt := TimeOfDay(state.Reflection.DailyAt)
+			reflection.DailyAt = &dailyAt
+		}
+	}
+
 	scratch := PersonaState{
 		VisionR:                 state.VisionRadius,
 		AttBandwidth:            state.AttentionBandwidth,
@@ -238,16 +314,29 @@ func (fs *FileStorage) savePersonaState(p *agent.Persona) error {
 		ChattingEndTime:    (*CurrentTime)(chatEndTime),
 		ActPathSet:         state.ActivityPathSet,
 		PlannedPath:        plannedPath,
+		Forgetting:         forgetting,
+		Reflection:         reflection,
+	}
+	if !state.LastDailyReflection.IsZero() {
+		scratch.LastDailyReflection = (*CurrentTime)(&state.LastDailyReflection)
+	}
+	for _, entry := range state.Diary {
+		scratch.Diary = append(scratch.Diary, newDiaryEntry(entry))
 	}
 
-	if err := writeJson(path.Join(fs.personaFolder(p.Name()), "scratch.json"), scratch); err != nil {
-		return fmt.Errorf("could not save persona %s state: %w", p.Name(), err)
+	return scratch
+}
+
+func (fs *FileStorage) saveSpatialMemory(w *stepWriter, name string, store *memory.Spatial) error {
+	if err := w.writeJson(path.Join(fs.personaFolder(name), "spatial_memory.json"), newSpatialMemoryTree(store)); err != nil {
+		return fmt.Errorf("could not save persona %s spatial memory: %w", name, err)
 	}
 
 	return nil
 }
 
-func (fs *FileStorage) saveSpatialMemory(name string, store *memory.Spatial) error {
+// newSpatialMemoryTree converts spatial memory to the world -> sector -> arena -> objects tree it is saved as.
+func newSpatialMemoryTree(store *memory.Spatial) map[string]map[string]map[string][]string {
 	mem := map[string]map[string]map[string][]string{}
 
 	for world, sectors := range store.Worlds() {
@@ -255,27 +344,25 @@ func (fs *FileStorage) saveSpatialMemory(name string, store *memory.Spatial) err
 		for sector, arenas := range sectors {
 			mem[world][sector] = make(map[string][]string)
 			for arena, objects := range arenas {
-				mem[world][sector][arena] = make([]string, 0, len(objects))
-				for obj := range objects {
-					mem[world][sector][arena] = append(mem[world][sector][arena], obj)
-				}
+				// Sorted so saving the same memory always writes the same file
+				mem[world][sector][arena] = slices.Sorted(maps.Keys(objects))
 			}
 		}
 	}
 
-	if err := writeJson(path.Join(fs.personaFolder(name), "spatial_memory.json"), mem); err != nil {
-		return fmt.Errorf("could not save persona %s spatial memory: %w", name, err)
-	}
-
-	return nil
+	return mem
 }
 
-func (fs *FileStorage) saveAssociativeMemory(name string, store *memory.Associative) error {
-	if err := writeJson(path.Join(fs.personaFolder(name), "associative_memory", "embeddings.json"), store.Embeddings()); err != nil {
+func (fs *FileStorage) saveAssociativeMemory(w *stepWriter, name string, store *memory.Associative) error {
+	if err := w.writeJson(path.Join(fs.personaFolder(name), "associative_memory", "embeddings.json"), store.Embeddings()); err != nil {
 		return fmt.Errorf("could not save persona %s associative embeddings: %w", name, err)
 	}
 
-	if err := writeJson(path.Join(fs.personaFolder(name), "associative_memory", "kw_strength.json"), KwStength{
+	if err := w.writeJson(path.Join(fs.personaFolder(name), "associative_memory", "embeddings_index.json"), store.Index()); err != nil {
+		return fmt.Errorf("could not save persona %s associative embeddings index: %w", name, err)
+	}
+
+	if err := w.writeJson(path.Join(fs.personaFolder(name), "associative_memory", "kw_strength.json"), KwStength{
 		Thoughts: store.ThoughtKeywordStrength(),
 		Events:   store.EventKeywordStrength(),
 	}); err != nil {
@@ -284,68 +371,136 @@ func (fs *FileStorage) saveAssociativeMemory(name string, store *memory.Associat
 
 	nodes := map[string]MemoryNode{}
 	for _, node := range store.Nodes() {
-		var filling []any
-		switch node.Type {
-		case memory.NodeTypeChat:
-			for _, utt := range node.Chat {
-				filling = append(filling, Utterance{
-					Speaker:   utt.Speaker,
-					Utterance: utt.Sentence,
-				})
-			}
-		case memory.NodeTypeEvent, memory.NodeTypeThought:
-			for _, id := range node.Evidence {
-				filling = append(filling, fmt.Sprintf("node_%d", id))
-			}
-		default:
-			panic(fmt.Sprintf("unexpected memory.NodeType: %#v", node.Type))
-		}
-
-		nodes[fmt.Sprintf("node_%d", node.Id)] = MemoryNode{
-			NodeCount:           node.NodeCount,
-			TypeCount:           node.TypeCount,
-			Type:                node.Type.ToString(),
-			Depth:               node.Depth,
-			Created:             MemoryTime(node.Created),
-			Expiration:          (*MemoryTime)(node.Expiration),
-			Subject:             node.Subject,
-			Predicate:           node.Predicate,
-			Object:              node.Object,
-			Description:         node.Description,
-			OriginalDescription: node.OriginalDescription,
-			EmbeddingKey:        node.EmbeddingKey,
-			Poignancy:           node.Importance,
-			Valence:             node.Valence,
-			Keywords:            node.Keywords,
-			Filling:             filling,
-		}
+		nodes[fmt.Sprintf("node_%d", node.Id)] = newMemoryNode(node)
 	}
 
-	if err := writeJson(path.Join(fs.personaFolder(name), "associative_memory", "nodes.json"), nodes); err != nil {
-		return fmt.Errorf("could not save persona %s associative nodes: %v", err)
+	if err := w.writeJson(path.Join(fs.personaFolder(name), "associative_memory", "nodes.json"), nodes); err != nil {
+		return fmt.Errorf("could not save persona %s associative nodes: %w", name, err)
 	}
 
 	return nil
 }
 
+func newMemoryNode(node memory.ConceptNode) MemoryNode {
+	var filling []any
+	switch node.Type {
+	case memory.NodeTypeChat:
+		for _, utt := range node.Chat {
+			filling = append(filling, Utterance{
+				Speaker:   utt.Speaker,
+				Utterance: utt.Sentence,
+			})
+		}
+	case memory.NodeTypeEvent, memory.NodeTypeThought:
+		for _, id := range node.Evidence {
+			filling = append(filling, fmt.Sprintf("node_%d", id))
+		}
+	default:
+		panic(fmt.Sprintf("unexpected memory.NodeType: %#v", node.Type))
+	}
+
+	return MemoryNode{
+		NodeCount:           node.NodeCount,
+		TypeCount:           node.TypeCount,
+		Type:                node.Type.ToString(),
+		Depth:               node.Depth,
+		Created:             MemoryTime(node.Created),
+		Expiration:          (*MemoryTime)(node.Expiration),
+		LastAccessed:        (*MemoryTime)(&node.LastAccessed),
+		Subject:             node.Subject,
+		Predicate:           node.Predicate,
+		Object:              node.Object,
+		Description:         node.Description,
+		OriginalDescription: node.OriginalDescription,
+		EmbeddingKey:        node.EmbeddingKey,
+		Poignancy:           node.Importance,
+		Valence:             node.Valence,
+		Keywords:            node.Keywords,
+		Filling:             filling,
+		Merged:              node.Merged,
+		Forgotten:           node.Forgotten,
+	}
+}
+
+// SavePersona saves persona p on its own, either all files of the persona are saved or none of them are.
 func (fs *FileStorage) SavePersona(p *agent.Persona) error {
-	if err := fs.savePersonaState(p); err != nil {
+	w, err := beginStep(fs.simulationFolder())
+	if err != nil {
+		return err
+	}
+
+	if err := fs.savePersona(w, p); err != nil {
+		return err
+	}
+
+	return w.commit()
+}
+
+func (fs *FileStorage) savePersona(w *stepWriter, p *agent.Persona) error {
+	if err := fs.savePersonaState(w, p); err != nil {
 		return err
 	}
 
 	assoc, spatial := p.Memory()
 
-	if err := fs.saveSpatialMemory(p.Name(), spatial); err != nil {
+	if err := fs.saveSpatialMemory(w, p.Name(), spatial); err != nil {
 		return err
 	}
 
-	if err := fs.saveAssociativeMemory(p.Name(), assoc); err != nil {
+	if err := fs.saveAssociativeMemory(w, p.Name(), assoc); err != nil {
 		return err
 	}
 
+	if err := fs.saveDiary(w, p); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// saveDiary writes the latest diary entry of p to diary/<day>.md in the folder of the persona. Entries do not change
+// once written, and the persona only keeps its latest entries, so the files are the whole diary.
+func (fs *FileStorage) saveDiary(w *stepWriter, p *agent.Persona) error {
+	diary := p.State().Diary
+	if len(diary) == 0 {
+		return nil
+	}
+	entry := diary[len(diary)-1]
+
+	file := fs.diaryFile(p.Name(), entry.Day)
+	if _, err := os.Stat(file); err == nil {
+		return nil
+	}
+	if err := w.writeFile(file, []byte(diaryMarkdown(p.Name(), entry))); err != nil {
+		return fmt.Errorf("could not save diary of %s: %w", p.Name(), err)
+	}
+
 	return nil
 }
 
+func newDiaryEntry(entry agent.DiaryEntry) DiaryEntry {
+	return DiaryEntry{
+		Day:      StartDate(entry.Day),
+		Events:   entry.Events,
+		Feelings: entry.Feelings,
+		Wants:    entry.Wants,
+	}
+}
+
+func diaryMarkdown(name string, entry agent.DiaryEntry) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# %s, %s\n\n", name, entry.Day.Format("Monday January 02, 2006"))
+	b.WriteString("## Memorable events\n\n")
+	for _, event := range entry.Events {
+		fmt.Fprintf(&b, "- %s\n", event)
+	}
+	fmt.Fprintf(&b, "\n## How the day felt\n\n%s\n", entry.Feelings)
+	fmt.Fprintf(&b, "\n## Wishes for tomorrow\n\n%s\n", entry.Wants)
+
+	return b.String()
+}
+
 func writeJson(path string, v any) error {
 	data, err := json.MarshalIndent(v, "", "  ")
 	if err != nil {
@@ -366,8 +521,8 @@ func writeFileWithDirs(path string, data []byte, perm os.FileMode) error {
 		return err
 	}
 
-	// Write the file
-	return os.WriteFile(path, data, perm)
+	// The frontend reads the files whilst the simulation runs, so it must never see a partially written file
+	return writeFileAtomic(path, data, perm)
 }
 
 func (fs *FileStorage) Backup(step int) error {
@@ -512,3 +667,199 @@ func (fs *FileStorage) backupLatestStateFile(src, sharedDir, snapshotDst string)
 
 	return copyFile(sharedDst, snapshotDst, srcInfo.Mode())
 }
+
+// readBackupMeta reads the meta file of the backup at step, which also checks whether there is a backup at all.
+func (fs *FileStorage) readBackupMeta(step int) (SimulationMeta, error) {
+	var meta SimulationMeta
+	content, err := os.ReadFile(path.Join(fs.backupFolder(step), "reverie", "meta.json"))
+	if err != nil {
+		return meta, fmt.Errorf("could not read backup of step %d: %w", step, err)
+	}
+	if err := json.Unmarshal(content, &meta); err != nil {
+		return meta, fmt.Errorf("could not unmarshal backup meta file: %w", err)
+	}
+
+	return meta, nil
+}
+
+// Fork creates the simulation name from the backup of this simulation at step, the fork continues from that step
+// and records this simulation as the one it was forked from.
+func (fs *FileStorage) Fork(step int, name string) error {
+	if !filepath.IsLocal(name) || filepath.Base(name) != name {
+		return fmt.Errorf("invalid simulation name %q", name)
+	}
+
+	meta, err := fs.readBackupMeta(step)
+	if err != nil {
+		return err
+	}
+
+	forkFolder := path.Join(fs.SimulationsFolder, name)
+	if _, err := os.Stat(forkFolder); err == nil {
+		return fmt.Errorf("simulation %s already exists", name)
+	}
+
+	if err := fs.fork(step, meta, forkFolder); err != nil {
+		_ = os.RemoveAll(forkFolder)
+		return err
+	}
+
+	return nil
+}
+
+func (fs *FileStorage) fork(step int, meta SimulationMeta, forkFolder string) error {
+	backup := fs.backupFolder(step)
+
+	if err := copyDirFilesOnly(path.Join(backup, "personas"), path.Join(forkFolder, "personas")); err != nil {
+		return fmt.Errorf("could not copy personas: %w", err)
+	}
+
+	// The history before the fork is shared, so the frontend can replay the fork from the start
+	if err := copySteps(fs.movementFolder(), path.Join(forkFolder, "movement"), step-1); err != nil {
+		return fmt.Errorf("could not copy movements: %w", err)
+	}
+	if err := copySteps(fs.environmentFolder(), path.Join(forkFolder, "environment"), step-1); err != nil {
+		return fmt.Errorf("could not copy environments: %w", err)
+	}
+	env := fmt.Sprintf("%d.json", step)
+	if err := copyFile(path.Join(backup, "environment", env), path.Join(forkFolder, "environment", env), 0o644); err != nil {
+		return fmt.Errorf("could not copy environment of step %d: %w", step, err)
+	}
+
+	meta.ForkSimCode = fs.Simulation
+	if err := writeJson(path.Join(forkFolder, "reverie", "meta.json"), meta); err != nil {
+		return fmt.Errorf("could not save meta: %w", err)
+	}
+
+	return nil
+}
+
+// Rewind restores this simulation to the backup at step, everything that happened after that step is removed.
+func (fs *FileStorage) Rewind(step int) error {
+	if _, err := fs.readBackupMeta(step); err != nil {
+		return err
+	}
+
+	backup := fs.backupFolder(step)
+	simulation := fs.simulationFolder()
+
+	w, err := beginStep(simulation)
+	if err != nil {
+		return err
+	}
+
+	restored := map[string]bool{}
+	err = filepath.WalkDir(path.Join(backup, "personas"), func(p string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		rel, err := filepath.Rel(backup, p)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+
+		restored[filepath.Join(simulation, rel)] = true
+		return w.writeFile(filepath.Join(simulation, rel), data)
+	})
+	if err != nil {
+		return fmt.Errorf("could not restore personas: %w", err)
+	}
+
+	for _, file := range []string{path.Join("environment", fmt.Sprintf("%d.json", step)), path.Join("reverie", "meta.json")} {
+		data, err := os.ReadFile(path.Join(backup, file))
+		if err != nil {
+			return fmt.Errorf("could not read backup of %s: %w", file, err)
+		}
+		if err := w.writeFile(path.Join(simulation, file), data); err != nil {
+			return fmt.Errorf("could not restore %s: %w", file, err)
+		}
+	}
+
+	if err := w.commit(); err != nil {
+		return fmt.Errorf("could not commit rewind: %w", err)
+	}
+
+	// Files that were created after the backup are no longer part of the simulation
+	err = filepath.WalkDir(path.Join(simulation, "personas"), func(p string, d os.DirEntry, err error) error {
+		if err != nil || d.IsDir() || restored[filepath.Clean(p)] {
+			return err
+		}
+		return os.Remove(p)
+	})
+	if err != nil {
+		return fmt.Errorf("could not remove persona files created after step %d: %w", step, err)
+	}
+	if err := removeSteps(fs.movementFolder(), step); err != nil {
+		return fmt.Errorf("could not remove movements after step %d: %w", step, err)
+	}
+	if err := removeSteps(fs.environmentFolder(), step+1); err != nil {
+		return fmt.Errorf("could not remove environments after step %d: %w", step, err)
+	}
+
+	return nil
+}
+
+// steps returns the step of every <step>.json file in folder.
+func steps(folder string) ([]int, error) {
+	entries, err := os.ReadDir(folder)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	steps := []int{}
+	for _, e := range entries {
+		step, err := strconv.Atoi(strings.TrimSuffix(e.Name(), ".json"))
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") || err != nil {
+			continue
+		}
+		steps = append(steps, step)
+	}
+
+	return steps, nil
+}
+
+// copySteps copies the files of steps up to and including last from src to dst.
+func copySteps(src, dst string, last int) error {
+	steps, err := steps(src)
+	if err != nil {
+		return err
+	}
+
+	for _, step := range steps {
+		if step > last {
+			continue
+		}
+		file := fmt.Sprintf("%d.json", step)
+		if err := copyFile(path.Join(src, file), path.Join(dst, file), 0o644); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// removeSteps removes the files of steps from first onwards from folder.
+func removeSteps(folder string, first int) error {
+	steps, err := steps(folder)
+	if err != nil {
+		return err
+	}
+
+	for _, step := range steps {
+		if step < first {
+			continue
+		}
+		if err := os.Remove(path.Join(folder, fmt.Sprintf("%d.json", step))); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -2,8 +2,11 @@ package simulationloader
 
 import (
 	"encoding/json"
+	"fmt"
 	"strings"
 	"time"
+
+	"github.com/fvdveen/generative_agents/simulation_server/memory"
 )
 
 type MazeMetaInfo struct {
@@ -156,6 +159,9 @@ type MemoryNode struct {
 	Valence             int         `json:"valence"`
 	Keywords            []string    `json:"keywords"`
 	Filling             interface{} `json:"filling"`
+	LastAccessed        *MemoryTime `json:"last_accessed,omitempty"`
+	Merged              int         `json:"merged,omitempty"`
+	Forgotten           bool        `json:"forgotten,omitempty"`
 }
 
 type PersonaState struct {
@@ -209,6 +215,89 @@ type PersonaState struct {
 	ChattingEndTime         *CurrentTime   `json:"chatting_end_time"`
 	ActPathSet              bool           `json:"act_path_set"`
 	PlannedPath             []Position     `json:"planned_path"`
+	// Not part of the original simulation files, the persona forgets nothing when it is missing
+	Forgetting *ForgettingPolicy `json:"forgetting,omitempty"`
+	// Not part of the original simulation files, the persona reflects like in the original code when it is missing
+	Reflection          *ReflectionPolicy `json:"reflection,omitempty"`
+	LastDailyReflection *CurrentTime      `json:"last_daily_reflection,omitempty"`
+	Diary               []DiaryEntry      `json:"diary,omitempty"`
+}
+
+type ForgettingPolicy struct {
+	Expire              bool `json:"expire"`
+	DecayImportance     int  `json:"decay_importance"`
+	DecayAfterHours     int  `json:"decay_after_hours"`
+	MergeIdleAfterHours int  `json:"merge_idle_after_hours"`
+	Archive             bool `json:"archive"`
+}
+
+type ReflectionPolicy struct {
+	Evidence         []NodeType `json:"evidence,omitempty"`
+	FocalPoints      int        `json:"focal_points,omitempty"`
+	Insights         int        `json:"insights,omitempty"`
+	Levels           int        `json:"levels,omitempty"`
+	IgnoreImportance bool       `json:"ignore_importance,omitempty"`
+	// The persona does not reflect daily when it is missing
+	DailyAt          *TimeOfDay `json:"daily_at,omitempty"`
+	IdleAfterMinutes int        `json:"idle_after_minutes,omitempty"`
+}
+
+type DiaryEntry struct {
+	Day      StartDate `json:"day"`
+	Events   []string  `json:"events"`
+	Feelings string    `json:"feelings"`
+	Wants    string    `json:"wants"`
+}
+
+// NodeType is the type of a memory, "event", "thought" or "chat".
+type NodeType memory.NodeType
+
+func (t NodeType) MarshalJSON() ([]byte, error) {
+	return json.Marshal(memory.NodeType(t).ToString())
+}
+
+func (t *NodeType) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+
+	switch s {
+	case "event":
+		*t = NodeType(memory.NodeTypeEvent)
+	case "thought":
+		*t = NodeType(memory.NodeTypeThought)
+	case "chat":
+		*t = NodeType(memory.NodeTypeChat)
+	default:
+		return fmt.Errorf("unknown memory type %q, expected \"event\", \"thought\" or \"chat\"", s)
+	}
+	return nil
+}
+
+// TimeOfDay is the time since midnight, written like "23:30".
+type TimeOfDay time.Duration
+
+const TimeOfDayFormat = "15:04"
+
+func (t TimeOfDay) MarshalJSON() ([]byte, error) {
+	d := time.Duration(t)
+	return json.Marshal(fmt.Sprintf("%02d:%02d", int(d.Hours()), int(d.Minutes())%60))
+}
+
+func (t *TimeOfDay) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+
+	d, err := time.Parse(TimeOfDayFormat, s)
+	if err != nil {
+		return err
+	}
+
+	*t = TimeOfDay(time.Duration(d.Hour())*time.Hour + time.Duration(d.Minute())*time.Minute)
+	return nil
 }
 
 type Plan struct {
@@ -0,0 +1,109 @@
+// Package tracing exports OpenTelemetry spans of the steps of a simulation, the phases of its personas and the calls
+// they make to language models, so the time of a step can be broken down.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+const name = "github.com/fvdveen/generative_agents/simulation_server"
+
+// Provider exports the spans of the tracers it creates.
+type Provider struct {
+	provider *sdktrace.TracerProvider
+	// Closed after the spans are flushed, nil when writing to stdout
+	file *os.File
+}
+
+// Open creates a Provider exporting spans with exporter, either "stdout" to write them to stdout or "file" to append
+// them to file. The spans are written as JSON, one per line, so they can be read without a collector.
+// The resource of the spans names simulation and run, to tell simulations running side by side apart.
+func Open(exporter string, file string, simulation string, run string) (*Provider, error) {
+	p := &Provider{}
+
+	var w io.Writer
+	switch exporter {
+	case "stdout":
+		w = os.Stdout
+	case "file":
+		f, err := os.OpenFile(file, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("could not open trace file: %w", err)
+		}
+		p.file, w = f, f
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q, expected \"stdout\" or \"file\"", exporter)
+	}
+
+	exp, err := stdouttrace.New(stdouttrace.WithWriter(w))
+	if err != nil {
+		if p.file != nil {
+			_ = p.file.Close()
+		}
+		return nil, fmt.Errorf("could not create trace exporter: %w", err)
+	}
+
+	p.provider = sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exp),
+		sdktrace.WithResource(resource.NewSchemaless(
+			attribute.String("service.name", "generative_agents"),
+			attribute.String("simulation.name", simulation),
+			attribute.String("simulation.run_id", run),
+		)),
+	)
+
+	return p, nil
+}
+
+func (p *Provider) Tracer() trace.Tracer {
+	return p.provider.Tracer(name)
+}
+
+// Close exports the spans that have not been exported yet.
+func (p *Provider) Close(ctx context.Context) error {
+	err := p.provider.Shutdown(ctx)
+	if p.file != nil {
+		err = errors.Join(err, p.file.Close())
+	}
+	return err
+}
+
+// Nop returns a tracer that records nothing.
+func Nop() trace.Tracer {
+	return noop.NewTracerProvider().Tracer(name)
+}
+
+// End ends span, marking it as failed when err is not nil.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// LogAttrs returns the ids of the span in ctx as attributes for a log record, so the record can be found in the trace.
+// It returns nil when ctx holds no span that is recorded.
+func LogAttrs(ctx context.Context) []any {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return nil
+	}
+	return []any{
+		slog.String("trace_id", sc.TraceID().String()),
+		slog.String("span_id", sc.SpanID().String()),
+	}
+}